@@ -58,6 +58,10 @@ Logging Options:
 JetStream Options:
     -js, --jetstream                 Enable JetStream functionality
     -sd, --store_dir <dir>           Set the storage directory
+        --store_inspect <dir>        Inspect a storage directory offline and exit
+        --store_repair               Rebuild indexes and truncate torn tails when inspecting
+        --store_export <acc>/<str>   Export a stream to a snapshot when inspecting
+        --store_export_file <file>   Snapshot file for the export (default: <stream>.tar.s2)
 
 Authorization Options:
         --user <user>                User required for connections
@@ -112,6 +116,11 @@ func main() {
 	} else if opts.CheckConfig {
 		fmt.Fprintf(os.Stderr, "%s: configuration file %s is valid (%s)\n", exe, opts.ConfigFile, opts.ConfigDigest())
 		os.Exit(0)
+	} else if opts.StoreInspect != "" {
+		if err := server.InspectStore(opts, os.Stdout); err != nil {
+			server.PrintAndDie(fmt.Sprintf("%s: %s", exe, err))
+		}
+		os.Exit(0)
 	}
 
 	// Create the server with appropriate options.
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"regexp"
@@ -64,6 +65,10 @@ type ConsumerInfo struct {
 	PushBound      bool            `json:"push_bound,omitempty"`
 	Paused         bool            `json:"paused,omitempty"`
 	PauseRemaining time.Duration   `json:"pause_remaining,omitempty"`
+	// Complete is set once a bounded consumer delivered and got acks for all messages up to its stop.
+	Complete bool `json:"complete,omitempty"`
+	// Latency holds delivery and ack histograms when LatencyStats is set in the config.
+	Latency *ConsumerLatencyStats `json:"latency,omitempty"`
 	// TimeStamp indicates when the info was gathered
 	TimeStamp      time.Time            `json:"ts"`
 	PriorityGroups []PriorityGroupState `json:"priority_groups,omitempty"`
@@ -73,6 +78,8 @@ type PriorityGroupState struct {
 	Group          string    `json:"group"`
 	PinnedClientID string    `json:"pinned_client_id,omitempty"`
 	PinnedTS       time.Time `json:"pinned_ts,omitempty"`
+	// Partitions assigned to each member, for the partitioned priority policy.
+	Partitions map[string][]int `json:"partitions,omitempty"`
 }
 
 type ConsumerConfig struct {
@@ -82,6 +89,8 @@ type ConsumerConfig struct {
 	DeliverPolicy   DeliverPolicy   `json:"deliver_policy"`
 	OptStartSeq     uint64          `json:"opt_start_seq,omitempty"`
 	OptStartTime    *time.Time      `json:"opt_start_time,omitempty"`
+	OptStopSeq      uint64          `json:"opt_stop_seq,omitempty"`
+	OptStopTime     *time.Time      `json:"opt_stop_time,omitempty"`
 	AckPolicy       AckPolicy       `json:"ack_policy"`
 	AckWait         time.Duration   `json:"ack_wait,omitempty"`
 	MaxDeliver      int             `json:"max_deliver,omitempty"`
@@ -89,12 +98,16 @@ type ConsumerConfig struct {
 	FilterSubject   string          `json:"filter_subject,omitempty"`
 	FilterSubjects  []string        `json:"filter_subjects,omitempty"`
 	ReplayPolicy    ReplayPolicy    `json:"replay_policy"`
-	RateLimit       uint64          `json:"rate_limit_bps,omitempty"` // Bits per sec
+	ReplaySpeed     float64         `json:"replay_speed,omitempty"`     // Factor applied to original timing, 2 is twice as fast
+	RateLimit       uint64          `json:"rate_limit_bps,omitempty"`   // Bits per sec
+	RateLimitMsgs   uint64          `json:"rate_limit_msgs,omitempty"`  // Messages per sec, push and pull
+	RateLimitBytes  uint64          `json:"rate_limit_bytes,omitempty"` // Bytes per sec, push and pull
 	SampleFrequency string          `json:"sample_freq,omitempty"`
 	MaxWaiting      int             `json:"max_waiting,omitempty"`
 	MaxAckPending   int             `json:"max_ack_pending,omitempty"`
 	FlowControl     bool            `json:"flow_control,omitempty"`
 	HeadersOnly     bool            `json:"headers_only,omitempty"`
+	LatencyStats    bool            `json:"latency_stats,omitempty"` // Track delivery and ack latency histograms
 
 	// Pull based options.
 	MaxRequestBatch    int           `json:"max_batch,omitempty"`
@@ -127,6 +140,42 @@ type ConsumerConfig struct {
 	PriorityGroups []string       `json:"priority_groups,omitempty"`
 	PriorityPolicy PriorityPolicy `json:"priority_policy,omitempty"`
 	PinnedTTL      time.Duration  `json:"priority_timeout,omitempty"`
+
+	// Partitions is the number of partitions messages are split into for the partitioned priority policy.
+	// Partitions are assigned to the members of the priority group, and rebalanced as members come and go.
+	Partitions int `json:"partitions,omitempty"`
+	// PartitionWildcards are the 1-based positions of the filter subject wildcards that are hashed
+	// by the partition() subject transform function to select the partition of a message.
+	PartitionWildcards []int `json:"partition_wildcards,omitempty"`
+
+	// HeaderFilters only deliver messages whose headers match all of the filters,
+	// in addition to matching the filter subjects.
+	HeaderFilters []HeaderFilter `json:"header_filters,omitempty"`
+
+	// DeadLetterSubject is where messages are republished to once they exceed MaxDeliver or
+	// are terminated, after which they count as acknowledged. Binding the subject to another
+	// stream turns that stream into a dead-letter stream.
+	DeadLetterSubject string `json:"dead_letter_subject,omitempty"`
+
+	// OrderingKey enables key-ordered delivery. At most one unacknowledged message per key is
+	// outstanding, while messages with different keys are delivered in parallel.
+	OrderingKey *ConsumerOrderingKey `json:"ordering_key,omitempty"`
+}
+
+// HeaderFilter matches messages on a header. Without values the header only has to be present,
+// otherwise its value has to equal one of the values.
+type HeaderFilter struct {
+	Header string   `json:"header"`
+	Values []string `json:"values,omitempty"`
+}
+
+// ConsumerOrderingKey determines the key of a message for key-ordered delivery, taken from
+// either a subject token or a header. Messages without a key are delivered without ordering.
+type ConsumerOrderingKey struct {
+	// SubjectToken is the 1-based position of the subject token holding the key.
+	SubjectToken int `json:"subject_token,omitempty"`
+	// Header is the name of the header holding the key.
+	Header string `json:"header,omitempty"`
 }
 
 // SequenceInfo has both the consumer and the stream sequence and last activity.
@@ -207,6 +256,31 @@ type ConsumerNakOptions struct {
 	Delay time.Duration `json:"delay"`
 }
 
+// ConsumerPendingAck describes a message delivered by a consumer that is still awaiting an ack.
+type ConsumerPendingAck struct {
+	Sequence uint64 `json:"stream_seq"`
+	// ConsumerSeq is not set for messages held back for their ordering key, which are delivered out of order.
+	ConsumerSeq  uint64    `json:"consumer_seq"`
+	NumDelivered uint64    `json:"num_delivered"`
+	Delivered    time.Time `json:"last_delivered"`
+	// DeliverSubject is where the message was last delivered to, the pull request's reply subject for pull consumers.
+	DeliverSubject string `json:"deliver_subject,omitempty"`
+	// Held is set when the message is parked for its partition and has not been delivered yet.
+	Held bool `json:"held,omitempty"`
+}
+
+// ConsumerPendingAction is an administrative action taken on a pending message.
+type ConsumerPendingAction string
+
+const (
+	// ConsumerPendingRedeliver redelivers the message right away.
+	ConsumerPendingRedeliver ConsumerPendingAction = "redeliver"
+	// ConsumerPendingSkip acks the message on behalf of the client.
+	ConsumerPendingSkip ConsumerPendingAction = "skip"
+	// ConsumerPendingTerm terminates the message on behalf of the client.
+	ConsumerPendingTerm ConsumerPendingAction = "term"
+)
+
 // PriorityPolicy determines policy for selecting messages based on priority.
 type PriorityPolicy int
 
@@ -217,18 +291,22 @@ const (
 	PriorityOverflow
 	// Single client takes over handling of the messages, while others are on standby.
 	PriorityPinnedClient
+	// Messages are split into partitions, each handled by a single member of the group.
+	PriorityPartitioned
 )
 
 const (
 	PriorityNoneJSONString         = `"none"`
 	PriorityOverflowJSONString     = `"overflow"`
 	PriorityPinnedClientJSONString = `"pinned_client"`
+	PriorityPartitionedJSONString  = `"partitioned"`
 )
 
 var (
 	PriorityNoneJSONBytes         = []byte(PriorityNoneJSONString)
 	PriorityOverflowJSONBytes     = []byte(PriorityOverflowJSONString)
 	PriorityPinnedClientJSONBytes = []byte(PriorityPinnedClientJSONString)
+	PriorityPartitionedJSONBytes  = []byte(PriorityPartitionedJSONString)
 )
 
 func (pp PriorityPolicy) String() string {
@@ -237,6 +315,8 @@ func (pp PriorityPolicy) String() string {
 		return PriorityOverflowJSONString
 	case PriorityPinnedClient:
 		return PriorityPinnedClientJSONString
+	case PriorityPartitioned:
+		return PriorityPartitionedJSONString
 	default:
 		return PriorityNoneJSONString
 	}
@@ -248,6 +328,8 @@ func (pp PriorityPolicy) MarshalJSON() ([]byte, error) {
 		return PriorityOverflowJSONBytes, nil
 	case PriorityPinnedClient:
 		return PriorityPinnedClientJSONBytes, nil
+	case PriorityPartitioned:
+		return PriorityPartitionedJSONBytes, nil
 	case PriorityNone:
 		return PriorityNoneJSONBytes, nil
 	default:
@@ -261,6 +343,8 @@ func (pp *PriorityPolicy) UnmarshalJSON(data []byte) error {
 		*pp = PriorityOverflow
 	case PriorityPinnedClientJSONString:
 		*pp = PriorityPinnedClient
+	case PriorityPartitionedJSONString:
+		*pp = PriorityPartitioned
 	case PriorityNoneJSONString:
 		*pp = PriorityNone
 	default:
@@ -402,19 +486,28 @@ type consumer struct {
 	sid               int
 	name              string
 	stream            string
-	sseq              uint64         // next stream sequence
-	subjf             subjectFilters // subject filters and their sequences
-	filters           *Sublist       // When we have multiple filters we will use LoadNextMsgMulti and pass this in.
-	dseq              uint64         // delivered consumer sequence
-	adflr             uint64         // ack delivery floor
-	asflr             uint64         // ack store floor
-	chkflr            uint64         // our check floor, interest streams only.
-	npc               int64          // Num Pending Count
-	npf               uint64         // Num Pending Floor Sequence
+	sseq              uint64          // next stream sequence
+	subjf             subjectFilters  // subject filters and their sequences
+	filters           *Sublist        // When we have multiple filters we will use LoadNextMsgMulti and pass this in.
+	dseq              uint64          // delivered consumer sequence
+	dmsgs             uint64          // messages delivered since created, to weigh our leader when balancing
+	dbytes            uint64          // bytes delivered since created
+	adflr             uint64          // ack delivery floor
+	asflr             uint64          // ack store floor
+	chkflr            uint64          // our check floor, interest streams only.
+	rsdflr            uint64          // delivery floor of the last reset, acks at or below are ignored.
+	npc               int64           // Num Pending Count
+	npf               uint64          // Num Pending Floor Sequence
+	npcstale          bool            // Num Pending needs recalculating, stop time only
+	hfpend            avl.SequenceSet // Pending sequences matching our header filters, up to npf.
 	dsubj             string
 	qgroup            string
 	lss               *lastSeqSkipList
 	rlimit            *rate.Limiter
+	mrlimit           *rate.Limiter  // messages per second, shared by all requesters
+	brlimit           *rate.Limiter  // bytes per second, shared by all requesters
+	rlthrottled       time.Time      // throttled by mrlimit or brlimit until
+	lstats            *consumerStats // latency histograms, leader only
 	reqSub            *subscription
 	ackSub            *subscription
 	ackReplyT         string
@@ -428,13 +521,31 @@ type consumer struct {
 	fcsz              int
 	fcid              string
 	fcSub             *subscription
+	dlSub             *subscription                 // dead-letter publish acknowledgements
+	dlq               map[uint64]*deadLetterPending // dead-letter publishes waiting to be stored
+	dltmr             *time.Timer                   // expires unacknowledged dead-letter publishes
 	outq              *jsOutQ
 	pending           map[uint64]*Pending
+	pdsubj            map[uint64]string // last delivery subject of pending messages, pull mode only
 	ptmr              *time.Timer
 	ptmrEnd           time.Time
 	rdq               []uint64
 	rdqi              avl.SequenceSet
 	rdc               map[uint64]uint64
+	okeys             map[string][]uint64    // outstanding message per ordering key, followed by those held back
+	okseq             map[uint64]string      // ordering key of outstanding messages
+	oheld             avl.SequenceSet        // held back for their ordering key and not delivered yet
+	oout              avl.SequenceSet        // held back before and delivered out of order, until acknowledged
+	oready            []uint64               // held back messages whose ordering key is free
+	ptr               *subjectTransform      // selects the partition of a message
+	pmembers          map[string]*time.Timer // partition group members and their expiry timers
+	passign           []string               // member assigned to each partition
+	ptarget           []string               // member each partition should be assigned to once handed over
+	phandover         map[int]time.Time      // partitions being handed over and since when
+	phtmr             *time.Timer            // forces handovers once the fencing timeout passes
+	pinflight         map[uint64]int         // delivered messages and their partition, pruned lazily
+	pparked           map[uint64]int         // pending messages waiting for their partition's member
+	pqueue            map[int][]uint64       // parked messages per partition in sequence order
 	replies           map[uint64]string
 	maxdc             uint64
 	waiting           *waitQueue
@@ -445,6 +556,8 @@ type consumer struct {
 	replay            bool
 	dtmr              *time.Timer
 	uptmr             *time.Timer // Unpause timer
+	sttmr             *time.Timer // Stop time timer
+	completed         bool        // Bounded ephemeral removal was started
 	gwdtmr            *time.Timer
 	dthresh           time.Duration
 	mch               chan struct{} // Message channel
@@ -469,6 +582,7 @@ type consumer struct {
 	prOk      bool
 	uch       chan struct{}
 	retention RetentionPolicy
+	schedules bool
 
 	monitorWg sync.WaitGroup
 	inMonitor bool
@@ -587,8 +701,8 @@ func setConsumerConfigDefaults(config *ConsumerConfig, streamCfg *StreamConfig,
 		config.MaxRequestBatch = lim.MaxRequestBatch
 	}
 
-	// set the default value only if pinned policy is used.
-	if config.PriorityPolicy == PriorityPinnedClient && config.PinnedTTL == 0 {
+	// set the default value only if pinned or partitioned policy is used.
+	if (config.PriorityPolicy == PriorityPinnedClient || config.PriorityPolicy == PriorityPartitioned) && config.PinnedTTL == 0 {
 		config.PinnedTTL = JsDefaultPinnedTTL
 	}
 	return nil
@@ -790,6 +904,29 @@ func checkConsumerCfg(
 		}
 	}
 
+	// Check on stop position conflicts.
+	if config.OptStopSeq > 0 || config.OptStopTime != nil {
+		if config.OptStopSeq > 0 && config.OptStopTime != nil {
+			return NewJSConsumerInvalidPolicyError(errors.New("consumer optional stop sequence and time are mutually exclusive"))
+		}
+		if config.OptStopSeq > 0 && config.OptStopSeq < config.OptStartSeq {
+			return NewJSConsumerInvalidPolicyError(errors.New("consumer optional stop sequence is before the start sequence"))
+		}
+		if config.OptStopTime != nil && config.OptStartTime != nil && config.OptStopTime.Before(*config.OptStartTime) {
+			return NewJSConsumerInvalidPolicyError(errors.New("consumer optional stop time is before the start time"))
+		}
+		if config.DeliverPolicy == DeliverLastPerSubject {
+			return NewJSConsumerInvalidPolicyError(errors.New("consumer optional stop can not be combined with deliver last per subject policy"))
+		}
+	}
+
+	if config.ReplaySpeed < 0 {
+		return NewJSConsumerInvalidPolicyError(errors.New("consumer replay speed can not be negative"))
+	}
+	if config.ReplaySpeed > 0 && config.ReplayPolicy != ReplayOriginal {
+		return NewJSConsumerInvalidPolicyError(errors.New("consumer replay speed requires replay original policy"))
+	}
+
 	if config.SampleFrequency != _EMPTY_ {
 		s := strings.TrimSuffix(config.SampleFrequency, "%")
 		if sampleFreq, err := strconv.Atoi(s); err != nil || sampleFreq < 0 {
@@ -831,6 +968,51 @@ func checkConsumerCfg(
 		}
 	}
 
+	for _, hf := range config.HeaderFilters {
+		if hf.Header == _EMPTY_ || strings.ContainsAny(hf.Header, " \t\r\n:") {
+			return NewJSConsumerHeaderFilterInvalidError(fmt.Errorf("header name %q is invalid", hf.Header))
+		}
+	}
+	if len(config.HeaderFilters) > 0 && config.DeliverPolicy == DeliverLastPerSubject {
+		return NewJSConsumerHeaderFilterInvalidError(errors.New("can not be combined with deliver last per subject policy"))
+	}
+
+	if config.PriorityPolicy == PriorityPartitioned || config.Partitions != 0 || len(config.PartitionWildcards) > 0 {
+		if _, err := newPartitionTransform(config); err != nil {
+			return NewJSConsumerPartitionsInvalidError(err)
+		}
+	}
+
+	if config.DeadLetterSubject != _EMPTY_ {
+		if !IsValidPublishSubject(config.DeadLetterSubject) {
+			return NewJSConsumerDeadLetterInvalidError(errors.New("must be a valid literal subject"))
+		}
+		if config.AckPolicy != AckExplicit {
+			return NewJSConsumerDeadLetterInvalidError(errors.New("requires explicit ack policy"))
+		}
+		// Dead-lettered messages must not loop back into the stream they came from.
+		for _, subj := range cfg.Subjects {
+			if subjectIsSubsetMatch(config.DeadLetterSubject, subj) {
+				return NewJSConsumerDeadLetterInvalidError(fmt.Errorf("can not be a subject of stream %q", cfg.Name))
+			}
+		}
+	}
+
+	if ok := config.OrderingKey; ok != nil {
+		if (ok.SubjectToken > 0) == (ok.Header != _EMPTY_) {
+			return NewJSConsumerOrderingKeyInvalidError(errors.New("requires either a subject token or a header"))
+		}
+		if ok.SubjectToken < 0 {
+			return NewJSConsumerOrderingKeyInvalidError(errors.New("subject token can not be negative"))
+		}
+		if config.DeliverSubject != _EMPTY_ {
+			return NewJSConsumerOrderingKeyInvalidError(errors.New("requires a pull consumer"))
+		}
+		if config.AckPolicy != AckExplicit {
+			return NewJSConsumerOrderingKeyInvalidError(errors.New("requires explicit ack policy"))
+		}
+	}
+
 	// For now don't allow preferred server in placement.
 	if cfg.Placement != nil && cfg.Placement.Preferred != _EMPTY_ {
 		return NewJSStreamInvalidConfigError(fmt.Errorf("preferred server not permitted in placement"))
@@ -1017,8 +1199,13 @@ func (mset *stream) addConsumerWithAssignment(config *ConsumerConfig, oname stri
 		maxdc:     uint64(config.MaxDeliver),
 		maxp:      config.MaxAckPending,
 		retention: cfg.Retention,
+		schedules: cfg.AllowMsgSchedules,
 		created:   time.Now().UTC(),
 	}
+	if config.PriorityPolicy == PriorityPartitioned {
+		// Already validated by checkConsumerCfg.
+		o.ptr, _ = newPartitionTransform(config)
+	}
 
 	// Bind internal client to the user account.
 	o.client.registerWithAccount(a)
@@ -1174,6 +1361,9 @@ func (mset *stream) addConsumerWithAssignment(config *ConsumerConfig, oname stri
 	if config.RateLimit != 0 {
 		o.setRateLimit(config.RateLimit)
 	}
+	if config.RateLimitMsgs != 0 || config.RateLimitBytes != 0 {
+		o.setThroughputLimits(config.RateLimitMsgs, config.RateLimitBytes)
+	}
 
 	mset.setConsumer(o)
 	mset.mu.Unlock()
@@ -1376,6 +1566,12 @@ func (o *consumer) setLeader(isLeader bool) {
 		// Restore our saved state. During non-leader status we just update our underlying store.
 		o.readStoredState(lseq)
 
+		// Rebuild which messages are held back for their ordering key.
+		o.oheld.Empty()
+		o.rebuildOrderingKeys()
+		// Rebuild which messages are parked for their partition.
+		o.rebuildPartitionParking()
+
 		// Setup initial num pending.
 		o.streamNumPending()
 
@@ -1413,6 +1609,16 @@ func (o *consumer) setLeader(isLeader bool) {
 			return
 		}
 
+		// Dead-lettered messages are only acknowledged once stored.
+		if o.cfg.DeadLetterSubject != _EMPTY_ {
+			dlsubj := fmt.Sprintf(jsDeadLetter, stream, o.name)
+			if o.dlSub, err = o.subscribeInternal(dlsubj, o.processDeadLetterAck); err != nil {
+				o.mu.Unlock()
+				o.deleteWithoutAdvisory()
+				return
+			}
+		}
+
 		// Check on flow control settings.
 		if o.cfg.FlowControl {
 			o.setMaxPendingBytes(JsFlowControlMaxPending)
@@ -1450,6 +1656,12 @@ func (o *consumer) setLeader(isLeader bool) {
 
 		// Update the consumer pause tracking.
 		o.updatePauseState(&o.cfg)
+		// Kick the consumer once the stop time of a bounded consumer is reached.
+		o.updateStopState(&o.cfg)
+		o.completed = false
+		// Latency stats are tracked by the leader only, start fresh.
+		o.lstats = nil
+		o.setLatencyStats(o.cfg.LatencyStats)
 
 		// If we are not in ReplayInstant mode mark us as in replay state until resolved.
 		if o.cfg.ReplayPolicy != ReplayInstant {
@@ -1521,18 +1733,27 @@ func (o *consumer) setLeader(isLeader bool) {
 		}
 		// Stop any inactivity timers. Should only be running on leaders.
 		stopAndClearTimer(&o.dtmr)
-		// Stop any unpause timers. Should only be running on leaders.
+		// Stop any unpause and stop time timers. Should only be running on leaders.
 		stopAndClearTimer(&o.uptmr)
+		stopAndClearTimer(&o.sttmr)
+		o.lstats = nil
+		// Partition group members are tracked by the leader.
+		o.clearPartitionMembers()
 		// Make sure to clear out any re-deliver queues
 		o.stopAndClearPtmr()
 		o.rdq = nil
 		o.rdqi.Empty()
-		o.pending = nil
+		o.pending, o.pdsubj = nil, nil
+		o.pparked, o.pqueue, o.pinflight = nil, nil, nil
 		// ok if they are nil, we protect inside unsubscribe()
 		o.unsubscribe(o.ackSub)
 		o.unsubscribe(o.reqSub)
 		o.unsubscribe(o.fcSub)
-		o.ackSub, o.reqSub, o.fcSub = nil, nil, nil
+		o.unsubscribe(o.dlSub)
+		o.ackSub, o.reqSub, o.fcSub, o.dlSub = nil, nil, nil, nil
+		// Outstanding dead-letters are left to the new leader, messages remain in the stream.
+		stopAndClearTimer(&o.dltmr)
+		o.dlq = nil
 		if o.infoSub != nil {
 			o.srv.sysUnsubscribe(o.infoSub)
 			o.infoSub = nil
@@ -1707,6 +1928,24 @@ func (o *consumer) sendPauseAdvisoryLocked(cfg *ConsumerConfig) {
 	o.sendAdvisory(subj, e)
 }
 
+// Lock should be held.
+func (o *consumer) sendResetAdvisoryLocked(sseq uint64) {
+	e := JSConsumerResetAdvisory{
+		TypedEvent: TypedEvent{
+			Type: JSConsumerResetAdvisoryType,
+			ID:   nuid.Next(),
+			Time: time.Now().UTC(),
+		},
+		Stream:   o.stream,
+		Consumer: o.name,
+		Sequence: sseq,
+		Domain:   o.srv.getOpts().JetStreamDomain,
+	}
+
+	subj := JSAdvisoryConsumerResetPre + "." + o.stream + "." + o.name
+	o.sendAdvisory(subj, e)
+}
+
 // Created returns created time.
 func (o *consumer) createdTime() time.Time {
 	o.mu.Lock()
@@ -1828,6 +2067,10 @@ func (o *consumer) deleteNotActive() {
 		o.mu.Unlock()
 		return
 	}
+	// Ephemeral bounded consumers are removed once complete, regardless of activity.
+	if o.completed {
+		goto remove
+	}
 	// Push mode just look at active.
 	if o.isPushMode() {
 		// If we are active simply return.
@@ -1860,6 +2103,7 @@ func (o *consumer) deleteNotActive() {
 		}
 	}
 
+remove:
 	s, js := o.mset.srv, o.srv.js.Load()
 	acc, stream, name, isDirect := o.acc.Name, o.stream, o.name, o.cfg.Direct
 	var qch, cqch chan struct{}
@@ -1979,9 +2223,14 @@ func (o *consumer) hasMaxDeliveries(seq uint64) bool {
 		// Only send the advisory once.
 		if dc == o.maxdc {
 			o.notifyDeliveryExceeded(seq, dc)
+			// Dead-lettered messages are acknowledged once stored, so work queue streams remove the original.
+			if p, ok := o.pending[seq]; ok {
+				o.deadLetter(seq, &deadLetterPending{dseq: p.Sequence, dc: dc, reason: deadLetterMaxDeliveriesReason})
+			}
 		}
-		// Determine if we signal to start flow of messages again.
-		if o.maxp > 0 && len(o.pending) >= o.maxp {
+		// Determine if we signal to start flow of messages again,
+		// or to release messages held back for the same ordering key.
+		if o.maxp > 0 && len(o.pending) >= o.maxp || o.releaseOrderingKey(seq) {
 			o.signalNewMessages()
 		}
 		// Cleanup our tracking.
@@ -1999,6 +2248,9 @@ func (o *consumer) hasMaxDeliveries(seq uint64) bool {
 func (o *consumer) forceExpirePending() {
 	var expired []uint64
 	for seq := range o.pending {
+		if o.isHeld(seq) {
+			continue
+		}
 		if !o.onRedeliverQueue(seq) && !o.hasMaxDeliveries(seq) {
 			expired = append(expired, seq)
 		}
@@ -2033,6 +2285,7 @@ func (o *consumer) setRateLimitNeedsLocks() {
 	mset.mu.RLock()
 	o.mu.Lock()
 	o.setRateLimit(o.cfg.RateLimit)
+	o.setThroughputLimits(o.cfg.RateLimitMsgs, o.cfg.RateLimitBytes)
 	o.mu.Unlock()
 	mset.mu.RUnlock()
 }
@@ -2048,22 +2301,121 @@ func (o *consumer) setRateLimit(bps uint64) {
 	// TODO(dlc) - Make sane values or error if not sane?
 	// We are configured in bits per sec so adjust to bytes.
 	rl := rate.Limit(bps / 8)
-	mset := o.mset
+	o.rlimit = rate.NewLimiter(rl, o.rateLimitBurst())
+}
+
+// Set the message and byte throughput limiters.
+// Unlike the bits per second rate limit these apply to pull consumers
+// as well, and are shared by all requesters of the consumer.
+// Both mset and consumer lock should be held.
+func (o *consumer) setThroughputLimits(mps, bps uint64) {
+	if mps == 0 {
+		o.mrlimit = nil
+	} else {
+		o.mrlimit = rate.NewLimiter(rate.Limit(mps), 1)
+	}
+	if bps == 0 {
+		o.brlimit = nil
+	} else {
+		// Allow up to a second worth of bytes, but never less than a single message.
+		burst := o.rateLimitBurst()
+		if bps < uint64(burst) {
+			burst = int(bps)
+		}
+		o.brlimit = rate.NewLimiter(rate.Limit(bps), burst)
+	}
+	if o.mrlimit == nil && o.brlimit == nil {
+		o.rlthrottled = time.Time{}
+	}
+}
 
-	// Burst should be set to maximum msg size for this account, etc.
-	var burst int
+// Burst for byte based rate limiters.
+// Should be set to maximum msg size for this account, etc.
+// Both mset and consumer lock should be held.
+func (o *consumer) rateLimitBurst() int {
+	mset := o.mset
 	// We don't need to get cfgMu's rlock here since this function
 	// is already invoked under mset.mu.RLock(), which superseeds cfgMu.
 	if mset.cfg.MaxMsgSize > 0 {
-		burst = int(mset.cfg.MaxMsgSize)
+		return int(mset.cfg.MaxMsgSize)
 	} else if mset.jsa.account.limits.mpay > 0 {
-		burst = int(mset.jsa.account.limits.mpay)
-	} else {
-		s := mset.jsa.account.srv
-		burst = int(s.getOpts().MaxPayload)
+		return int(mset.jsa.account.limits.mpay)
+	}
+	s := mset.jsa.account.srv
+	return int(s.getOpts().MaxPayload)
+}
+
+// Check if delivering a message of size sz now would exceed our message or
+// byte throughput limits. If so we record when we can deliver again and
+// return true. No tokens are consumed here, that is done by takeThroughput
+// once we know there is someone to deliver the message to.
+// Lock should be held.
+func (o *consumer) throttled(sz int) bool {
+	if o.mrlimit == nil && o.brlimit == nil {
+		return false
+	}
+	now := time.Now()
+	delay := rateLimiterDelay(o.mrlimit, now, 1)
+	if o.brlimit != nil {
+		// A single message larger than our burst can never be reserved, so cap it.
+		delay = max(delay, rateLimiterDelay(o.brlimit, now, min(sz, o.brlimit.Burst())))
+	}
+	if delay <= 0 {
+		o.rlthrottled = time.Time{}
+		return false
+	}
+	o.rlthrottled = now.Add(delay)
+	o.notifyThrottled()
+	return true
+}
+
+// Returns how long until n tokens are available from the limiter.
+func rateLimiterDelay(l *rate.Limiter, now time.Time, n int) time.Duration {
+	if l == nil {
+		return 0
+	}
+	need := float64(n) - l.TokensAt(now)
+	if need <= 0 {
+		return 0
+	}
+	return time.Duration(need / float64(l.Limit()) * float64(time.Second))
+}
+
+// Consume the tokens for delivering a message of size sz, once throttled allowed it.
+// Lock should be held.
+func (o *consumer) takeThroughput(sz int) {
+	now := time.Now()
+	if o.mrlimit != nil {
+		o.mrlimit.ReserveN(now, 1)
+	}
+	if o.brlimit != nil {
+		o.brlimit.ReserveN(now, min(sz, o.brlimit.Burst()))
+	}
+}
+
+// Let pull requesters know we are holding back due to our throughput limits.
+// Ones without idle heartbeats would otherwise not hear from us until their
+// request expires, so each request is told once. Others get it with their heartbeats.
+// Lock should be held.
+func (o *consumer) notifyThrottled() {
+	if o.isPushMode() || o.waiting == nil {
+		return
+	}
+	for wr := o.waiting.head; wr != nil; wr = wr.next {
+		if wr.hb == 0 && !wr.rlsent {
+			o.sendIdleHeartbeat(wr.reply)
+			wr.rlsent = true
+		}
 	}
+}
 
-	o.rlimit = rate.NewLimiter(rl, burst)
+// Returns how much longer we are throttled by our throughput limits.
+// Lock should be held.
+func (o *consumer) throttleRemaining() time.Duration {
+	if o.rlthrottled.IsZero() {
+		return 0
+	}
+	return max(time.Until(o.rlthrottled), 0)
 }
 
 // Check if new consumer config allowed vs old.
@@ -2087,6 +2439,16 @@ func (acc *Account) checkNewConsumerConfig(cfg, ncfg *ConsumerConfig) error {
 		// At least one start time is set and the other is not
 		return errors.New("start time can not be updated")
 	}
+	if cfg.OptStopSeq != ncfg.OptStopSeq {
+		return errors.New("stop sequence can not be updated")
+	}
+	if cfg.OptStopTime != nil && ncfg.OptStopTime != nil {
+		if !cfg.OptStopTime.Equal(*ncfg.OptStopTime) {
+			return errors.New("stop time can not be updated")
+		}
+	} else if cfg.OptStopTime != nil || ncfg.OptStopTime != nil {
+		return errors.New("stop time can not be updated")
+	}
 	if cfg.AckPolicy != ncfg.AckPolicy {
 		return errors.New("ack policy can not be updated")
 	}
@@ -2117,6 +2479,15 @@ func (acc *Account) checkNewConsumerConfig(cfg, ncfg *ConsumerConfig) error {
 		return errors.New("max waiting can not be updated")
 	}
 
+	if !reflect.DeepEqual(cfg.OrderingKey, ncfg.OrderingKey) {
+		return errors.New("ordering key can not be updated")
+	}
+
+	if (cfg.PriorityPolicy == PriorityPartitioned) != (ncfg.PriorityPolicy == PriorityPartitioned) ||
+		cfg.Partitions != ncfg.Partitions || !slices.Equal(cfg.PartitionWildcards, ncfg.PartitionWildcards) {
+		return errors.New("partitions can not be updated")
+	}
+
 	// Check if BackOff is defined, MaxDeliver is within range.
 	if lbo := len(ncfg.BackOff); lbo > 0 && ncfg.MaxDeliver != -1 && lbo > ncfg.MaxDeliver {
 		return NewJSConsumerMaxDeliverBackoffError()
@@ -2168,10 +2539,14 @@ func (o *consumer) updateConfig(cfg *ConsumerConfig) error {
 		}
 	}
 	// Rate Limit
-	if cfg.RateLimit != o.cfg.RateLimit {
+	if cfg.RateLimit != o.cfg.RateLimit || cfg.RateLimitMsgs != o.cfg.RateLimitMsgs || cfg.RateLimitBytes != o.cfg.RateLimitBytes {
 		// We need both locks here so do in Go routine.
 		go o.setRateLimitNeedsLocks()
 	}
+	// Latency stats are only tracked by the leader.
+	if cfg.LatencyStats != o.cfg.LatencyStats && o.isLeader() {
+		o.setLatencyStats(cfg.LatencyStats)
+	}
 	if cfg.SampleFrequency != o.cfg.SampleFrequency {
 		s := strings.TrimSuffix(cfg.SampleFrequency, "%")
 		// String has been already verified for validity up in the stack, so no
@@ -2183,6 +2558,18 @@ func (o *consumer) updateConfig(cfg *ConsumerConfig) error {
 	if cfg.MaxDeliver != o.cfg.MaxDeliver {
 		o.maxdc = uint64(cfg.MaxDeliver)
 	}
+	// Dead-letter acknowledgements are only received by the leader.
+	if (cfg.DeadLetterSubject == _EMPTY_) != (o.cfg.DeadLetterSubject == _EMPTY_) && o.isLeader() {
+		if cfg.DeadLetterSubject == _EMPTY_ {
+			o.unsubscribe(o.dlSub)
+			o.dlSub = nil
+		} else if o.dlSub == nil {
+			dlsubj := fmt.Sprintf(jsDeadLetter, o.stream, o.name)
+			if sub, err := o.subscribeInternal(dlsubj, o.processDeadLetterAck); err == nil {
+				o.dlSub = sub
+			}
+		}
+	}
 	// Set InactiveThreshold if changed.
 	if val := cfg.InactiveThreshold; val != o.cfg.InactiveThreshold {
 		o.updateInactiveThreshold(cfg)
@@ -2246,6 +2633,15 @@ func (o *consumer) updateConfig(cfg *ConsumerConfig) error {
 		}
 	}
 
+	// Our stream needs to know if we need the headers of new messages.
+	if hf := len(cfg.HeaderFilters) > 0; hf != (len(o.cfg.HeaderFilters) > 0) && o.mset != nil {
+		if hf {
+			o.mset.hfcons.Add(1)
+		} else {
+			o.mset.hfcons.Add(-1)
+		}
+	}
+
 	// Record new config for others that do not need special handling.
 	// Allowed but considered no-op, [Description, SampleFrequency, MaxWaiting, HeadersOnly]
 	o.cfg = *cfg
@@ -2376,7 +2772,21 @@ func (o *consumer) processAck(subject, reply string, hdr int, rmsg []byte) {
 		if buf := msg[len(AckTerm):]; len(buf) > 0 {
 			reason = string(bytes.TrimSpace(buf))
 		}
-		if !o.processTerm(sseq, dseq, dc, reason, reply) {
+		// The term removes the message from work queue streams, so when dead-lettering
+		// it is only processed once the dead-letter publish was stored.
+		o.mu.Lock()
+		var dl bool
+		if _, ok := o.pending[sseq]; ok {
+			dlReason := deadLetterTerminatedReason
+			if reason != _EMPTY_ {
+				dlReason += ": " + reason
+			}
+			dl = o.deadLetter(sseq, &deadLetterPending{dseq: dseq, dc: dc, reason: dlReason, term: true, treason: reason, reply: reply})
+		}
+		o.mu.Unlock()
+		if dl {
+			skipAckReply = true
+		} else if !o.processTerm(sseq, dseq, dc, reason, reply) {
 			// We handle replies for acks in updateAcks
 			skipAckReply = true
 		}
@@ -2873,6 +3283,17 @@ func (o *consumer) writeStoreStateUnlocked() error {
 		Pending:     o.pending,
 		Redelivered: o.rdc,
 	}
+	// Messages held back for their ordering key are pending without a delivery sequence or time.
+	if !o.oheld.IsEmpty() {
+		state.Pending = make(map[uint64]*Pending, len(o.pending)+o.oheld.Size())
+		for seq, p := range o.pending {
+			state.Pending[seq] = p
+		}
+		o.oheld.Range(func(seq uint64) bool {
+			state.Pending[seq] = &Pending{}
+			return true
+		})
+	}
 	return o.store.Update(&state)
 }
 
@@ -2932,6 +3353,7 @@ func (o *consumer) infoWithSnapAndReply(snap bool, reply string) *ConsumerInfo {
 			Group:          o.cfg.PriorityGroups[0],
 			PinnedClientID: o.currentPinId,
 			PinnedTS:       o.pinnedTS,
+			Partitions:     o.partitionAssignments(),
 		})
 	}
 
@@ -2962,6 +3384,10 @@ func (o *consumer) infoWithSnapAndReply(snap bool, reply string) *ConsumerInfo {
 			info.PauseRemaining = time.Until(p)
 		}
 	}
+	if o.isBounded() && o.isLeader() {
+		info.Complete = o.isComplete()
+	}
+	info.Latency = o.latencyStats()
 
 	// If we are replicated, we need to pull certain data from our store.
 	if rg != nil && rg.node != nil && o.store != nil {
@@ -3081,6 +3507,12 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 		return false
 	}
 
+	// Ignore acks for messages delivered before the consumer was reset.
+	if dseq > 0 && dseq <= o.rsdflr {
+		o.mu.Unlock()
+		return true
+	}
+
 	// Check if this ack is above the current pointer to our next to deliver.
 	// This could happen on a cooperative takeover with high speed deliveries.
 	if sseq >= o.sseq {
@@ -3108,13 +3540,15 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 	ackInPlace := o.node == nil && o.retention != LimitsPolicy
 
 	var sgap, floor uint64
-	var needSignal bool
+	// Acks can drain a partition that is being handed over.
+	needSignal := len(o.phandover) > 0
 
 	switch o.cfg.AckPolicy {
 	case AckExplicit:
 		if p, ok := o.pending[sseq]; ok {
 			if doSample {
 				o.sampleAck(sseq, dseq, dc)
+				o.recordAckLatency(p.Timestamp, dc)
 			}
 			if o.maxp > 0 && len(o.pending) >= o.maxp {
 				needSignal = true
@@ -3122,6 +3556,10 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 			delete(o.pending, sseq)
 			// Use the original deliver sequence from our pending record.
 			dseq = p.Sequence
+			// Acks can free an ordering key that other messages are held back for.
+			if o.releaseOrderingKey(sseq) {
+				needSignal = true
+			}
 
 			// Only move floors if we matched an existing pending.
 			if len(o.pending) == 0 {
@@ -3138,6 +3576,7 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 					}
 				}
 			}
+			o.holdAckFloor()
 		}
 		delete(o.rdc, sseq)
 		o.removeFromRedeliverQueue(sseq)
@@ -3151,6 +3590,9 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 		if o.maxp > 0 && len(o.pending) >= o.maxp {
 			needSignal = true
 		}
+		if p, ok := o.pending[sseq]; ok && doSample {
+			o.recordAckLatency(p.Timestamp, dc)
+		}
 		sgap = sseq - o.asflr
 		floor = sgap // start at same and set lower as we go.
 		o.adflr, o.asflr = dseq, sseq
@@ -3181,6 +3623,11 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 		return ackInPlace
 	}
 
+	// A bounded consumer could be complete now, which releases pull requests.
+	if len(o.pending) == 0 && o.isBounded() {
+		needSignal = true
+	}
+
 	// No ack replication, so we set reply to "" so that updateAcks does not
 	// send the reply. The caller will.
 	if ackInPlace {
@@ -3188,6 +3635,7 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 	}
 	// Update underlying store.
 	o.updateAcks(dseq, sseq, reply)
+	hdrFiltered := len(o.cfg.HeaderFilters) > 0
 	o.mu.Unlock()
 
 	if ackInPlace {
@@ -3199,6 +3647,12 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 		} else {
 			mset.ackMsg(o, sseq)
 		}
+		// Messages skipped by our header filters are never acked, release them based on our ack floor.
+		if hdrFiltered {
+			var ss StreamState
+			mset.store.FastState(&ss)
+			o.checkStateForInterestStream(&ss)
+		}
 	}
 
 	// If we had max ack pending set and were at limit we need to unblock ourselves.
@@ -3284,6 +3738,10 @@ func (o *consumer) needAck(sseq uint64, subj string) bool {
 			return false
 		}
 	}
+	// Messages past the stop of a bounded consumer are never delivered, so don't need an ack.
+	if o.isBounded() && o.isPastStopSeq(sseq) {
+		return false
+	}
 	if o.isLeader() {
 		asflr, osseq = o.asflr, o.sseq
 		pending = o.pending
@@ -3300,6 +3758,7 @@ func (o *consumer) needAck(sseq uint64, subj string) bool {
 		asflr, osseq, pending = state.AckFloor.Stream, state.Delivered.Stream+1, state.Pending
 	}
 
+	var isPending bool
 	switch o.cfg.AckPolicy {
 	case AckNone, AckAll:
 		needAck = sseq > asflr
@@ -3309,10 +3768,25 @@ func (o *consumer) needAck(sseq uint64, subj string) bool {
 				needAck = true
 			} else {
 				_, needAck = pending[sseq]
+				// As leader we track messages held back for their ordering key outside of pending.
+				if !needAck && o.isLeader() {
+					needAck = o.oheld.Exists(sseq)
+				}
+				isPending = needAck
 			}
 		}
 	}
 
+	// Neither are messages not matching our header filters. Pending messages were delivered so did match,
+	// and as leader we know which ones we have not delivered yet do.
+	if needAck && !isPending && len(o.cfg.HeaderFilters) > 0 {
+		if o.isLeader() && sseq >= osseq && sseq <= o.npf {
+			needAck = o.hfpend.Exists(sseq)
+		} else {
+			needAck = o.isHeaderFilteredSeqMatch(sseq)
+		}
+	}
+
 	return needAck
 }
 
@@ -3383,6 +3857,7 @@ type waitingRequest struct {
 	hb            time.Duration
 	hbt           time.Time
 	noWait        bool
+	rlsent        bool // Told we are throttled by our throughput limits
 	priorityGroup *PriorityGroup
 }
 
@@ -3569,7 +4044,9 @@ func (o *consumer) setPinnedTimer(priorityGroup string) {
 // Return next waiting request. This will check for expirations but not noWait or interest.
 // That will be handled by processWaiting.
 // Lock should be held.
-func (o *consumer) nextWaiting(sz int) *waitingRequest {
+// For partitioned consumers only requests of the given member are considered.
+// Lock should be held.
+func (o *consumer) nextWaiting(sz int, member string) *waitingRequest {
 	if o.waiting == nil || o.waiting.isEmpty() {
 		return nil
 	}
@@ -3652,6 +4129,14 @@ func (o *consumer) nextWaiting(sz int) *waitingRequest {
 				}
 			}
 
+			if o.cfg.PriorityPolicy == PriorityPartitioned && (wr.priorityGroup == nil || wr.priorityGroup.Id != member) {
+				o.waiting.cycle()
+				if wr == lastRequest {
+					return nil
+				}
+				continue
+			}
+
 			if o.cfg.PriorityPolicy == PriorityOverflow {
 				if wr.priorityGroup != nil &&
 					// We need to check o.npc+1, because before calling nextWaiting, we do o.npc--
@@ -3806,11 +4291,17 @@ func (o *consumer) processNextMsgRequest(reply string, msg []byte) {
 			sendErr(400, "Bad Request - Not a Overflow Priority consumer")
 		}
 
-		if priorityGroup.Id != _EMPTY_ && o.cfg.PriorityPolicy != PriorityPinnedClient {
+		if priorityGroup.Id != _EMPTY_ && o.cfg.PriorityPolicy != PriorityPinnedClient && o.cfg.PriorityPolicy != PriorityPartitioned {
 			sendErr(400, "Bad Request - Not a Pinned Client Priority consumer")
 		}
 	}
 
+	// Partitioned consumers identify group members by the id of their requests.
+	if o.cfg.PriorityPolicy == PriorityPartitioned && (priorityGroup == nil || priorityGroup.Id == _EMPTY_) {
+		sendErr(400, "Bad Request - Partition Member Id missing")
+		return
+	}
+
 	if priorityGroup != nil && o.cfg.PriorityPolicy != PriorityNone {
 		if priorityGroup.Group == _EMPTY_ {
 			sendErr(400, "Bad Request - Priority Group missing")
@@ -3837,6 +4328,10 @@ func (o *consumer) processNextMsgRequest(reply string, msg []byte) {
 				return
 			}
 		}
+
+		if o.cfg.PriorityPolicy == PriorityPartitioned {
+			o.joinPartitionMember(priorityGroup.Id)
+		}
 	}
 
 	// If we have the max number of requests already pending try to expire.
@@ -3876,7 +4371,7 @@ func (o *consumer) processNextMsgRequest(reply string, msg []byte) {
 	// Create a waiting request.
 	wr := wrPool.Get().(*waitingRequest)
 	wr.acc, wr.interest, wr.reply, wr.n, wr.d, wr.noWait, wr.expires, wr.hb, wr.hbt, wr.priorityGroup = acc, interest, reply, batchSize, 0, noWait, expires, hb, hbt, priorityGroup
-	wr.b = maxBytes
+	wr.b, wr.rlsent = maxBytes, false
 	wr.received = time.Now()
 
 	if err := o.waiting.add(wr); err != nil {
@@ -3884,6 +4379,10 @@ func (o *consumer) processNextMsgRequest(reply string, msg []byte) {
 		wr.recycle()
 		return
 	}
+	// If we are holding back due to our throughput limits let them know right away.
+	if o.throttleRemaining() > 0 {
+		o.notifyThrottled()
+	}
 	o.signalNewMessages()
 	// If we are clustered update our followers about this request.
 	if o.node != nil {
@@ -3957,6 +4456,174 @@ func (o *consumer) notifyDeliveryExceeded(sseq, dc uint64) {
 	o.sendAdvisory(o.deliveryExcEventT, e)
 }
 
+// Reasons for dead-lettering a message.
+const (
+	deadLetterMaxDeliveriesReason = "MaxDeliveries"
+	deadLetterTerminatedReason    = "Terminated"
+)
+
+// Headers for dead-lettered messages, in addition to the origin stream, subject, sequence and time stamp.
+const (
+	JSDeadLetterConsumer   = "Nats-Dead-Letter-Consumer"
+	JSDeadLetterDeliveries = "Nats-Dead-Letter-Deliveries"
+	JSDeadLetterReason     = "Nats-Dead-Letter-Reason"
+)
+
+// How long we wait for a dead-letter publish to be stored before giving up on it.
+var deadLetterTimeout = 10 * time.Second
+
+// deadLetterPending tracks a dead-letter publish until it was stored.
+type deadLetterPending struct {
+	dseq    uint64
+	dc      uint64
+	reason  string    // Reason in the dead-letter headers.
+	term    bool      // Process the term once stored, otherwise acknowledge.
+	treason string    // Reason for the term, if any.
+	reply   string    // Reply for the term, if any.
+	sent    time.Time // When we published it.
+}
+
+// Republish a message that will not be delivered again to our dead-letter subject.
+// The original is only acknowledged, or terminated when term is set, once the stream
+// bound to our dead-letter subject stored the republished message. Otherwise the
+// original is left in place and we send an advisory.
+// Returns whether a dead-letter publish is in flight for the message, in which case
+// the caller must leave the term to us.
+// Lock should be held.
+func (o *consumer) deadLetter(sseq uint64, dl *deadLetterPending) bool {
+	if o.cfg.DeadLetterSubject == _EMPTY_ || o.dlSub == nil || o.mset == nil || o.mset.store == nil {
+		return false
+	}
+	// Already on its way, for instance terminated again after it was redelivered.
+	if _, ok := o.dlq[sseq]; ok {
+		return true
+	}
+	var smv StoreMsg
+	sm, err := o.mset.store.LoadMsg(sseq, &smv)
+	if err != nil {
+		if err != ErrStoreMsgNotFound && err != errDeletedMsg {
+			o.srv.Warnf("JetStream consumer '%s > %s > %s' could not load message %d to dead-letter: %v",
+				o.acc.Name, o.stream, o.name, sseq, err)
+		}
+		return false
+	}
+
+	var hdr []byte
+	if len(sm.hdr) > 0 {
+		hdr = copyBytes(sm.hdr)
+		// Publish expectations and batches only applied to the original publish.
+		hdr = removeHeaderIfPrefixPresent(hdr, "Nats-Expected-")
+		hdr = removeHeaderIfPrefixPresent(hdr, "Nats-Batch-")
+	}
+	hdr = genHeader(hdr, JSStream, o.stream)
+	hdr = genHeader(hdr, JSSubject, sm.subj)
+	hdr = genHeader(hdr, JSSequence, strconv.FormatUint(sm.seq, 10))
+	hdr = genHeader(hdr, JSTimeStamp, time.Unix(0, sm.ts).UTC().Format(time.RFC3339Nano))
+	hdr = genHeader(hdr, JSDeadLetterConsumer, o.name)
+	hdr = genHeader(hdr, JSDeadLetterDeliveries, strconv.FormatUint(dl.dc, 10))
+	hdr = genHeader(hdr, JSDeadLetterReason, dl.reason)
+
+	if o.dlq == nil {
+		o.dlq = make(map[uint64]*deadLetterPending)
+	}
+	dl.sent = time.Now()
+	o.dlq[sseq] = dl
+	if o.dltmr == nil {
+		o.dltmr = time.AfterFunc(deadLetterTimeout, o.expireDeadLetters)
+	}
+
+	dlreply := fmt.Sprintf(jsDeadLetterT, o.stream, o.name, sseq)
+	o.outq.send(newJSPubMsg(o.cfg.DeadLetterSubject, _EMPTY_, dlreply, hdr, copyBytes(sm.msg), nil, 0))
+	return true
+}
+
+// Processes the publish acknowledgement for a dead-lettered message.
+// This is coming on the wire so do not block here.
+func (o *consumer) processDeadLetterAck(_ *subscription, c *client, _ *Account, subject, _ string, rmsg []byte) {
+	_, msg := c.msgParts(rmsg)
+	tsa := [32]string{}
+	tokens := tokenizeSubjectIntoSlice(tsa[:0], subject)
+	sseq, err := strconv.ParseUint(tokens[len(tokens)-1], 10, 64)
+	if err != nil {
+		return
+	}
+
+	var resp JSPubAckResponse
+	var failed string
+	if err := json.Unmarshal(msg, &resp); err != nil {
+		failed = fmt.Sprintf("invalid publish acknowledgement: %v", err)
+	} else if resp.Error != nil {
+		failed = resp.Error.Error()
+	} else if resp.PubAck == nil {
+		failed = "missing publish acknowledgement"
+	}
+
+	o.mu.Lock()
+	dl := o.dlq[sseq]
+	if dl == nil {
+		o.mu.Unlock()
+		return
+	}
+	delete(o.dlq, sseq)
+	if failed != _EMPTY_ {
+		o.sendDeadLetterFailedAdvisory(sseq, dl, failed)
+		o.mu.Unlock()
+		return
+	}
+	o.mu.Unlock()
+
+	if dl.term {
+		go o.processTerm(sseq, dl.dseq, dl.dc, dl.treason, dl.reply)
+	} else {
+		go o.processAckMsg(sseq, dl.dseq, dl.dc, _EMPTY_, false)
+	}
+}
+
+// Gives up on dead-letter publishes that were not stored in time, for instance when
+// no stream is bound to our dead-letter subject. The originals are left in place.
+func (o *consumer) expireDeadLetters() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.dltmr = nil
+	now, next := time.Now(), time.Duration(0)
+	for sseq, dl := range o.dlq {
+		if elapsed := now.Sub(dl.sent); elapsed >= deadLetterTimeout {
+			delete(o.dlq, sseq)
+			o.sendDeadLetterFailedAdvisory(sseq, dl, "timeout waiting for the dead-letter message to be stored")
+		} else if wait := deadLetterTimeout - elapsed; next == 0 || wait < next {
+			next = wait
+		}
+	}
+	if next > 0 {
+		o.dltmr = time.AfterFunc(next, o.expireDeadLetters)
+	}
+}
+
+// Lock should be held.
+func (o *consumer) sendDeadLetterFailedAdvisory(sseq uint64, dl *deadLetterPending, reason string) {
+	o.srv.Warnf("JetStream consumer '%s > %s > %s' could not dead-letter message %d: %s",
+		o.acc.Name, o.stream, o.name, sseq, reason)
+
+	e := JSConsumerDeadLetterFailedAdvisory{
+		TypedEvent: TypedEvent{
+			Type: JSConsumerDeadLetterFailedAdvisoryType,
+			ID:   nuid.Next(),
+			Time: time.Now().UTC(),
+		},
+		Stream:      o.stream,
+		Consumer:    o.name,
+		ConsumerSeq: dl.dseq,
+		StreamSeq:   sseq,
+		Deliveries:  dl.dc,
+		Subject:     o.cfg.DeadLetterSubject,
+		Reason:      reason,
+		Domain:      o.srv.getOpts().JetStreamDomain,
+	}
+	subj := JSAdvisoryConsumerDeadLetterFailedPre + "." + o.stream + "." + o.name
+	o.sendAdvisory(subj, e)
+}
+
 // Check if the candidate subject matches a filter if its present.
 // Lock should be held.
 func (o *consumer) isFilteredMatch(subj string) bool {
@@ -3981,29 +4648,188 @@ func (o *consumer) isFilteredMatch(subj string) bool {
 	return false
 }
 
-// Check if the candidate filter subject is equal to or a subset match
-// of one of the filter subjects.
+// Check if the headers of a candidate message match all header filters.
 // Lock should be held.
-func (o *consumer) isEqualOrSubsetMatch(subj string) bool {
-	for _, filter := range o.subjf {
-		if !filter.hasWildcard && subj == filter.subject {
-			return true
+func (o *consumer) isHeaderFilteredMatch(hdr []byte) bool {
+	for _, hf := range o.cfg.HeaderFilters {
+		v := sliceHeader(hf.Header, hdr)
+		if v == nil {
+			return false
 		}
-	}
-	tsa := [32]string{}
-	tts := tokenizeSubjectIntoSlice(tsa[:0], subj)
-	for _, filter := range o.subjf {
-		if isSubsetMatchTokenized(filter.tokenizedSubject, tts) {
-			return true
+		if len(hf.Values) > 0 && !slices.Contains(hf.Values, string(v)) {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-var (
+// Check if the message at the given sequence matches the header filters, if any.
+// Lock should be held.
+func (o *consumer) isHeaderFilteredSeqMatch(seq uint64) bool {
+	if len(o.cfg.HeaderFilters) == 0 {
+		return true
+	}
+	if o.mset == nil || o.mset.store == nil {
+		return false
+	}
+	var smv StoreMsg
+	sm, err := o.mset.store.LoadMsg(seq, &smv)
+	return err == nil && o.isHeaderFilteredMatch(sm.hdr)
+}
+
+// Check if a message our stream signaled us about matches the header filters.
+// The stream includes the headers when it has consumers with header filters,
+// marked with sigHdrs, otherwise we need to load the message.
+// Lock should be held.
+func (o *consumer) isHeaderFilteredSignalMatch(seq uint64, hdr []byte) bool {
+	if len(hdr) > 0 && hdr[0] == sigHdrs {
+		return o.isHeaderFilteredMatch(hdr[1:])
+	}
+	return o.isHeaderFilteredSeqMatch(seq)
+}
+
+// Check if the consumer has a stop sequence or time.
+// Lock should be held.
+func (o *consumer) isBounded() bool {
+	return o.cfg.OptStopSeq > 0 || o.cfg.OptStopTime != nil
+}
+
+// Check if a message is past the stop sequence or time of a bounded consumer.
+// Lock should be held.
+func (o *consumer) isPastStop(seq uint64, ts int64) bool {
+	return o.cfg.OptStopSeq > 0 && seq > o.cfg.OptStopSeq ||
+		o.cfg.OptStopTime != nil && ts > o.cfg.OptStopTime.UnixNano()
+}
+
+// Check if the message at the given sequence is past the stop of a bounded consumer.
+// Lock should be held.
+func (o *consumer) isPastStopSeq(seq uint64) bool {
+	if o.cfg.OptStopSeq > 0 {
+		return seq > o.cfg.OptStopSeq
+	}
+	if o.cfg.OptStopTime == nil || o.mset == nil || o.mset.store == nil {
+		return false
+	}
+	var smv StoreMsg
+	sm, err := o.mset.store.LoadMsg(seq, &smv)
+	return err == nil && o.isPastStop(sm.seq, sm.ts)
+}
+
+// Returns the last stream sequence a bounded consumer can deliver, or 0 if not known yet.
+// Lock should be held.
+func (o *consumer) stopSeq() uint64 {
+	if o.cfg.OptStopSeq > 0 {
+		return o.cfg.OptStopSeq
+	}
+	if o.cfg.OptStopTime == nil || time.Now().Before(*o.cfg.OptStopTime) || o.mset == nil || o.mset.store == nil {
+		return 0
+	}
+	// The first message after the stop time, everything before it can be delivered.
+	return o.mset.store.GetSeqFromTime(o.cfg.OptStopTime.Add(time.Nanosecond)) - 1
+}
+
+// Check if a bounded consumer has delivered all messages up to its stop.
+// Lock should be held.
+func (o *consumer) stopReached() bool {
+	if !o.isBounded() || o.mset == nil || o.mset.store == nil {
+		return false
+	}
+	store := o.mset.store
+	var smv StoreMsg
+	var sm *StoreMsg
+	var err error
+	if o.filters != nil {
+		sm, _, err = store.LoadNextMsgMulti(o.filters, o.sseq, &smv)
+	} else if len(o.subjf) > 0 {
+		sm, _, err = store.LoadNextMsg(o.subjf[0].subject, o.subjf[0].hasWildcard, o.sseq, &smv)
+	} else {
+		sm, _, err = store.LoadNextMsg(_EMPTY_, false, o.sseq, &smv)
+	}
+	if err == nil {
+		return o.isPastStop(sm.seq, sm.ts)
+	} else if err != ErrStoreEOF {
+		return false
+	}
+	// No more messages for us, but more could still be stored before the stop.
+	if o.cfg.OptStopSeq > 0 {
+		var ss StreamState
+		store.FastState(&ss)
+		return ss.LastSeq >= o.cfg.OptStopSeq
+	}
+	return time.Now().After(*o.cfg.OptStopTime)
+}
+
+// Check if a bounded consumer is complete, meaning all messages up to its stop were delivered and acked.
+// Lock should be held.
+func (o *consumer) isComplete() bool {
+	return len(o.pending) == 0 && o.stopReached()
+}
+
+// Releases all pull requests of a complete bounded consumer, and removes it if it's ephemeral.
+// Lock should be held.
+func (o *consumer) processComplete() {
+	if o.isPullMode() && !o.waiting.isEmpty() {
+		hdr := []byte("NATS/1.0 409 Consumer Complete\r\n\r\n")
+		wq := o.waiting
+		for wr := wq.head; wr != nil; wr = wq.head {
+			o.outq.send(newJSPubMsg(wr.reply, _EMPTY_, _EMPTY_, hdr, nil, nil, 0))
+			if o.node != nil {
+				o.removeClusterPendingRequest(wr.reply)
+			}
+			wq.removeCurrent()
+			wr.recycle()
+		}
+	}
+	// There is nothing left to deliver, so don't wait for the inactive threshold.
+	if !o.isDurable() && !o.completed {
+		o.completed = true
+		go o.deleteNotActive()
+	}
+}
+
+// Updates the stop state. If we are the leader and the stop time hasn't passed yet
+// then we will start a timer to kick the consumer once reached, so pull requests
+// can be released. Lock should be held.
+func (o *consumer) updateStopState(cfg *ConsumerConfig) {
+	if o.sttmr != nil {
+		stopAndClearTimer(&o.sttmr)
+	}
+	if !o.isLeader() || cfg.OptStopTime == nil || cfg.OptStopTime.Before(time.Now()) {
+		return
+	}
+	o.sttmr = time.AfterFunc(time.Until(*cfg.OptStopTime), func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		stopAndClearTimer(&o.sttmr)
+		o.signalNewMessages()
+	})
+}
+
+// Check if the candidate filter subject is equal to or a subset match
+// of one of the filter subjects.
+// Lock should be held.
+func (o *consumer) isEqualOrSubsetMatch(subj string) bool {
+	for _, filter := range o.subjf {
+		if !filter.hasWildcard && subj == filter.subject {
+			return true
+		}
+	}
+	tsa := [32]string{}
+	tts := tokenizeSubjectIntoSlice(tsa[:0], subj)
+	for _, filter := range o.subjf {
+		if isSubsetMatchTokenized(filter.tokenizedSubject, tts) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
 	errMaxAckPending = errors.New("max ack pending reached")
 	errBadConsumer   = errors.New("consumer not valid")
 	errNoInterest    = errors.New("consumer requires interest for delivery subject when ephemeral")
+	errStopReached   = errors.New("consumer stop reached")
 )
 
 // Get next available message from underlying store.
@@ -4013,6 +4839,14 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 	if o.mset == nil || o.mset.store == nil {
 		return nil, 0, errBadConsumer
 	}
+	// Hand over partitions whose previous member is done with them.
+	var pwaiting map[string]struct{}
+	if o.ptr != nil {
+		if len(o.phandover) > 0 {
+			o.checkPartitionHandovers()
+		}
+		pwaiting = o.waitingPartitionMembers()
+	}
 	// Process redelivered messages before looking at possibly "skip list" (deliver last per subject)
 	if o.hasRedeliveries() {
 		var seq, dc uint64
@@ -4022,11 +4856,16 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 				// Only send once
 				if dc == o.maxdc+1 {
 					o.notifyDeliveryExceeded(seq, dc-1)
+					// Dead-lettered messages are acknowledged once stored, so work queue streams remove the original.
+					if p, ok := o.pending[seq]; ok && p != nil {
+						o.deadLetter(seq, &deadLetterPending{dseq: p.Sequence, dc: dc - 1, reason: deadLetterMaxDeliveriesReason})
+					}
 				}
 				// Make sure to remove from pending.
 				if p, ok := o.pending[seq]; ok && p != nil {
 					delete(o.pending, seq)
 					o.updateDelivered(p.Sequence, seq, dc, p.Timestamp)
+					o.releaseOrderingKey(seq)
 				}
 				continue
 			}
@@ -4046,10 +4885,30 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 				// That will correct the pending state and delivery/ack floors, so just skip here.
 				continue
 			}
+			// Park the redelivery if the member its partition is assigned to is not waiting.
+			if o.ptr != nil && !o.partitionMemberWaiting(sm.subj, pwaiting) {
+				pmsg.returnToPool()
+				o.decDeliveryCount(seq)
+				o.parkForPartition(seq, sm.subj)
+				continue
+			}
 			return pmsg, dc, err
 		}
 	}
 
+	// Deliver parked messages once the member their partition is assigned to is waiting.
+	// These are already pending, so are not limited by max pending.
+	if seq := o.nextUnparkedForPartition(pwaiting); seq > 0 {
+		pmsg := getJSPubMsgFromPool()
+		sm, err := o.mset.store.LoadMsg(seq, &pmsg.StoreMsg)
+		if sm == nil || err != nil {
+			pmsg.returnToPool()
+			// Removed in the meantime, cleanup is the same as for redeliveries.
+			return o.getNextMsg()
+		}
+		return pmsg, o.deliveryCount(seq), nil
+	}
+
 	// Check if we have max pending.
 	if o.maxp > 0 && len(o.pending) >= o.maxp {
 		// maxp only set when ack policy != AckNone and user set MaxAckPending
@@ -4057,6 +4916,22 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 		return nil, 0, errMaxAckPending
 	}
 
+	// Deliver messages that were held back for their ordering key once it is free.
+	for len(o.oready) > 0 {
+		seq := o.oready[0]
+		if o.oready = o.oready[1:]; len(o.oready) == 0 {
+			o.oready = nil
+		}
+		pmsg := getJSPubMsgFromPool()
+		sm, err := o.mset.store.LoadMsg(seq, &pmsg.StoreMsg)
+		if sm == nil || err != nil {
+			pmsg.returnToPool()
+			o.dropHeld(seq)
+			continue
+		}
+		return pmsg, 1, nil
+	}
+
 	if o.hasSkipListPending() {
 		seq := o.lss.seqs[0]
 		if len(o.lss.seqs) == 1 {
@@ -4072,6 +4947,10 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 			pmsg.returnToPool()
 		}
 		o.sseq++
+		if sm != nil && o.schedules && isScheduledMsg(sm.hdr) {
+			pmsg.returnToPool()
+			return o.getNextMsg()
+		}
 		return pmsg, 1, err
 	}
 
@@ -4085,15 +4964,53 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 
 	// Grab next message applicable to us.
 	filters, subjf, fseq := o.filters, o.subjf, o.sseq
-	// Check if we are multi-filtered or not.
-	if filters != nil {
-		sm, sseq, err = store.LoadNextMsgMulti(filters, fseq, &pmsg.StoreMsg)
-	} else if len(subjf) > 0 { // Means single filtered subject since o.filters means > 1.
-		filter, wc := subjf[0].subject, subjf[0].hasWildcard
-		sm, sseq, err = store.LoadNextMsg(filter, wc, fseq, &pmsg.StoreMsg)
-	} else {
-		// No filter here.
-		sm, sseq, err = store.LoadNextMsg(_EMPTY_, false, fseq, &pmsg.StoreMsg)
+	for {
+		// Check if we are multi-filtered or not.
+		if filters != nil {
+			sm, sseq, err = store.LoadNextMsgMulti(filters, fseq, &pmsg.StoreMsg)
+		} else if len(subjf) > 0 { // Means single filtered subject since o.filters means > 1.
+			filter, wc := subjf[0].subject, subjf[0].hasWildcard
+			sm, sseq, err = store.LoadNextMsg(filter, wc, fseq, &pmsg.StoreMsg)
+		} else {
+			// No filter here.
+			sm, sseq, err = store.LoadNextMsg(_EMPTY_, false, fseq, &pmsg.StoreMsg)
+		}
+		// Skip over messages held back by a schedule, their released copy is delivered instead.
+		if sm != nil && o.schedules && isScheduledMsg(sm.hdr) {
+			fseq = sseq + 1
+			continue
+		}
+		// Skip over messages not matching our header filters, these will never be delivered.
+		if sm != nil && len(o.cfg.HeaderFilters) > 0 && !o.isHeaderFilteredMatch(sm.hdr) {
+			o.sseq, fseq = sseq+1, sseq+1
+			continue
+		}
+		// Bounded consumers don't deliver past their stop sequence or time.
+		if sm != nil && o.isPastStop(sm.seq, sm.ts) {
+			pmsg.returnToPool()
+			return nil, 0, errStopReached
+		}
+		// Park the message as pending if the member its partition is assigned to is not waiting.
+		// The zero delivery time marks it as not delivered in our replicated state.
+		if sm != nil && o.ptr != nil && !o.partitionMemberWaiting(sm.subj, pwaiting) {
+			dseq := o.dseq
+			o.dseq++
+			o.updateDelivered(dseq, sm.seq, 1, 0)
+			o.trackPending(sm.seq, dseq)
+			o.parkForPartition(sm.seq, sm.subj)
+			o.sseq, fseq = sseq+1, sseq+1
+			if o.maxp > 0 && len(o.pending) >= o.maxp {
+				pmsg.returnToPool()
+				return nil, 0, errMaxAckPending
+			}
+			continue
+		}
+		// Hold back the message if another one with the same ordering key is outstanding.
+		if sm != nil && o.cfg.OrderingKey != nil && o.holdForOrderingKey(sm) {
+			o.sseq, fseq = sseq+1, sseq+1
+			continue
+		}
+		break
 	}
 	if sm == nil {
 		pmsg.returnToPool()
@@ -4111,6 +5028,491 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 	return pmsg, 1, err
 }
 
+// Returns the ordering key of a message, or an empty string if it has none.
+// Lock should be held.
+func (o *consumer) orderingKey(subj string, hdr []byte) string {
+	ok := o.cfg.OrderingKey
+	if ok.Header != _EMPTY_ {
+		return string(getHeader(ok.Header, hdr))
+	}
+	tsa := [32]string{}
+	if tts := tokenizeSubjectIntoSlice(tsa[:0], subj); ok.SubjectToken <= len(tts) {
+		return tts[ok.SubjectToken-1]
+	}
+	return _EMPTY_
+}
+
+// Holds back a message if another one with the same ordering key is outstanding. Held messages are
+// neither pending nor have a delivery sequence until they are delivered. They are recorded in our
+// replicated state without either, so they are not lost on a leader change.
+// Returns whether the message was held back.
+// Lock should be held.
+func (o *consumer) holdForOrderingKey(sm *StoreMsg) bool {
+	key := o.orderingKey(sm.subj, sm.hdr)
+	if key == _EMPTY_ {
+		return false
+	}
+	seqs, ok := o.okeys[key]
+	if !ok {
+		return false
+	}
+	o.okeys[key] = append(seqs, sm.seq)
+	o.oheld.Insert(sm.seq)
+	o.updateDelivered(0, sm.seq, 1, 0)
+	return true
+}
+
+// Tracks a message that is delivered for the first time as outstanding for its ordering key.
+// Returns whether it was held back, in which case it is delivered out of order.
+// Lock should be held.
+func (o *consumer) trackOrderingKey(seq uint64, subj string, hdr []byte) bool {
+	if o.oheld.Delete(seq) {
+		o.oout.Insert(seq)
+		return true
+	}
+	key := o.orderingKey(subj, hdr)
+	if key == _EMPTY_ {
+		return false
+	}
+	if o.okeys == nil {
+		o.okeys, o.okseq = make(map[string][]uint64), make(map[uint64]string)
+	}
+	if _, ok := o.okeys[key]; !ok {
+		o.okeys[key] = []uint64{seq}
+		o.okseq[seq] = key
+	}
+	return false
+}
+
+// Frees the ordering key of an outstanding message that is no longer pending,
+// and releases the next message held back for it.
+// Returns whether a message was released.
+// Lock should be held.
+func (o *consumer) releaseOrderingKey(seq uint64) bool {
+	key, ok := o.okseq[seq]
+	if !ok {
+		return false
+	}
+	delete(o.okseq, seq)
+	o.oout.Delete(seq)
+	seqs := o.okeys[key][1:]
+	if len(seqs) == 0 {
+		delete(o.okeys, key)
+		return false
+	}
+	o.okeys[key] = seqs
+	o.okseq[seqs[0]] = key
+	o.oready = append(o.oready, seqs[0])
+	return true
+}
+
+// Drops a released message that was removed from the stream before it could be delivered.
+// Lock should be held.
+func (o *consumer) dropHeld(seq uint64) {
+	if o.oheld.Delete(seq) && o.npc > 0 {
+		o.npc--
+	}
+	o.updateAcks(0, seq, _EMPTY_)
+	o.releaseOrderingKey(seq)
+}
+
+// Keeps our ack floor below messages held back for their ordering key, since these
+// are not acknowledged yet even if messages after them are.
+// Lock should be held.
+func (o *consumer) holdAckFloor() {
+	for _, ss := range []*avl.SequenceSet{&o.oheld, &o.oout} {
+		if ss.IsEmpty() {
+			continue
+		}
+		if first, _ := ss.MinMax(); o.asflr >= first {
+			o.asflr = first - 1
+		}
+	}
+}
+
+// Rebuilds the ordering keys, used when becoming leader and after a purge. Messages that were held
+// back are pending without a delivery sequence, and without a delivery time if not delivered yet.
+// For every key the lowest message is outstanding, and all others are held back.
+// Lock should be held.
+func (o *consumer) rebuildOrderingKeys() {
+	held := o.oheld.Clone()
+	o.okeys, o.okseq, o.oready = nil, nil, nil
+	o.oheld.Empty()
+	o.oout.Empty()
+	if o.cfg.OrderingKey == nil || o.mset == nil || o.mset.store == nil {
+		return
+	}
+	seqs := make([]uint64, 0, len(o.pending)+held.Size())
+	for seq := range o.pending {
+		seqs = append(seqs, seq)
+	}
+	held.Range(func(seq uint64) bool {
+		seqs = append(seqs, seq)
+		return true
+	})
+	slices.Sort(seqs)
+	var smv StoreMsg
+	for _, seq := range seqs {
+		p, isPending := o.pending[seq]
+		wasHeld, delivered := !isPending, isPending
+		if isPending && p.Sequence == 0 {
+			wasHeld, delivered = true, p.Timestamp > 0
+		}
+		if !delivered {
+			delete(o.pending, seq)
+		}
+		sm, err := o.mset.store.LoadMsg(seq, &smv)
+		if err != nil {
+			if !delivered {
+				o.updateAcks(0, seq, _EMPTY_)
+			}
+			continue
+		}
+		key := o.orderingKey(sm.subj, sm.hdr)
+		if key == _EMPTY_ {
+			continue
+		}
+		if o.okeys == nil {
+			o.okeys, o.okseq = make(map[string][]uint64), make(map[uint64]string)
+		}
+		if kseqs, ok := o.okeys[key]; ok {
+			if !delivered {
+				o.okeys[key] = append(kseqs, seq)
+				o.oheld.Insert(seq)
+			}
+			continue
+		}
+		o.okeys[key] = []uint64{seq}
+		o.okseq[seq] = key
+		if !delivered {
+			o.oheld.Insert(seq)
+			o.oready = append(o.oready, seq)
+		} else if wasHeld {
+			o.oout.Insert(seq)
+		}
+	}
+}
+
+// Returns whether a message was not delivered yet, because it is held back
+// for its ordering key or parked for its partition.
+// Lock should be held.
+func (o *consumer) isHeld(seq uint64) bool {
+	if o.oheld.Exists(seq) {
+		return true
+	}
+	_, ok := o.pparked[seq]
+	return ok
+}
+
+// Returns the transform selecting the partition of a message, built from
+// the partition() subject transform function over the filter subject.
+func newPartitionTransform(config *ConsumerConfig) (*subjectTransform, error) {
+	if config.PriorityPolicy != PriorityPartitioned {
+		return nil, errors.New("requires the partitioned priority policy")
+	}
+	if config.Partitions <= 0 {
+		return nil, errors.New("number of partitions must be positive")
+	}
+	if config.DeliverSubject != _EMPTY_ {
+		return nil, errors.New("requires a pull consumer")
+	}
+	if config.AckPolicy != AckExplicit {
+		return nil, errors.New("requires explicit ack policy")
+	}
+	if config.OrderingKey != nil {
+		return nil, errors.New("can not be combined with an ordering key")
+	}
+	filter := config.FilterSubject
+	if len(config.FilterSubjects) == 1 {
+		filter = config.FilterSubjects[0]
+	} else if len(config.FilterSubjects) > 1 {
+		return nil, errors.New("requires a single filter subject")
+	}
+	if filter == _EMPTY_ || len(config.PartitionWildcards) == 0 {
+		return nil, errors.New("requires a filter subject and the wildcards to partition by")
+	}
+	wildcards := make([]string, 0, len(config.PartitionWildcards))
+	for _, wc := range config.PartitionWildcards {
+		wildcards = append(wildcards, strconv.Itoa(wc))
+	}
+	dest := fmt.Sprintf("{{partition(%d,%s)}}", config.Partitions, strings.Join(wildcards, ","))
+	return NewSubjectTransform(filter, dest)
+}
+
+// Returns the member the partition of a message is assigned to, if any.
+// Lock should be held.
+func (o *consumer) partitionMember(subj string) string {
+	if o.ptr == nil || len(o.passign) == 0 {
+		return _EMPTY_
+	}
+	return o.passign[o.partition(subj)]
+}
+
+// Lock should be held.
+func (o *consumer) partition(subj string) int {
+	p, err := strconv.Atoi(o.ptr.TransformSubject(subj))
+	if err != nil || p < 0 || p >= o.cfg.Partitions {
+		return 0
+	}
+	return p
+}
+
+// Returns whether the member the partition of a message is assigned to is in the
+// waiting members, and the partition is not being handed over.
+// Lock should be held.
+func (o *consumer) partitionMemberWaiting(subj string, waiting map[string]struct{}) bool {
+	p := o.partition(subj)
+	if len(o.passign) == 0 || o.passign[p] == _EMPTY_ {
+		return false
+	}
+	if _, ok := o.phandover[p]; ok {
+		return false
+	}
+	_, ok := waiting[o.passign[p]]
+	return ok
+}
+
+// Returns the members that have a request waiting.
+// Lock should be held.
+func (o *consumer) waitingPartitionMembers() map[string]struct{} {
+	if o.waiting == nil || o.waiting.isEmpty() {
+		return nil
+	}
+	members, now := make(map[string]struct{}), time.Now()
+	for wr := o.waiting.head; wr != nil; wr = wr.next {
+		if wr.priorityGroup != nil && (wr.expires.IsZero() || now.Before(wr.expires)) {
+			members[wr.priorityGroup.Id] = struct{}{}
+		}
+	}
+	return members
+}
+
+// Parks a pending message until the member its partition is assigned to is waiting.
+// Lock should be held.
+func (o *consumer) parkForPartition(seq uint64, subj string) {
+	p := o.partition(subj)
+	if o.pparked == nil {
+		o.pparked = make(map[uint64]int)
+		o.pqueue = make(map[int][]uint64)
+	}
+	if _, ok := o.pparked[seq]; ok {
+		return
+	}
+	o.pparked[seq] = p
+	// Redeliveries can be parked behind messages with a higher sequence.
+	q := o.pqueue[p]
+	if i, _ := slices.BinarySearch(q, seq); i == len(q) {
+		o.pqueue[p] = append(q, seq)
+	} else {
+		o.pqueue[p] = slices.Insert(q, i, seq)
+	}
+}
+
+// Rebuilds which pending messages are parked for their partition, used when becoming leader.
+// Parked messages are recorded with a zero delivery time in our replicated state. All others
+// were delivered by the previous leader, so partitions are handed over once these are done.
+// Lock should be held.
+func (o *consumer) rebuildPartitionParking() {
+	o.pparked, o.pqueue, o.pinflight = nil, nil, nil
+	if o.ptr == nil || len(o.pending) == 0 || o.mset == nil || o.mset.store == nil {
+		return
+	}
+	var smv StoreMsg
+	for seq, p := range o.pending {
+		sm, err := o.mset.store.LoadMsg(seq, &smv)
+		if err != nil {
+			continue
+		}
+		if p.Timestamp == 0 {
+			o.parkForPartition(seq, sm.subj)
+		} else {
+			o.trackPartitionInflight(seq, sm.subj)
+		}
+	}
+}
+
+// Returns the lowest parked message whose partition's member is waiting, or 0 if there is none.
+// Lock should be held.
+func (o *consumer) nextUnparkedForPartition(waiting map[string]struct{}) uint64 {
+	if len(o.pparked) == 0 || len(o.passign) == 0 || len(waiting) == 0 {
+		return 0
+	}
+	var next uint64
+	np := -1
+	for p, member := range o.passign {
+		if _, ok := waiting[member]; !ok {
+			continue
+		}
+		if _, ok := o.phandover[p]; ok {
+			continue
+		}
+		// Drop messages that are no longer pending from the front.
+		q := o.pqueue[p]
+		for len(q) > 0 {
+			if _, ok := o.pending[q[0]]; ok {
+				break
+			}
+			delete(o.pparked, q[0])
+			q = q[1:]
+		}
+		if len(q) == 0 {
+			delete(o.pqueue, p)
+			continue
+		}
+		o.pqueue[p] = q
+		if next == 0 || q[0] < next {
+			next, np = q[0], p
+		}
+	}
+	if next > 0 {
+		delete(o.pparked, next)
+		if q := o.pqueue[np][1:]; len(q) == 0 {
+			delete(o.pqueue, np)
+		} else {
+			o.pqueue[np] = q
+		}
+	}
+	return next
+}
+
+// Tracks a message delivered to the member its partition is assigned to, so that
+// the partition is only handed over once the member is done with it.
+// Lock should be held.
+func (o *consumer) trackPartitionInflight(seq uint64, subj string) {
+	if o.pinflight == nil {
+		o.pinflight = make(map[uint64]int)
+	}
+	o.pinflight[seq] = o.partition(subj)
+	// Acknowledged messages are pruned lazily, make sure we don't grow unbounded.
+	if len(o.pinflight) > 2*len(o.pending)+64 {
+		o.prunePartitionInflight()
+	}
+}
+
+// Removes delivered messages that are no longer being processed by a member,
+// because they were acknowledged, or will be redelivered.
+// Lock should be held.
+func (o *consumer) prunePartitionInflight() {
+	for seq := range o.pinflight {
+		if _, ok := o.pending[seq]; !ok || o.isHeld(seq) || o.onRedeliverQueue(seq) {
+			delete(o.pinflight, seq)
+		}
+	}
+}
+
+// Registers a member of a partitioned consumer, rebalancing partitions if it is new.
+// Members leave once they did not send a request within the priority timeout.
+// Lock should be held.
+func (o *consumer) joinPartitionMember(member string) {
+	if t, ok := o.pmembers[member]; ok {
+		t.Reset(o.cfg.PinnedTTL)
+		return
+	}
+	if o.pmembers == nil {
+		o.pmembers = make(map[string]*time.Timer)
+	}
+	o.pmembers[member] = time.AfterFunc(o.cfg.PinnedTTL, func() {
+		o.mu.Lock()
+		if _, ok := o.pmembers[member]; ok {
+			delete(o.pmembers, member)
+			o.rebalancePartitions()
+		}
+		o.mu.Unlock()
+		o.signalNewMessages()
+	})
+	o.rebalancePartitions()
+}
+
+// Assigns partitions round-robin over the members sorted by id, so that every
+// member can be handed a stable share regardless of the order they joined in.
+// A partition that moves to another member is handed over once the previous
+// member is done with the messages it was delivered, so that two members never
+// process the same partition at once. Until then its messages are parked.
+// Lock should be held.
+func (o *consumer) rebalancePartitions() {
+	members := make([]string, 0, len(o.pmembers))
+	for member := range o.pmembers {
+		members = append(members, member)
+	}
+	slices.Sort(members)
+	o.ptarget = make([]string, o.cfg.Partitions)
+	if len(members) > 0 {
+		for p := range o.ptarget {
+			o.ptarget[p] = members[p%len(members)]
+		}
+	}
+	if len(o.passign) == 0 {
+		o.passign = make([]string, o.cfg.Partitions)
+	}
+	now := time.Now()
+	for p, member := range o.ptarget {
+		if o.passign[p] == member {
+			delete(o.phandover, p)
+		} else if _, ok := o.phandover[p]; !ok {
+			if o.phandover == nil {
+				o.phandover = make(map[int]time.Time)
+			}
+			o.phandover[p] = now
+		}
+	}
+	o.checkPartitionHandovers()
+}
+
+// Completes handovers of partitions that are drained, or whose fencing timeout passed.
+// Since messages will be redelivered after the ack wait, we use that as the timeout.
+// Lock should be held.
+func (o *consumer) checkPartitionHandovers() {
+	if len(o.phandover) == 0 {
+		return
+	}
+	o.prunePartitionInflight()
+	inflight := make(map[int]bool)
+	for _, p := range o.pinflight {
+		inflight[p] = true
+	}
+	now, fence := time.Now(), o.cfg.AckWait
+	var next time.Duration
+	for p, start := range o.phandover {
+		if !inflight[p] || now.Sub(start) >= fence {
+			o.passign[p] = o.ptarget[p]
+			delete(o.phandover, p)
+		} else if wait := fence - now.Sub(start); next == 0 || wait < next {
+			next = wait
+		}
+	}
+	stopAndClearTimer(&o.phtmr)
+	if next > 0 {
+		o.phtmr = time.AfterFunc(next, o.signalNewMessages)
+	} else {
+		o.phandover = nil
+	}
+}
+
+// Lock should be held.
+func (o *consumer) clearPartitionMembers() {
+	for _, t := range o.pmembers {
+		t.Stop()
+	}
+	stopAndClearTimer(&o.phtmr)
+	o.pmembers, o.passign, o.ptarget, o.phandover = nil, nil, nil, nil
+}
+
+// Returns the partitions assigned to each member.
+// Lock should be held.
+func (o *consumer) partitionAssignments() map[string][]int {
+	var assignments map[string][]int
+	for p, member := range o.passign {
+		if member == _EMPTY_ {
+			continue
+		}
+		if assignments == nil {
+			assignments = make(map[string][]int, len(o.pmembers))
+		}
+		assignments[member] = append(assignments[member], p)
+	}
+	return assignments
+}
+
 // Will check for expiration and lack of interest on waiting requests.
 // Will also do any heartbeats and return the next expiration or HB interval.
 func (o *consumer) processWaiting(eos bool) (int, int, int, time.Time) {
@@ -4295,11 +5697,16 @@ func (o *consumer) checkAckFloor() {
 				psseq = ss.FirstSeq - 1
 			}
 		} else {
+			// Messages delivered out of order for their ordering key have no delivery sequence.
+			if pdseq == 0 {
+				pdseq = o.adflr + 1
+			}
 			// Since this was set via the pending, we should not include
 			// it directly but set floors to -1.
 			psseq, pdseq = psseq-1, pdseq-1
 		}
 		o.asflr, o.adflr = psseq, pdseq
+		o.holdAckFloor()
 	}
 }
 
@@ -4474,6 +5881,11 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			goto waitForMsgs
 		}
 
+		// If we are throttled by our throughput limits wait until we can deliver again.
+		if o.throttleRemaining() > 0 {
+			goto waitForMsgs
+		}
+
 		// Grab our next msg.
 		pmsg, dc, err = o.getNextMsg()
 
@@ -4489,7 +5901,11 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			if err == ErrStoreEOF {
 				o.checkNumPendingOnEOF()
 			}
-			if err == ErrStoreMsgNotFound || err == errDeletedMsg || err == ErrStoreEOF || err == errMaxAckPending {
+			// Once a bounded consumer is complete, release any pull requests.
+			if (err == errStopReached || err == ErrStoreEOF) && o.isComplete() {
+				o.processComplete()
+			}
+			if err == ErrStoreMsgNotFound || err == errDeletedMsg || err == ErrStoreEOF || err == errMaxAckPending || err == errStopReached {
 				goto waitForMsgs
 			} else if err == errPartialCache {
 				s.Warnf("Unexpected partial cache error looking up message for consumer '%s > %s > %s'",
@@ -4506,6 +5922,9 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 		// Update our cached num pending here first.
 		if dc == 1 {
 			o.npc--
+			if len(o.cfg.HeaderFilters) > 0 {
+				o.hfpend.Delete(pmsg.seq)
+			}
 		}
 		// Pre-calculate ackReply
 		ackReply = o.ackReply(pmsg.seq, o.dseq, dc, pmsg.ts, o.numPending())
@@ -4519,11 +5938,22 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 		// We do not include transport subject here since not generally known on client.
 		sz = len(pmsg.subj) + len(ackReply) + len(pmsg.hdr) + len(pmsg.msg)
 
+		// Check our message and byte throughput limits before we pick a requester,
+		// so we don't take a request we can not deliver to yet.
+		if o.throttled(sz) {
+			o.returnUndelivered(pmsg.seq, dc)
+			pmsg.returnToPool()
+			goto waitForMsgs
+		}
+
 		if o.isPushMode() {
 			dsubj = o.dsubj
-		} else if wr := o.nextWaiting(sz); wr != nil {
+		} else if wr := o.nextWaiting(sz, o.partitionMember(pmsg.subj)); wr != nil {
 			wrn, wrb = wr.n, wr.b
 			dsubj = wr.reply
+			if o.ptr != nil {
+				o.trackPartitionInflight(pmsg.seq, pmsg.subj)
+			}
 			if o.cfg.PriorityPolicy == PriorityPinnedClient {
 				// FIXME(jrm): Can we make this prettier?
 				if len(pmsg.hdr) == 0 {
@@ -4545,26 +5975,25 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 				wr.hbt = time.Now().Add(wr.hb)
 			}
 		} else {
-			// We will redo this one as long as this is not a redelivery.
-			// Need to also test that this is not going backwards since if
-			// we fail to deliver we can end up here from rdq but we do not
-			// want to decrement o.sseq if that is the case.
-			if dc == 1 && pmsg.seq == o.sseq-1 {
-				o.sseq--
-				o.npc++
-			} else if !o.onRedeliverQueue(pmsg.seq) {
-				// We are not on the rdq so decrement the delivery count
-				// and add it back.
-				o.decDeliveryCount(pmsg.seq)
-				o.addToRedeliverQueue(pmsg.seq)
-			}
+			o.returnUndelivered(pmsg.seq, dc)
 			pmsg.returnToPool()
 			goto waitForMsgs
 		}
+		// Only now that we have someone to deliver to does it count against our throughput limits.
+		o.takeThroughput(sz)
 
 		// If we are in a replay scenario and have not caught up check if we need to delay here.
 		if o.replay && lts > 0 {
-			if delay = time.Duration(pmsg.ts - lts); delay > time.Millisecond {
+			delay = time.Duration(pmsg.ts - lts)
+			if speed := o.cfg.ReplaySpeed; speed > 0 {
+				// Very slow speeds would overflow, in which case wait as long as we can.
+				if d := float64(delay) / speed; d < math.MaxInt64 {
+					delay = time.Duration(d)
+				} else {
+					delay = math.MaxInt64
+				}
+			}
+			if delay > time.Millisecond {
 				o.mu.Unlock()
 				select {
 				case <-qch:
@@ -4632,6 +6061,12 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			}
 		}
 
+		// If throttled wake up once we are allowed to deliver again.
+		var rlExp <-chan time.Time
+		if d := o.throttleRemaining(); d > 0 {
+			rlExp = time.NewTimer(d).C
+		}
+
 		// We will wait here for new messages to arrive.
 		mch, odsubj := o.mch, o.cfg.DeliverSubject
 		o.mu.Unlock()
@@ -4649,6 +6084,8 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			o.mu.Lock()
 			o.processWaiting(true)
 			o.mu.Unlock()
+		case <-rlExp:
+			// Throttle has passed.
 		case <-hbc:
 			if o.isActive() {
 				o.mu.RLock()
@@ -4671,9 +6108,41 @@ func (o *consumer) sendIdleHeartbeat(subj string) {
 		addOn := fmt.Appendf(nil, "%s: %s\r\n\r\n", JSConsumerStalled, fcp)
 		hdr = append(hdr[:len(hdr)-LEN_CR_LF], []byte(addOn)...)
 	}
+	if d := o.throttleRemaining(); d > 0 {
+		// Let them know we are holding back due to our throughput limits.
+		addOn := fmt.Appendf(nil, "%s: %v\r\n\r\n", JSConsumerRateLimited, d)
+		hdr = append(hdr[:len(hdr)-LEN_CR_LF], []byte(addOn)...)
+	}
 	o.outq.send(newJSPubMsg(subj, _EMPTY_, _EMPTY_, hdr, nil, nil, 0))
 }
 
+// Put back a message we got from getNextMsg but did not deliver, so it will be retried.
+// Lock should be held.
+func (o *consumer) returnUndelivered(seq, dc uint64) {
+	// Released messages go back to the front of the ones waiting for delivery.
+	if dc == 1 && o.oheld.Exists(seq) {
+		o.oready = append([]uint64{seq}, o.oready...)
+		o.npc++
+		return
+	}
+	// We will redo this one as long as this is not a redelivery.
+	// Need to also test that this is not going backwards since if
+	// we fail to deliver we can end up here from rdq but we do not
+	// want to decrement o.sseq if that is the case.
+	if dc == 1 && seq == o.sseq-1 {
+		o.sseq--
+		o.npc++
+		if len(o.cfg.HeaderFilters) > 0 {
+			o.hfpend.Insert(seq)
+		}
+	} else if !o.onRedeliverQueue(seq) {
+		// We are not on the rdq so decrement the delivery count
+		// and add it back.
+		o.decDeliveryCount(seq)
+		o.addToRedeliverQueue(seq)
+	}
+}
+
 func (o *consumer) ackReply(sseq, dseq, dc uint64, ts int64, pending uint64) string {
 	return fmt.Sprintf(o.ackReplyT, dc, sseq, dseq, ts, pending)
 }
@@ -4697,7 +6166,7 @@ func (o *consumer) checkNumPending() uint64 {
 		var state StreamState
 		o.mset.store.FastState(&state)
 		npc := o.numPending()
-		if o.sseq > state.LastSeq && npc > 0 || npc > state.Msgs {
+		if o.sseq > state.LastSeq && npc > uint64(o.oheld.Size()) || npc > state.Msgs || o.npcstale {
 			// Re-calculate.
 			o.streamNumPending()
 		}
@@ -4722,17 +6191,55 @@ func (o *consumer) checkNumPendingOnEOF() {
 	}
 	var state StreamState
 	o.mset.store.FastState(&state)
-	if o.sseq > state.LastSeq && o.npc != 0 {
+	if held := int64(o.oheld.Size()); o.sseq > state.LastSeq && o.npc != held {
 		// We know here we can reset our running state for num pending.
-		o.npc, o.npf = 0, state.LastSeq
+		o.npc, o.npf = held, state.LastSeq
+		o.hfpend.Empty()
 	}
 }
 
-// Call into streamNumPending after acquiring the consumer lock.
-func (o *consumer) streamNumPendingLocked() uint64 {
+// Called after a batch of messages were removed from the stream.
+// Without header filters we can ask the store, with them we drop the removed
+// messages from the ones we track instead of walking the stream again.
+// Lock should not be held.
+func (o *consumer) streamNumPendingAfterRemoval() {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	return o.streamNumPending()
+	if len(o.cfg.HeaderFilters) == 0 || o.mset == nil || o.mset.store == nil {
+		o.streamNumPending()
+		return
+	}
+	o.pruneHeaderFilteredPending()
+}
+
+// Drops the messages no longer in the stream from the ones pending that match our header filters.
+// Removals below the first sequence are cheap to tell apart, only if the stream has interior
+// deletes do we need to check the ones left with the store.
+// Lock should be held.
+func (o *consumer) pruneHeaderFilteredPending() {
+	store := o.mset.store
+	var ss StreamState
+	store.FastState(&ss)
+	floor := max(ss.FirstSeq, o.sseq)
+
+	var removed []uint64
+	var smv StoreMsg
+	o.hfpend.Range(func(seq uint64) bool {
+		if seq < floor {
+			removed = append(removed, seq)
+		} else if ss.NumDeleted > 0 {
+			if _, err := store.LoadMsg(seq, &smv); err == errDeletedMsg || err == ErrStoreMsgNotFound {
+				removed = append(removed, seq)
+			}
+		} else {
+			return false
+		}
+		return true
+	})
+	for _, seq := range removed {
+		o.hfpend.Delete(seq)
+	}
+	o.npc = int64(o.hfpend.Size() + o.oheld.Size())
 }
 
 // Will force a set from the stream store of num pending.
@@ -4741,10 +6248,21 @@ func (o *consumer) streamNumPendingLocked() uint64 {
 func (o *consumer) streamNumPending() uint64 {
 	if o.mset == nil || o.mset.store == nil {
 		o.npc, o.npf = 0, 0
+		o.hfpend.Empty()
 		return 0
 	}
-	npc, npf := o.calculateNumPending()
-	o.npc, o.npf = int64(npc), npf
+	if len(o.cfg.HeaderFilters) > 0 {
+		// From here on we keep track of these as messages come and go.
+		o.hfpend, o.npf = o.calculateHeaderFilteredPending()
+		o.npc = int64(o.hfpend.Size())
+	} else {
+		npc, npf := o.calculateNumPending()
+		o.npc, o.npf = int64(npc), npf
+		o.hfpend.Empty()
+	}
+	// Messages held back for their ordering key are behind us, but were not delivered yet.
+	o.npc += int64(o.oheld.Size())
+	o.npcstale = false
 	return o.numPending()
 }
 
@@ -4756,16 +6274,75 @@ func (o *consumer) calculateNumPending() (npc, npf uint64) {
 		return 0, 0
 	}
 
+	npc, npf = o.calculateNumPendingFrom(o.sseq)
+	// Bounded consumers don't count messages past their stop.
+	if stop := o.stopSeq(); stop > 0 {
+		if stop < o.sseq {
+			return 0, npf
+		}
+		past, _ := o.calculateNumPendingFrom(stop + 1)
+		npc -= min(past, npc)
+	}
+	return npc, npf
+}
+
+// Calculates num pending starting at the given stream sequence.
+// At least RLock should be held.
+func (o *consumer) calculateNumPendingFrom(sseq uint64) (npc, npf uint64) {
 	isLastPerSubject := o.cfg.DeliverPolicy == DeliverLastPerSubject
 	filters, subjf := o.filters, o.subjf
 
 	if filters != nil {
-		return o.mset.store.NumPendingMulti(o.sseq, filters, isLastPerSubject)
+		npc, npf = o.mset.store.NumPendingMulti(sseq, filters, isLastPerSubject)
 	} else if len(subjf) > 0 {
 		filter := subjf[0].subject
-		return o.mset.store.NumPending(o.sseq, filter, isLastPerSubject)
+		npc, npf = o.mset.store.NumPending(sseq, filter, isLastPerSubject)
+	} else {
+		npc, npf = o.mset.store.NumPending(sseq, _EMPTY_, isLastPerSubject)
+	}
+	// Messages held back by a schedule are not delivered, their released copies are.
+	if o.schedules && !isLastPerSubject {
+		npc -= min(o.mset.held.numPending(sseq, o.isFilteredMatch), npc)
+	}
+	return npc, npf
+}
+
+// Header filters require looking at every message, so we walk the messages matching
+// our filter subjects from our next sequence up to our stop, if bounded, and collect
+// the ones matching our header filters. This is only done when we become leader or
+// are reset, afterwards we keep track of them as messages are stored, delivered and removed.
+// Lock should be held.
+func (o *consumer) calculateHeaderFilteredPending() (avl.SequenceSet, uint64) {
+	var hfpend avl.SequenceSet
+	store := o.mset.store
+	var ss StreamState
+	store.FastState(&ss)
+	last := ss.LastSeq
+	if stop := o.stopSeq(); stop > 0 {
+		last = min(last, stop)
+	}
+	var smv StoreMsg
+	for seq := o.sseq; seq <= last; seq++ {
+		var sm *StoreMsg
+		var err error
+		if o.filters != nil {
+			sm, seq, err = store.LoadNextMsgMulti(o.filters, seq, &smv)
+		} else if len(o.subjf) > 0 {
+			sm, seq, err = store.LoadNextMsg(o.subjf[0].subject, o.subjf[0].hasWildcard, seq, &smv)
+		} else {
+			sm, seq, err = store.LoadNextMsg(_EMPTY_, false, seq, &smv)
+		}
+		if err != nil || seq > last {
+			break
+		}
+		if o.schedules && isScheduledMsg(sm.hdr) {
+			continue
+		}
+		if o.isHeaderFilteredMatch(sm.hdr) {
+			hfpend.Insert(seq)
+		}
 	}
-	return o.mset.store.NumPending(o.sseq, _EMPTY_, isLastPerSubject)
+	return hfpend, ss.LastSeq
 }
 
 func convertToHeadersOnly(pmsg *jsPubMsg) {
@@ -4807,6 +6384,8 @@ func (o *consumer) deliverMsg(dsubj, ackReply string, pmsg *jsPubMsg, dc uint64,
 
 	pmsg.dsubj, pmsg.reply, pmsg.o = dsubj, ackReply, o
 	psz := pmsg.size()
+	o.dmsgs++
+	o.dbytes += uint64(psz)
 
 	if o.maxpb > 0 {
 		o.pbytes += psz
@@ -4818,14 +6397,27 @@ func (o *consumer) deliverMsg(dsubj, ackReply string, pmsg *jsPubMsg, dc uint64,
 	// Cant touch pmsg after this sending so capture what we need.
 	seq, ts := pmsg.seq, pmsg.ts
 
+	// Messages held back for their ordering key are delivered out of order,
+	// so are tracked without a delivery sequence to keep our ack floors in order.
+	pdseq := dseq
+	if dc == 1 && o.cfg.OrderingKey != nil && o.trackOrderingKey(seq, pmsg.subj, pmsg.hdr) {
+		pdseq = 0
+	}
+
 	// Update delivered first.
 	o.updateDelivered(dseq, seq, dc, ts)
+	if dc == 1 {
+		o.recordDeliveryLatency(ts)
+	}
 
 	// Send message.
 	o.outq.send(pmsg)
 
 	if ap == AckExplicit || ap == AckAll {
-		o.trackPending(seq, dseq)
+		o.trackPending(seq, pdseq)
+		if o.isPullMode() {
+			o.trackDeliverSubject(seq, dsubj)
+		}
 	} else if ap == AckNone {
 		o.adflr = dseq
 		o.asflr = seq
@@ -4957,6 +6549,117 @@ func (o *consumer) trackPending(sseq, dseq uint64) {
 	}
 }
 
+// Remember where a pending message was delivered to.
+// Lock should be held.
+func (o *consumer) trackDeliverSubject(sseq uint64, dsubj string) {
+	if o.pdsubj == nil {
+		o.pdsubj = make(map[uint64]string)
+	}
+	// Entries are not removed when a message is acked, so prune once we are tracking too many.
+	if len(o.pdsubj) >= 2*len(o.pending)+1024 {
+		for seq := range o.pdsubj {
+			if _, ok := o.pending[seq]; !ok {
+				delete(o.pdsubj, seq)
+			}
+		}
+	}
+	o.pdsubj[sseq] = dsubj
+}
+
+// Returns the pending messages ordered by stream sequence, starting at offset and
+// returning at most limit entries, together with the total number of pending messages.
+func (o *consumer) pendingAcks(offset, limit int) ([]*ConsumerPendingAck, int) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	seqs := make([]uint64, 0, len(o.pending))
+	for seq := range o.pending {
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	total := len(seqs)
+	if offset > total {
+		offset = total
+	}
+	seqs = seqs[offset:]
+	if len(seqs) > limit {
+		seqs = seqs[:limit]
+	}
+
+	pending := make([]*ConsumerPendingAck, 0, len(seqs))
+	for _, seq := range seqs {
+		p := o.pending[seq]
+		pa := &ConsumerPendingAck{
+			Sequence:     seq,
+			ConsumerSeq:  p.Sequence,
+			NumDelivered: o.rdc[seq] + 1,
+			Delivered:    time.Unix(0, p.Timestamp).UTC(),
+			Held:         o.isHeld(seq),
+		}
+		if !pa.Held {
+			if o.isPullMode() {
+				pa.DeliverSubject = o.pdsubj[seq]
+			} else {
+				pa.DeliverSubject = o.cfg.DeliverSubject
+			}
+		}
+		pending = append(pending, pa)
+	}
+	return pending, total
+}
+
+// Redeliver, skip or terminate a pending message on behalf of the client.
+// Acks are replicated as usual. For redeliveries the message is marked as expired,
+// so a new leader will redeliver it as well.
+func (o *consumer) processPendingAction(sseq uint64, action ConsumerPendingAction, reason string) error {
+	o.mu.Lock()
+	p, ok := o.pending[sseq]
+	if !ok {
+		o.mu.Unlock()
+		return fmt.Errorf("stream sequence %d is not pending", sseq)
+	}
+	dseq, dc := p.Sequence, o.rdc[sseq]+1
+
+	switch action {
+	case ConsumerPendingRedeliver:
+		// Parked messages are delivered once their partition's member is waiting.
+		if o.isHeld(sseq) {
+			o.mu.Unlock()
+			return fmt.Errorf("stream sequence %d is held back and was not delivered yet", sseq)
+		}
+		// Like a NAK with a delay, older than any ack wait or backoff is expired now.
+		wait := o.cfg.AckWait
+		for _, d := range o.cfg.BackOff {
+			wait = max(wait, d)
+		}
+		p.Timestamp = time.Now().Add(-wait).UnixNano()
+		// Update store system which will update followers as well.
+		o.updateDelivered(dseq, sseq, dc, p.Timestamp)
+		if !o.onRedeliverQueue(sseq) {
+			o.addToRedeliverQueue(sseq)
+		}
+		o.signalNewMessages()
+		o.mu.Unlock()
+	case ConsumerPendingSkip:
+		o.mu.Unlock()
+		o.processAckMsg(sseq, dseq, dc, _EMPTY_, false)
+	case ConsumerPendingTerm:
+		dlReason := deadLetterTerminatedReason
+		if reason != _EMPTY_ {
+			dlReason += ": " + reason
+		}
+		dl := o.deadLetter(sseq, &deadLetterPending{dseq: dseq, dc: dc, reason: dlReason, term: true, treason: reason})
+		o.mu.Unlock()
+		if !dl {
+			o.processTerm(sseq, dseq, dc, reason, _EMPTY_)
+		}
+	default:
+		o.mu.Unlock()
+		return fmt.Errorf("unknown action %q", action)
+	}
+	return nil
+}
+
 // Credit back a failed delivery.
 // lock should be held.
 func (o *consumer) creditWaitingRequest(reply string) {
@@ -5080,7 +6783,7 @@ func (o *consumer) checkPending() {
 		return
 	}
 
-	var shouldUpdateState bool
+	var shouldUpdateState, released bool
 	var state StreamState
 	mset.store.FastState(&state)
 	fseq := state.FirstSeq
@@ -5108,6 +6811,9 @@ func (o *consumer) checkPending() {
 			delete(o.pending, seq)
 			delete(o.rdc, seq)
 			o.removeFromRedeliverQueue(seq)
+			if o.releaseOrderingKey(seq) {
+				released = true
+			}
 			shouldUpdateState = true
 			// Check if we need to move ack floors.
 			if seq > o.asflr {
@@ -5118,6 +6824,10 @@ func (o *consumer) checkPending() {
 			}
 			continue
 		}
+		// Parked messages were never delivered, so they can't expire.
+		if o.isHeld(seq) {
+			continue
+		}
 		elapsed, deadline := now-p.Timestamp, ttl
 		if len(o.cfg.BackOff) > 0 {
 			// This is ok even if o.rdc is nil, we would get dc == 0, which is what we want.
@@ -5164,6 +6874,8 @@ func (o *consumer) checkPending() {
 			}
 		}
 		o.signalNewMessages()
+	} else if released {
+		o.signalNewMessages()
 	}
 
 	if len(o.pending) > 0 {
@@ -5173,9 +6885,10 @@ func (o *consumer) checkPending() {
 		o.stopAndClearPtmr()
 		o.rdq = nil
 		o.rdqi.Empty()
-		o.pending = nil
+		o.pending, o.pdsubj = nil, nil
 		// Mimic behavior in processAckMsg when pending is empty.
 		o.adflr, o.asflr = o.dseq-1, o.sseq-1
+		o.holdAckFloor()
 	}
 
 	// Update our state if needed.
@@ -5531,10 +7244,16 @@ func (o *consumer) purge(sseq uint64, slseq uint64, isWider bool) {
 		}
 	}
 
+	// Messages held back for their ordering key could have been purged as well.
+	if o.cfg.OrderingKey != nil {
+		o.rebuildOrderingKeys()
+	}
+
 	// This means we can reset everything at this point.
 	if len(o.pending) == 0 {
 		o.pending, o.rdc = nil, nil
 		o.adflr, o.asflr = o.dseq-1, o.sseq-1
+		o.holdAckFloor()
 	}
 
 	// We need to remove all those being queued for redelivery under o.rdq
@@ -5557,6 +7276,69 @@ func (o *consumer) purge(sseq uint64, slseq uint64, isWider bool) {
 	}
 }
 
+// reset moves the consumer so that sseq is the next stream sequence delivered,
+// dropping all pending and redelivery state. The delivery sequence keeps increasing,
+// so acks for messages delivered before the reset are ignored.
+// Lock should be held.
+func (o *consumer) reset(sseq uint64) error {
+	if o.closed || o.mset == nil {
+		return errConsumerClosed
+	}
+	dseq := o.dseq
+	o.applyReset(sseq, dseq)
+	if err := o.resetStoreState(sseq, dseq); err != nil {
+		return err
+	}
+	o.sendResetAdvisoryLocked(sseq)
+	o.signalNewMessages()
+	return nil
+}
+
+// proposeReset is the clustered version of reset. The reset is applied by all replicas,
+// including us, once committed, after which we respond to the request.
+// Lock should be held.
+func (o *consumer) proposeReset(sseq uint64, ci *ClientInfo, subject, reply string) error {
+	if o.closed || o.mset == nil || o.node == nil {
+		return errConsumerClosed
+	}
+	o.propose(encodeConsumerReset(&consumerReset{
+		Client:   ci,
+		Subject:  subject,
+		Reply:    reply,
+		Sequence: sseq,
+		DSeq:     o.dseq,
+	}))
+	return nil
+}
+
+// Lock should be held.
+func (o *consumer) applyReset(sseq, dseq uint64) {
+	o.sseq, o.dseq = sseq, dseq
+	o.asflr, o.adflr = sseq-1, dseq-1
+	o.rsdflr = dseq - 1
+	o.pending, o.pdsubj, o.rdc = nil, nil, nil
+	o.rdq = nil
+	o.rdqi.Empty()
+	o.okeys, o.okseq, o.oready = nil, nil, nil
+	o.oheld.Empty()
+	o.oout.Empty()
+	o.pparked, o.pqueue, o.pinflight = nil, nil, nil
+	o.lss = nil
+	if o.chkflr > sseq {
+		o.chkflr = sseq
+	}
+	o.streamNumPending()
+}
+
+// Lock should be held.
+func (o *consumer) resetStoreState(sseq, dseq uint64) error {
+	if o.store == nil {
+		return nil
+	}
+	floor := SequencePair{Consumer: dseq - 1, Stream: sseq - 1}
+	return o.store.Reset(&ConsumerState{Delivered: floor, AckFloor: floor})
+}
+
 func stopAndClearTimer(tp **time.Timer) {
 	if *tp == nil {
 		return
@@ -5665,6 +7447,8 @@ func (o *consumer) stopWithFlags(dflag, sdflag, doSignal, advisory bool) error {
 	o.stopAndClearPtmr()
 	stopAndClearTimer(&o.dtmr)
 	stopAndClearTimer(&o.gwdtmr)
+	stopAndClearTimer(&o.sttmr)
+	o.clearPartitionMembers()
 	delivery := o.cfg.DeliverSubject
 	o.waiting = nil
 	// Break us out of the readLoop.
@@ -5853,7 +7637,17 @@ func (o *consumer) decStreamPending(sseq uint64, subj string) {
 
 	// Update our cached num pending only if we think deliverMsg has not done so.
 	if sseq >= o.sseq && o.isFilteredMatch(subj) {
-		o.npc--
+		if len(o.cfg.HeaderFilters) > 0 {
+			// We know which ones matched our header filters, and were not past our stop.
+			if o.hfpend.Delete(sseq) {
+				o.npc--
+			}
+		} else if o.cfg.OptStopTime != nil {
+			// The message is gone, so we can't tell if it was past our stop time.
+			o.npcstale = true
+		} else if !o.isPastStop(sseq, 0) {
+			o.npc--
+		}
 	}
 
 	// Check if this message was pending.
@@ -5923,8 +7717,13 @@ func (o *consumer) processStreamSignal(_ *subscription, _ *client, _ *Account, s
 	var le = binary.LittleEndian
 	seq := le.Uint64(seqb)
 
-	if seq > o.npf {
-		o.npc++
+	if seq > o.npf && !o.isPastStop(seq, time.Now().UnixNano()) {
+		if len(o.cfg.HeaderFilters) == 0 {
+			o.npc++
+		} else if o.isHeaderFilteredSignalMatch(seq, seqb[8:]) {
+			o.hfpend.Insert(seq)
+			o.npc++
+		}
 	}
 	if seq < o.sseq {
 		return
@@ -6018,13 +7817,18 @@ func (o *consumer) checkStateForInterestStream(ss *StreamState) error {
 		filter, wc = subjf[0].subject, subjf[0].hasWildcard
 	}
 	chkfloor := o.chkflr
+	// Messages skipped by our header filters are never delivered, so are not reflected in the stored ack floor.
+	var hdrflr uint64
+	if len(o.cfg.HeaderFilters) > 0 && o.isLeader() {
+		hdrflr = o.asflr
+	}
 	o.mu.RUnlock()
 
 	if err != nil {
 		return err
 	}
 
-	asflr := state.AckFloor.Stream
+	asflr := max(state.AckFloor.Stream, hdrflr)
 	// Protect ourselves against rolling backwards.
 	if asflr&(1<<63) != 0 {
 		return errAckFloorInvalid
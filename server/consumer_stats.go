@@ -0,0 +1,195 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math/bits"
+	"time"
+)
+
+// ConsumerLatencyStats holds delivery and ack histograms for a consumer.
+// These are only tracked when LatencyStats is set in the consumer config,
+// and are kept in memory by the consumer leader only.
+type ConsumerLatencyStats struct {
+	// Time from the message being stored to its first delivery.
+	Delivery LatencyPercentiles `json:"delivery"`
+	// Time from the last delivery of a message to its ack.
+	Ack LatencyPercentiles `json:"ack"`
+	// Number of redeliveries needed before a message was acked.
+	Redeliveries CountPercentiles `json:"redeliveries"`
+	// Since is when tracking started on this leader.
+	Since time.Time `json:"since"`
+}
+
+// LatencyPercentiles summarizes a latency histogram.
+type LatencyPercentiles struct {
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// CountPercentiles summarizes a histogram of counts.
+type CountPercentiles struct {
+	Count uint64 `json:"count"`
+	P50   uint64 `json:"p50"`
+	P90   uint64 `json:"p90"`
+	P99   uint64 `json:"p99"`
+	Max   uint64 `json:"max"`
+}
+
+// Number of linear sub-buckets per power of two, gives a relative error of at most 1/histSubBuckets.
+const (
+	histSubBits    = 3
+	histSubBuckets = 1 << histSubBits
+	histBuckets    = (64-histSubBits)*histSubBuckets + histSubBuckets
+)
+
+// histogram is a fixed size histogram with exponentially growing buckets,
+// each power of two is split into histSubBuckets linear buckets.
+// Not safe for concurrent use, callers hold the consumer lock.
+type histogram struct {
+	counts [histBuckets]uint64
+	total  uint64
+	max    uint64
+}
+
+// Returns the bucket for v.
+func histBucket(v uint64) int {
+	if v < 2*histSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - histSubBits - 1
+	return (shift+1)*histSubBuckets + int(v>>shift) - histSubBuckets
+}
+
+// Returns the highest value that falls into bucket b.
+func histBucketUpper(b int) uint64 {
+	if b < 2*histSubBuckets {
+		return uint64(b)
+	}
+	shift := b/histSubBuckets - 1
+	lower := uint64(b%histSubBuckets+histSubBuckets) << shift
+	return lower + (1 << shift) - 1
+}
+
+func (h *histogram) record(v uint64) {
+	h.counts[histBucket(v)]++
+	h.total++
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Returns the value at or below which the given fraction of samples fall.
+// The result is the upper bound of the matching bucket, capped at the max seen.
+func (h *histogram) percentile(p float64) uint64 {
+	if h.total == 0 {
+		return 0
+	}
+	target := uint64(p*float64(h.total) + 0.5)
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for b, c := range h.counts {
+		if seen += c; seen >= target {
+			return min(histBucketUpper(b), h.max)
+		}
+	}
+	return h.max
+}
+
+func (h *histogram) latencies() LatencyPercentiles {
+	return LatencyPercentiles{
+		Count: h.total,
+		P50:   time.Duration(h.percentile(0.50)),
+		P90:   time.Duration(h.percentile(0.90)),
+		P99:   time.Duration(h.percentile(0.99)),
+		Max:   time.Duration(h.max),
+	}
+}
+
+func (h *histogram) counters() CountPercentiles {
+	return CountPercentiles{
+		Count: h.total,
+		P50:   h.percentile(0.50),
+		P90:   h.percentile(0.90),
+		P99:   h.percentile(0.99),
+		Max:   h.max,
+	}
+}
+
+// consumerStats tracks the histograms behind ConsumerLatencyStats.
+type consumerStats struct {
+	delivery     histogram
+	ack          histogram
+	redeliveries histogram
+	since        time.Time
+}
+
+// Enable or disable latency tracking based on our config.
+// Lock should be held.
+func (o *consumer) setLatencyStats(enabled bool) {
+	if !enabled {
+		o.lstats = nil
+	} else if o.lstats == nil {
+		o.lstats = &consumerStats{since: time.Now().UTC()}
+	}
+}
+
+// Record the time from store to first delivery.
+// Lock should be held.
+func (o *consumer) recordDeliveryLatency(ts int64) {
+	if o.lstats == nil {
+		return
+	}
+	if d := time.Now().UnixNano() - ts; d > 0 {
+		o.lstats.delivery.record(uint64(d))
+	} else {
+		o.lstats.delivery.record(0)
+	}
+}
+
+// Record the time from delivery to ack, and how many redeliveries it took.
+// Lock should be held.
+func (o *consumer) recordAckLatency(delivered int64, dc uint64) {
+	if o.lstats == nil {
+		return
+	}
+	if d := time.Now().UnixNano() - delivered; d > 0 {
+		o.lstats.ack.record(uint64(d))
+	} else {
+		o.lstats.ack.record(0)
+	}
+	if dc > 0 {
+		dc--
+	}
+	o.lstats.redeliveries.record(dc)
+}
+
+// Returns the latency stats if tracked.
+// Lock should be held.
+func (o *consumer) latencyStats() *ConsumerLatencyStats {
+	if o.lstats == nil {
+		return nil
+	}
+	return &ConsumerLatencyStats{
+		Delivery:     o.lstats.delivery.latencies(),
+		Ack:          o.lstats.ack.latencies(),
+		Redeliveries: o.lstats.redeliveries.counters(),
+		Since:        o.lstats.since,
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	// Buckets must be contiguous and cover the full range.
+	for b := 1; b < histBuckets; b++ {
+		require_Equal(t, histBucket(histBucketUpper(b-1)+1), b)
+		require_Equal(t, histBucket(histBucketUpper(b)), b)
+	}
+	require_Equal(t, histBucket(math.MaxUint64), histBuckets-1)
+	require_Equal(t, histBucketUpper(histBuckets-1), uint64(math.MaxUint64))
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	var h histogram
+	require_Equal(t, h.percentile(0.5), 0)
+
+	for v := uint64(1); v <= 1000; v++ {
+		h.record(v)
+	}
+	require_Equal(t, h.total, 1000)
+	require_Equal(t, h.max, 1000)
+	require_Equal(t, h.percentile(1), 1000)
+
+	// Results are within the relative error of a bucket.
+	for _, test := range []struct {
+		p        float64
+		expected uint64
+	}{{0.5, 500}, {0.9, 900}, {0.99, 990}} {
+		v := h.percentile(test.p)
+		if v < test.expected || v > test.expected+test.expected/histSubBuckets {
+			t.Fatalf("Expected p%v to be close to %d, got %d", test.p*100, test.expected, v)
+		}
+	}
+
+	c := h.counters()
+	require_Equal(t, c.Count, 1000)
+	require_Equal(t, c.Max, 1000)
+	require_True(t, c.P50 <= c.P90 && c.P90 <= c.P99 && c.P99 <= c.Max)
+}
@@ -66,6 +66,7 @@ const (
 	serverPingReqSubj         = "$SYS.REQ.SERVER.PING.%s"
 	serverStatsPingReqSubj    = "$SYS.REQ.SERVER.PING"             // use $SYS.REQ.SERVER.PING.STATSZ instead
 	serverReloadReqSubj       = "$SYS.REQ.SERVER.%s.RELOAD"        // with server ID
+	serverJSKeyRotateReqSubj  = "$SYS.REQ.SERVER.%s.JS.KEY.ROTATE" // with server ID
 	leafNodeConnectEventSubj  = "$SYS.ACCOUNT.%s.LEAFNODE.CONNECT" // for internal use only
 	remoteLatencyEventSubj    = "$SYS.LATENCY.M2.%s"
 	inboxRespSubj             = "$SYS._INBOX.%s.%s"
@@ -265,6 +266,7 @@ const (
 	JetStreamEnabled     ServerCapability = 1 << iota // Server had JetStream enabled.
 	BinaryStreamSnapshot                              // New stream snapshot capability.
 	AccountNRG                                        // Move NRG traffic out of system account.
+	RaftPreVote                                       // NRG pre-vote before starting an election.
 )
 
 // Set JetStream capability.
@@ -301,6 +303,16 @@ func (si *ServerInfo) AccountNRG() bool {
 	return si.Flags&AccountNRG != 0
 }
 
+// Set NRG pre-vote capability.
+func (si *ServerInfo) SetRaftPreVote() {
+	si.Flags |= RaftPreVote
+}
+
+// RaftPreVote indicates whether or not we understand NRG pre-vote requests.
+func (si *ServerInfo) RaftPreVote() bool {
+	return si.Flags&RaftPreVote != 0
+}
+
 // ClientInfo is detailed information about the client forming a connection.
 type ClientInfo struct {
 	Start      *time.Time    `json:"start,omitempty"`
@@ -527,6 +539,7 @@ RESET:
 						if s.accountNRGAllowed.Load() {
 							si.SetAccountNRG()
 						}
+						si.SetRaftPreVote()
 					}
 				}
 				var b []byte
@@ -1432,6 +1445,13 @@ func (s *Server) initEventTracking() {
 		return
 	}
 
+	// Listen for requests to rotate the JetStream encryption key.
+	subject = fmt.Sprintf(serverJSKeyRotateReqSubj, s.info.ID)
+	if _, err := s.sysSubscribe(subject, s.noInlineCallback(s.jsKeyRotate)); err != nil {
+		s.Errorf("Error setting up JetStream key rotation handler: %v", err)
+		return
+	}
+
 	// Client connection kick
 	subject = fmt.Sprintf(clientKickReqSubj, s.info.ID)
 	if _, err := s.sysSubscribe(subject, s.noInlineCallback(s.kickClient)); err != nil {
@@ -1699,6 +1719,7 @@ func (s *Server) remoteServerUpdate(sub *subscription, c *client, _ *Account, su
 		si.JetStreamEnabled(),
 		si.BinaryStreamSnapshot(),
 		accountNRG,
+		si.RaftPreVote(),
 	})
 	if oldInfo == nil || accountNRG != oldInfo.(nodeInfo).accountNRG {
 		// One of the servers we received statsz from changed its mind about
@@ -1753,6 +1774,7 @@ func (s *Server) processNewServer(si *ServerInfo) {
 				si.JetStreamEnabled(),
 				si.BinaryStreamSnapshot(),
 				si.AccountNRG(),
+				si.RaftPreVote(),
 			})
 		}
 	}
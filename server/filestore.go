@@ -42,6 +42,7 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
 	"github.com/minio/highwayhash"
 	"github.com/nats-io/nats-server/v2/server/avl"
 	"github.com/nats-io/nats-server/v2/server/stree"
@@ -69,6 +70,10 @@ type FileStoreConfig struct {
 	Cipher StoreCipher
 	// Compression is the algorithm to use when compressing.
 	Compression StoreCompression
+	// CompressionOpts are optional settings for the compression algorithm.
+	CompressionOpts *StoreCompressionOpts
+	// ColdStore is where sealed blocks are offloaded to, as configured by the stream's cold tier.
+	ColdStore ColdStore
 
 	// Internal reference to our server.
 	srv *Server
@@ -106,6 +111,7 @@ type StoreCompression uint8
 const (
 	NoCompression StoreCompression = iota
 	S2Compression
+	ZstdCompression
 )
 
 func (alg StoreCompression) String() string {
@@ -114,6 +120,8 @@ func (alg StoreCompression) String() string {
 		return "None"
 	case S2Compression:
 		return "S2"
+	case ZstdCompression:
+		return "Zstd"
 	default:
 		return "Unknown StoreCompression"
 	}
@@ -124,6 +132,8 @@ func (alg StoreCompression) MarshalJSON() ([]byte, error) {
 	switch alg {
 	case S2Compression:
 		str = "s2"
+	case ZstdCompression:
+		str = "zstd"
 	case NoCompression:
 		str = "none"
 	default:
@@ -140,6 +150,8 @@ func (alg *StoreCompression) UnmarshalJSON(b []byte) error {
 	switch str {
 	case "s2":
 		*alg = S2Compression
+	case "zstd":
+		*alg = ZstdCompression
 	case "none":
 		*alg = NoCompression
 	default:
@@ -148,6 +160,42 @@ func (alg *StoreCompression) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// StoreCompressionOpts are optional settings for the compression algorithm.
+type StoreCompressionOpts struct {
+	// Level is the zstd compression level, from 1 (fastest) to 22 (best). Zero uses the default level.
+	Level int `json:"level,omitempty"`
+	// Dictionary is a zstd dictionary, as trained by `zstd --train` on representative messages.
+	// It is needed to read back compressed blocks, so can't be changed once set.
+	Dictionary []byte `json:"dictionary,omitempty"`
+}
+
+// Returns true if both options are the same.
+func (opts *StoreCompressionOpts) equal(other *StoreCompressionOpts) bool {
+	if opts == nil || other == nil {
+		return opts == other
+	}
+	return opts.Level == other.Level && bytes.Equal(opts.Dictionary, other.Dictionary)
+}
+
+// Maximum zstd compression level.
+const maxZstdCompressionLevel = 22
+
+// validate checks the compression options, returning an error if invalid.
+func (opts *StoreCompressionOpts) validate() error {
+	if opts == nil {
+		return nil
+	}
+	if opts.Level < 0 || opts.Level > maxZstdCompressionLevel {
+		return fmt.Errorf("compression level must be between 0 and %d", maxZstdCompressionLevel)
+	}
+	if len(opts.Dictionary) > 0 {
+		if _, err := zstd.InspectDictionary(opts.Dictionary); err != nil {
+			return fmt.Errorf("invalid compression dictionary: %w", err)
+		}
+	}
+	return nil
+}
+
 // File ConsumerInfo is used for creating consumer stores.
 type FileConsumerInfo struct {
 	Created time.Time
@@ -179,8 +227,10 @@ type fileStore struct {
 	syncTmr     *time.Timer
 	cfg         FileStreamInfo
 	fcfg        FileStoreConfig
+	zc          *zstdCoders
 	prf         keyGen
 	oldprf      keyGen
+	rkprfs      []keyGen // prior keys during an online key rotation
 	aek         cipher.AEAD
 	lmb         *msgBlock
 	blks        []*msgBlock
@@ -203,6 +253,8 @@ type fileStore struct {
 	ttls        *thw.HashWheel
 	ttlseq      uint64 // How up-to-date is the `ttls` THW?
 	markers     []string
+	ncold       atomic.Int64        // Number of blocks in the cold tier.
+	coldBlks    map[uint32]struct{} // Blocks found in the cold tier, only used on startup.
 }
 
 // Represents a message store block and its data.
@@ -251,6 +303,12 @@ type msgBlock struct {
 	noCompact  bool
 	closed     bool
 	ttls       uint64 // How many msgs have TTLs?
+	cold       bool   // Offloaded to the cold tier.
+	clts       int64  // Last access of the local copy of a cold block, zero if there is none.
+	cfetching  int    // Number of fetches from the cold tier done without holding the lock.
+
+	// Serializes transfers to and from the cold tier, which are done without holding mu if possible.
+	cfmu sync.Mutex
 
 	// Used to mock write failures.
 	mockWriteErr bool
@@ -408,6 +466,7 @@ func newFileStoreWithCreated(fcfg FileStoreConfig, cfg StreamConfig, created tim
 
 	fs := &fileStore{
 		fcfg:   fcfg,
+		zc:     newZstdCoders(fcfg.CompressionOpts),
 		psim:   stree.NewSubjectTree[psi](),
 		bim:    make(map[uint32]*msgBlock),
 		cfg:    FileStreamInfo{Created: created, StreamConfig: cfg},
@@ -451,6 +510,18 @@ func newFileStoreWithCreated(fcfg FileStoreConfig, cfg StreamConfig, created tim
 		}
 	}
 
+	// Finish any key rotation of message blocks we were in the middle of.
+	if fs.prf != nil {
+		finishBlockRekeys(mdir)
+	}
+
+	// Check which blocks have been offloaded to the cold tier.
+	if fcfg.ColdStore != nil {
+		if err := fs.recoverColdBlocks(); err != nil {
+			return nil, fmt.Errorf("could not list cold tier blocks - %v", err)
+		}
+	}
+
 	// Attempt to recover our state.
 	err = fs.recoverFullState()
 	if err != nil {
@@ -486,6 +557,10 @@ func newFileStoreWithCreated(fcfg FileStoreConfig, cfg StreamConfig, created tim
 		fs.dirty++
 	}
 
+	if fs.coldBlks != nil {
+		fs.cleanupColdBlocks()
+	}
+
 	// See if we can bring back our TTL timed hash wheel state from disk.
 	if cfg.AllowMsgTTL {
 		if err = fs.recoverTTLState(); err != nil && !os.IsNotExist(err) {
@@ -615,16 +690,31 @@ func (fs *fileStore) UpdateConfig(cfg *StreamConfig) error {
 	old_cfg := fs.cfg
 	// The reference story has changed here, so this full msg block lock
 	// may not be needed.
+	old_cmp, old_cmpOpts, old_zc := fs.fcfg.Compression, fs.fcfg.CompressionOpts, fs.zc
 	fs.lockAllMsgBlocks()
 	fs.cfg = new_cfg
+	// New blocks, and existing blocks when compacted, will use the updated compression.
+	fs.fcfg.Compression, fs.fcfg.CompressionOpts = cfg.Compression, cfg.CompressionOpts
+	if !old_cmpOpts.equal(cfg.CompressionOpts) {
+		fs.zc = newZstdCoders(cfg.CompressionOpts)
+	}
 	fs.unlockAllMsgBlocks()
 	if err := fs.writeStreamMeta(); err != nil {
 		fs.lockAllMsgBlocks()
 		fs.cfg = old_cfg
+		fs.fcfg.Compression, fs.fcfg.CompressionOpts = old_cmp, old_cmpOpts
+		if fs.zc != old_zc {
+			fs.zc.close()
+			fs.zc = old_zc
+		}
 		fs.unlockAllMsgBlocks()
 		fs.mu.Unlock()
 		return err
 	}
+	// The blocks only use our coders while locked, so the old ones are no longer in use.
+	if fs.zc != old_zc {
+		old_zc.close()
+	}
 
 	// Limits checks and enforcement.
 	fs.enforceMsgLimit()
@@ -704,17 +794,20 @@ func genEncryptionKey(sc StoreCipher, seed []byte) (ek cipher.AEAD, err error) {
 
 // Generate an asset encryption key from the context and server PRF.
 func (fs *fileStore) genEncryptionKeys(context string) (aek cipher.AEAD, bek cipher.Stream, seed, encrypted []byte, err error) {
-	if fs.prf == nil {
+	return genEncryptionKeysWithPrf(fs.prf, fs.fcfg.Cipher, context)
+}
+
+// Generate an asset encryption key from the context and the given PRF.
+func genEncryptionKeysWithPrf(prf keyGen, sc StoreCipher, context string) (aek cipher.AEAD, bek cipher.Stream, seed, encrypted []byte, err error) {
+	if prf == nil {
 		return nil, nil, nil, nil, errNoEncryption
 	}
 	// Generate key encryption key.
-	rb, err := fs.prf([]byte(context))
+	rb, err := prf([]byte(context))
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
 
-	sc := fs.fcfg.Cipher
-
 	kek, err := genEncryptionKey(sc, rb)
 	if err != nil {
 		return nil, nil, nil, nil, err
@@ -931,6 +1024,14 @@ func (fs *fileStore) initMsgBlock(index uint32) *msgBlock {
 	mdir := filepath.Join(fs.fcfg.StoreDir, msgDir)
 	mb.mfn = filepath.Join(mdir, fmt.Sprintf(blkScan, index))
 
+	// Check if this block lives in the cold tier, any local copy is treated as cached.
+	if _, ok := fs.coldBlks[index]; ok {
+		mb.cold = true
+		if _, err := os.Stat(mb.mfn); err == nil {
+			mb.clts = time.Now().UnixNano()
+		}
+	}
+
 	if mb.hh == nil {
 		key := sha256.Sum256(fs.hashKeyForBlock(index))
 		mb.hh, _ = highwayhash.New64(key[:])
@@ -971,6 +1072,10 @@ func (fs *fileStore) loadEncryptionForMsgBlock(mb *msgBlock) error {
 		}
 		ns := kek.NonceSize()
 		seed, err := kek.Open(nil, ekey[:ns], ekey[ns:], nil)
+		if err != nil && len(fs.rkprfs) > 0 {
+			// We may be in the middle of an online key rotation.
+			seed, err = openKeySeed(ekey, fmt.Sprintf("%s:%d", fs.cfg.Name, mb.index), sc, fs.rkprfs...)
+		}
 		if err != nil {
 			// We may be here on a cipher conversion, so attempt to convert.
 			if err = mb.convertCipher(); err != nil {
@@ -1221,7 +1326,11 @@ func (mb *msgBlock) convertCipher() error {
 		buf, _ := mb.loadBlock(nil)
 		bek.XORKeyStream(buf, buf)
 		// Make sure we can parse with old cipher and key file.
-		if err = mb.indexCacheBuf(buf); err != nil {
+		rbuf, err := mb.decompressIfNeeded(buf)
+		if err != nil {
+			return err
+		}
+		if err = mb.indexCacheBuf(rbuf); err != nil {
 			return err
 		}
 		// Reset the cache since we just read everything in.
@@ -1235,6 +1344,9 @@ func (mb *msgBlock) convertCipher() error {
 			return err
 		}
 		mb.bek.XORKeyStream(buf, buf)
+		if err := mb.promoteColdBlockLocked(); err != nil {
+			return err
+		}
 		<-dios
 		err = os.WriteFile(mb.mfn, buf, defaultFilePerms)
 		dios <- struct{}{}
@@ -1263,6 +1375,9 @@ func (mb *msgBlock) convertToEncrypted() error {
 	// Undo cache from above for later.
 	mb.cache = nil
 	mb.bek.XORKeyStream(buf, buf)
+	if err := mb.promoteColdBlockLocked(); err != nil {
+		return err
+	}
 	<-dios
 	err = os.WriteFile(mb.mfn, buf, defaultFilePerms)
 	dios <- struct{}{}
@@ -1351,6 +1466,9 @@ func (mb *msgBlock) rebuildStateLocked() (*LostStreamData, []uint64, error) {
 	var le = binary.LittleEndian
 
 	truncate := func(index uint32) {
+		if err := mb.promoteColdBlockLocked(); err != nil {
+			return
+		}
 		var fd *os.File
 		if mb.mfd != nil {
 			fd = mb.mfd
@@ -2003,10 +2121,19 @@ func (fs *fileStore) recoverMsgs() error {
 	indices := make(sort.IntSlice, 0, len(dirs))
 	var index int
 	for _, fi := range dirs {
+		if strings.HasSuffix(fi.Name(), rekeyTmpSuffix) {
+			continue
+		}
 		if n, err := fmt.Sscanf(fi.Name(), blkScan, &index); err == nil && n == 1 {
-			indices = append(indices, index)
+			// Local copies of cold blocks are added below.
+			if _, ok := fs.coldBlks[uint32(index)]; !ok {
+				indices = append(indices, index)
+			}
 		}
 	}
+	for index := range fs.coldBlks {
+		indices = append(indices, int(index))
+	}
 	indices.Sort()
 
 	// Recover all of the msg blocks.
@@ -4760,6 +4887,10 @@ func (mb *msgBlock) compact() {
 // if fseq > 0 we will attempt to cleanup stale tombstones.
 // Write lock needs to be held.
 func (mb *msgBlock) compactWithFloor(floor uint64) {
+	// Blocks in the cold tier are not rewritten, their space is reclaimed once removed.
+	if mb.cold {
+		return
+	}
 	wasLoaded := mb.cacheAlreadyLoaded()
 	if !wasLoaded {
 		if err := mb.loadMsgsWithLock(); err != nil {
@@ -4819,14 +4950,19 @@ func (mb *msgBlock) compactWithFloor(floor uint64) {
 		index += rl
 	}
 
-	// Handle compression
-	if mb.cmp != NoCompression && len(nbuf) > 0 {
-		cbuf, err := mb.cmp.Compress(nbuf)
+	// Handle compression. If the block was compressed use the currently configured
+	// algorithm, so blocks get recompressed as they are compacted after a config update.
+	alg := mb.cmp
+	if alg != NoCompression && mb.fs != nil {
+		alg = mb.fs.fcfg.Compression
+	}
+	if alg != NoCompression && len(nbuf) > 0 {
+		cbuf, err := alg.compressWith(nbuf, mb.fs.zc)
 		if err != nil {
 			return
 		}
 		meta := &CompressionInfo{
-			Algorithm:    mb.cmp,
+			Algorithm:    alg,
 			OriginalSize: uint64(len(nbuf)),
 		}
 		nbuf = append(meta.MarshalMetadata(), cbuf...)
@@ -4861,6 +4997,7 @@ func (mb *msgBlock) compactWithFloor(floor uint64) {
 
 	// Make sure to sync
 	mb.needSync = true
+	mb.cmp = alg
 
 	// Capture the updated rbytes.
 	if rbytes := uint64(len(nbuf)); rbytes == mb.rbytes {
@@ -5025,6 +5162,11 @@ func (mb *msgBlock) flushLoop(fch, qch chan struct{}) {
 
 // Lock should be held.
 func (mb *msgBlock) eraseMsg(seq uint64, ri, rl int) error {
+	// Cold blocks need to move back before being modified.
+	if err := mb.promoteColdBlockLocked(); err != nil {
+		return err
+	}
+
 	var le = binary.LittleEndian
 	var hdr [msgHdrSize]byte
 
@@ -5088,6 +5230,11 @@ func (mb *msgBlock) truncate(sm *StoreMsg) (nmsgs, nbytes uint64, err error) {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
 
+	// Cold blocks need to move back before being modified.
+	if err := mb.promoteColdBlockLocked(); err != nil {
+		return 0, 0, err
+	}
+
 	// Make sure we are loaded to process messages etc.
 	if err := mb.loadMsgsWithLock(); err != nil {
 		return 0, 0, err
@@ -5129,6 +5276,19 @@ func (mb *msgBlock) truncate(sm *StoreMsg) (nmsgs, nbytes uint64, err error) {
 				purged++
 				bytes += uint64(rl)
 			}
+		} else if err == errBadMsg && mb.msgs > 0 {
+			// Corrupt messages still need to be accounted for, so use the record length from our index.
+			if _, rl, _, err := mb.slotInfo(int(seq - mb.cache.fseq)); err == nil {
+				rl := uint64(rl)
+				mb.msgs--
+				if rl > mb.bytes {
+					rl = mb.bytes
+				}
+				mb.bytes -= rl
+				mb.rbytes -= rl
+				purged++
+				bytes += rl
+			}
 		}
 	}
 
@@ -5154,7 +5314,7 @@ func (mb *msgBlock) truncate(sm *StoreMsg) (nmsgs, nbytes uint64, err error) {
 		}
 		buf = buf[:eof]
 		copy(mb.lchk[0:], buf[:len(buf)-checksumSize])
-		buf, err = mb.cmp.Compress(buf)
+		buf, err = mb.cmp.compressWith(buf, mb.fs.zc)
 		if err != nil {
 			return 0, 0, fmt.Errorf("failed to recompress block: %w", err)
 		}
@@ -5650,6 +5810,9 @@ func (mb *msgBlock) enableForWriting(fip bool) error {
 	if mb.mfd != nil {
 		return nil
 	}
+	if err := mb.promoteColdBlockLocked(); err != nil {
+		return err
+	}
 	<-dios
 	mfd, err := os.OpenFile(mb.mfn, os.O_CREATE|os.O_RDWR, defaultFilePerms)
 	dios <- struct{}{}
@@ -5947,9 +6110,13 @@ func (fs *fileStore) writeTombstone(seq uint64, ts int64) error {
 }
 
 func (mb *msgBlock) recompressOnDiskIfNeeded() error {
-	alg := mb.fs.fcfg.Compression
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
+	alg := mb.fs.fcfg.Compression
+	// A stopped store could be reopened underneath of us, e.g. with new keys.
+	if mb.cold || mb.closed {
+		return nil
+	}
 
 	origFN := mb.mfn                    // The original message block on disk.
 	tmpFN := mb.mfn + compressTmpSuffix // The compressed block will be written here.
@@ -5999,7 +6166,7 @@ func (mb *msgBlock) recompressOnDiskIfNeeded() error {
 		// The block is already compressed using some algorithm, so we need
 		// to decompress the block using the existing algorithm before we can
 		// recompress it with the new one.
-		if origBuf, err = meta.Algorithm.Decompress(origBuf); err != nil {
+		if origBuf, err = meta.Algorithm.decompressWith(origBuf, mb.fs.zc); err != nil {
 			return fmt.Errorf("failed to decompress original block: %w", err)
 		}
 	}
@@ -6025,7 +6192,7 @@ func (mb *msgBlock) recompressOnDiskIfNeeded() error {
 	// The original buffer at this point is uncompressed, so we will now compress
 	// it if needed. Note that if the selected algorithm is NoCompression, the
 	// Compress function will just return the input buffer unmodified.
-	cmpBuf, err := alg.Compress(origBuf)
+	cmpBuf, err := alg.compressWith(origBuf, mb.fs.zc)
 	if err != nil {
 		return errorCleanup(fmt.Errorf("failed to compress block: %w", err))
 	}
@@ -6097,7 +6264,7 @@ func (mb *msgBlock) decompressIfNeeded(buf []byte) ([]byte, error) {
 		// are compressed. If by any chance the metadata claims that the
 		// block is uncompressed, then the input slice is just returned
 		// unmodified.
-		return meta.Algorithm.Decompress(buf[n:])
+		return meta.Algorithm.decompressWith(buf[n:], mb.fs.zc)
 	}
 }
 
@@ -6154,7 +6321,7 @@ func (fs *fileStore) syncBlocks() {
 		// Check if we should compact here as well.
 		// Do not compact last mb.
 		var needsCompact bool
-		if mb != lmb && mb.ensureRawBytesLoaded() == nil && mb.shouldCompactSync() {
+		if mb != lmb && !mb.cold && mb.ensureRawBytesLoaded() == nil && mb.shouldCompactSync() {
 			needsCompact = true
 			markDirty = true
 		}
@@ -6219,6 +6386,11 @@ func (fs *fileStore) syncBlocks() {
 		}
 	}
 
+	// Move blocks between tiers if needed.
+	if fs.fcfg.ColdStore != nil {
+		fs.tierBlocks()
+	}
+
 	fs.mu.Lock()
 	if fs.closed {
 		fs.mu.Unlock()
@@ -6648,6 +6820,12 @@ func (mb *msgBlock) fssNotLoaded() bool {
 // Wrap openBlock for the gated semaphore processing.
 // Lock should be held
 func (mb *msgBlock) openBlock() (*os.File, error) {
+	// Make sure we have a local copy if offloaded to the cold tier.
+	if mb.cold {
+		if err := mb.fetchColdBlockLocked(); err != nil {
+			return nil, err
+		}
+	}
 	// Gate with concurrent IO semaphore.
 	<-dios
 	f, err := os.Open(mb.mfn)
@@ -6728,6 +6906,17 @@ func (mb *msgBlock) loadMsgsWithLock() error {
 		return nil
 	}
 
+	// Make sure we have a local copy if offloaded to the cold tier.
+	// We don't want to hold the lock while fetching, so this releases it in the meantime.
+	if mb.cold && !mb.cacheAlreadyLoaded() {
+		if err := mb.fetchColdBlockUnlocked(); err != nil {
+			return err
+		}
+		if mb.loading {
+			return nil
+		}
+	}
+
 	// Set loading status.
 	mb.loading = true
 	defer mb.clearLoading()
@@ -7395,6 +7584,11 @@ func (fs *fileStore) FastState(state *StreamState) {
 	}
 	state.Consumers = fs.numConsumers()
 	state.NumSubjects = fs.numSubjects()
+	if fs.cfg.ColdTier != nil || fs.ncold.Load() > 0 {
+		state.HotBytes, state.ColdBytes = fs.tierBytes()
+	} else {
+		state.HotBytes, state.ColdBytes = 0, 0
+	}
 	fs.mu.RUnlock()
 }
 
@@ -7405,6 +7599,9 @@ func (fs *fileStore) State() StreamState {
 	state.Consumers = fs.numConsumers()
 	state.NumSubjects = fs.numSubjects()
 	state.Deleted = nil // make sure.
+	if fs.cfg.ColdTier != nil || fs.ncold.Load() > 0 {
+		state.HotBytes, state.ColdBytes = fs.tierBytes()
+	}
 
 	if numDeleted := int((state.LastSeq - state.FirstSeq + 1) - state.Msgs); numDeleted > 0 {
 		state.Deleted = make([]uint64, 0, numDeleted)
@@ -7843,6 +8040,13 @@ func (fs *fileStore) purge(fseq uint64, noMarkers bool) (uint64, error) {
 	for _, mb := range fs.blks {
 		mb.dirtyClose()
 	}
+	// Block indexes will be reused, so remove any cold blocks now.
+	if fs.fcfg.ColdStore != nil && fs.ncold.Load() > 0 {
+		if err := fs.fcfg.ColdStore.RemoveAll(); err != nil {
+			fs.warn("Error removing cold tier blocks: %v", err)
+		}
+		fs.ncold.Store(0)
+	}
 
 	fs.blks = nil
 	fs.lmb = nil
@@ -8071,7 +8275,11 @@ func (fs *fileStore) compact(seq uint64, noMarkers bool) (uint64, error) {
 			}
 			// Recompress if necessary (smb.cmp contains the algorithm used when
 			// the block was loaded from disk, or defaults to NoCompression if not)
-			if nbuf, err = smb.cmp.Compress(nbuf); err != nil {
+			if nbuf, err = smb.cmp.compressWith(nbuf, fs.zc); err != nil {
+				goto SKIP
+			}
+			// Cold blocks need to move back before being modified.
+			if err = smb.promoteColdBlockLocked(); err != nil {
 				goto SKIP
 			}
 			<-dios
@@ -8437,6 +8645,13 @@ func (mb *msgBlock) dirtyCloseWithRemove(remove bool) error {
 	if remove {
 		// Clear any tracking by subject if we are removing.
 		mb.fss = nil
+		if mb.cold {
+			if err := mb.fs.fcfg.ColdStore.Remove(mb.coldName()); err != nil {
+				return err
+			}
+			mb.cold, mb.clts = false, 0
+			mb.fs.ncold.Add(-1)
+		}
 		if mb.mfn != _EMPTY_ {
 			err := os.Remove(mb.mfn)
 			if isPermissionError(err) {
@@ -8755,6 +8970,9 @@ func (fs *fileStore) Delete() error {
 	if fs.isClosed() {
 		// Always attempt to remove since we could have been closed beforehand.
 		os.RemoveAll(fs.fcfg.StoreDir)
+		if fs.fcfg.ColdStore != nil {
+			fs.fcfg.ColdStore.RemoveAll()
+		}
 		// Since we did remove, if we did have anything remaining make sure to
 		// call into any storage updates that had been registered.
 		fs.mu.Lock()
@@ -8799,6 +9017,11 @@ func (fs *fileStore) Delete() error {
 	if err := os.Remove(filepath.Join(fs.fcfg.StoreDir, JetStreamMetaFile)); err != nil {
 		return err
 	}
+	if fs.fcfg.ColdStore != nil {
+		if err := fs.fcfg.ColdStore.RemoveAll(); err != nil {
+			fs.warn("Error removing cold tier blocks: %v", err)
+		}
+	}
 	// Now move into different directory with "." prefix.
 	ndir := filepath.Join(filepath.Dir(fs.fcfg.StoreDir), tsep+filepath.Base(fs.fcfg.StoreDir))
 	if err := os.Rename(fs.fcfg.StoreDir, ndir); err != nil {
@@ -9168,6 +9391,7 @@ func (fs *fileStore) stop(delete, writeState bool) error {
 	// writeFullState has completed.
 	fs.closed = true
 	fs.lmb = nil
+	fs.zc.close()
 
 	// We should update the upper usage layer on a stop.
 	cb, bytes := fs.scb, int64(fs.state.Bytes)
@@ -9641,6 +9865,19 @@ func (fs *fileStore) ConsumerStore(name string, cfg *ConsumerConfig) (ConsumerSt
 			ns := kek.NonceSize()
 			nonce := ekey[:ns]
 			seed, err := kek.Open(nil, nonce, ekey[ns:], nil)
+			if err != nil {
+				// We may be in the middle of an online key rotation or restarted with our old key.
+				// If so re-wrap the key file with our current key.
+				if prfs := fs.priorKeys(); len(prfs) > 0 {
+					context := fs.cfg.Name + tsep + o.name
+					if seed, err = openKeySeed(ekey, context, sc, prfs...); err == nil {
+						keyFile := filepath.Join(odir, JetStreamMetaFileKey)
+						if _, err := rekeyKeyFile(keyFile, context, sc, prfs, o.prf, fs.fcfg.SyncAlways); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
 			if err != nil {
 				// We may be here on a cipher conversion, so attempt to convert.
 				if err = o.convertCipher(); err != nil {
@@ -9889,7 +10126,12 @@ func (o *consumerFileStore) UpdateDelivered(dseq, sseq, dc uint64, ts int64) err
 	}
 
 	// On restarts the old leader may get a replay from the raft logs that are old.
-	if dseq <= o.state.AckFloor.Consumer {
+	// Messages held back from delivery have no consumer sequence, so check their pending record instead.
+	if dseq == 0 {
+		if sseq <= o.state.Delivered.Stream && !o.state.isHeldPending(sseq) {
+			return nil
+		}
+	} else if dseq <= o.state.AckFloor.Consumer {
 		return nil
 	}
 
@@ -9958,9 +10200,14 @@ func (o *consumerFileStore) UpdateAcks(dseq, sseq uint64) error {
 	}
 
 	// On restarts the old leader may get a replay from the raft logs that are old.
-	if dseq <= o.state.AckFloor.Consumer {
+	if dseq == 0 {
+		if !o.state.isHeldPending(sseq) {
+			return nil
+		}
+	} else if dseq <= o.state.AckFloor.Consumer {
 		return nil
 	}
+	prev := o.state.AckFloor.Stream
 
 	// Match leader logic on checking if ack is ahead of delivered.
 	// This could happen on a cooperative takeover with high speed deliveries.
@@ -10023,6 +10270,10 @@ func (o *consumerFileStore) UpdateAcks(dseq, sseq uint64) error {
 			}
 		}
 	}
+	// Messages held back for their ordering key are delivered out of order.
+	if o.cfg.OrderingKey != nil {
+		o.state.holdAckFloor(prev)
+	}
 	// We do these regardless.
 	delete(o.state.Redelivered, sseq)
 
@@ -10062,6 +10313,15 @@ func (o *consumerFileStore) UpdateConfig(cfg *ConsumerConfig) error {
 }
 
 func (o *consumerFileStore) Update(state *ConsumerState) error {
+	return o.setState(state, false)
+}
+
+// Reset replaces our state, allowing the stream positions to move backwards.
+func (o *consumerFileStore) Reset(state *ConsumerState) error {
+	return o.setState(state, true)
+}
+
+func (o *consumerFileStore) setState(state *ConsumerState, reset bool) error {
 	// Sanity checks.
 	if state.AckFloor.Consumer > state.Delivered.Consumer {
 		return fmt.Errorf("bad ack floor for consumer")
@@ -10094,7 +10354,7 @@ func (o *consumerFileStore) Update(state *ConsumerState) error {
 	defer o.mu.Unlock()
 
 	// Check to see if this is an outdated update.
-	if state.Delivered.Consumer < o.state.Delivered.Consumer || state.AckFloor.Stream < o.state.AckFloor.Stream {
+	if !reset && (state.Delivered.Consumer < o.state.Delivered.Consumer || state.AckFloor.Stream < o.state.AckFloor.Stream) {
 		return fmt.Errorf("old update ignored")
 	}
 
@@ -10699,7 +10959,101 @@ func (c *CompressionInfo) UnmarshalMetadata(b []byte) (int, error) {
 	return 4 + n, nil
 }
 
+// zstd encoders and decoders are expensive to set up and safe for concurrent use
+// of EncodeAll and DecodeAll. The default ones are shared, while ones for a store's
+// level or dictionary are set up when first needed and closed along with the store.
+type zstdCoders struct {
+	mu     sync.Mutex
+	opts   *StoreCompressionOpts
+	enc    *zstd.Encoder
+	dec    *zstd.Decoder
+	closed bool
+}
+
+var defaultZstdCoders zstdCoders
+
+// Returns nil if the default coders can be used for these options.
+func newZstdCoders(opts *StoreCompressionOpts) *zstdCoders {
+	if opts == nil || (opts.Level == 0 && len(opts.Dictionary) == 0) {
+		return nil
+	}
+	return &zstdCoders{opts: opts}
+}
+
+func (zc *zstdCoders) encoder() (*zstd.Encoder, error) {
+	if zc == nil {
+		zc = &defaultZstdCoders
+	}
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	if zc.closed {
+		return nil, ErrStoreClosed
+	}
+	if zc.enc == nil {
+		var eopts []zstd.EOption
+		if opts := zc.opts; opts != nil {
+			if opts.Level > 0 {
+				eopts = append(eopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level)))
+			}
+			if len(opts.Dictionary) > 0 {
+				eopts = append(eopts, zstd.WithEncoderDict(opts.Dictionary))
+			}
+		}
+		enc, err := zstd.NewWriter(nil, eopts...)
+		if err != nil {
+			return nil, err
+		}
+		zc.enc = enc
+	}
+	return zc.enc, nil
+}
+
+func (zc *zstdCoders) decoder() (*zstd.Decoder, error) {
+	if zc == nil {
+		zc = &defaultZstdCoders
+	}
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	if zc.closed {
+		return nil, ErrStoreClosed
+	}
+	if zc.dec == nil {
+		var dopts []zstd.DOption
+		if opts := zc.opts; opts != nil && len(opts.Dictionary) > 0 {
+			dopts = append(dopts, zstd.WithDecoderDicts(opts.Dictionary))
+		}
+		dec, err := zstd.NewReader(nil, dopts...)
+		if err != nil {
+			return nil, err
+		}
+		zc.dec = dec
+	}
+	return zc.dec, nil
+}
+
+func (zc *zstdCoders) close() {
+	if zc == nil {
+		return
+	}
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	if zc.enc != nil {
+		zc.enc.Close()
+		zc.enc = nil
+	}
+	if zc.dec != nil {
+		zc.dec.Close()
+		zc.dec = nil
+	}
+	zc.closed = true
+}
+
 func (alg StoreCompression) Compress(buf []byte) ([]byte, error) {
+	return alg.compressWith(buf, nil)
+}
+
+// Compress using the given zstd coders, or the default ones if nil.
+func (alg StoreCompression) compressWith(buf []byte, zc *zstdCoders) ([]byte, error) {
 	if len(buf) < checksumSize {
 		return nil, fmt.Errorf("uncompressed buffer is too short")
 	}
@@ -10711,6 +11065,14 @@ func (alg StoreCompression) Compress(buf []byte) ([]byte, error) {
 		return buf, nil
 	case S2Compression:
 		writer = s2.NewWriter(&output)
+	case ZstdCompression:
+		// Compress the whole block at once, the checksum is kept as-is at the end.
+		enc, err := zc.encoder()
+		if err != nil {
+			return nil, fmt.Errorf("error creating compression writer: %w", err)
+		}
+		cbuf := enc.EncodeAll(buf[:bodyLen], make([]byte, 0, bodyLen/2+checksumSize))
+		return append(cbuf, buf[bodyLen:]...), nil
 	default:
 		return nil, fmt.Errorf("compression algorithm not known")
 	}
@@ -10740,6 +11102,11 @@ func (alg StoreCompression) Compress(buf []byte) ([]byte, error) {
 }
 
 func (alg StoreCompression) Decompress(buf []byte) ([]byte, error) {
+	return alg.decompressWith(buf, nil)
+}
+
+// Decompress using the given zstd coders, or the default ones if nil.
+func (alg StoreCompression) decompressWith(buf []byte, zc *zstdCoders) ([]byte, error) {
 	if len(buf) < checksumSize {
 		return nil, fmt.Errorf("compressed buffer is too short")
 	}
@@ -10752,6 +11119,16 @@ func (alg StoreCompression) Decompress(buf []byte) ([]byte, error) {
 		return buf, nil
 	case S2Compression:
 		reader = io.NopCloser(s2.NewReader(input))
+	case ZstdCompression:
+		dec, err := zc.decoder()
+		if err != nil {
+			return nil, fmt.Errorf("error creating compression reader: %w", err)
+		}
+		output, err := dec.DecodeAll(buf[:bodyLen], nil)
+		if err != nil {
+			return nil, fmt.Errorf("error reading compression reader: %w", err)
+		}
+		return append(output, buf[bodyLen:]...), nil
 	default:
 		return nil, fmt.Errorf("compression algorithm not known")
 	}
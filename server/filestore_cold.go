@@ -0,0 +1,433 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// ColdStore is a secondary location that sealed message blocks of a file based stream
+// can be offloaded to, e.g. a larger and slower disk or an object store.
+// Blocks are always transferred as whole files and are never modified once offloaded.
+type ColdStore interface {
+	// Put stores the contents of the local file src under name, replacing any existing copy.
+	Put(name, src string) error
+	// Get writes the block stored under name to the local file dst.
+	Get(name, dst string) error
+	// Remove removes the block stored under name. Removing a missing block is not an error.
+	Remove(name string) error
+	// List returns the names of all stored blocks.
+	List() ([]string, error)
+	// RemoveAll removes all stored blocks.
+	RemoveAll() error
+}
+
+const (
+	// How long a local copy of a cold block is kept after it was last accessed.
+	coldCacheExpire = 2 * time.Minute
+	// Maximum number of cold blocks we keep local copies of.
+	coldCacheMaxBlocks = 8
+)
+
+// dirColdStore is a ColdStore backed by a directory, typically on a different mount.
+type dirColdStore struct {
+	dir string
+}
+
+func newDirColdStore(dir string) *dirColdStore {
+	return &dirColdStore{dir: dir}
+}
+
+// Returns the directory in the cold store for a stream. Replicas could share the cold store,
+// so blocks are kept apart per server when it has a name, which clustering requires.
+// Otherwise the name is generated on every start, so can't be used.
+func (s *Server) coldStreamDir(coldDir, acc, stream string) string {
+	if s.getOpts().ServerName == _EMPTY_ {
+		return filepath.Join(coldDir, acc, streamsDir, stream)
+	}
+	return filepath.Join(coldDir, s.NodeName(), acc, streamsDir, stream)
+}
+
+func (cs *dirColdStore) Put(name, src string) error {
+	if err := os.MkdirAll(cs.dir, defaultDirPerms); err != nil {
+		return err
+	}
+	return copyFileAtomic(src, filepath.Join(cs.dir, name))
+}
+
+func (cs *dirColdStore) Get(name, dst string) error {
+	return copyFileAtomic(filepath.Join(cs.dir, name), dst)
+}
+
+func (cs *dirColdStore) Remove(name string) error {
+	if err := os.Remove(filepath.Join(cs.dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (cs *dirColdStore) List() ([]string, error) {
+	entries, err := os.ReadDir(cs.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) != compressTmpSuffix {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (cs *dirColdStore) RemoveAll() error {
+	return os.RemoveAll(cs.dir)
+}
+
+// copyFileAtomic copies src to dst through a temporary file, so dst is either
+// the old or the complete new contents.
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + compressTmpSuffix
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, defaultFilePerms)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Name the block is stored under in the cold tier.
+func (mb *msgBlock) coldName() string {
+	return fmt.Sprintf(blkScan, mb.index)
+}
+
+// fetchColdBlock fetches a cold block into the local file, unless another fetch already did.
+// Local copies are only ever written atomically, so if there is one it is complete.
+// Cold transfer lock should be held.
+func (mb *msgBlock) fetchColdBlock(name, mfn string) error {
+	if _, err := os.Stat(mfn); err == nil {
+		return nil
+	}
+	if err := mb.fs.fcfg.ColdStore.Get(name, mfn); err != nil {
+		return fmt.Errorf("error fetching cold msg block [%d]: %w", mb.index, err)
+	}
+	return nil
+}
+
+// fetchColdBlockLocked makes sure we have a local copy of a cold block.
+// Lock should be held.
+func (mb *msgBlock) fetchColdBlockLocked() error {
+	if mb.clts == 0 {
+		mb.cfmu.Lock()
+		err := mb.fetchColdBlock(mb.coldName(), mb.mfn)
+		mb.cfmu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	mb.clts = time.Now().UnixNano()
+	return nil
+}
+
+// fetchColdBlockUnlocked makes sure we have a local copy of a cold block, like fetchColdBlockLocked,
+// but releases the lock while fetching so the cold tier doesn't hold up others using the block.
+// Lock should be held, it is released and acquired again.
+func (mb *msgBlock) fetchColdBlockUnlocked() error {
+	if mb.clts == 0 {
+		name, mfn := mb.coldName(), mb.mfn
+		// Keep the local copy from being evicted until we are done.
+		mb.cfetching++
+		mb.mu.Unlock()
+		mb.cfmu.Lock()
+		err := mb.fetchColdBlock(name, mfn)
+		mb.cfmu.Unlock()
+		mb.mu.Lock()
+		mb.cfetching--
+		if err != nil {
+			return err
+		}
+		if mb.closed {
+			return errNoBlkData
+		}
+		// Could have been promoted in the meantime, in which case the local copy is the block.
+		if !mb.cold {
+			return nil
+		}
+	}
+	mb.clts = time.Now().UnixNano()
+	return nil
+}
+
+// promoteColdBlockLocked moves a cold block back to the hot tier, needed before it can be modified.
+// Lock should be held.
+func (mb *msgBlock) promoteColdBlockLocked() error {
+	if !mb.cold {
+		return nil
+	}
+	if err := mb.fetchColdBlockLocked(); err != nil {
+		return err
+	}
+	// Cold blocks are skipped when recompressing on disk, so make sure we know
+	// how the block is compressed before it is truncated or compacted.
+	buf, err := mb.loadBlock(nil)
+	if err != nil {
+		return err
+	}
+	if mb.bek != nil && len(buf) > 0 {
+		bek, err := genBlockEncryptionKey(mb.fs.fcfg.Cipher, mb.seed, mb.nonce)
+		if err != nil {
+			return err
+		}
+		mb.bek = bek
+		mb.bek.XORKeyStream(buf, buf)
+	}
+	var meta CompressionInfo
+	if _, err := meta.UnmarshalMetadata(buf); err != nil {
+		return err
+	}
+	mb.cmp = meta.Algorithm
+	// Remove from the cold tier first, if we crash in between the local copy is the only one.
+	if err := mb.fs.fcfg.ColdStore.Remove(mb.coldName()); err != nil {
+		return err
+	}
+	mb.cold, mb.clts = false, 0
+	mb.fs.ncold.Add(-1)
+	return nil
+}
+
+// moveToCold offloads a sealed block to the cold tier and removes the local copy.
+// The block is copied without holding the lock, and only switched over to the cold tier
+// if it did not change in the meantime, otherwise it is offloaded again later.
+// Returns whether the block was moved.
+// Lock should not be held.
+func (mb *msgBlock) moveToCold() (bool, error) {
+	mb.mu.Lock()
+	if mb.cold || mb.closed || mb.needSync || mb.pendingWriteSizeLocked() > 0 {
+		mb.mu.Unlock()
+		return false, nil
+	}
+	// Anything rewriting the block changes one of these.
+	name, mfn, msgs, rbytes, lseq := mb.coldName(), mb.mfn, mb.msgs, mb.rbytes, atomic.LoadUint64(&mb.last.seq)
+	alg, nonce := mb.cmp, mb.nonce
+	mb.mu.Unlock()
+
+	mb.cfmu.Lock()
+	err := mb.fs.fcfg.ColdStore.Put(name, mfn)
+	mb.cfmu.Unlock()
+	if err != nil {
+		return false, fmt.Errorf("error moving msg block [%d] to cold tier: %w", mb.index, err)
+	}
+
+	mb.mu.Lock()
+	moved := !mb.cold && !mb.closed && !mb.needSync && mb.pendingWriteSizeLocked() == 0 &&
+		mb.msgs == msgs && mb.rbytes == rbytes && atomic.LoadUint64(&mb.last.seq) == lseq &&
+		mb.cmp == alg && bytes.Equal(mb.nonce, nonce)
+	if moved {
+		mb.closeFDsLockedNoCheck()
+		mb.cold, mb.clts = true, 0
+		mb.fs.ncold.Add(1)
+		<-dios
+		os.Remove(mb.mfn)
+		dios <- struct{}{}
+	}
+	// Unless offloaded since by someone else, our copy is not needed.
+	orphan := !moved && !mb.cold
+	mb.mu.Unlock()
+
+	if orphan {
+		mb.cfmu.Lock()
+		err = mb.fs.fcfg.ColdStore.Remove(name)
+		mb.cfmu.Unlock()
+	}
+	return moved, err
+}
+
+// moveToColdLocked offloads a sealed block to the cold tier and removes the local copy,
+// holding the lock while doing so. Only used when the block is rewritten anyway.
+// Lock should be held.
+func (mb *msgBlock) moveToColdLocked() error {
+	if mb.cold || mb.closed || mb.needSync || mb.pendingWriteSizeLocked() > 0 {
+		return nil
+	}
+	mb.closeFDsLockedNoCheck()
+	mb.cfmu.Lock()
+	err := mb.fs.fcfg.ColdStore.Put(mb.coldName(), mb.mfn)
+	mb.cfmu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error moving msg block [%d] to cold tier: %w", mb.index, err)
+	}
+	mb.cold, mb.clts = true, 0
+	mb.fs.ncold.Add(1)
+	<-dios
+	os.Remove(mb.mfn)
+	dios <- struct{}{}
+	return nil
+}
+
+// evictColdBlockLocked removes the local copy of a cold block.
+// Lock should be held.
+func (mb *msgBlock) evictColdBlockLocked() {
+	if !mb.cold || mb.clts == 0 || mb.loading || mb.cfetching > 0 {
+		return
+	}
+	mb.closeFDsLockedNoCheck()
+	<-dios
+	os.Remove(mb.mfn)
+	dios <- struct{}{}
+	mb.clts = 0
+}
+
+// tierBlocks moves sealed blocks past the configured age or hot tier size to the cold tier,
+// and drops local copies of cold blocks that have not been accessed recently.
+// Called from syncBlocks.
+func (fs *fileStore) tierBlocks() {
+	fs.mu.RLock()
+	if fs.closed || fs.sips > 0 || fs.fcfg.ColdStore == nil {
+		fs.mu.RUnlock()
+		return
+	}
+	tcfg := fs.cfg.ColdTier
+	blks := append([]*msgBlock(nil), fs.blks...)
+	lmb := fs.lmb
+	fs.mu.RUnlock()
+
+	// Determine how many bytes are held in the hot tier.
+	var hot uint64
+	for _, mb := range blks {
+		mb.mu.RLock()
+		if !mb.cold {
+			hot += mb.bytes
+		}
+		mb.mu.RUnlock()
+	}
+
+	now := time.Now().UnixNano()
+	type cachedBlock struct {
+		mb   *msgBlock
+		clts int64
+	}
+	var cached []cachedBlock
+	// Blocks are ordered oldest first, so we offload from the front.
+	for _, mb := range blks {
+		if mb == lmb {
+			break
+		}
+		mb.mu.RLock()
+		move := !mb.cold && tcfg != nil &&
+			(tcfg.MaxAge > 0 && mb.last.ts < now-int64(tcfg.MaxAge) || tcfg.MaxBytes > 0 && hot > uint64(tcfg.MaxBytes))
+		bytes := mb.bytes
+		mb.mu.RUnlock()
+		if move {
+			if moved, err := mb.moveToCold(); err != nil {
+				fs.warn("%v", err)
+			} else if moved {
+				hot -= bytes
+			}
+		}
+
+		mb.mu.Lock()
+		if mb.cold && mb.clts > 0 {
+			if now-mb.clts > int64(coldCacheExpire) {
+				mb.evictColdBlockLocked()
+			} else {
+				cached = append(cached, cachedBlock{mb, mb.clts})
+			}
+		}
+		mb.mu.Unlock()
+	}
+
+	// Keep the local cache small, dropping the least recently accessed first.
+	if len(cached) > coldCacheMaxBlocks {
+		slices.SortFunc(cached, func(a, b cachedBlock) int { return cmp.Compare(a.clts, b.clts) })
+		for _, cb := range cached[:len(cached)-coldCacheMaxBlocks] {
+			cb.mb.mu.Lock()
+			cb.mb.evictColdBlockLocked()
+			cb.mb.mu.Unlock()
+		}
+	}
+}
+
+// tierBytes returns the bytes held in the hot and cold tiers.
+// Read lock should be held.
+func (fs *fileStore) tierBytes() (hot, cold uint64) {
+	for _, mb := range fs.blks {
+		mb.mu.RLock()
+		if mb.cold {
+			cold += mb.bytes
+		} else {
+			hot += mb.bytes
+		}
+		mb.mu.RUnlock()
+	}
+	return hot, cold
+}
+
+// recoverColdBlocks loads which blocks live in the cold tier, used on startup.
+// Lock should be held.
+func (fs *fileStore) recoverColdBlocks() error {
+	names, err := fs.fcfg.ColdStore.List()
+	if err != nil {
+		return err
+	}
+	fs.coldBlks = make(map[uint32]struct{}, len(names))
+	for _, name := range names {
+		var index uint32
+		if n, err := fmt.Sscanf(name, blkScan, &index); err == nil && n == 1 {
+			fs.coldBlks[index] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// cleanupColdBlocks removes cold blocks that are no longer part of the stream after recovery,
+// and tracks how many are.
+// Lock should be held.
+func (fs *fileStore) cleanupColdBlocks() {
+	for index := range fs.coldBlks {
+		if mb := fs.bim[index]; mb != nil && mb.cold {
+			fs.ncold.Add(1)
+		} else if err := fs.fcfg.ColdStore.Remove(fmt.Sprintf(blkScan, index)); err != nil {
+			fs.warn("Error removing cold msg block [%d]: %v", index, err)
+		}
+	}
+	fs.coldBlks = nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Suffix for key files and message blocks being written during a key rotation.
+const rekeyTmpSuffix = ".rekey"
+
+// Open the seed held in an encrypted key file, trying each of the main keys in order.
+func openKeySeed(ekey []byte, context string, sc StoreCipher, prfs ...keyGen) ([]byte, error) {
+	if len(ekey) < minBlkKeySize {
+		return nil, errBadKeySize
+	}
+	for _, prf := range prfs {
+		if prf == nil {
+			continue
+		}
+		rb, err := prf([]byte(context))
+		if err != nil {
+			continue
+		}
+		kek, err := genEncryptionKey(sc, rb)
+		if err != nil {
+			continue
+		}
+		ns := kek.NonceSize()
+		if seed, err := kek.Open(nil, ekey[:ns], ekey[ns:], nil); err == nil {
+			return seed, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to recover keys")
+}
+
+// Re-wrap the seed in an encrypted key file with a new main key.
+// The seed and nonce are kept, so the data the key file protects does not need to be rewritten.
+// The new key file is moved into place so a crash leaves either the old or new one.
+// Returns false if the key file was already using the new key.
+func rekeyKeyFile(path, context string, sc StoreCipher, oprfs []keyGen, nprf keyGen, syncAlways bool) (bool, error) {
+	ekey, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if _, err := openKeySeed(ekey, context, sc, nprf); err == nil {
+		return false, nil
+	}
+	seed, err := openKeySeed(ekey, context, sc, oprfs...)
+	if err != nil {
+		return false, err
+	}
+	rb, err := nprf([]byte(context))
+	if err != nil {
+		return false, err
+	}
+	kek, err := genEncryptionKey(sc, rb)
+	if err != nil {
+		return false, err
+	}
+	nonce := slices.Clone(ekey[:kek.NonceSize()])
+	encrypted := kek.Seal(nonce, nonce, seed, nil)
+
+	tmp := path + rekeyTmpSuffix
+	if syncAlways {
+		err = writeFileWithSync(tmp, encrypted, defaultFilePerms)
+	} else {
+		<-dios
+		err = os.WriteFile(tmp, encrypted, defaultFilePerms)
+		dios <- struct{}{}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns the main keys key files may still be wrapped with besides our current one.
+// This is the case during an online key rotation, or when restarted with an old key.
+func (fs *fileStore) priorKeys() []keyGen {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	prfs := slices.Clone(fs.rkprfs)
+	if fs.oldprf != nil {
+		prfs = append(prfs, fs.oldprf)
+	}
+	return prfs
+}
+
+// Re-encrypt a message block with a new random seed and nonce, wrapped with the new main key.
+// The block is written out next to the current one, followed by its new key file. Moving the block
+// into place is the point at which it uses the new key, after which the key file is moved into place.
+// See finishBlockRekeys for how we recover if we stop in between.
+// Returns false if the block was already using the new key.
+// Lock should be held.
+func (mb *msgBlock) rekeyLocked(prf keyGen) (bool, error) {
+	if mb.closed {
+		return false, nil
+	}
+	fs := mb.fs
+	sc := fs.fcfg.Cipher
+	context := fmt.Sprintf("%s:%d", fs.cfg.Name, mb.index)
+	kfn := filepath.Join(fs.fcfg.StoreDir, msgDir, fmt.Sprintf(keyScan, mb.index))
+	ekey, err := os.ReadFile(kfn)
+	if err != nil {
+		return false, err
+	}
+	if _, err := openKeySeed(ekey, context, sc, prf); err == nil {
+		return false, nil
+	}
+	if mb.aek == nil || mb.bek == nil {
+		if err := fs.loadEncryptionForMsgBlock(mb); err != nil {
+			return false, err
+		}
+	}
+	// Cold blocks are never modified in place, so bring it back and offload it again once done.
+	wasCold := mb.cold
+	if err := mb.promoteColdBlockLocked(); err != nil {
+		return false, err
+	}
+	// Make sure everything we have is on disk.
+	if _, err := mb.flushPendingMsgsLocked(); err != nil {
+		return false, err
+	}
+	buf, err := mb.loadBlock(nil)
+	defer recycleMsgBlockBuf(buf)
+	if err != nil && err != errNoBlkData {
+		return false, err
+	}
+	// Compressed blocks stay as they are, we only need to swap the stream cipher.
+	if len(buf) > 0 {
+		obek, err := genBlockEncryptionKey(sc, mb.seed, mb.nonce)
+		if err != nil {
+			return false, err
+		}
+		obek.XORKeyStream(buf, buf)
+	}
+	aek, bek, seed, encrypted, err := genEncryptionKeysWithPrf(prf, sc, context)
+	if err != nil {
+		return false, err
+	}
+	// This also keeps the counter correct for future writes.
+	bek.XORKeyStream(buf, buf)
+
+	mb.closeFDsLocked()
+
+	// These are always synced, the key file can not be moved into place without the block.
+	tmfn, tkfn := mb.mfn+rekeyTmpSuffix, kfn+rekeyTmpSuffix
+	err = writeFileWithSync(tmfn, buf, defaultFilePerms)
+	if err == nil {
+		err = writeFileWithSync(tkfn, encrypted, defaultFilePerms)
+	}
+	if err == nil {
+		err = os.Rename(tmfn, mb.mfn)
+	}
+	if err != nil {
+		os.Remove(tmfn)
+		os.Remove(tkfn)
+		return false, err
+	}
+	mb.aek, mb.bek, mb.seed, mb.nonce = aek, bek, seed, encrypted[:aek.NonceSize()]
+	if err := os.Rename(tkfn, kfn); err != nil {
+		return true, err
+	}
+	mb.kfn = kfn
+
+	if wasCold {
+		if err := mb.moveToColdLocked(); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Finish or undo any message block re-encryptions that were interrupted.
+// A new key file without its block means the block was moved into place,
+// so the key file has to follow. Otherwise the block still uses the old key.
+// Should be called before the blocks are recovered.
+func finishBlockRekeys(mdir string) {
+	<-dios
+	dirs, err := os.ReadDir(mdir)
+	dios <- struct{}{}
+	if err != nil {
+		return
+	}
+	for _, fi := range dirs {
+		name := fi.Name()
+		if !strings.HasSuffix(name, rekeyTmpSuffix) {
+			continue
+		}
+		var index uint32
+		if n, err := fmt.Sscanf(name, keyScan, &index); err != nil || n != 1 || name != fmt.Sprintf(keyScan, index)+rekeyTmpSuffix {
+			continue
+		}
+		tkfn := filepath.Join(mdir, name)
+		tmfn := filepath.Join(mdir, fmt.Sprintf(blkScan, index)+rekeyTmpSuffix)
+		if _, err := os.Stat(tmfn); err == nil {
+			os.Remove(tkfn)
+		} else {
+			os.Rename(tkfn, filepath.Join(mdir, fmt.Sprintf(keyScan, index)))
+		}
+	}
+	// Blocks left behind were never moved into place.
+	for _, fi := range dirs {
+		if name := fi.Name(); strings.HasSuffix(name, ".blk"+rekeyTmpSuffix) {
+			os.Remove(filepath.Join(mdir, name))
+		}
+	}
+}
+
+// Rotate the main encryption key for this store and its consumers online.
+// Each message block is re-encrypted with a new random seed and nonce, so the new
+// key is all that is needed to read them, and the prior key together with old key
+// files no longer is. The stream and consumer meta and state are small and hold no
+// messages, their key files are only re-wrapped with the new key and keep their seeds.
+// Until this completes key files can be opened with either key. Key files and blocks are
+// processed one at a time and wait is called before each one with the number of block
+// bytes to be rewritten, which allows the caller to throttle or abort.
+// Returns the number of key files that were re-wrapped and blocks that were re-encrypted.
+func (fs *fileStore) rotateEncryptionKey(prf keyGen, wait func(n int) error) (int, int, error) {
+	fs.mu.Lock()
+	if fs.closed {
+		fs.mu.Unlock()
+		return 0, 0, ErrStoreClosed
+	}
+	if fs.prf == nil || prf == nil {
+		fs.mu.Unlock()
+		return 0, 0, errNoEncryption
+	}
+	// Any key files not yet rotated can still be opened with our prior keys,
+	// including ones from an earlier rotation that did not complete.
+	fs.rkprfs = append(fs.rkprfs, fs.prf)
+	fs.prf = prf
+	oprfs := slices.Clone(fs.rkprfs)
+	if fs.oldprf != nil {
+		oprfs = append(oprfs, fs.oldprf)
+	}
+	sc, syncAlways, name, sdir := fs.fcfg.Cipher, fs.fcfg.SyncAlways, fs.cfg.Name, fs.fcfg.StoreDir
+	// New blocks will be created with the new key.
+	blks := slices.Clone(fs.blks)
+	fs.mu.Unlock()
+
+	var rotated, reencrypted int
+	// Closed is guarded by mu.
+	rekey := func(mu sync.Locker, closed *bool, path, context string) error {
+		if err := wait(0); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if *closed {
+			return ErrStoreClosed
+		}
+		ok, err := rekeyKeyFile(path, context, sc, oprfs, prf, syncAlways)
+		if err != nil {
+			// Could have been removed underneath of us.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("rotating key file %q: %w", path, err)
+		}
+		if ok {
+			rotated++
+		}
+		return nil
+	}
+
+	// Our stream meta key.
+	if err := rekey(&fs.mu, &fs.closed, filepath.Join(sdir, JetStreamMetaFileKey), name); err != nil {
+		return rotated, reencrypted, err
+	}
+
+	// Message blocks.
+	for _, mb := range blks {
+		mb.mu.RLock()
+		sz := int(mb.rbytes)
+		mb.mu.RUnlock()
+		if err := wait(sz); err != nil {
+			return rotated, reencrypted, err
+		}
+		if fs.isClosed() {
+			return rotated, reencrypted, ErrStoreClosed
+		}
+		mb.mu.Lock()
+		ok, err := mb.rekeyLocked(prf)
+		mb.mu.Unlock()
+		if err != nil {
+			// Could have been removed underneath of us.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return rotated, reencrypted, fmt.Errorf("re-encrypting msg block [%d]: %w", mb.index, err)
+		}
+		if ok {
+			reencrypted++
+		}
+	}
+
+	// Consumer meta keys, these are written under the consumer's lock.
+	fs.cmu.RLock()
+	cfs := slices.Clone(fs.cfs)
+	fs.cmu.RUnlock()
+	for _, cs := range cfs {
+		o, ok := cs.(*consumerFileStore)
+		if !ok {
+			continue
+		}
+		o.mu.Lock()
+		o.prf = prf
+		o.mu.Unlock()
+		if err := rekey(&o.mu, &o.closed, filepath.Join(o.odir, JetStreamMetaFileKey), name+tsep+o.name); err != nil {
+			return rotated, reencrypted, err
+		}
+	}
+
+	// All done, no need to keep the prior keys around.
+	fs.mu.Lock()
+	fs.rkprfs = nil
+	fs.mu.Unlock()
+
+	return rotated, reencrypted, nil
+}
@@ -0,0 +1,520 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+)
+
+var errScrubIndexMismatch = errors.New("index mismatch")
+
+// scrubRange is a range of sequences in a message block found to be corrupt.
+type scrubRange struct {
+	blk   uint32
+	first uint64
+	last  uint64
+	err   error
+}
+
+// Verify every record of the block on disk against its checksum, and that the records
+// match what we have indexed for the block. Nothing is modified, so corrupt records are
+// left in place to be dealt with by the caller. Blocks offloaded to the cold tier are skipped.
+// Returns the number of records checked and any corrupt ranges.
+func (mb *msgBlock) scrub() (int, []scrubRange, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.closed || mb.cold {
+		return 0, nil, nil
+	}
+	// Make sure everything we have is on disk.
+	if _, err := mb.flushPendingMsgsLocked(); err != nil {
+		return 0, nil, err
+	}
+	// We do not load keys on startup anymore so might need to load them here.
+	if mb.fs.prf != nil && (mb.aek == nil || mb.bek == nil) {
+		if err := mb.fs.loadEncryptionForMsgBlock(mb); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	fseq, lseq := atomic.LoadUint64(&mb.first.seq), atomic.LoadUint64(&mb.last.seq)
+
+	// Loading the block will update our raw bytes, which we do not want here.
+	rbytes := mb.rbytes
+	buf, err := mb.loadBlock(nil)
+	mb.rbytes = rbytes
+	defer recycleMsgBlockBuf(buf)
+	if err != nil {
+		if err == errNoBlkData && mb.msgs == 0 {
+			return 0, nil, nil
+		}
+		return 0, nil, err
+	}
+
+	var bad []scrubRange
+	addBad := func(first, last uint64, err error) {
+		first = max(first, fseq)
+		if first > last {
+			return
+		}
+		bad = append(bad, scrubRange{mb.index, first, last, err})
+	}
+
+	// Use our own cipher state so we do not disturb the one used for writes.
+	if mb.bek != nil && len(buf) > 0 {
+		bek, err := genBlockEncryptionKey(mb.fs.fcfg.Cipher, mb.seed, mb.nonce)
+		if err != nil {
+			return 0, nil, err
+		}
+		bek.XORKeyStream(buf, buf)
+	}
+	if buf, err = mb.decompressIfNeeded(buf); err != nil {
+		addBad(fseq, lseq, err)
+		return 0, bad, nil
+	}
+
+	var le = binary.LittleEndian
+
+	var (
+		records int
+		live    uint64
+		last    uint64
+		// Set when we have seen a corrupt record whose sequence we can not trust,
+		// so it is only known to be between the last and next good records.
+		pending bool
+	)
+	for index, lbuf := uint32(0), uint32(len(buf)); index < lbuf; {
+		if index+msgHdrSize > lbuf {
+			pending = true
+			break
+		}
+		hdr := buf[index : index+msgHdrSize]
+		rl, slen := le.Uint32(hdr[0:]), int(le.Uint16(hdr[20:]))
+
+		hasHeaders := rl&hbit != 0
+		rl &^= hbit
+		dlen := int(rl) - msgHdrSize
+		// Without a sane length we can not find the next record, so the rest of the block is suspect.
+		if dlen < 0 || slen > (dlen-recordHashSize) || dlen > int(rl) || index+rl > lbuf || rl > rlBadThresh {
+			pending = true
+			break
+		}
+		records++
+
+		data := buf[index+msgHdrSize : index+rl]
+		index += rl
+		if hh := mb.hh; hh != nil {
+			hh.Reset()
+			hh.Write(hdr[4:20])
+			hh.Write(data[:slen])
+			if hasHeaders {
+				hh.Write(data[slen+4 : dlen-recordHashSize])
+			} else {
+				hh.Write(data[slen : dlen-recordHashSize])
+			}
+			if !bytes.Equal(hh.Sum(nil), data[len(data)-recordHashSize:]) {
+				pending = true
+				continue
+			}
+		}
+
+		seq := le.Uint64(hdr[4:])
+		// Tombstones are for messages in other blocks.
+		if seq&tbit != 0 {
+			continue
+		}
+		erased := seq&ebit != 0
+		seq &^= ebit
+		// Old messages from before our first sequence.
+		if seq == 0 || seq < fseq {
+			continue
+		}
+		if pending {
+			addBad(last+1, seq-1, errBadMsg)
+			pending = false
+		}
+		if seq <= last || seq > lseq {
+			addBad(seq, seq, fmt.Errorf("%w: unexpected sequence %d", errScrubIndexMismatch, seq))
+			continue
+		}
+		last = seq
+		if !erased && !mb.dmap.Exists(seq) {
+			live++
+		}
+	}
+	if pending {
+		addBad(last+1, lseq, errBadMsg)
+	}
+	// If all records were good make sure they add up to what we have indexed.
+	if len(bad) == 0 && live != mb.msgs {
+		addBad(fseq, lseq, fmt.Errorf("%w: %d messages on disk, %d expected", errScrubIndexMismatch, live, mb.msgs))
+	}
+	return records, bad, nil
+}
+
+// Scrub all of our message blocks, see msgBlock.scrub for details.
+// The wait function is called with the size of each block before it is scrubbed,
+// which allows the caller to throttle or abort.
+// Returns the number of blocks and records checked along with any corrupt ranges.
+func (fs *fileStore) scrub(wait func(n int) error) (int, int, []scrubRange, error) {
+	fs.mu.RLock()
+	if fs.closed {
+		fs.mu.RUnlock()
+		return 0, 0, nil, ErrStoreClosed
+	}
+	blks := slices.Clone(fs.blks)
+	fs.mu.RUnlock()
+
+	var blocks, records int
+	var bad []scrubRange
+	for _, mb := range blks {
+		mb.mu.RLock()
+		sz := int(mb.rbytes)
+		mb.mu.RUnlock()
+		if err := wait(sz); err != nil {
+			return blocks, records, bad, err
+		}
+		n, mbad, err := mb.scrub()
+		if err != nil {
+			// The block could have been removed underneath of us.
+			if err == errNoBlkData && fs.isClosedOrRemoved(mb) {
+				continue
+			}
+			return blocks, records, bad, fmt.Errorf("scrubbing block %d: %w", mb.index, err)
+		}
+		blocks++
+		records += n
+		bad = append(bad, mbad...)
+	}
+	return blocks, records, bad, nil
+}
+
+// Returns true if we are closed or the block is no longer one of ours.
+func (fs *fileStore) isClosedOrRemoved(mb *msgBlock) bool {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.closed || fs.bim[mb.index] != mb
+}
+
+// Replace the messages in [first, last] of the given block with good copies, fetched from a peer,
+// keeping all other records of the block as they are. Messages in the range we have but the copies
+// do not are removed, and copies of messages we have already removed are ignored.
+func (fs *fileStore) repairRange(blk uint32, first, last uint64, msgs []*StoreMsg) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrStoreClosed
+	}
+	// The block could have been removed since it was scrubbed, then there is nothing left to repair.
+	mb := fs.bim[blk]
+	if mb == nil {
+		return nil
+	}
+
+	mb.mu.Lock()
+	msgs0, bytes0 := mb.msgs, mb.bytes
+	err := mb.repairRange(first, last, msgs)
+	dmsgs, dbytes := msgs0-mb.msgs, bytes0-mb.bytes
+	mb.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Nothing changed for our totals if all messages were repaired.
+	if dmsgs == 0 && dbytes == 0 {
+		return nil
+	}
+	fs.rebuildStateLocked(nil)
+	// We do not know the subjects of the messages we lost, so recalculate.
+	fs.resetGlobalPerSubjectInfo()
+	fs.dirty++
+	return nil
+}
+
+// Lock should be held.
+func (mb *msgBlock) repairRange(first, last uint64, msgs []*StoreMsg) error {
+	if mb.closed || mb.cold {
+		return nil
+	}
+	// Make sure everything we have is on disk.
+	if _, err := mb.flushPendingMsgsLocked(); err != nil {
+		return err
+	}
+	if mb.fs.prf != nil && (mb.aek == nil || mb.bek == nil) {
+		if err := mb.fs.loadEncryptionForMsgBlock(mb); err != nil {
+			return err
+		}
+	}
+
+	fseq, lseq := atomic.LoadUint64(&mb.first.seq), atomic.LoadUint64(&mb.last.seq)
+	first, last = max(first, fseq), min(last, lseq)
+	if first > last {
+		return nil
+	}
+
+	buf, err := mb.loadBlock(nil)
+	defer recycleMsgBlockBuf(buf)
+	if err != nil && err != errNoBlkData {
+		return err
+	}
+	if mb.bek != nil && len(buf) > 0 {
+		bek, err := genBlockEncryptionKey(mb.fs.fcfg.Cipher, mb.seed, mb.nonce)
+		if err != nil {
+			return err
+		}
+		bek.XORKeyStream(buf, buf)
+	}
+	// If we can not decompress, the whole block needs to be in the range.
+	if buf, err = mb.decompressIfNeeded(buf); err != nil {
+		buf = nil
+	}
+
+	nbuf := getMsgBlockBuf(len(buf))
+	defer recycleMsgBlockBuf(nbuf)
+
+	var le = binary.LittleEndian
+	var lchk [8]byte
+
+	// Copies we need to put back, skipping what we have removed already.
+	var copies []*StoreMsg
+	for _, sm := range msgs {
+		if sm.seq >= first && sm.seq <= last && !mb.dmap.Exists(sm.seq) {
+			copies = append(copies, sm)
+		}
+	}
+	slices.SortFunc(copies, func(a, b *StoreMsg) int { return cmp.Compare(a.seq, b.seq) })
+	var added bool
+	addCopies := func() {
+		if added {
+			return
+		}
+		added = true
+		for _, sm := range copies {
+			nbuf = appendMsgRecord(nbuf, mb.hh, sm)
+			copy(lchk[0:], nbuf[len(nbuf)-recordHashSize:])
+		}
+	}
+
+	var (
+		// The last good message, and corrupt records seen since.
+		prev uint64
+		bad  []byte
+	)
+	// Corrupt records are only known to be between the good messages around them. They are
+	// dropped when that is inside of the range, and kept as they are when outside of it, so
+	// they can be repaired on their own. Otherwise we could lose good messages.
+	checkBad := func(next uint64) error {
+		if len(bad) == 0 {
+			return nil
+		}
+		switch {
+		case prev+1 >= first && next <= last+1:
+		case next <= first:
+			nbuf = append(nbuf, bad...)
+		case prev >= last:
+			addCopies()
+			nbuf = append(nbuf, bad...)
+		default:
+			return fmt.Errorf("corrupt records between %d and %d overlap range [%d-%d]", prev, next, first, last)
+		}
+		bad = bad[:0]
+		return nil
+	}
+
+	for index, lbuf := uint32(0), uint32(len(buf)); index < lbuf; {
+		if index+msgHdrSize > lbuf {
+			bad = append(bad, buf[index:]...)
+			break
+		}
+		hdr := buf[index : index+msgHdrSize]
+		rl, slen := le.Uint32(hdr[0:]), int(le.Uint16(hdr[20:]))
+
+		hasHeaders := rl&hbit != 0
+		rl &^= hbit
+		dlen := int(rl) - msgHdrSize
+		// Without a sane length we can not find the next record, so the rest of the block is corrupt.
+		if dlen < 0 || slen > (dlen-recordHashSize) || dlen > int(rl) || index+rl > lbuf || rl > rlBadThresh {
+			bad = append(bad, buf[index:]...)
+			break
+		}
+		rec, data := buf[index:index+rl], buf[index+msgHdrSize:index+rl]
+		index += rl
+		if hh := mb.hh; hh != nil {
+			hh.Reset()
+			hh.Write(hdr[4:20])
+			hh.Write(data[:slen])
+			if hasHeaders {
+				hh.Write(data[slen+4 : dlen-recordHashSize])
+			} else {
+				hh.Write(data[slen : dlen-recordHashSize])
+			}
+			if !bytes.Equal(hh.Sum(nil), data[len(data)-recordHashSize:]) {
+				bad = append(bad, rec...)
+				continue
+			}
+		}
+
+		seq := le.Uint64(hdr[4:])
+		// Tombstones and old messages are kept as is.
+		if seq&tbit == 0 && seq != 0 {
+			if seq &^= ebit; seq >= fseq {
+				if err := checkBad(seq); err != nil {
+					return err
+				}
+				if seq >= first && seq <= last {
+					continue
+				}
+				if seq > last {
+					addCopies()
+				}
+				prev = seq
+			}
+		}
+		nbuf = append(nbuf, rec...)
+		copy(lchk[0:], rec[len(rec)-recordHashSize:])
+	}
+	if err := checkBad(lseq + 1); err != nil {
+		return err
+	}
+	addCopies()
+
+	// Handle compression and encryption the same way as when compacting.
+	alg := mb.cmp
+	if alg != NoCompression && mb.fs != nil {
+		alg = mb.fs.fcfg.Compression
+	}
+	if alg != NoCompression && len(nbuf) > 0 {
+		cbuf, err := alg.compressWith(nbuf, mb.fs.zc)
+		if err != nil {
+			return err
+		}
+		meta := &CompressionInfo{
+			Algorithm:    alg,
+			OriginalSize: uint64(len(nbuf)),
+		}
+		nbuf = append(meta.MarshalMetadata(), cbuf...)
+	}
+	if mb.bek != nil && len(nbuf) > 0 {
+		rbek, err := genBlockEncryptionKey(mb.fs.fcfg.Cipher, mb.seed, mb.nonce)
+		if err != nil {
+			return err
+		}
+		rbek.XORKeyStream(nbuf, nbuf)
+	}
+
+	mb.closeFDsLocked()
+
+	// We will write to a new file and mv/rename it in case of failure.
+	mfn := filepath.Join(mb.fs.fcfg.StoreDir, msgDir, fmt.Sprintf(newScan, mb.index))
+	<-dios
+	err = os.WriteFile(mfn, nbuf, defaultFilePerms)
+	dios <- struct{}{}
+	if err != nil {
+		os.Remove(mfn)
+		return err
+	}
+	if err := os.Rename(mfn, mb.mfn); err != nil {
+		os.Remove(mfn)
+		return err
+	}
+	mb.needSync = true
+	mb.cmp = alg
+	mb.rbytes = uint64(len(nbuf))
+	mb.lchk = lchk
+
+	// Messages in the range the copies do not have are gone now.
+	var removed bool
+	for seq, i := first, 0; seq <= last; seq++ {
+		if i < len(copies) && copies[i].seq == seq {
+			i++
+			continue
+		}
+		if mb.dmap.Exists(seq) {
+			continue
+		}
+		mb.dmap.Insert(seq)
+		removed = true
+	}
+	if removed {
+		mb.clearCacheAndOffset()
+		// Recalculate what is left from the indexed records.
+		if err := mb.loadMsgsWithLock(); err != nil {
+			return err
+		}
+		mb.msgs, mb.bytes = 0, 0
+		for seq := fseq; seq <= lseq; seq++ {
+			if mb.dmap.Exists(seq) {
+				continue
+			}
+			var smv StoreMsg
+			if sm, err := mb.cacheLookup(seq, &smv); err == nil && sm != nil {
+				mb.msgs++
+				mb.bytes += fileStoreMsgSize(sm.subj, sm.hdr, sm.msg)
+			}
+		}
+		if mb.dmap.Exists(fseq) {
+			mb.dmap.Delete(fseq)
+			mb.selectNextFirst()
+		}
+	}
+	mb.fss = nil
+	mb.clearCacheAndOffset()
+	return nil
+}
+
+// Append a message record in our on disk format.
+func appendMsgRecord(buf []byte, hh hash.Hash64, sm *StoreMsg) []byte {
+	var le = binary.LittleEndian
+	var hdr [msgHdrSize]byte
+
+	rl := uint32(fileStoreMsgSize(sm.subj, sm.hdr, sm.msg))
+	hasHeaders := len(sm.hdr) > 0
+	if hasHeaders {
+		rl |= hbit
+	}
+	le.PutUint32(hdr[0:], rl)
+	le.PutUint64(hdr[4:], sm.seq)
+	le.PutUint64(hdr[12:], uint64(sm.ts))
+	le.PutUint16(hdr[20:], uint16(len(sm.subj)))
+
+	buf = append(buf, hdr[:]...)
+	buf = append(buf, sm.subj...)
+	if hasHeaders {
+		var hlen [4]byte
+		le.PutUint32(hlen[0:], uint32(len(sm.hdr)))
+		buf = append(buf, hlen[:]...)
+		buf = append(buf, sm.hdr...)
+	}
+	buf = append(buf, sm.msg...)
+
+	hh.Reset()
+	hh.Write(hdr[4:20])
+	hh.Write([]byte(sm.subj))
+	if hasHeaders {
+		hh.Write(sm.hdr)
+	}
+	hh.Write(sm.msg)
+	return append(buf, hh.Sum(nil)...)
+}
@@ -41,6 +41,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
 	"github.com/nats-io/nuid"
 )
 
@@ -52,6 +53,9 @@ func testFileStoreAllPermutations(t *testing.T, fn func(t *testing.T, fcfg FileS
 		{Cipher: AES, Compression: S2Compression},
 		{Cipher: ChaCha, Compression: NoCompression},
 		{Cipher: ChaCha, Compression: S2Compression},
+		{Cipher: NoCipher, Compression: ZstdCompression},
+		{Cipher: AES, Compression: ZstdCompression},
+		{Cipher: ChaCha, Compression: ZstdCompression},
 	} {
 		subtestName := fmt.Sprintf("%s-%s", fcfg.Cipher, fcfg.Compression)
 		t.Run(subtestName, func(t *testing.T) {
@@ -4071,6 +4075,138 @@ func TestFileStoreEncrypted(t *testing.T) {
 	})
 }
 
+func TestFileStoreRotateEncryptionKey(t *testing.T) {
+	testFileStoreAllPermutations(t, func(t *testing.T, fcfg FileStoreConfig) {
+		if fcfg.Cipher == NoCipher {
+			t.SkipNow()
+		}
+		newPrf := func(context []byte) ([]byte, error) {
+			h := hmac.New(sha256.New, []byte("rotated"))
+			if _, err := h.Write(context); err != nil {
+				return nil, err
+			}
+			return h.Sum(nil), nil
+		}
+
+		// Small blocks so we have plenty of block keys.
+		fcfg.BlockSize = 256
+		created := time.Now()
+		cfg := StreamConfig{Name: "zzz", Storage: FileStorage}
+		fs, err := newFileStoreWithCreated(fcfg, cfg, created, prf(&fcfg), nil)
+		require_NoError(t, err)
+		defer fs.Stop()
+
+		subj, msg := "foo", []byte("rotate me")
+		for i := 0; i < 50; i++ {
+			_, _, err = fs.StoreMsg(subj, nil, msg, 0)
+			require_NoError(t, err)
+		}
+		nblks := fs.numMsgBlocks()
+		require_True(t, nblks > 2)
+
+		o, err := fs.ConsumerStore("o22", &ConsumerConfig{})
+		require_NoError(t, err)
+		state := &ConsumerState{}
+		state.Delivered.Consumer, state.Delivered.Stream = 22, 22
+		state.AckFloor.Consumer, state.AckFloor.Stream = 11, 11
+		require_NoError(t, o.Update(state))
+
+		checkStore := func(fs *fileStore) {
+			t.Helper()
+			var smv StoreMsg
+			for seq := uint64(1); seq <= 50; seq++ {
+				sm, err := fs.LoadMsg(seq, &smv)
+				require_NoError(t, err)
+				require_Equal(t, string(sm.msg), string(msg))
+			}
+			o, err := fs.ConsumerStore("o22", &ConsumerConfig{})
+			require_NoError(t, err)
+			rstate, err := o.State()
+			require_NoError(t, err)
+			require_Equal(t, rstate.Delivered, state.Delivered)
+			require_Equal(t, rstate.AckFloor, state.AckFloor)
+		}
+
+		// Seeds of our message blocks, which should all be replaced.
+		mdir := filepath.Join(fcfg.StoreDir, msgDir)
+		blockSeeds := func(prf keyGen) map[uint32][]byte {
+			t.Helper()
+			seeds := make(map[uint32][]byte)
+			fis, err := os.ReadDir(mdir)
+			require_NoError(t, err)
+			for _, fi := range fis {
+				var index uint32
+				if n, err := fmt.Sscanf(fi.Name(), keyScan, &index); err != nil || n != 1 || strings.HasSuffix(fi.Name(), rekeyTmpSuffix) {
+					continue
+				}
+				ekey, err := os.ReadFile(filepath.Join(mdir, fi.Name()))
+				require_NoError(t, err)
+				seed, err := openKeySeed(ekey, fmt.Sprintf("%s:%d", cfg.Name, index), fcfg.Cipher, prf)
+				require_NoError(t, err)
+				seeds[index] = seed
+			}
+			return seeds
+		}
+		oseeds := blockSeeds(prf(&fcfg))
+		require_Equal(t, len(oseeds), nblks)
+
+		// Abort part way through, keys can still be opened with either key.
+		var calls int
+		errAbort := errors.New("abort")
+		n, nb, err := fs.rotateEncryptionKey(newPrf, func(int) error {
+			if calls++; calls > 3 {
+				return errAbort
+			}
+			return nil
+		})
+		require_Error(t, err, errAbort)
+		require_Equal(t, n, 1)
+		require_Equal(t, nb, 2)
+		fs.Stop()
+
+		// A block re-encryption that did not complete is undone.
+		require_NoError(t, os.WriteFile(filepath.Join(mdir, fmt.Sprintf(blkScan, 5)+rekeyTmpSuffix), []byte("partial"), defaultFilePerms))
+		require_NoError(t, os.WriteFile(filepath.Join(mdir, fmt.Sprintf(keyScan, 5)+rekeyTmpSuffix), []byte("partial"), defaultFilePerms))
+
+		// Restarting with the old key as the prior key allows us to finish the rotation, while the store is in use.
+		fs, err = newFileStoreWithCreated(fcfg, cfg, created, newPrf, prf(&fcfg))
+		require_NoError(t, err)
+		defer fs.Stop()
+		for _, f := range []string{fmt.Sprintf(blkScan, 5), fmt.Sprintf(keyScan, 5)} {
+			_, err = os.Stat(filepath.Join(mdir, f+rekeyTmpSuffix))
+			require_True(t, os.IsNotExist(err))
+		}
+		var stored int
+		_, _, err = fs.rotateEncryptionKey(newPrf, func(int) error {
+			stored++
+			_, _, err := fs.StoreMsg("bar", nil, msg, 0)
+			return err
+		})
+		require_NoError(t, err)
+		checkStore(fs)
+		fs.Stop()
+
+		// Only the new key is needed from here on.
+		fs, err = newFileStoreWithCreated(fcfg, cfg, created, newPrf, nil)
+		require_NoError(t, err)
+		defer fs.Stop()
+		checkStore(fs)
+		require_Equal(t, fs.State().Msgs, uint64(50+stored))
+
+		// The old blocks were re-encrypted with new seeds.
+		nseeds := blockSeeds(newPrf)
+		for index, seed := range oseeds {
+			require_False(t, bytes.Equal(nseeds[index], seed))
+		}
+
+		// Nothing left to rotate.
+		n, nb, err = fs.rotateEncryptionKey(newPrf, func(int) error { return nil })
+		require_NoError(t, err)
+		require_Equal(t, n, 0)
+		require_Equal(t, nb, 0)
+	})
+}
+
 // Make sure we do not go through block loads when we know no subjects will exists, e.g. raft.
 func TestFileStoreNoFSSWhenNoSubjects(t *testing.T) {
 	testFileStoreAllPermutations(t, func(t *testing.T, fcfg FileStoreConfig) {
@@ -7646,6 +7782,111 @@ func TestFileStoreSyncCompressOnlyIfDirty(t *testing.T) {
 	require_False(t, noCompact)
 }
 
+func TestFileStoreZstdCompressionOpts(t *testing.T) {
+	var samples [][]byte
+	for i := 0; i < 100; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"order":%d,"customer":"customer-%d","status":"shipped","items":[{"sku":"sku-%d"}]}`, i, i%7, i%13)))
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{ID: 1, Contents: samples, History: bytes.Join(samples, nil)})
+	require_NoError(t, err)
+
+	for _, opts := range []*StoreCompressionOpts{
+		nil,
+		{Level: 1},
+		{Level: 19},
+		{Dictionary: dict},
+		{Level: 3, Dictionary: dict},
+	} {
+		require_NoError(t, opts.validate())
+
+		fcfg := FileStoreConfig{StoreDir: t.TempDir(), BlockSize: 4096, Compression: ZstdCompression, CompressionOpts: opts}
+		cfg := StreamConfig{Name: "zzz", Subjects: []string{"foo"}, Storage: FileStorage}
+		fs, err := newFileStore(fcfg, cfg)
+		require_NoError(t, err)
+
+		for _, msg := range samples {
+			_, _, err = fs.StoreMsg("foo", nil, msg, 0)
+			require_NoError(t, err)
+		}
+		// Only the default options share coders, others are released with the store.
+		zc := fs.zc
+		require_Equal(t, zc == nil, opts == nil)
+		fs.Stop()
+		if zc != nil {
+			require_True(t, zc.closed)
+			require_True(t, zc.enc == nil && zc.dec == nil)
+		}
+
+		// Blocks are read back with the same options after a restart.
+		fs, err = newFileStore(fcfg, cfg)
+		require_NoError(t, err)
+		for i, msg := range samples {
+			sm, err := fs.LoadMsg(uint64(i+1), nil)
+			require_NoError(t, err)
+			require_True(t, bytes.Equal(sm.msg, msg))
+		}
+		fs.Stop()
+	}
+
+	for _, opts := range []*StoreCompressionOpts{
+		{Level: -1},
+		{Level: maxZstdCompressionLevel + 1},
+		{Dictionary: []byte("not a dictionary")},
+	} {
+		require_True(t, opts.validate() != nil)
+	}
+}
+
+func TestFileStoreCompressionUpdateRecompressesOnCompact(t *testing.T) {
+	sd := t.TempDir()
+	fs, err := newFileStore(
+		FileStoreConfig{StoreDir: sd, BlockSize: 8192, Compression: S2Compression},
+		StreamConfig{Name: "zzz", Subjects: []string{"foo"}, Storage: FileStorage, Compression: S2Compression})
+	require_NoError(t, err)
+	defer fs.Stop()
+
+	msg := bytes.Repeat([]byte("ABCD"), 32)
+	for i := 0; i < 40; i++ {
+		_, _, err = fs.StoreMsg("foo", nil, msg, 0)
+		require_NoError(t, err)
+	}
+
+	fs.mu.RLock()
+	fmb := fs.blks[0]
+	fs.mu.RUnlock()
+	require_NoError(t, fmb.recompressOnDiskIfNeeded())
+	fmb.mu.RLock()
+	alg := fmb.cmp
+	fmb.mu.RUnlock()
+	require_Equal(t, alg, S2Compression)
+
+	require_NoError(t, fs.UpdateConfig(&StreamConfig{Name: "zzz", Subjects: []string{"foo"}, Storage: FileStorage, Compression: ZstdCompression}))
+
+	// Compacting the block rewrites it using the updated algorithm.
+	for seq := uint64(1); seq <= 20; seq++ {
+		_, err = fs.RemoveMsg(seq)
+		require_NoError(t, err)
+	}
+	fmb.mu.Lock()
+	fmb.compactWithFloor(0)
+	alg = fmb.cmp
+	fmb.mu.Unlock()
+	require_Equal(t, alg, ZstdCompression)
+
+	buf, err := os.ReadFile(fmb.mfn)
+	require_NoError(t, err)
+	var meta CompressionInfo
+	_, err = meta.UnmarshalMetadata(buf)
+	require_NoError(t, err)
+	require_Equal(t, meta.Algorithm, ZstdCompression)
+
+	for seq := uint64(21); seq <= 40; seq++ {
+		sm, err := fs.LoadMsg(seq, nil)
+		require_NoError(t, err)
+		require_True(t, bytes.Equal(sm.msg, msg))
+	}
+}
+
 // This test is for deleted interior message tracking after compaction from limits based deletes, meaning no tombstones.
 // Bug was that dmap would not be properly be hydrated after the compact from rebuild. But we did so in populateGlobalInfo.
 // So this is just to fix a bug in rebuildState tracking gaps after a compact.
@@ -8887,3 +9128,179 @@ func changeDirectoryPermission(directory string, mode fs.FileMode) error {
 	})
 	return err
 }
+
+func TestFileStoreColdTier(t *testing.T) {
+	testFileStoreAllPermutations(t, func(t *testing.T, fcfg FileStoreConfig) {
+		coldDir := t.TempDir()
+		fcfg.BlockSize = 1024
+		fcfg.ColdStore = newDirColdStore(coldDir)
+		cfg := StreamConfig{Name: "zzz", Subjects: []string{"foo.*"}, Storage: FileStorage, ColdTier: &StreamColdTier{MaxBytes: 2048}}
+		created := time.Now()
+		fs, err := newFileStoreWithCreated(fcfg, cfg, created, prf(&fcfg), nil)
+		require_NoError(t, err)
+		defer fs.Stop()
+
+		msg := bytes.Repeat([]byte("Z"), 100)
+		for i := 0; i < 100; i++ {
+			_, _, err = fs.StoreMsg(fmt.Sprintf("foo.%d", i%10), nil, msg, 0)
+			require_NoError(t, err)
+		}
+		fs.syncBlocks()
+
+		coldBlocks := func() int {
+			t.Helper()
+			names, err := fcfg.ColdStore.List()
+			require_NoError(t, err)
+			return len(names)
+		}
+		ncold := coldBlocks()
+		require_True(t, ncold > 0)
+		require_Equal(t, fs.ncold.Load(), int64(ncold))
+
+		// Cold blocks don't have a local copy anymore.
+		fs.mu.RLock()
+		mb := fs.blks[0]
+		fs.mu.RUnlock()
+		require_True(t, mb.cold)
+		_, err = os.Stat(mb.mfn)
+		require_True(t, os.IsNotExist(err))
+
+		state := fs.State()
+		require_True(t, state.ColdBytes > 0)
+		require_True(t, state.HotBytes <= 2048)
+		require_Equal(t, state.HotBytes+state.ColdBytes, state.Bytes)
+		var fstate StreamState
+		fs.FastState(&fstate)
+		require_Equal(t, fstate.ColdBytes, state.ColdBytes)
+
+		checkMsgs := func() {
+			t.Helper()
+			for seq := uint64(1); seq <= 100; seq++ {
+				sm, err := fs.LoadMsg(seq, nil)
+				require_NoError(t, err)
+				require_Equal(t, sm.subj, fmt.Sprintf("foo.%d", (seq-1)%10))
+				require_True(t, bytes.Equal(sm.msg, msg))
+			}
+			sm, _, err := fs.LoadNextMsg("foo.3", false, 1, nil)
+			require_NoError(t, err)
+			require_Equal(t, sm.seq, 4)
+		}
+		// Cold blocks are fetched on demand.
+		checkMsgs()
+		_, err = os.Stat(mb.mfn)
+		require_NoError(t, err)
+
+		// Only a few local copies are kept around.
+		fs.tierBlocks()
+		var cached int
+		fs.mu.RLock()
+		for _, mb := range fs.blks {
+			mb.mu.RLock()
+			if mb.cold && mb.clts > 0 {
+				cached++
+			}
+			mb.mu.RUnlock()
+		}
+		fs.mu.RUnlock()
+		require_True(t, cached <= coldCacheMaxBlocks)
+
+		// Cold blocks are recovered on restart, also when rebuilding without the stream state.
+		for _, rebuild := range []bool{false, true} {
+			fs.Stop()
+			if rebuild {
+				require_NoError(t, os.Remove(filepath.Join(fcfg.StoreDir, msgDir, streamStreamStateFile)))
+			}
+			fs, err = newFileStoreWithCreated(fcfg, cfg, created, prf(&fcfg), nil)
+			require_NoError(t, err)
+			defer fs.Stop()
+			require_Equal(t, fs.ncold.Load(), int64(ncold))
+			require_Equal(t, fs.State().ColdBytes, state.ColdBytes)
+			checkMsgs()
+		}
+
+		// Truncating into a cold block moves it back.
+		require_NoError(t, fs.Truncate(15))
+		require_True(t, coldBlocks() < ncold)
+		sm, err := fs.LoadMsg(15, nil)
+		require_NoError(t, err)
+		require_Equal(t, sm.subj, "foo.4")
+
+		// Purging removes all cold blocks.
+		_, err = fs.Purge()
+		require_NoError(t, err)
+		require_Equal(t, coldBlocks(), 0)
+		require_Equal(t, fs.ncold.Load(), int64(0))
+	})
+}
+
+func TestFileStoreScrub(t *testing.T) {
+	testFileStoreAllPermutations(t, func(t *testing.T, fcfg FileStoreConfig) {
+		fs, err := newFileStoreWithCreated(fcfg, StreamConfig{Name: "zzz", Storage: FileStorage}, time.Now(), prf(&fcfg), nil)
+		require_NoError(t, err)
+		defer fs.Stop()
+
+		for i := 0; i < 100; i++ {
+			_, _, err = fs.StoreMsg("foo", nil, []byte("Hello World"), 0)
+			require_NoError(t, err)
+		}
+		_, err = fs.RemoveMsg(10)
+		require_NoError(t, err)
+
+		var waited int
+		wait := func(n int) error {
+			waited += n
+			return nil
+		}
+		blocks, records, bad, err := fs.scrub(wait)
+		require_NoError(t, err)
+		require_Equal(t, blocks, 1)
+		require_Equal(t, records, 101)
+		require_Len(t, len(bad), 0)
+		require_True(t, waited > 0)
+
+		// Only the corrupt message is reported.
+		corruptStoredMsg(t, fs, 50)
+		_, _, bad, err = fs.scrub(wait)
+		require_NoError(t, err)
+		require_Len(t, len(bad), 1)
+		require_Equal(t, bad[0].blk, 1)
+		require_Equal(t, bad[0].first, 50)
+		require_Equal(t, bad[0].last, 50)
+		require_Error(t, bad[0].err, errBadMsg)
+
+		// Nothing was changed by the scrub.
+		state := fs.State()
+		require_Equal(t, state.Msgs, 99)
+		_, err = fs.LoadMsg(51, nil)
+		require_NoError(t, err)
+
+		// A corrupt last message can not be bounded by a good one after it.
+		corruptStoredMsg(t, fs, 100)
+		_, _, bad, err = fs.scrub(wait)
+		require_NoError(t, err)
+		require_Len(t, len(bad), 2)
+		require_Equal(t, bad[1].first, 100)
+		require_Equal(t, bad[1].last, 100)
+
+		// Repairs only replace the corrupt messages. Without a copy the message is removed.
+		fixed := &StoreMsg{subj: "foo", msg: []byte("Hello World"), seq: 50, ts: time.Now().UnixNano()}
+		require_NoError(t, fs.repairRange(bad[0].blk, 50, 50, []*StoreMsg{fixed}))
+		require_NoError(t, fs.repairRange(bad[1].blk, 100, 100, nil))
+		_, _, bad, err = fs.scrub(wait)
+		require_NoError(t, err)
+		require_Len(t, len(bad), 0)
+		state = fs.State()
+		require_Equal(t, state.Msgs, 98)
+		require_Equal(t, state.FirstSeq, 1)
+		require_Equal(t, state.LastSeq, 100)
+		sm, err := fs.LoadMsg(50, nil)
+		require_NoError(t, err)
+		require_Equal(t, string(sm.msg), "Hello World")
+		_, err = fs.LoadMsg(100, nil)
+		require_Error(t, err, ErrStoreMsgNotFound, errDeletedMsg)
+
+		// The wait function can abort.
+		_, _, _, err = fs.scrub(func(int) error { return ErrServerNotRunning })
+		require_Error(t, err, ErrServerNotRunning)
+	})
+}
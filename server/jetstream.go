@@ -121,6 +121,21 @@ type jetStream struct {
 	// System level request to purge a stream move
 	accountPurge *subscription
 
+	// Status of the last online encryption key rotation.
+	krs *KeyRotationStatus
+
+	// Status of the last integrity scrub.
+	scrub *ScrubStatus
+
+	// Status of the replica rebalancer while we are the meta leader.
+	rebalance *RebalanceStatus
+
+	// Load of the leaders we hold, for the leader balancer.
+	lloads leaderLoads
+
+	// Lock on our store directory, held until we shut down.
+	storeLock *os.File
+
 	// Some bools regarding general state.
 	metaRecovering bool
 	standAlone     bool
@@ -270,7 +285,11 @@ func (s *Server) decryptMeta(sc StoreCipher, ekey, buf []byte, acc, context stri
 		StoreCipher
 	}
 	var prfs []prfWithCipher
-	if prf := s.jsKeyGen(s.getOpts().JetStreamKey, acc); prf == nil {
+	prf, oldprf, err := s.jsAccountKeyGen(acc, acc)
+	if err != nil {
+		return nil, false, err
+	}
+	if prf == nil {
 		return nil, false, errNoEncryption
 	} else {
 		// First of all, try our current encryption keys with both
@@ -278,7 +297,7 @@ func (s *Server) decryptMeta(sc StoreCipher, ekey, buf []byte, acc, context stri
 		prfs = append(prfs, prfWithCipher{prf, sc})
 		prfs = append(prfs, prfWithCipher{prf, osc})
 	}
-	if prf := s.jsKeyGen(s.getOpts().JetStreamOldKey, acc); prf != nil {
+	if prf := oldprf; prf != nil {
 		// Then, if we have an old encryption key, try with also with
 		// both store cipher algorithms.
 		prfs = append(prfs, prfWithCipher{prf, sc})
@@ -442,6 +461,13 @@ func (s *Server) enableJetStream(cfg JetStreamConfig) error {
 		os.Remove(tmpfile.Name())
 	}
 
+	// Let offline tools such as the store inspector know that the store is in use.
+	if lf, err := lockStoreDir(cfg.StoreDir); err != nil {
+		s.Warnf("Could not lock storage directory: %v", err)
+	} else {
+		js.storeLock = lf
+	}
+
 	if err := s.initJetStreamEncryption(); err != nil {
 		return err
 	}
@@ -471,6 +497,9 @@ func (s *Server) enableJetStream(cfg JetStreamConfig) error {
 	s.Noticef("  Max Memory:      %s", friendlyBytes(cfg.MaxMemory))
 	s.Noticef("  Max Storage:     %s", friendlyBytes(cfg.MaxStore))
 	s.Noticef("  Store Directory: \"%s\"", cfg.StoreDir)
+	if opts.JetStreamColdStoreDir != _EMPTY_ {
+		s.Noticef("  Cold Store:      \"%s\"", opts.JetStreamColdStoreDir)
+	}
 	if cfg.Domain != _EMPTY_ {
 		s.Noticef("  Domain:          %s", cfg.Domain)
 	}
@@ -478,6 +507,18 @@ func (s *Server) enableJetStream(cfg JetStreamConfig) error {
 	if ek := opts.JetStreamKey; ek != _EMPTY_ {
 		s.Noticef("  Encryption:      %s", opts.JetStreamCipher)
 	}
+	if opts.JetStreamKeyProvider != nil {
+		s.Noticef("  Account Keys:    %s", opts.JetStreamCipher)
+	}
+	if opts.JetStreamScrubInterval > 0 {
+		s.Noticef("  Scrub Interval:  %v", opts.JetStreamScrubInterval)
+	}
+	if opts.JetStreamLeaderBalance.Interval > 0 {
+		s.Noticef("  Leader Balance:  %v", opts.JetStreamLeaderBalance.Interval)
+	}
+	if opts.JetStreamRebalance.Interval > 0 {
+		s.Noticef("  Rebalance:       %v", opts.JetStreamRebalance.Interval)
+	}
 	if opts.JetStreamTpm.KeysFile != _EMPTY_ {
 		s.Noticef("  TPM File:        %q, Pcr: %d", opts.JetStreamTpm.KeysFile,
 			opts.JetStreamTpm.Pcr)
@@ -519,6 +560,21 @@ func (s *Server) enableJetStream(cfg JetStreamConfig) error {
 		s.jsClustered.Store(true)
 	}
 
+	// Start our background integrity scrubber if configured.
+	if interval := opts.JetStreamScrubInterval; interval > 0 {
+		s.startGoRoutine(func() { s.runJetStreamScrubber(js, interval) })
+	}
+
+	// Balance leaders across the cluster if configured, this only acts while we are the meta leader.
+	if interval := opts.JetStreamLeaderBalance.Interval; interval > 0 && s.JetStreamIsClustered() {
+		s.startGoRoutine(func() { s.runJetStreamLeaderBalancer(js, interval) })
+	}
+
+	// Move replicas onto underused servers if configured, this also only acts while we are the meta leader.
+	if interval := opts.JetStreamRebalance.Interval; interval > 0 && s.JetStreamIsClustered() {
+		s.startGoRoutine(func() { s.runJetStreamRebalancer(js, interval) })
+	}
+
 	// Mark when we are up and running.
 	js.setStarted()
 
@@ -816,7 +872,7 @@ func (s *Server) configAllJetStreamAccounts() error {
 		saccName := s.sys.account.Name
 		accStoreDirs, _ := os.ReadDir(js.config.StoreDir)
 		for _, acc := range accStoreDirs {
-			if accName := acc.Name(); accName != saccName {
+			if accName := acc.Name(); acc.IsDir() && accName != saccName {
 				// no op if not empty
 				accDir := filepath.Join(js.config.StoreDir, accName)
 				os.Remove(filepath.Join(accDir, streamsDir))
@@ -845,7 +901,7 @@ func (s *Server) configAllJetStreamAccounts() error {
 	// This is important in resolver/operator models.
 	fis, _ := os.ReadDir(js.config.StoreDir)
 	for _, fi := range fis {
-		if accName := fi.Name(); accName != _EMPTY_ {
+		if accName := fi.Name(); fi.IsDir() && accName != _EMPTY_ {
 			// Only load up ones not already loaded since they are processed above.
 			if _, ok := accounts.Load(accName); !ok {
 				if acc, err := s.lookupAccount(accName); err != nil && acc != nil {
@@ -1041,6 +1097,14 @@ func (s *Server) shutdownJetStream() {
 		default:
 		}
 	}
+
+	// Our stores are all stopped, so release the store directory.
+	js.mu.Lock()
+	if js.storeLock != nil {
+		js.storeLock.Close()
+		js.storeLock = nil
+	}
+	js.mu.Unlock()
 }
 
 // JetStreamConfig will return the current config. Useful if the system
@@ -1262,7 +1326,7 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 	var ipstreams []*stream
 
 	// Remember if we should be encrypted and what cipher we think we should use.
-	encrypted := s.getOpts().JetStreamKey != _EMPTY_
+	encrypted := s.getOpts().JetStreamKey != _EMPTY_ || s.getOpts().JetStreamKeyProvider != nil
 	plaintext := true
 	sc := s.getOpts().JetStreamCipher
 
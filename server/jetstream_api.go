@@ -178,6 +178,22 @@ const (
 	JSApiConsumerUnpin  = "$JS.API.CONSUMER.UNPIN.*.*"
 	JSApiConsumerUnpinT = "$JS.API.CONSUMER.UNPIN.%s.%s"
 
+	// JSApiConsumerReset is the endpoint to move a consumer to a new starting position.
+	// Will return JSON response.
+	JSApiConsumerReset  = "$JS.API.CONSUMER.RESET.*.*"
+	JSApiConsumerResetT = "$JS.API.CONSUMER.RESET.%s.%s"
+
+	// JSApiConsumerPending is the endpoint to list the messages a consumer is waiting on acks for.
+	// Will return JSON response.
+	JSApiConsumerPending  = "$JS.API.CONSUMER.PENDING.*.*"
+	JSApiConsumerPendingT = "$JS.API.CONSUMER.PENDING.%s.%s"
+
+	// JSApiConsumerPendingAction is the endpoint to redeliver, skip or terminate a pending message.
+	// This is not under PENDING, which would make it overlap with a stream named ACTION.
+	// Will return JSON response.
+	JSApiConsumerPendingAction  = "$JS.API.CONSUMER.ACTION.*.*"
+	JSApiConsumerPendingActionT = "$JS.API.CONSUMER.ACTION.%s.%s"
+
 	// jsRequestNextPre
 	jsRequestNextPre = "$JS.API.CONSUMER.MSG.NEXT."
 
@@ -242,6 +258,10 @@ const (
 	// jsFlowControl is for FC responses.
 	jsFlowControl = "$JS.FC.%s.%s.*"
 
+	// jsDeadLetter is for publish acknowledgements of dead-lettered messages.
+	jsDeadLetter  = "$JS.DL.%s.%s.*"
+	jsDeadLetterT = "$JS.DL.%s.%s.%d"
+
 	// JSAdvisoryPrefix is a prefix for all JetStream advisories.
 	JSAdvisoryPrefix = "$JS.EVENT.ADVISORY"
 
@@ -260,6 +280,9 @@ const (
 	// JSAdvisoryConsumerMsgTerminatedPre is a notification published when a message has been terminated.
 	JSAdvisoryConsumerMsgTerminatedPre = "$JS.EVENT.ADVISORY.CONSUMER.MSG_TERMINATED"
 
+	// JSAdvisoryConsumerDeadLetterFailedPre is a notification published when a message could not be dead-lettered.
+	JSAdvisoryConsumerDeadLetterFailedPre = "$JS.EVENT.ADVISORY.CONSUMER.DEAD_LETTER_FAILED"
+
 	// JSAdvisoryStreamCreatedPre notification that a stream was created.
 	JSAdvisoryStreamCreatedPre = "$JS.EVENT.ADVISORY.STREAM.CREATED"
 
@@ -284,6 +307,9 @@ const (
 	// JSAdvisoryConsumerUnpinnedPre notification that a consumer was unpinned.
 	JSAdvisoryConsumerUnpinnedPre = "$JS.EVENT.ADVISORY.CONSUMER.UNPINNED"
 
+	// JSAdvisoryConsumerResetPre notification that a consumer was reset.
+	JSAdvisoryConsumerResetPre = "$JS.EVENT.ADVISORY.CONSUMER.RESET"
+
 	// JSAdvisoryStreamSnapshotCreatePre notification that a snapshot was created.
 	JSAdvisoryStreamSnapshotCreatePre = "$JS.EVENT.ADVISORY.STREAM.SNAPSHOT_CREATE"
 
@@ -314,6 +340,14 @@ const (
 	// JSAdvisoryServerOutOfStorage notification that a server has no more storage.
 	JSAdvisoryServerOutOfStorage = "$JS.EVENT.ADVISORY.SERVER.OUT_OF_STORAGE"
 
+	// JSAdvisoryStreamCorruptPre notification that corrupt messages were found in a stream's store.
+	// This is sent to the system account.
+	JSAdvisoryStreamCorruptPre = "$JS.EVENT.ADVISORY.STREAM.CORRUPT"
+
+	// JSAdvisoryStreamRebalancePre notification that a stream is being moved to balance replicas.
+	// This is sent to the system account.
+	JSAdvisoryStreamRebalancePre = "$JS.EVENT.ADVISORY.STREAM.REBALANCE"
+
 	// JSAdvisoryServerRemoved notification that a server has been removed from the system.
 	JSAdvisoryServerRemoved = "$JS.EVENT.ADVISORY.SERVER.REMOVED"
 
@@ -526,6 +560,49 @@ type JSApiConsumerUnpinResponse struct {
 
 const JSApiConsumerUnpinResponseType = "io.nats.jetstream.api.v1.consumer_unpin_response"
 
+// JSApiConsumerResetRequest moves a consumer to a new starting position.
+// Exactly one of the stream sequence or start time should be set.
+type JSApiConsumerResetRequest struct {
+	Sequence  uint64     `json:"seq,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+}
+
+type JSApiConsumerResetResponse struct {
+	ApiResponse
+	*ConsumerInfo
+}
+
+const JSApiConsumerResetResponseType = "io.nats.jetstream.api.v1.consumer_reset_response"
+
+// JSApiConsumerPendingRequest pages through the pending messages of a consumer.
+type JSApiConsumerPendingRequest struct {
+	ApiPagedRequest
+}
+
+// JSApiConsumerPendingResponse lists pending messages ordered by stream sequence.
+type JSApiConsumerPendingResponse struct {
+	ApiResponse
+	ApiPaged
+	Pending []*ConsumerPendingAck `json:"pending"`
+}
+
+const JSApiConsumerPendingResponseType = "io.nats.jetstream.api.v1.consumer_pending_response"
+
+// JSApiConsumerPendingActionRequest acts on a single pending message on behalf of the client.
+type JSApiConsumerPendingActionRequest struct {
+	Sequence uint64                `json:"seq"`
+	Action   ConsumerPendingAction `json:"action"`
+	// Reason is included in the terminated advisory.
+	Reason string `json:"reason,omitempty"`
+}
+
+type JSApiConsumerPendingActionResponse struct {
+	ApiResponse
+	Success bool `json:"success,omitempty"`
+}
+
+const JSApiConsumerPendingActionResponseType = "io.nats.jetstream.api.v1.consumer_pending_action_response"
+
 // JSApiStreamUpdateResponse for updating a stream.
 type JSApiStreamUpdateResponse struct {
 	ApiResponse
@@ -689,6 +766,10 @@ type JSApiMsgGetRequest struct {
 	UpToSeq uint64 `json:"up_to_seq,omitempty"`
 	// Only return messages up to this time.
 	UpToTime *time.Time `json:"up_to_time,omitempty"`
+
+	// Linearizable reads reflect all writes acknowledged before the request.
+	// These are only answered by the stream leader while it holds its leader lease.
+	Linearizable bool `json:"linearizable,omitempty"`
 }
 
 type JSApiMsgGetResponse struct {
@@ -1006,6 +1087,9 @@ func (s *Server) setJetStreamExportSubs() error {
 		{JSApiConsumerDelete, s.jsConsumerDeleteRequest},
 		{JSApiConsumerPause, s.jsConsumerPauseRequest},
 		{JSApiConsumerUnpin, s.jsConsumerUnpinRequest},
+		{JSApiConsumerReset, s.jsConsumerResetRequest},
+		{JSApiConsumerPending, s.jsConsumerPendingRequest},
+		{JSApiConsumerPendingAction, s.jsConsumerPendingActionRequest},
 	}
 
 	js.mu.Lock()
@@ -2682,6 +2766,7 @@ func (s *Server) jsLeaderServerStreamMoveRequest(sub *subscription, c *client, _
 	cfg := StreamConfig{}
 	currPeers := []string{}
 	currCluster := _EMPTY_
+	var currLearners []string
 	js.mu.Lock()
 	streams, ok := cc.streams[accName]
 	if ok {
@@ -2691,6 +2776,7 @@ func (s *Server) jsLeaderServerStreamMoveRequest(sub *subscription, c *client, _
 			streamFound = true
 			currPeers = sa.Group.Peers
 			currCluster = sa.Group.Cluster
+			currLearners = sa.Group.Learners
 		}
 	}
 	js.mu.Unlock()
@@ -2743,7 +2829,8 @@ func (s *Server) jsLeaderServerStreamMoveRequest(sub *subscription, c *client, _
 		cfg.Placement.Tags = append(cfg.Placement.Tags, req.Tags...)
 	}
 
-	peers, e := cc.selectPeerGroup(cfg.Replicas+1, currCluster, &cfg, currPeers, 1, nil)
+	// Our learners can not become peers.
+	peers, e := cc.selectPeerGroup(cfg.Replicas+1, currCluster, &cfg, currPeers, 1, currLearners)
 	if len(peers) <= cfg.Replicas {
 		// since expanding in the same cluster did not yield a result, try in different cluster
 		peers = nil
@@ -2758,7 +2845,7 @@ func (s *Server) jsLeaderServerStreamMoveRequest(sub *subscription, c *client, _
 		errs := &selectPeerError{}
 		errs.accumulate(e)
 		for cluster := range clusters {
-			newPeers, e := cc.selectPeerGroup(cfg.Replicas, cluster, &cfg, nil, 0, nil)
+			newPeers, e := cc.selectPeerGroup(cfg.Replicas, cluster, &cfg, nil, 0, currLearners)
 			if len(newPeers) >= cfg.Replicas {
 				peers = append([]string{}, currPeers...)
 				peers = append(peers, newPeers[:cfg.Replicas]...)
@@ -3452,13 +3539,30 @@ func (s *Server) jsMsgGetRequest(sub *subscription, c *client, _ *Account, subje
 		seq = req.Seq
 	}
 
+	// Messages held back by a schedule are not visible until released.
+	schedules := mset.cfg.AllowMsgSchedules
 	if seq > 0 && req.NextFor == _EMPTY_ {
 		sm, err = mset.store.LoadMsg(seq, &svp)
+		if err == nil && schedules && isScheduledMsg(sm.hdr) {
+			err = ErrStoreMsgNotFound
+		}
 	} else if req.NextFor != _EMPTY_ {
-		sm, _, err = mset.store.LoadNextMsg(req.NextFor, subjectHasWildcard(req.NextFor), seq, &svp)
+		wc := subjectHasWildcard(req.NextFor)
+		sm, seq, err = mset.store.LoadNextMsg(req.NextFor, wc, seq, &svp)
+		for err == nil && schedules && isScheduledMsg(sm.hdr) {
+			sm, seq, err = mset.store.LoadNextMsg(req.NextFor, wc, seq+1, &svp)
+		}
+	} else if schedules {
+		sm, err = loadLastVisibleMsg(mset.store, req.LastFor, &svp)
 	} else {
 		sm, err = mset.store.LoadLastMsg(req.LastFor, &svp)
 	}
+	// Make sure no other leader could have been elected while we were reading.
+	if req.Linearizable && !mset.leaseValid() {
+		resp.Error = NewJSStreamLeaderLeaseNotHeldError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
 	if err != nil {
 		resp.Error = NewJSNoMessageFoundError()
 		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
@@ -3592,6 +3696,352 @@ func (s *Server) jsConsumerUnpinRequest(sub *subscription, c *client, _ *Account
 	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
 }
 
+// Request to move a consumer to a new starting position.
+func (s *Server) jsConsumerResetRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if c == nil || !s.JetStreamEnabled() {
+		return
+	}
+
+	ci, acc, _, msg, err := s.getRequestInfo(c, rmsg)
+	if err != nil {
+		s.Warnf(badAPIRequestT, msg)
+		return
+	}
+
+	stream := streamNameFromSubject(subject)
+	consumer := consumerNameFromSubject(subject)
+
+	var req JSApiConsumerResetRequest
+	var resp = JSApiConsumerResetResponse{ApiResponse: ApiResponse{Type: JSApiConsumerResetResponseType}}
+
+	if err := json.Unmarshal(msg, &req); err != nil {
+		resp.Error = NewJSInvalidJSONError(err)
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if req.Sequence == 0 && req.StartTime == nil {
+		resp.Error = NewJSConsumerResetInvalidError(errors.New("stream sequence or start time required"))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if req.Sequence > 0 && req.StartTime != nil {
+		resp.Error = NewJSConsumerResetInvalidError(errors.New("stream sequence and start time are mutually exclusive"))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if s.JetStreamIsClustered() {
+		// Check to make sure the stream is assigned.
+		js, cc := s.getJetStreamCluster()
+		if js == nil || cc == nil {
+			return
+		}
+
+		// First check if the stream and consumer is there.
+		js.mu.RLock()
+		sa := js.streamAssignment(acc.Name, stream)
+		if sa == nil {
+			js.mu.RUnlock()
+			resp.Error = NewJSStreamNotFoundError(Unless(err))
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+
+		ca, ok := sa.consumers[consumer]
+		if !ok || ca == nil {
+			js.mu.RUnlock()
+			resp.Error = NewJSConsumerNotFoundError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		js.mu.RUnlock()
+
+		// Then check if we are the leader.
+		mset, err := acc.lookupStream(stream)
+		if err != nil {
+			return
+		}
+
+		o := mset.lookupConsumer(consumer)
+		if o == nil {
+			return
+		}
+		if !o.isLeader() {
+			return
+		}
+	}
+
+	if hasJS, doErr := acc.checkJetStream(); !hasJS {
+		if doErr {
+			resp.Error = NewJSNotEnabledForAccountError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		}
+		return
+	}
+
+	mset, err := acc.lookupStream(stream)
+	if err != nil {
+		resp.Error = NewJSStreamNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	o := mset.lookupConsumer(consumer)
+	if o == nil {
+		resp.Error = NewJSConsumerNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if !o.isDurable() {
+		resp.Error = NewJSConsumerResetNotDurableError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	var state StreamState
+	mset.store.FastState(&state)
+	sseq := req.Sequence
+	if req.StartTime != nil {
+		sseq = mset.store.GetSeqFromTime(*req.StartTime)
+	}
+	// Don't point before the first message still in the stream,
+	// nor past the next one to be stored, which would skip messages yet to come.
+	if sseq < state.FirstSeq {
+		sseq = state.FirstSeq
+	} else if sseq > state.LastSeq+1 {
+		sseq = state.LastSeq + 1
+	}
+
+	o.mu.Lock()
+	clustered := o.node != nil
+	if clustered {
+		err = o.proposeReset(sseq, ci, subject, reply)
+	} else {
+		err = o.reset(sseq)
+	}
+	o.mu.Unlock()
+	if err != nil {
+		resp.Error = NewJSConsumerResetInvalidError(err)
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	// When clustered, the response is sent once the reset is applied.
+	if clustered {
+		return
+	}
+	// Moving forward may allow messages to be removed from interest based streams.
+	o.checkStateForInterestStream(&state)
+
+	resp.ConsumerInfo = setDynamicConsumerInfoMetadata(o.info())
+	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
+}
+
+// Request to list the pending messages of a consumer.
+func (s *Server) jsConsumerPendingRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if c == nil || !s.JetStreamEnabled() {
+		return
+	}
+
+	ci, acc, _, msg, err := s.getRequestInfo(c, rmsg)
+	if err != nil {
+		s.Warnf(badAPIRequestT, msg)
+		return
+	}
+
+	stream := streamNameFromSubject(subject)
+	consumer := consumerNameFromSubject(subject)
+
+	var req JSApiConsumerPendingRequest
+	var resp = JSApiConsumerPendingResponse{
+		ApiResponse: ApiResponse{Type: JSApiConsumerPendingResponseType},
+		Pending:     []*ConsumerPendingAck{},
+	}
+
+	if isJSONObjectOrArray(msg) {
+		if err := json.Unmarshal(msg, &req); err != nil {
+			resp.Error = NewJSInvalidJSONError(err)
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+	}
+
+	if s.JetStreamIsClustered() {
+		// Check to make sure the stream is assigned.
+		js, cc := s.getJetStreamCluster()
+		if js == nil || cc == nil {
+			return
+		}
+
+		// First check if the stream and consumer is there.
+		js.mu.RLock()
+		sa := js.streamAssignment(acc.Name, stream)
+		if sa == nil {
+			js.mu.RUnlock()
+			resp.Error = NewJSStreamNotFoundError(Unless(err))
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+
+		ca, ok := sa.consumers[consumer]
+		if !ok || ca == nil {
+			js.mu.RUnlock()
+			resp.Error = NewJSConsumerNotFoundError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		js.mu.RUnlock()
+
+		// Then check if we are the leader.
+		mset, err := acc.lookupStream(stream)
+		if err != nil {
+			return
+		}
+
+		o := mset.lookupConsumer(consumer)
+		if o == nil {
+			return
+		}
+		if !o.isLeader() {
+			return
+		}
+	}
+
+	if hasJS, doErr := acc.checkJetStream(); !hasJS {
+		if doErr {
+			resp.Error = NewJSNotEnabledForAccountError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		}
+		return
+	}
+
+	mset, err := acc.lookupStream(stream)
+	if err != nil {
+		resp.Error = NewJSStreamNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	o := mset.lookupConsumer(consumer)
+	if o == nil {
+		resp.Error = NewJSConsumerNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	offset := max(req.Offset, 0)
+	resp.Pending, resp.Total = o.pendingAcks(offset, JSApiListLimit)
+	resp.Offset, resp.Limit = min(offset, resp.Total), JSApiListLimit
+	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
+}
+
+// Request to redeliver, skip or terminate a pending message of a consumer.
+func (s *Server) jsConsumerPendingActionRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if c == nil || !s.JetStreamEnabled() {
+		return
+	}
+
+	ci, acc, _, msg, err := s.getRequestInfo(c, rmsg)
+	if err != nil {
+		s.Warnf(badAPIRequestT, msg)
+		return
+	}
+
+	stream := tokenAt(subject, 5)
+	consumer := tokenAt(subject, 6)
+
+	var req JSApiConsumerPendingActionRequest
+	var resp = JSApiConsumerPendingActionResponse{ApiResponse: ApiResponse{Type: JSApiConsumerPendingActionResponseType}}
+
+	if err := json.Unmarshal(msg, &req); err != nil {
+		resp.Error = NewJSInvalidJSONError(err)
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if req.Sequence == 0 {
+		resp.Error = NewJSConsumerPendingActionInvalidError(errors.New("stream sequence required"))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	switch req.Action {
+	case ConsumerPendingRedeliver, ConsumerPendingSkip, ConsumerPendingTerm:
+	default:
+		resp.Error = NewJSConsumerPendingActionInvalidError(fmt.Errorf("unknown action %q", req.Action))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if s.JetStreamIsClustered() {
+		// Check to make sure the stream is assigned.
+		js, cc := s.getJetStreamCluster()
+		if js == nil || cc == nil {
+			return
+		}
+
+		// First check if the stream and consumer is there.
+		js.mu.RLock()
+		sa := js.streamAssignment(acc.Name, stream)
+		if sa == nil {
+			js.mu.RUnlock()
+			resp.Error = NewJSStreamNotFoundError(Unless(err))
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+
+		ca, ok := sa.consumers[consumer]
+		if !ok || ca == nil {
+			js.mu.RUnlock()
+			resp.Error = NewJSConsumerNotFoundError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		js.mu.RUnlock()
+
+		// Then check if we are the leader.
+		mset, err := acc.lookupStream(stream)
+		if err != nil {
+			return
+		}
+
+		o := mset.lookupConsumer(consumer)
+		if o == nil {
+			return
+		}
+		if !o.isLeader() {
+			return
+		}
+	}
+
+	if hasJS, doErr := acc.checkJetStream(); !hasJS {
+		if doErr {
+			resp.Error = NewJSNotEnabledForAccountError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		}
+		return
+	}
+
+	mset, err := acc.lookupStream(stream)
+	if err != nil {
+		resp.Error = NewJSStreamNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	o := mset.lookupConsumer(consumer)
+	if o == nil {
+		resp.Error = NewJSConsumerNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if err := o.processPendingAction(req.Sequence, req.Action, req.Reason); err != nil {
+		resp.Error = NewJSConsumerPendingActionInvalidError(err)
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	resp.Success = true
+	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
+}
+
 // Request to purge a stream.
 func (s *Server) jsStreamPurgeRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
 	if c == nil || !s.JetStreamEnabled() {
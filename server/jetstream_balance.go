@@ -0,0 +1,522 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// Default maximum number of leaders moved per balancing round.
+	defaultLeaderBalanceMaxTransfers = 10
+	// Time between leader transfers on a single server.
+	leaderTransferInterval = 250 * time.Millisecond
+	// On top of counting as one, a leader adds one to the load of its server
+	// for every this many messages or bytes it stores or delivers per second.
+	leaderLoadMsgRate  = 1000
+	leaderLoadByteRate = 1024 * 1024
+)
+
+// The number of replicated stream and consumer leaders a server holds, and their load.
+type leaderReport struct {
+	Streams   int     `json:"streams"`
+	Consumers int     `json:"consumers"`
+	Load      float64 `json:"load"`
+}
+
+// Asks a server to move up to Count of its leaders, to shed Load.
+// Targets holds the peers that can take more load, and how much each can take.
+type leaderTransferRequest struct {
+	Count   int                `json:"count"`
+	Load    float64            `json:"load"`
+	Targets map[string]float64 `json:"targets"`
+}
+
+// The number of leaders and the load moved to each target.
+type leaderTransferResponse struct {
+	Transfers map[string]int     `json:"transfers"`
+	Load      map[string]float64 `json:"load"`
+}
+
+// A leader we are moving to a preferred peer.
+type leaderTransfer struct {
+	acc       string
+	stream    string
+	consumer  string
+	node      RaftNode
+	preferred string
+}
+
+// The load of the leaders we hold as of our last leader report, keyed by raft group.
+type leaderLoads struct {
+	sync.Mutex
+	samples map[string]*leaderSample
+}
+
+// What a leader had stored or delivered when we last reported on it, and the load derived from it.
+type leaderSample struct {
+	msgs  uint64
+	bytes uint64
+	ts    time.Time
+	load  float64
+}
+
+// Periodically balance stream and consumer leaders across our servers when we are the meta leader.
+func (s *Server) runJetStreamLeaderBalancer(js *jetStream, interval time.Duration) {
+	defer s.grWG.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.quitCh:
+			return
+		case <-t.C:
+		}
+		// Stop if JetStream was disabled, a new balancer is started if re-enabled.
+		if s.getJetStream() != js || !js.isEnabled() {
+			return
+		}
+		s.balanceJetStreamLeaders(js)
+	}
+}
+
+// Move leaders from servers that carry more than their share of the load to those that carry less.
+// The load of a server is the number of leaders it holds, weighed by their message and byte rates.
+// Only online servers with all of the configured tags take part, and servers are only
+// balanced against others in the same cluster since that is where their assets are placed.
+func (s *Server) balanceJetStreamLeaders(js *jetStream) {
+	js.mu.RLock()
+	cc := js.cluster
+	if cc == nil || cc.meta == nil || !cc.isLeader() {
+		js.mu.RUnlock()
+		return
+	}
+	peers := cc.meta.Peers()
+	js.mu.RUnlock()
+
+	opts := s.getOpts().JetStreamLeaderBalance
+	budget := opts.MaxTransfers
+	if budget <= 0 {
+		budget = defaultLeaderBalanceMaxTransfers
+	}
+
+	clusters := make(map[string][]string)
+	for _, p := range peers {
+		si, ok := s.nodeToInfo.Load(p.ID)
+		if !ok || si == nil {
+			continue
+		}
+		ni := si.(nodeInfo)
+		if ni.offline || !ni.js || !hasAllTags(ni, opts.Tags) {
+			continue
+		}
+		clusters[ni.cluster] = append(clusters[ni.cluster], p.ID)
+	}
+
+	for cluster, nodes := range clusters {
+		if budget <= 0 {
+			return
+		}
+		budget -= s.balanceClusterLeaders(js, cluster, nodes, opts.Threshold, budget)
+	}
+}
+
+// Balance the leaders of the servers in a single cluster, moving at most budget leaders.
+// All servers are asked at once, so one that is slow to respond does not hold up the others.
+// Returns the number of leaders that were moved.
+func (s *Server) balanceClusterLeaders(js *jetStream, cluster string, nodes []string, threshold, budget int) int {
+	if len(nodes) < 2 {
+		return 0
+	}
+	// Our own system subscriptions do not see our requests, so we handle those directly.
+	ourNode := s.NodeName()
+
+	reports := make([]*leaderReport, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		if node == ourNode {
+			reports[i] = js.leaderReport()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lr, err := sysRequest[leaderReport](s, clusterLeaderReportT, node)
+			if err != nil {
+				s.Debugf("JetStream leader balancing skipping %q: %v", s.serverNameForNode(node), err)
+			}
+			reports[i] = lr
+		}()
+	}
+	wg.Wait()
+
+	loads := make(map[string]float64, len(nodes))
+	var total float64
+	for i, lr := range reports {
+		if lr != nil {
+			loads[nodes[i]] = lr.Load
+			total += lr.Load
+		}
+	}
+	if len(loads) < 2 {
+		return 0
+	}
+	avg := total / float64(len(loads))
+
+	var over, under []string
+	room := make(map[string]float64, len(loads))
+	for node, load := range loads {
+		if load > avg+float64(threshold) {
+			over = append(over, node)
+		} else if load < avg {
+			under = append(under, node)
+			room[node] = avg - load
+		}
+	}
+	slices.SortFunc(over, func(i, j string) int { return cmp.Compare(loads[j], loads[i]) })
+	slices.SortFunc(under, func(i, j string) int { return cmp.Compare(room[j], room[i]) })
+
+	// Hand out the room on the other servers up front, most loaded servers first,
+	// so the requests can be sent at once.
+	reqs := make(map[string]*leaderTransferRequest, len(over))
+	left := budget
+	for _, node := range over {
+		// Every leader counts as at least one, so that is as many as we could move.
+		shed := loads[node] - avg
+		count := min(int(shed), left)
+		if count <= 0 {
+			break
+		}
+		req := &leaderTransferRequest{Count: count, Targets: make(map[string]float64)}
+		for _, tn := range under {
+			if req.Load >= shed {
+				break
+			}
+			if take := min(room[tn], shed-req.Load); take > 0 {
+				req.Targets[tn] = take
+				req.Load += take
+				room[tn] -= take
+			}
+		}
+		if len(req.Targets) == 0 {
+			break
+		}
+		reqs[node] = req
+		left -= count
+	}
+
+	var mu sync.Mutex
+	var moved int
+	var movedLoad float64
+	for node, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var resp *leaderTransferResponse
+			var err error
+			if node == ourNode {
+				resp = s.startLeaderTransfers(req)
+			} else {
+				resp, err = sysRequestMsg[leaderTransferResponse](s, req, clusterLeaderTransferT, node)
+			}
+			if err != nil || resp == nil {
+				s.Debugf("JetStream leader balancing could not move leaders from %q: %v", s.serverNameForNode(node), err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for tn, n := range resp.Transfers {
+				if _, ok := req.Targets[tn]; ok {
+					moved += n
+					movedLoad += resp.Load[tn]
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if moved > 0 {
+		s.Noticef("JetStream leader balancing moving %d leaders with a load of %.1f in cluster %q", moved, movedLoad, cluster)
+	}
+	return moved
+}
+
+// Returns true if the server has all of the given tags.
+func hasAllTags(ni nodeInfo, tags []string) bool {
+	for _, tag := range tags {
+		if !ni.tags.Contains(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports the replicated stream and consumer leaders we hold.
+func (s *Server) jsLeaderReportRequest(sub *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+	js := s.getJetStream()
+	if js == nil || reply == _EMPTY_ {
+		return
+	}
+	if lr := js.leaderReport(); lr != nil {
+		s.sendInternalMsgLocked(reply, _EMPTY_, nil, lr)
+	}
+}
+
+// Returns the number of replicated stream and consumer leaders we hold and their load.
+// The rates of each leader are taken over the time since our last report, which for
+// the meta leader asking is a balancing round. A leader we did not report on before
+// counts as one.
+func (js *jetStream) leaderReport() *leaderReport {
+	isLeader := func(rg *raftGroup) bool {
+		return rg != nil && len(rg.Peers) > 1 && rg.node != nil && rg.node.Leader()
+	}
+
+	var leaders []*leaderTransfer
+	js.mu.RLock()
+	cc := js.cluster
+	if cc == nil {
+		js.mu.RUnlock()
+		return nil
+	}
+	for accName, asa := range cc.streams {
+		for _, sa := range asa {
+			if isLeader(sa.Group) {
+				leaders = append(leaders, &leaderTransfer{acc: accName, stream: sa.Config.Name, node: sa.Group.node})
+			}
+			for _, ca := range sa.consumers {
+				if isLeader(ca.Group) {
+					leaders = append(leaders, &leaderTransfer{acc: accName, stream: sa.Config.Name, consumer: ca.Name, node: ca.Group.node})
+				}
+			}
+		}
+	}
+	js.mu.RUnlock()
+
+	var lr leaderReport
+	now := time.Now()
+	samples := make(map[string]*leaderSample, len(leaders))
+	for _, lt := range leaders {
+		if lt.consumer == _EMPTY_ {
+			lr.Streams++
+		} else {
+			lr.Consumers++
+		}
+		msgs, bytes := js.srv.leaderCounters(lt)
+		samples[lt.node.Group()] = &leaderSample{msgs: msgs, bytes: bytes, ts: now, load: 1}
+	}
+
+	js.lloads.Lock()
+	defer js.lloads.Unlock()
+	for group, ls := range samples {
+		// Counters start over when an asset is recreated.
+		if prev := js.lloads.samples[group]; prev != nil && ls.msgs >= prev.msgs && ls.bytes >= prev.bytes {
+			if secs := now.Sub(prev.ts).Seconds(); secs > 0 {
+				ls.load += float64(ls.msgs-prev.msgs)/secs/leaderLoadMsgRate + float64(ls.bytes-prev.bytes)/secs/leaderLoadByteRate
+			}
+		}
+		lr.Load += ls.load
+	}
+	// Leaders we no longer hold are dropped.
+	js.lloads.samples = samples
+	return &lr
+}
+
+// Returns the load of the leaders we held as of our last report, keyed by raft group.
+func (js *jetStream) currentLeaderLoads() map[string]float64 {
+	js.lloads.Lock()
+	defer js.lloads.Unlock()
+	loads := make(map[string]float64, len(js.lloads.samples))
+	for group, ls := range js.lloads.samples {
+		loads[group] = ls.load
+	}
+	return loads
+}
+
+// Returns the messages and bytes a stream stored, or a consumer delivered, so far.
+func (s *Server) leaderCounters(lt *leaderTransfer) (msgs, bytes uint64) {
+	acc, err := s.LookupAccount(lt.acc)
+	if err != nil {
+		return 0, 0
+	}
+	mset, err := acc.lookupStream(lt.stream)
+	if err != nil {
+		return 0, 0
+	}
+	if lt.consumer == _EMPTY_ {
+		mset.mu.RLock()
+		defer mset.mu.RUnlock()
+		return mset.imsgs, mset.ibytes
+	}
+	o := mset.lookupConsumer(lt.consumer)
+	if o == nil {
+		return 0, 0
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.dmsgs, o.dbytes
+}
+
+// Moves some of our leaders to the targets picked by the meta leader.
+func (s *Server) jsLeaderTransferRequest(sub *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+	if reply == _EMPTY_ {
+		return
+	}
+	var req leaderTransferRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		s.Warnf("Error unmarshalling leader transfer request: %v", err)
+		return
+	}
+	if resp := s.startLeaderTransfers(&req); resp != nil {
+		s.sendInternalMsgLocked(reply, _EMPTY_, nil, resp)
+	}
+}
+
+// Starts moving up to the requested number of our leaders, stream leaders first, to even out our
+// load with that of the targets. Each leader is moved to the healthy target with the most room left
+// that is a voting member of its group and has all of its stream's placement tags. Leaders that
+// carry too much load to leave us closer to even are skipped in favor of lighter ones.
+// Returns what will be moved, the step-downs themselves are spread out in the background.
+func (s *Server) startLeaderTransfers(req *leaderTransferRequest) *leaderTransferResponse {
+	js, cc := s.getJetStreamCluster()
+	if js == nil || cc == nil {
+		return nil
+	}
+	resp := &leaderTransferResponse{Transfers: make(map[string]int), Load: make(map[string]float64)}
+	// Do not change the caller's targets.
+	targets := make(map[string]float64, len(req.Targets))
+	for peer, n := range req.Targets {
+		targets[peer] = n
+	}
+	left := req.Load
+	loads := js.currentLeaderLoads()
+
+	// Returns the target that should lead this group, if any.
+	pick := func(rg *raftGroup, tags []string) string {
+		if rg == nil || len(rg.Peers) <= 1 || rg.node == nil || !rg.node.Leader() {
+			return _EMPTY_
+		}
+		load, ok := loads[rg.node.Group()]
+		if !ok {
+			load = 1
+		}
+		var preferred string
+		var room float64
+		for _, p := range rg.node.Peers() {
+			// Only move the leader if that leaves us and the target closer to even. We are above
+			// the average by what is left and the target below it by its room, so that holds
+			// while the leader's load is less than the two combined.
+			r, ok := targets[p.ID]
+			if !ok || p.Learner || !p.Current || load >= left+r || (preferred != _EMPTY_ && r <= room) {
+				continue
+			}
+			si, ok := s.nodeToInfo.Load(p.ID)
+			if !ok || si == nil {
+				continue
+			}
+			if ni := si.(nodeInfo); ni.offline || !hasAllTags(ni, tags) {
+				continue
+			}
+			preferred, room = p.ID, targets[p.ID]
+		}
+		if preferred != _EMPTY_ {
+			targets[preferred] -= load
+			left -= load
+			resp.Transfers[preferred]++
+			resp.Load[preferred] += load
+		}
+		return preferred
+	}
+
+	var transfers []*leaderTransfer
+	js.mu.RLock()
+	// Stream leaders first, then consumer leaders.
+	for _, consumers := range []bool{false, true} {
+		for accName, asa := range cc.streams {
+			for _, sa := range asa {
+				if len(transfers) >= req.Count {
+					break
+				}
+				var tags []string
+				if sa.Config != nil && sa.Config.Placement != nil {
+					tags = sa.Config.Placement.Tags
+				}
+				if !consumers {
+					if p := pick(sa.Group, tags); p != _EMPTY_ {
+						transfers = append(transfers, &leaderTransfer{acc: accName, stream: sa.Config.Name, node: sa.Group.node, preferred: p})
+					}
+					continue
+				}
+				for _, ca := range sa.consumers {
+					if len(transfers) >= req.Count {
+						break
+					}
+					if p := pick(ca.Group, tags); p != _EMPTY_ {
+						transfers = append(transfers, &leaderTransfer{acc: accName, stream: sa.Config.Name, consumer: ca.Name, node: ca.Group.node, preferred: p})
+					}
+				}
+			}
+		}
+	}
+	js.mu.RUnlock()
+
+	if len(transfers) > 0 {
+		s.startGoRoutine(func() {
+			defer s.grWG.Done()
+			s.transferLeaders(transfers)
+		})
+	}
+	return resp
+}
+
+// Step down as leader of each asset in favor of its preferred peer, one at a time.
+// This is done the same way as a leader step-down requested through the API.
+func (s *Server) transferLeaders(transfers []*leaderTransfer) {
+	for _, lt := range transfers {
+		select {
+		case <-s.quitCh:
+			return
+		case <-time.After(leaderTransferInterval):
+		}
+		if !lt.node.Leader() {
+			continue
+		}
+		acc, err := s.LookupAccount(lt.acc)
+		if err != nil {
+			continue
+		}
+		mset, err := acc.lookupStream(lt.stream)
+		if err != nil {
+			continue
+		}
+		if lt.consumer == _EMPTY_ {
+			s.Debugf("JetStream leader balancing moving leader of stream '%s > %s' to %q",
+				lt.acc, lt.stream, s.serverNameForNode(lt.preferred))
+			mset.setLeader(false)
+		} else if o := mset.lookupConsumer(lt.consumer); o != nil {
+			s.Debugf("JetStream leader balancing moving leader of consumer '%s > %s > %s' to %q",
+				lt.acc, lt.stream, lt.consumer, s.serverNameForNode(lt.preferred))
+			o.setLeader(false)
+		} else {
+			continue
+		}
+		time.Sleep(250 * time.Millisecond)
+		lt.node.StepDown(lt.preferred)
+	}
+}
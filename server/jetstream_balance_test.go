@@ -0,0 +1,204 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats.go"
+)
+
+func TestJetStreamClusterLeaderBalancing(t *testing.T) {
+	tmpl := strings.Replace(jsClusterTempl, "store_dir:", `leader_balance: {interval: "1h", max_transfers: 2}, store_dir:`, 1)
+	c := createJetStreamClusterWithTemplate(t, tmpl, "R3S", 3)
+	defer c.shutdown()
+
+	lb := c.randomServer().getOpts().JetStreamLeaderBalance
+	require_Equal(t, lb.Interval, time.Hour)
+	require_Equal(t, lb.MaxTransfers, 2)
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	var streams []string
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("S%d", i)
+		_, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{name}, Replicas: 3})
+		require_NoError(t, err)
+		_, err = js.AddConsumer(name, &nats.ConsumerConfig{Durable: "C", AckPolicy: nats.AckExplicitPolicy})
+		require_NoError(t, err)
+		streams = append(streams, name)
+	}
+
+	// Pile up all leaders on a single server, as can happen after a rolling restart.
+	sl := c.randomServer()
+	moveTo := func(subject string, leader func() *Server) {
+		t.Helper()
+		if leader() == sl {
+			return
+		}
+		req, err := json.Marshal(&JSApiLeaderStepdownRequest{Placement: &Placement{Preferred: sl.Name()}})
+		require_NoError(t, err)
+		_, err = nc.Request(subject, req, 2*time.Second)
+		require_NoError(t, err)
+		checkFor(t, 10*time.Second, 100*time.Millisecond, func() error {
+			if leader() != sl {
+				return errors.New("leader not moved yet")
+			}
+			return nil
+		})
+	}
+	for _, name := range streams {
+		moveTo(fmt.Sprintf(JSApiStreamLeaderStepDownT, name), func() *Server {
+			return c.streamLeader(globalAccountName, name)
+		})
+		moveTo(fmt.Sprintf(JSApiConsumerLeaderStepDownT, name, "C"), func() *Server {
+			return c.consumerLeader(globalAccountName, name, "C")
+		})
+	}
+
+	leaders := func() (map[*Server]int, int) {
+		counts := make(map[*Server]int)
+		var sls int
+		for _, name := range streams {
+			if s := c.streamLeader(globalAccountName, name); s != nil {
+				counts[s]++
+				if s == sl {
+					sls++
+				}
+			}
+			if s := c.consumerLeader(globalAccountName, name, "C"); s != nil {
+				counts[s]++
+			}
+		}
+		return counts, sls
+	}
+	counts, _ := leaders()
+	require_Equal(t, counts[sl], 8)
+
+	ml := c.leader()
+	setBalanceOpts := func(tags jwt.TagList) {
+		opts := ml.getOpts().Clone()
+		opts.JetStreamLeaderBalance.Tags = tags
+		ml.setOpts(opts)
+	}
+
+	// Servers without all of the configured tags are not balanced.
+	setBalanceOpts(jwt.TagList{"az:1"})
+	ml.balanceJetStreamLeaders(ml.getJetStream())
+	time.Sleep(time.Second)
+	counts, _ = leaders()
+	require_Equal(t, counts[sl], 8)
+
+	// Only max_transfers leaders are moved per round, stream leaders first.
+	setBalanceOpts(nil)
+	ml.balanceJetStreamLeaders(ml.getJetStream())
+	checkFor(t, 10*time.Second, 250*time.Millisecond, func() error {
+		if counts, sls := leaders(); counts[sl] != 6 || sls != 2 {
+			return fmt.Errorf("expected 6 leaders with 2 streams, got %d with %d streams", counts[sl], sls)
+		}
+		return nil
+	})
+
+	// Keep going until no server leads more than its share of 8 leaders.
+	checkFor(t, 30*time.Second, 2*time.Second, func() error {
+		ml.balanceJetStreamLeaders(ml.getJetStream())
+		time.Sleep(1500 * time.Millisecond)
+		counts, _ := leaders()
+		for s, n := range counts {
+			if n > 3 {
+				return fmt.Errorf("server %q leads %d", s.Name(), n)
+			}
+		}
+		return nil
+	})
+}
+
+func TestJetStreamClusterLeaderBalancingByLoad(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	for _, name := range []string{"HOT", "COLD"} {
+		_, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{name}, Replicas: 3})
+		require_NoError(t, err)
+	}
+
+	// Have the same server lead both streams.
+	sl := c.streamLeader(globalAccountName, "HOT")
+	if c.streamLeader(globalAccountName, "COLD") != sl {
+		req, err := json.Marshal(&JSApiLeaderStepdownRequest{Placement: &Placement{Preferred: sl.Name()}})
+		require_NoError(t, err)
+		_, err = nc.Request(fmt.Sprintf(JSApiStreamLeaderStepDownT, "COLD"), req, 2*time.Second)
+		require_NoError(t, err)
+		checkFor(t, 10*time.Second, 100*time.Millisecond, func() error {
+			if c.streamLeader(globalAccountName, "COLD") != sl {
+				return errors.New("leader not moved yet")
+			}
+			return nil
+		})
+	}
+
+	// Without traffic each leader counts as one.
+	sjs := sl.getJetStream()
+	lr := sjs.leaderReport()
+	require_Equal(t, lr.Streams, 2)
+	require_Equal(t, lr.Load, 2)
+
+	msg := make([]byte, 1024)
+	for i := 0; i < 5000; i++ {
+		_, err := js.PublishAsync("HOT", msg)
+		require_NoError(t, err)
+	}
+	select {
+	case <-js.PublishAsyncComplete():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Did not receive completion signal")
+	}
+	lr = sjs.leaderReport()
+	if lr.Load <= 4 {
+		t.Fatalf("Expected the load of the hot stream to count, got %v", lr.Load)
+	}
+
+	// Moving the hot stream would just move the imbalance, so only the cold one moves.
+	var target *Server
+	for _, s := range c.servers {
+		if s != sl {
+			target = s
+			break
+		}
+	}
+	resp := sl.startLeaderTransfers(&leaderTransferRequest{
+		Count: 2, Load: 1.5, Targets: map[string]float64{target.NodeName(): 1.5},
+	})
+	require_Equal(t, resp.Transfers[target.NodeName()], 1)
+	require_Equal(t, resp.Load[target.NodeName()], 1)
+	checkFor(t, 10*time.Second, 250*time.Millisecond, func() error {
+		if c.streamLeader(globalAccountName, "COLD") != target {
+			return errors.New("cold stream leader not moved yet")
+		}
+		return nil
+	})
+	require_True(t, c.streamLeader(globalAccountName, "HOT") == sl)
+}
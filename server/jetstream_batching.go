@@ -0,0 +1,762 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Limits for atomic batch publishing.
+const (
+	// Maximum number of messages in a single batch.
+	streamMaxBatchSize = 1000
+	// Maximum number of batches that can be staged per stream at the same time.
+	streamMaxBatchInflight = 50
+	// Batches that don't receive a new message within this time are abandoned.
+	streamMaxBatchTimeout = 10 * time.Second
+	// Maximum length of a batch ID.
+	streamMaxBatchIdLen = 64
+)
+
+// batchGroup holds the messages of an atomic batch while it is being staged.
+type batchGroup struct {
+	msgs  []*inMsg
+	timer *time.Timer
+}
+
+// batchApply holds the messages of an atomic batch applied from the raft log,
+// until the message that commits the batch is applied as well.
+type batchApply struct {
+	id   string
+	msgs []*inMsg
+	lseq uint64
+	ts   int64
+}
+
+// Fast lookup of the batch ID.
+func getBatchId(hdr []byte) string {
+	return string(getHeader(JSBatchId, hdr))
+}
+
+// Fast lookup of the sequence of a message within its batch.
+func getBatchSequence(hdr []byte) (uint64, bool) {
+	bseq := getHeader(JSBatchSeq, hdr)
+	if len(bseq) == 0 {
+		return 0, false
+	}
+	seq := parseInt64(bseq)
+	if seq <= 0 {
+		return 0, false
+	}
+	return uint64(seq), true
+}
+
+// Fast lookup of the batch commit marker.
+func isBatchCommit(hdr []byte) bool {
+	return bytes.Equal(getHeader(JSBatchCommit, hdr), []byte("1"))
+}
+
+// processInboundBatchMsg stages a message that is part of an atomic batch.
+// Once the commit message is received the batch is processed as a whole.
+// This takes ownership of the inbound message.
+func (mset *stream) processInboundBatchMsg(im *inMsg) {
+	mset.mu.Lock()
+	name, outq := mset.cfg.Name, mset.outq
+	canRespond := !mset.cfg.NoAck && len(im.rply) > 0
+
+	reject := func(apiErr *ApiError) {
+		mset.mu.Unlock()
+		if canRespond {
+			b, _ := json.Marshal(&JSPubAckResponse{PubAck: &PubAck{Stream: name}, Error: apiErr})
+			outq.sendMsg(im.rply, b)
+		}
+		im.mt.sendEventFromJetStream(apiErr)
+		im.returnToPool()
+	}
+
+	if !mset.cfg.AllowAtomicPublish {
+		reject(NewJSAtomicPublishDisabledError())
+		return
+	}
+	batchId := getBatchId(im.hdr)
+	if len(batchId) > streamMaxBatchIdLen {
+		reject(NewJSAtomicPublishInvalidBatchIDError())
+		return
+	}
+	bseq, ok := getBatchSequence(im.hdr)
+	if !ok {
+		reject(NewJSAtomicPublishMissingSeqError())
+		return
+	}
+
+	b := mset.batches[batchId]
+	if b == nil {
+		// A new batch always needs to start at the first sequence.
+		if bseq != 1 {
+			reject(NewJSAtomicPublishIncompleteBatchError())
+			return
+		}
+		if len(mset.batches) >= streamMaxBatchInflight {
+			reject(NewJSStreamTooManyRequestsError())
+			return
+		}
+		if mset.batches == nil {
+			mset.batches = make(map[string]*batchGroup)
+		}
+		b = &batchGroup{}
+		b.timer = time.AfterFunc(streamMaxBatchTimeout, func() {
+			mset.mu.Lock()
+			defer mset.mu.Unlock()
+			if mset.batches[batchId] == b {
+				mset.abandonBatchLocked(batchId)
+			}
+		})
+		mset.batches[batchId] = b
+	} else if bseq != uint64(len(b.msgs))+1 {
+		// We missed a message, so the batch can never be committed.
+		mset.abandonBatchLocked(batchId)
+		reject(NewJSAtomicPublishIncompleteBatchError())
+		return
+	} else {
+		b.timer.Reset(streamMaxBatchTimeout)
+	}
+
+	if len(b.msgs) >= streamMaxBatchSize {
+		mset.abandonBatchLocked(batchId)
+		reject(NewJSAtomicPublishTooLargeBatchError(streamMaxBatchSize))
+		return
+	}
+	b.msgs = append(b.msgs, im)
+
+	// Wait for more messages if this didn't commit the batch.
+	if !isBatchCommit(im.hdr) {
+		mset.mu.Unlock()
+		return
+	}
+
+	b.timer.Stop()
+	delete(mset.batches, batchId)
+	isClustered := mset.isClustered()
+	mset.mu.Unlock()
+
+	var err error
+	if isClustered {
+		err = mset.processClusteredInboundBatch(batchId, b.msgs)
+	} else {
+		err = mset.processJetStreamBatch(batchId, b.msgs, 0, 0)
+	}
+	for _, im := range b.msgs {
+		im.mt.sendEventFromJetStream(err)
+		im.returnToPool()
+	}
+}
+
+// abandonBatchLocked removes a staged batch, dropping all of its messages.
+// Lock should be held.
+func (mset *stream) abandonBatchLocked(batchId string) {
+	b := mset.batches[batchId]
+	if b == nil {
+		return
+	}
+	b.timer.Stop()
+	delete(mset.batches, batchId)
+	for _, im := range b.msgs {
+		im.returnToPool()
+	}
+}
+
+// clearBatchesLocked removes all staged batches.
+// Lock should be held.
+func (mset *stream) clearBatchesLocked() {
+	for batchId := range mset.batches {
+		mset.abandonBatchLocked(batchId)
+	}
+	mset.batches = nil
+}
+
+// stageBatchMsg stages a message of an atomic batch applied from the raft log,
+// and returns the batch once the message committing it is staged.
+// A batch that was only partly proposed before a leader change will never be
+// committed, so it is dropped once anything else is applied.
+// Only called from the monitor routine.
+func (mset *stream) stageBatchMsg(subj, reply string, hdr, msg []byte, lseq uint64, ts int64) *batchApply {
+	batchId := getBatchId(hdr)
+	bseq, _ := getBatchSequence(hdr)
+	b := mset.batchApply
+	if bseq == 1 {
+		b = &batchApply{id: batchId, lseq: lseq, ts: ts}
+		mset.batchApply = b
+	} else if b == nil || b.id != batchId || bseq != uint64(len(b.msgs))+1 {
+		mset.batchApply = nil
+		return nil
+	}
+	// The entry this was decoded from will be reused once applied.
+	b.msgs = append(b.msgs, &inMsg{subj: subj, rply: reply, hdr: copyBytes(hdr), msg: copyBytes(msg)})
+	if !isBatchCommit(hdr) {
+		return nil
+	}
+	mset.batchApply = nil
+	return b
+}
+
+// processJetStreamBatch will process an atomic batch of messages. All messages are validated
+// upfront and either all of them are stored or none are. Any error rejects the whole batch.
+// For clustering the lower layers will pass the expected lseq of the first message in the batch.
+func (mset *stream) processJetStreamBatch(batchId string, msgs []*inMsg, lseq uint64, ts int64) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if mset.closed.Load() {
+		return errStreamClosed
+	}
+
+	commit := msgs[len(msgs)-1]
+	batchSize := uint64(len(msgs))
+
+	// Validate against the schemas before taking the lock. When clustered this was done before proposing.
+	var (
+		schemaIdx    = -1
+		schemaApiErr *ApiError
+		schemaErr    error
+	)
+	if lseq == 0 {
+		for i, im := range msgs {
+			if schemaApiErr, schemaErr = mset.checkSchemas(im.subj, im.msg); schemaApiErr != nil {
+				schemaIdx = i
+				break
+			}
+		}
+	}
+
+	mset.mu.Lock()
+	s, store, js, jsa := mset.srv, mset.store, mset.js, mset.jsa
+	name, stype, tierName := mset.cfg.Name, mset.cfg.Storage, mset.tier
+	maxMsgSize := int(mset.cfg.MaxMsgSize)
+	numConsumers := len(mset.consumers)
+	interestRetention := mset.cfg.Retention == InterestPolicy
+	isLeader, isClustered := mset.isLeader(), mset.isClustered()
+	canRespond := !mset.cfg.NoAck && len(commit.rply) > 0 && isLeader
+
+	var accName string
+	if mset.acc != nil {
+		accName = mset.acc.Name
+	}
+
+	// The number of sequences to account as failed when rejecting.
+	failed := batchSize
+	reject := func(apiErr *ApiError, err error) error {
+		mset.mu.Unlock()
+		mset.clMu.Lock()
+		mset.clfs += failed
+		mset.clMu.Unlock()
+		if canRespond {
+			b, _ := json.Marshal(&JSPubAckResponse{PubAck: &PubAck{Stream: name, BatchId: batchId}, Error: apiErr})
+			mset.outq.sendMsg(commit.rply, b)
+		}
+		if err != nil {
+			return err
+		}
+		return apiErr
+	}
+
+	if mset.cfg.Sealed {
+		return reject(NewJSStreamSealedError(), nil)
+	}
+
+	// If this is a non-clustered batch and we are not considered active, meaning no active subscription, do not process.
+	if lseq == 0 && ts == 0 && !mset.active {
+		mset.mu.Unlock()
+		return nil
+	}
+
+	// For clustering the lower layers will pass our expected lseq. If it is present check for that here.
+	if lseq > 0 && lseq != (mset.lseq+mset.clfs) {
+		isMisMatch := true
+		// We may be able to recover here if we have no state whatsoever.
+		if mset.lseq == 0 {
+			var state StreamState
+			store.FastState(&state)
+			if state.FirstSeq == 0 {
+				store.Compact(lseq + 1)
+				mset.lseq = lseq
+				isMisMatch = false
+			}
+		}
+		if isMisMatch {
+			mset.mu.Unlock()
+			if canRespond {
+				b, _ := json.Marshal(&JSPubAckResponse{PubAck: &PubAck{Stream: name, BatchId: batchId}, Error: NewJSStreamSequenceNotMatchError()})
+				mset.outq.sendMsg(commit.rply, b)
+			}
+			return errLastSeqMismatch
+		}
+	}
+
+	var (
+		subjects  = make([]string, len(msgs))
+		hdrs      = make([][]byte, len(msgs))
+		ttls      = make([]int64, len(msgs))
+		msgIds    = make([]string, len(msgs))
+		tlseqs    = make([]uint64, len(msgs))
+		ids       map[string]struct{}
+		counts    map[string]uint64
+		lseqs     = make(map[string]uint64) // Sequences of messages staged in this batch, per subject.
+		totalSize uint64
+	)
+
+	// Returns the last sequence for the subject, taking the earlier messages in this batch into account.
+	lastSeqForSubject := func(subj string) (uint64, error) {
+		var smv StoreMsg
+		var fseq uint64
+		sm, err := store.LoadLastMsg(subj, &smv)
+		if sm != nil {
+			fseq = sm.seq
+		}
+		if err == ErrStoreMsgNotFound {
+			err = nil
+		}
+		for bsubj, bseq := range lseqs {
+			if bseq > fseq && (bsubj == subj || subjectIsSubsetMatch(bsubj, subj)) {
+				fseq, err = bseq, nil
+			}
+		}
+		return fseq, err
+	}
+
+	for i, im := range msgs {
+		subj, hdr := im.subj, im.hdr
+
+		// Apply the input subject transform if any.
+		if mset.itr != nil {
+			if tsubj, err := mset.itr.Match(subj); err == nil {
+				subj = tsubj
+			}
+		}
+		if len(hdr) > 0 {
+			hdr = removeHeaderIfPresent(hdr, ClientInfoHdr)
+		}
+
+		// Rollups and the expected last msgId can't be satisfied for a batch as a whole.
+		if getRollup(hdr) != _EMPTY_ {
+			return reject(NewJSAtomicPublishUnsupportedHeaderBatchError(JSMsgRollup), nil)
+		}
+		// Held messages are released one by one, so can't be part of a batch either.
+		for _, sh := range []string{JSMsgDeliverAt, JSMsgDelay} {
+			if len(getHeader(sh, hdr)) > 0 {
+				return reject(NewJSAtomicPublishUnsupportedHeaderBatchError(sh), nil)
+			}
+		}
+		if getExpectedLastMsgId(hdr) != _EMPTY_ {
+			return reject(NewJSAtomicPublishUnsupportedHeaderBatchError(JSExpectedLastMsgId), nil)
+		}
+		if sname := getExpectedStream(hdr); sname != _EMPTY_ && sname != name {
+			return reject(NewJSStreamNotMatchError(), errStreamMismatch)
+		}
+
+		ttl, err := getMessageTTL(hdr)
+		if err != nil {
+			return reject(NewJSMessageTTLInvalidError(), err)
+		}
+		if ttl != 0 && !mset.cfg.AllowMsgTTL {
+			return reject(NewJSMessageTTLDisabledError(), errMsgTTLDisabled)
+		}
+		if apiErr, err := checkMsgCounter(hdr, mset.cfg.AllowMsgCounter, false); apiErr != nil {
+			return reject(apiErr, err)
+		}
+		if i == schemaIdx {
+			return reject(schemaApiErr, schemaErr)
+		}
+
+		if maxMsgSize >= 0 && (len(hdr)+len(im.msg)) > maxMsgSize {
+			return reject(NewJSStreamMessageExceedsMaximumError(), ErrMaxPayload)
+		}
+		if len(hdr) > math.MaxUint16 {
+			return reject(NewJSStreamHeaderExceedsMaximumError(), ErrMaxPayload)
+		}
+
+		// Expected last sequence only makes sense for the first message of the batch.
+		if seq, exists := getExpectedLastSeq(hdr); exists {
+			if i > 0 {
+				return reject(NewJSAtomicPublishUnsupportedHeaderBatchError(JSExpectedLastSeq), nil)
+			}
+			if seq != mset.lseq {
+				return reject(NewJSStreamWrongLastSequenceError(mset.lseq), fmt.Errorf("last sequence mismatch: %d vs %d", seq, mset.lseq))
+			}
+		}
+
+		// Expected last sequence per subject.
+		if seq, exists := getExpectedLastSeqPerSubject(hdr); exists {
+			// Allow override of the subject used for the check.
+			seqSubj := subj
+			if optSubj := getExpectedLastSeqPerSubjectForSubject(hdr); optSubj != _EMPTY_ {
+				seqSubj = optSubj
+			}
+			fseq, err := lastSeqForSubject(seqSubj)
+			if err != nil || fseq != seq {
+				return reject(NewJSStreamWrongLastSequenceError(fseq), fmt.Errorf("last sequence by subject mismatch: %d vs %d", seq, fseq))
+			}
+		}
+
+		// Any duplicate rejects the batch, duplicates within the batch itself included.
+		if msgId := getMsgId(hdr); msgId != _EMPTY_ {
+			if _, found := ids[msgId]; found || mset.checkMsgId(msgId) != nil {
+				return reject(NewJSAtomicPublishContainsDuplicateMessageError(), errMsgIdDuplicate)
+			}
+			if ids == nil {
+				ids = make(map[string]struct{})
+			}
+			ids[msgId] = struct{}{}
+			msgIds[i] = msgId
+		}
+
+		// If clustered this was already checked and we do not want to check here and possibly introduce skew.
+		if !isClustered {
+			if exceeded, apiErr := jsa.wouldExceedLimits(stype, tierName, mset.cfg.Replicas, subj, hdr, im.msg); exceeded {
+				if apiErr == nil {
+					apiErr = NewJSAccountResourcesExceededError()
+				}
+				s.RateLimitWarnf("JetStream resource limits exceeded for account: %q", accName)
+				return reject(apiErr, nil)
+			}
+		}
+
+		// For republishing, track the last sequence for this exact subject.
+		if mset.tr != nil {
+			tlseqs[i], _ = lastSeqForSubject(subj)
+		}
+
+		if stype == FileStorage {
+			totalSize += fileStoreMsgSize(subj, hdr, im.msg)
+		} else {
+			totalSize += memStoreMsgSize(subj, hdr, im.msg)
+		}
+		if mset.cfg.DiscardNewPer {
+			if counts == nil {
+				counts = make(map[string]uint64)
+			}
+			counts[subj]++
+		}
+		subjects[i], hdrs[i], ttls[i] = subj, hdr, ttl
+		lseqs[subj] = mset.lseq + uint64(i) + 1
+	}
+
+	// Check to see if we have exceeded our limits.
+	if js.limitsExceeded(stype) {
+		s.resourcesExceededError()
+		err := reject(NewJSInsufficientResourcesError(), nil)
+		// Stepdown regardless.
+		if node := mset.raftNode(); node != nil {
+			node.StepDown()
+		}
+		return err
+	}
+
+	// With discard new the store would reject part of the batch, so make sure all of it fits.
+	if mset.cfg.Discard == DiscardNew {
+		var state StreamState
+		store.FastState(&state)
+		var err error
+		// Same as the store, which leaves these to the leader when clustered with a retention other than limits.
+		storeLimits := mset.cfg.Retention == LimitsPolicy || mset.cfg.Replicas == 1
+		if maxMsgs := mset.cfg.MaxMsgs; storeLimits && maxMsgs > 0 && state.Msgs+batchSize > uint64(maxMsgs) {
+			err = ErrMaxMsgs
+		} else if maxBytes := mset.cfg.MaxBytes; storeLimits && maxBytes > 0 && state.Bytes+totalSize >= uint64(maxBytes) {
+			err = ErrMaxBytes
+		} else if maxMsgsPer := mset.cfg.MaxMsgsPer; maxMsgsPer > 0 {
+			for subj, n := range counts {
+				if ss := store.FilteredState(1, subj); ss.Msgs+n > uint64(maxMsgsPer) {
+					err = ErrMaxMsgsPerSubject
+					break
+				}
+			}
+		}
+		if err != nil {
+			return reject(NewJSStreamStoreFailedError(err, Unless(err)), err)
+		}
+	}
+
+	// Grab timestamp if not already set.
+	if ts == 0 && lseq > 0 {
+		ts = time.Now().UnixNano()
+	}
+
+	// Everything that could reject the batch was checked above, and we hold the lock
+	// while storing, so the batch is stored as a whole without having to undo anything.
+	var (
+		seqs  = make([]uint64, len(msgs))
+		tss   = make([]int64, len(msgs))
+		clfs  = mset.clfs
+		plseq = mset.lseq
+		err   error
+	)
+	for i, im := range msgs {
+		subj := subjects[i]
+		// If we are interest based retention and have no consumers then we can skip.
+		if interestRetention {
+			mset.clsMu.RLock()
+			noInterest := numConsumers == 0 || mset.csl == nil || !mset.csl.HasInterest(subj)
+			mset.clsMu.RUnlock()
+			if noInterest {
+				seqs[i], tss[i] = store.SkipMsg(), ts
+				continue
+			}
+		}
+		if lseq == 0 && ts == 0 {
+			seqs[i], tss[i], err = store.StoreMsg(subj, hdrs[i], im.msg, ttls[i])
+		} else {
+			// Make sure to take into account any message assignments that we had to skip (clfs).
+			seqs[i], tss[i] = lseq+1-clfs+uint64(i), ts
+			// Check for preAcks and the need to clear it.
+			if mset.hasAllPreAcks(seqs[i], subj) {
+				mset.clearAllPreAcks(seqs[i])
+			}
+			err = store.StoreRawMsg(subj, hdrs[i], im.msg, seqs[i], ts, ttls[i])
+		}
+		if err != nil {
+			break
+		}
+		mset.imsgs++
+		mset.ibytes += uint64(len(hdrs[i]) + len(im.msg))
+	}
+
+	// This means the store itself failed, for instance writing to disk, which we handle the same as for single messages.
+	if err != nil {
+		var state StreamState
+		store.FastState(&state)
+		mset.lseq = state.LastSeq
+		if isPermissionError(err) {
+			mset.mu.Unlock()
+			// messages in block cache could be lost in the worst case.
+			// In the clustered mode it is very highly unlikely as a result of replication.
+			mset.srv.DisableJetStream()
+			mset.srv.Warnf("Filesystem permission denied while writing msg, disabling JetStream: %v", err)
+			return err
+		}
+		// Sequences that were used up by the store should not be accounted as failed.
+		if state.LastSeq > plseq {
+			failed -= min(failed, state.LastSeq-plseq)
+		}
+		switch err {
+		case ErrMaxMsgs, ErrMaxBytes, ErrMaxMsgsPerSubject, ErrMsgTooLarge:
+			s.RateLimitDebugf("JetStream failed to store a batch on stream '%s > %s': %v", accName, name, err)
+		case ErrStoreClosed:
+		default:
+			s.Errorf("JetStream failed to store a batch on stream '%s > %s': %v", accName, name, err)
+		}
+		return reject(NewJSStreamStoreFailedError(err, Unless(err)), err)
+	}
+
+	seq := seqs[len(seqs)-1]
+	mset.lseq = seq
+	for i, msgId := range msgIds {
+		if msgId == _EMPTY_ {
+			continue
+		}
+		mset.lmsgId = msgId
+		mset.storeMsgIdLocked(&ddentry{msgId, seqs[i], tss[i]})
+	}
+
+	// Republish state if needed.
+	var thdrsOnly bool
+	if mset.cfg.RePublish != nil {
+		thdrsOnly = mset.cfg.RePublish.HeadersOnly
+	}
+	tr := mset.tr
+	mset.mu.Unlock()
+
+	if tr != nil && isLeader {
+		for i, im := range msgs {
+			if tsubj, _ := tr.Match(subjects[i]); tsubj != _EMPTY_ {
+				mset.republishMsg(tsubj, name, subjects[i], hdrs[i], im.msg, seqs[i], tss[i], tlseqs[i], thdrsOnly)
+			}
+		}
+	}
+
+	// Send response here.
+	if canRespond {
+		var buf [256]byte
+		response := append(buf[:0], mset.pubAck...)
+		response = append(response, strconv.FormatUint(seq, 10)...)
+		response = fmt.Appendf(response, ",%q:%q,%q:%d}", "batch", batchId, "count", batchSize)
+		mset.outq.sendMsg(commit.rply, response)
+	}
+
+	// Signal consumers for new messages.
+	if numConsumers > 0 {
+		hfcons := mset.hfcons.Load() > 0
+		for i := range msgs {
+			var shdr []byte
+			if hfcons {
+				shdr = append([]byte{sigHdrs}, hdrs[i]...)
+			}
+			mset.sigq.push(newCMsg(subjects[i], seqs[i], shdr))
+		}
+		select {
+		case mset.sch <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// processClusteredInboundBatch will propose a committed atomic batch to the underlying raft group.
+func (mset *stream) processClusteredInboundBatch(batchId string, msgs []*inMsg) error {
+	commit := msgs[len(msgs)-1]
+
+	mset.mu.RLock()
+	canRespond := !mset.cfg.NoAck && len(commit.rply) > 0
+	name, stype := mset.cfg.Name, mset.cfg.Storage
+	s, js, jsa, r, tierName, outq, node := mset.srv, mset.js, mset.jsa, mset.cfg.Replicas, mset.tier, mset.outq, mset.node
+	lseq, isLeader := mset.lseq, mset.isLeader()
+	interestPolicy, discard, maxMsgs, maxBytes := mset.cfg.Retention != LimitsPolicy, mset.cfg.Discard, mset.cfg.MaxMsgs, mset.cfg.MaxBytes
+	mset.mu.RUnlock()
+
+	// This should not happen but possible now that we allow scale up, and scale down where this could trigger.
+	if node == nil {
+		return mset.processJetStreamBatch(batchId, msgs, 0, 0)
+	}
+
+	// Check that we are the leader. This can be false if we have scaled up from an R1 that had inbound queued messages.
+	if !isLeader {
+		return NewJSClusterNotLeaderError()
+	}
+
+	respondErr := func(apiErr *ApiError) {
+		if canRespond {
+			b, _ := json.Marshal(&JSPubAckResponse{PubAck: &PubAck{Stream: name, BatchId: batchId}, Error: apiErr})
+			outq.sendMsg(commit.rply, b)
+		}
+	}
+
+	// Check here pre-emptively if we have exceeded this server limits.
+	if js.limitsExceeded(stype) {
+		s.resourcesExceededError()
+		respondErr(NewJSInsufficientResourcesError())
+		// Stepdown regardless.
+		node.StepDown()
+		return NewJSInsufficientResourcesError()
+	}
+
+	// Check here pre-emptively if we have exceeded our account limits.
+	for _, im := range msgs {
+		if exceeded, err := jsa.wouldExceedLimits(stype, tierName, r, im.subj, im.hdr, im.msg); exceeded {
+			if err == nil {
+				err = NewJSAccountResourcesExceededError()
+			}
+			s.RateLimitWarnf("JetStream account limits exceeded for '%s': %s", jsa.acc().GetName(), err.Error())
+			respondErr(err)
+			return err
+		}
+	}
+
+	// Payloads must validate against any schemas bound to their subjects, otherwise every replica would reject the batch.
+	for _, im := range msgs {
+		if apiErr, err := mset.checkSchemas(im.subj, im.msg); apiErr != nil {
+			respondErr(apiErr)
+			return err
+		}
+	}
+
+	// We only use mset.clseq for clustering and in case we run ahead of actual commits.
+	// Check if we need to set initial value here
+	mset.clMu.Lock()
+	if mset.clseq == 0 || mset.clseq < lseq+mset.clfs {
+		// Re-capture
+		lseq = mset.lastSeq()
+		mset.clseq = lseq + mset.clfs
+	}
+
+	// Check if we have an interest policy and discard new with max msgs or bytes.
+	// Same as for single messages, we need to deny here otherwise it could succeed
+	// on some peers and not others depending on consumer ack state.
+	if interestPolicy && discard == DiscardNew && (maxMsgs > 0 || maxBytes > 0) {
+		if mset.inflight == nil {
+			mset.inflight = make(map[uint64]uint64)
+		}
+		for i, im := range msgs {
+			if stype == FileStorage {
+				mset.inflight[mset.clseq+uint64(i)] = fileStoreMsgSize(im.subj, im.hdr, im.msg)
+			} else {
+				mset.inflight[mset.clseq+uint64(i)] = memStoreMsgSize(im.subj, im.hdr, im.msg)
+			}
+		}
+
+		var state StreamState
+		mset.store.FastState(&state)
+
+		var err error
+		if maxMsgs > 0 && state.Msgs+uint64(len(mset.inflight)) > uint64(maxMsgs) {
+			err = ErrMaxMsgs
+		} else if maxBytes > 0 {
+			var bytesPending uint64
+			for _, nb := range mset.inflight {
+				bytesPending += nb
+			}
+			if state.Bytes+bytesPending > uint64(maxBytes) {
+				err = ErrMaxBytes
+			}
+		}
+		if err != nil {
+			for i := range msgs {
+				delete(mset.inflight, mset.clseq+uint64(i))
+			}
+			mset.clMu.Unlock()
+			respondErr(NewJSStreamStoreFailedError(err, Unless(err)))
+			return err
+		}
+	}
+
+	// Each message is its own entry, all proposed at once. Replicas stage them
+	// until the message committing the batch is applied, and apply the batch as a whole.
+	var sz int
+	ts := time.Now().UnixNano()
+	entries := make([]*Entry, 0, len(msgs))
+	for i, im := range msgs {
+		esm := encodeStreamBatchMsg(im, mset.clseq+uint64(i), ts)
+		entries = append(entries, newEntry(EntryNormal, esm))
+		sz += len(esm)
+	}
+
+	// Do proposal.
+	err := node.ProposeMulti(entries)
+	if err == nil {
+		mset.clseq += uint64(len(msgs))
+		// If we are using the system account for NRG, add in the extra sent msgs and bytes to our account
+		// so that the end user / account owner has visibility.
+		if node.IsSystemAccount() && mset.acc != nil && r > 1 {
+			atomic.AddInt64(&mset.acc.outMsgs, int64(len(msgs)*(r-1)))
+			atomic.AddInt64(&mset.acc.outBytes, int64(sz*(r-1)))
+		}
+	} else if mset.inflight != nil {
+		for i := range msgs {
+			delete(mset.inflight, mset.clseq+uint64(i))
+		}
+	}
+	mset.clMu.Unlock()
+
+	if err != nil {
+		respondErr(&ApiError{Code: 503, Description: err.Error()})
+		if isOutOfSpaceErr(err) {
+			s.handleOutOfSpace(mset)
+		}
+	}
+	return err
+}
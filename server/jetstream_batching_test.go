@@ -0,0 +1,428 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// publishBatch publishes all messages as a single atomic batch and returns the response to the commit.
+func publishBatch(t *testing.T, nc *nats.Conn, batchId string, msgs []*nats.Msg) *JSPubAckResponse {
+	t.Helper()
+	for i, m := range msgs {
+		if m.Header == nil {
+			m.Header = nats.Header{}
+		}
+		m.Header.Set(JSBatchId, batchId)
+		m.Header.Set(JSBatchSeq, strconv.Itoa(i+1))
+		if i < len(msgs)-1 {
+			require_NoError(t, nc.PublishMsg(m))
+			continue
+		}
+		m.Header.Set(JSBatchCommit, "1")
+		rmsg, err := nc.RequestMsg(m, time.Second)
+		require_NoError(t, err)
+		var resp JSPubAckResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+	return nil
+}
+
+func TestJetStreamAtomicBatchPublish(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:               "TEST",
+				Storage:            storage,
+				Subjects:           []string{"foo.*"},
+				AllowAtomicPublish: true,
+			})
+			require_NoError(t, err)
+
+			_, err = js.Publish("foo.0", nil)
+			require_NoError(t, err)
+
+			var msgs []*nats.Msg
+			for i := 1; i <= 5; i++ {
+				msgs = append(msgs, nats.NewMsg(fmt.Sprintf("foo.%d", i)))
+			}
+			resp := publishBatch(t, nc, "uuid", msgs)
+			require_True(t, resp.Error == nil)
+			require_Equal(t, resp.Sequence, 6)
+			require_Equal(t, resp.BatchId, "uuid")
+			require_Equal(t, resp.BatchSize, 5)
+
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 6)
+			require_Equal(t, si.State.LastSeq, 6)
+
+			for i := 1; i <= 5; i++ {
+				m, err := js.GetMsg("TEST", uint64(i+1))
+				require_NoError(t, err)
+				require_Equal(t, m.Subject, fmt.Sprintf("foo.%d", i))
+			}
+		})
+	}
+}
+
+func TestJetStreamAtomicBatchPublishDisabled(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"foo"},
+	})
+	require_NoError(t, err)
+
+	resp := publishBatch(t, nc, "uuid", []*nats.Msg{nats.NewMsg("foo")})
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSAtomicPublishDisabledErr))
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 0)
+}
+
+func TestJetStreamAtomicBatchPublishRejectsWholeBatch(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:               "TEST",
+				Storage:            storage,
+				Subjects:           []string{"foo.*"},
+				AllowAtomicPublish: true,
+			})
+			require_NoError(t, err)
+
+			_, err = js.Publish("foo.a", nil)
+			require_NoError(t, err)
+
+			// The expected last subject sequence takes earlier messages of the batch into account.
+			m1, m2, m3 := nats.NewMsg("foo.a"), nats.NewMsg("foo.a"), nats.NewMsg("foo.b")
+			m1.Header = nats.Header{JSExpectedLastSubjSeq: []string{"1"}}
+			m2.Header = nats.Header{JSExpectedLastSubjSeq: []string{"2"}}
+			resp := publishBatch(t, nc, "ok", []*nats.Msg{m1, m2, m3})
+			require_True(t, resp.Error == nil)
+			require_Equal(t, resp.Sequence, 4)
+
+			// A single failing condition rejects all messages of the batch.
+			m1, m2, m3 = nats.NewMsg("foo.b"), nats.NewMsg("foo.c"), nats.NewMsg("foo.a")
+			m3.Header = nats.Header{JSExpectedLastSubjSeq: []string{"2"}}
+			resp = publishBatch(t, nc, "bad", []*nats.Msg{m1, m2, m3})
+			require_NotNil(t, resp.Error)
+			require_Equal(t, resp.Error.ErrCode, uint16(JSStreamWrongLastSequenceErrF))
+
+			// So do duplicates.
+			m1, m2 = nats.NewMsg("foo.b"), nats.NewMsg("foo.c")
+			m1.Header = nats.Header{JSMsgId: []string{"dup"}}
+			m2.Header = nats.Header{JSMsgId: []string{"dup"}}
+			resp = publishBatch(t, nc, "dup", []*nats.Msg{m1, m2})
+			require_NotNil(t, resp.Error)
+			require_Equal(t, resp.Error.ErrCode, uint16(JSAtomicPublishContainsDuplicateMessageErr))
+
+			// And headers that can't apply to the batch as a whole.
+			m1 = nats.NewMsg("foo.b")
+			m1.Header = nats.Header{JSMsgRollup: []string{JSMsgRollupAll}}
+			resp = publishBatch(t, nc, "rollup", []*nats.Msg{m1})
+			require_NotNil(t, resp.Error)
+			require_Equal(t, resp.Error.ErrCode, uint16(JSAtomicPublishUnsupportedHeaderBatchErr))
+
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 4)
+			require_Equal(t, si.State.LastSeq, 4)
+
+			// Following batches should continue at the right sequence.
+			resp = publishBatch(t, nc, "next", []*nats.Msg{nats.NewMsg("foo.d")})
+			require_True(t, resp.Error == nil)
+			require_Equal(t, resp.Sequence, 5)
+		})
+	}
+}
+
+func TestJetStreamAtomicBatchPublishDiscardNew(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:               "TEST",
+		Storage:            FileStorage,
+		Subjects:           []string{"foo"},
+		MaxMsgs:            3,
+		Discard:            DiscardNew,
+		AllowAtomicPublish: true,
+	})
+	require_NoError(t, err)
+
+	_, err = js.Publish("foo", nil)
+	require_NoError(t, err)
+
+	// Only two more messages would fit, so the whole batch is rejected.
+	resp := publishBatch(t, nc, "uuid", []*nats.Msg{nats.NewMsg("foo"), nats.NewMsg("foo"), nats.NewMsg("foo")})
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamStoreFailedF))
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 1)
+	require_Equal(t, si.State.LastSeq, 1)
+
+	// Find out how many bytes a batch takes up.
+	batch := func() []*nats.Msg {
+		return []*nats.Msg{{Subject: "bar", Data: []byte("x")}, {Subject: "bar", Data: []byte("y")}}
+	}
+	_, err = jsStreamCreate(t, nc, &StreamConfig{Name: "SIZE", Storage: FileStorage, Subjects: []string{"bar"}, AllowAtomicPublish: true})
+	require_NoError(t, err)
+	resp = publishBatch(t, nc, "uuid", batch())
+	require_True(t, resp.Error == nil)
+	si, err = js.StreamInfo("SIZE")
+	require_NoError(t, err)
+	require_NoError(t, js.DeleteStream("SIZE"))
+
+	// The store needs to stay below max bytes, so this doesn't fit either. Nothing is stored
+	// rather than storing part of the batch and removing it again.
+	_, err = jsStreamCreate(t, nc, &StreamConfig{
+		Name:               "BYTES",
+		Storage:            FileStorage,
+		Subjects:           []string{"bar"},
+		MaxBytes:           int64(si.State.Bytes),
+		Discard:            DiscardNew,
+		AllowAtomicPublish: true,
+	})
+	require_NoError(t, err)
+	resp = publishBatch(t, nc, "uuid", batch())
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamStoreFailedF))
+	si, err = js.StreamInfo("BYTES")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 0)
+	require_Equal(t, si.State.LastSeq, 0)
+}
+
+func TestJetStreamAtomicBatchPublishIncomplete(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:               "TEST",
+		Storage:            FileStorage,
+		Subjects:           []string{"foo"},
+		AllowAtomicPublish: true,
+	})
+	require_NoError(t, err)
+
+	request := func(hdr nats.Header) *JSPubAckResponse {
+		t.Helper()
+		rmsg, err := nc.RequestMsg(&nats.Msg{Subject: "foo", Header: hdr}, time.Second)
+		require_NoError(t, err)
+		var resp JSPubAckResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+
+	// Missing the batch sequence.
+	resp := request(nats.Header{JSBatchId: []string{"uuid"}})
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSAtomicPublishMissingSeqErr))
+
+	// Not starting at the first sequence.
+	resp = request(nats.Header{JSBatchId: []string{"uuid"}, JSBatchSeq: []string{"2"}})
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSAtomicPublishIncompleteBatchErr))
+
+	// A gap in the sequence abandons the batch.
+	require_NoError(t, nc.PublishMsg(&nats.Msg{Subject: "foo", Header: nats.Header{JSBatchId: []string{"uuid"}, JSBatchSeq: []string{"1"}}}))
+	resp = request(nats.Header{JSBatchId: []string{"uuid"}, JSBatchSeq: []string{"3"}, JSBatchCommit: []string{"1"}})
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSAtomicPublishIncompleteBatchErr))
+
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	mset.mu.RLock()
+	batches := len(mset.batches)
+	mset.mu.RUnlock()
+	require_Equal(t, batches, 0)
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 0)
+}
+
+func TestJetStreamClusterAtomicBatchPublish(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:               "TEST",
+		Storage:            FileStorage,
+		Subjects:           []string{"foo.*"},
+		Replicas:           3,
+		AllowAtomicPublish: true,
+	})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	var msgs []*nats.Msg
+	for i := 1; i <= 10; i++ {
+		msgs = append(msgs, nats.NewMsg(fmt.Sprintf("foo.%d", i)))
+	}
+	resp := publishBatch(t, nc, "uuid", msgs)
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Sequence, 10)
+	require_Equal(t, resp.BatchSize, 10)
+
+	// A rejected batch is accounted as failed on all replicas.
+	m := nats.NewMsg("foo.1")
+	m.Header = nats.Header{JSExpectedLastSubjSeq: []string{"2"}}
+	resp = publishBatch(t, nc, "bad", []*nats.Msg{nats.NewMsg("foo.2"), m})
+	require_NotNil(t, resp.Error)
+
+	_, err = js.Publish("foo.11", nil)
+	require_NoError(t, err)
+
+	checkFor(t, 2*time.Second, 200*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			var state StreamState
+			mset.store.FastState(&state)
+			if state.Msgs != 11 || state.LastSeq != 11 {
+				return fmt.Errorf("expected 11 msgs, got %d (last %d)", state.Msgs, state.LastSeq)
+			}
+			if clfs := mset.getCLFS(); clfs != 2 {
+				return fmt.Errorf("expected clfs of 2, got %d", clfs)
+			}
+		}
+		return nil
+	})
+
+	// A batch this large spans multiple append entries, but is still applied as a whole.
+	msgs = msgs[:0]
+	for i := 0; i < streamMaxBatchSize; i++ {
+		msgs = append(msgs, nats.NewMsg("foo.batch"))
+	}
+	resp = publishBatch(t, nc, "large", msgs)
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Sequence, 11+streamMaxBatchSize)
+	checkFor(t, 2*time.Second, 200*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			var state StreamState
+			mset.store.FastState(&state)
+			if state.Msgs != 11+streamMaxBatchSize {
+				return fmt.Errorf("expected %d msgs, got %d", 11+streamMaxBatchSize, state.Msgs)
+			}
+		}
+		return nil
+	})
+}
+
+func TestJetStreamClusterStreamBatchEncoding(t *testing.T) {
+	im := &inMsg{subj: "foo", rply: "reply", hdr: []byte("NATS/1.0\r\nNats-Batch-Id: uuid\r\nNats-Batch-Sequence: 2\r\n\r\n"), msg: []byte("hello")}
+	buf := encodeStreamBatchMsg(im, 10, 22)
+	require_Equal(t, entryOp(buf[0]), batchMsgOp)
+
+	subj, reply, hdr, msg, lseq, ts, sourced, err := decodeStreamMsg(buf[1:])
+	require_NoError(t, err)
+	require_Equal(t, subj, im.subj)
+	require_Equal(t, reply, im.rply)
+	require_Equal(t, string(hdr), string(im.hdr))
+	require_Equal(t, string(msg), string(im.msg))
+	require_Equal(t, lseq, 10)
+	require_Equal(t, ts, 22)
+	require_False(t, sourced)
+	require_Equal(t, getBatchId(hdr), "uuid")
+
+	// Truncated entries should fail to decode.
+	_, _, _, _, _, _, _, err = decodeStreamMsg(buf[1 : len(buf)-3])
+	require_Error(t, err)
+}
+
+func TestJetStreamClusterStreamBatchStaging(t *testing.T) {
+	mset := &stream{}
+	hdr := func(id string, seq int, commit bool) []byte {
+		h := genHeader(nil, JSBatchId, id)
+		h = genHeader(h, JSBatchSeq, strconv.Itoa(seq))
+		if commit {
+			h = genHeader(h, JSBatchCommit, "1")
+		}
+		return h
+	}
+
+	// Messages are staged until the batch is committed.
+	require_True(t, mset.stageBatchMsg("foo", _EMPTY_, hdr("A", 1, false), []byte("1"), 10, 22) == nil)
+	require_True(t, mset.stageBatchMsg("foo", _EMPTY_, hdr("A", 2, false), []byte("2"), 11, 22) == nil)
+	b := mset.stageBatchMsg("foo", "reply", hdr("A", 3, true), []byte("3"), 12, 22)
+	require_NotNil(t, b)
+	require_Equal(t, b.id, "A")
+	require_Equal(t, b.lseq, 10)
+	require_Equal(t, b.ts, 22)
+	require_Len(t, len(b.msgs), 3)
+	require_Equal(t, b.msgs[2].rply, "reply")
+	require_True(t, mset.batchApply == nil)
+
+	// A batch that was only partly proposed is dropped once another one starts.
+	require_True(t, mset.stageBatchMsg("foo", _EMPTY_, hdr("B", 1, false), []byte("1"), 13, 22) == nil)
+	require_True(t, mset.stageBatchMsg("foo", _EMPTY_, hdr("C", 1, false), []byte("1"), 13, 23) == nil)
+	b = mset.stageBatchMsg("foo", _EMPTY_, hdr("C", 2, true), []byte("2"), 14, 23)
+	require_NotNil(t, b)
+	require_Equal(t, b.id, "C")
+	require_Len(t, len(b.msgs), 2)
+
+	// Or when the next message of the batch is not the one expected.
+	require_True(t, mset.stageBatchMsg("foo", _EMPTY_, hdr("D", 1, false), []byte("1"), 15, 24) == nil)
+	require_True(t, mset.stageBatchMsg("foo", _EMPTY_, hdr("D", 3, true), []byte("3"), 17, 24) == nil)
+	require_True(t, mset.batchApply == nil)
+}
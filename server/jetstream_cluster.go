@@ -82,6 +82,18 @@ type Placement struct {
 	Cluster   string   `json:"cluster,omitempty"`
 	Tags      []string `json:"tags,omitempty"`
 	Preferred string   `json:"preferred,omitempty"`
+	// Learners are read replicas in addition to the stream's replicas.
+	Learners *LearnerPlacement `json:"learners,omitempty"`
+}
+
+// LearnerPlacement places read replicas of a stream. Learners receive the
+// stream but do not vote, so they do not raise the quorum size or the latency
+// of writes. They never become the stream leader, but do serve direct gets.
+type LearnerPlacement struct {
+	Replicas int `json:"replicas"`
+	// Cluster defaults to the cluster of the stream.
+	Cluster string   `json:"cluster,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
 }
 
 // Define types of the entry.
@@ -114,6 +126,10 @@ const (
 	compressedStreamMsgOp
 	// For sending deleted gaps on catchups for replicas.
 	deleteRangeOp
+	// For stream msgs that are part of an atomic batch.
+	batchMsgOp
+	// For moving a consumer to a new starting position.
+	resetConsumerOp
 )
 
 // raftGroups are controlled by the metagroup controller.
@@ -121,6 +137,7 @@ const (
 type raftGroup struct {
 	Name      string      `json:"name"`
 	Peers     []string    `json:"peers"`
+	Learners  []string    `json:"learners,omitempty"`
 	Storage   StorageType `json:"store"`
 	Cluster   string      `json:"cluster,omitempty"`
 	Preferred string      `json:"preferred,omitempty"`
@@ -176,6 +193,16 @@ type streamPurge struct {
 	Request *JSApiStreamPurgeRequest `json:"request,omitempty"`
 }
 
+// consumerReset is what the consumer leader will replicate when resetting a consumer.
+// The leader responds to the request once applied.
+type consumerReset struct {
+	Client   *ClientInfo `json:"client,omitempty"`
+	Subject  string      `json:"subject"`
+	Reply    string      `json:"reply"`
+	Sequence uint64      `json:"seq"`
+	DSeq     uint64      `json:"dseq"`
+}
+
 // streamMsgDelete is what the stream leader will replicate when deleting a message.
 type streamMsgDelete struct {
 	Client  *ClientInfo `json:"client,omitempty"`
@@ -787,6 +814,16 @@ func (js *jetStream) setupMetaGroup() error {
 	atomic.StoreInt32(&js.clustered, 1)
 	c.registerWithAccount(sysAcc)
 
+	// Listen for the meta leader balancing stream and consumer leaders.
+	// These go away with our internal client when JetStream is shut down.
+	node := s.Node()
+	if _, err := s.systemSubscribe(fmt.Sprintf(clusterLeaderReportT, node), _EMPTY_, false, c, s.noInlineCallback(s.jsLeaderReportRequest)); err != nil {
+		s.Errorf("Error setting up JetStream leader report handler: %v", err)
+	}
+	if _, err := s.systemSubscribe(fmt.Sprintf(clusterLeaderTransferT, node), _EMPTY_, false, c, s.noInlineCallback(s.jsLeaderTransferRequest)); err != nil {
+		s.Errorf("Error setting up JetStream leader transfer handler: %v", err)
+	}
+
 	// Set to true before we start.
 	js.metaRecovering = true
 	js.srv.startGoRoutine(
@@ -1704,6 +1741,7 @@ func (sa *streamAssignment) copyGroup() *streamAssignment {
 	csa, cg := *sa, *sa.Group
 	csa.Group = &cg
 	csa.Group.Peers = copyStrings(sa.Group.Peers)
+	csa.Group.Learners = copyStrings(sa.Group.Learners)
 	return &csa
 }
 
@@ -2040,7 +2078,7 @@ func (rg *raftGroup) isMember(id string) bool {
 			return true
 		}
 	}
-	return false
+	return slices.Contains(rg.Learners, id)
 }
 
 func (rg *raftGroup) setPreferred() {
@@ -2085,9 +2123,10 @@ retry:
 		s.Debugf("JetStream cluster already has raft group %q assigned", rg.Name)
 		// Check and see if the group has the same peers. If not then we
 		// will update the known peers, which will send a peerstate if leader.
-		groupPeerIDs := append([]string{}, rg.Peers...)
+		groupPeerIDs := append(append([]string{}, rg.Peers...), rg.Learners...)
+		node.SetLearners(rg.Learners)
 		var samePeers bool
-		if nodePeers := node.Peers(); len(rg.Peers) == len(nodePeers) {
+		if nodePeers := node.Peers(); len(groupPeerIDs) == len(nodePeers) {
 			nodePeerIDs := make([]string, 0, len(nodePeers))
 			for _, n := range nodePeers {
 				nodePeerIDs = append(nodePeerIDs, n.ID)
@@ -2131,12 +2170,18 @@ retry:
 		syncAlways := js.srv.opts.SyncAlways
 		syncInterval := js.srv.opts.SyncInterval
 		js.srv.optsMu.RUnlock()
+		// Raft logs hold the account's data, so they use the account's key.
+		prf, oldprf, err := s.jsAccountKeyGen(accName, rg.Name)
+		if err != nil {
+			s.Errorf("Error creating filestore WAL: %v", err)
+			return err
+		}
 		fs, err := newFileStoreWithCreated(
 			FileStoreConfig{StoreDir: storeDir, BlockSize: defaultMediumBlockSize, AsyncFlush: false, SyncAlways: syncAlways, SyncInterval: syncInterval, srv: s},
 			StreamConfig{Name: rg.Name, Storage: FileStorage, Metadata: labels},
 			time.Now().UTC(),
-			s.jsKeyGen(s.getOpts().JetStreamKey, rg.Name),
-			s.jsKeyGen(s.getOpts().JetStreamOldKey, rg.Name),
+			prf,
+			oldprf,
 		)
 		if err != nil {
 			s.Errorf("Error creating filestore WAL: %v", err)
@@ -2152,10 +2197,10 @@ retry:
 		store = ms
 	}
 
-	cfg := &RaftConfig{Name: rg.Name, Store: storeDir, Log: store, Track: true}
+	cfg := &RaftConfig{Name: rg.Name, Store: storeDir, Log: store, Track: true, Learners: rg.Learners}
 
 	if _, err := readPeerState(storeDir); err != nil {
-		s.bootstrapRaftNode(cfg, rg.Peers, true)
+		s.bootstrapRaftNode(cfg, append(append([]string{}, rg.Peers...), rg.Learners...), true)
 	}
 
 	n, err := s.startRaftNode(accName, cfg, labels)
@@ -2321,6 +2366,8 @@ func (js *jetStream) monitorStream(mset *stream, sa *streamAssignment, sendSnaps
 	}()
 
 	qch, mqch, lch, aq, uch, ourPeerId := n.QuitC(), mset.monitorQuitC(), n.LeadChangeC(), n.ApplyQ(), mset.updateC(), meta.ID()
+	rpch := mset.repairC()
+	var prepair []scrubRange
 
 	s.Debugf("Starting stream monitor for '%s > %s' [%s]", sa.Client.serviceAccount(), sa.Config.Name, n.Group())
 	defer s.Debugf("Exiting stream monitor for '%s > %s' [%s]", sa.Client.serviceAccount(), sa.Config.Name, n.Group())
@@ -2504,8 +2551,10 @@ func (js *jetStream) monitorStream(mset *stream, sa *streamAssignment, sendSnaps
 
 				// Apply our entries.
 				if err := js.applyStreamEntries(mset, ce, isRecovering); err == nil {
-					// Update our applied.
-					ne, nb = n.Applied(ce.Index)
+					// Update our applied, unless we are staging a batch that a snapshot would lose.
+					if mset == nil || mset.batchApply == nil {
+						ne, nb = n.Applied(ce.Index)
+					}
 					ce.ReturnToPool()
 				} else {
 					// Our stream was closed out from underneath of us, simply return here.
@@ -2563,6 +2612,12 @@ func (js *jetStream) monitorStream(mset *stream, sa *streamAssignment, sendSnaps
 			// Process our leader change.
 			js.processStreamLeaderChange(mset, isLeader)
 
+			// A repair held on to while we were leader can be done now.
+			if !isLeader && len(prepair) > 0 {
+				mset.requestRepair(prepair)
+				prepair = nil
+			}
+
 			// We may receive a leader change after the stream assignment which would cancel us
 			// monitoring for this closely. So re-assess our state here as well.
 			// Or the old leader is no longer part of the set and transferred leadership
@@ -2633,6 +2688,21 @@ func (js *jetStream) monitorStream(mset *stream, sa *streamAssignment, sendSnaps
 		case <-t.C:
 			doSnapshot()
 
+		case bad := <-rpch:
+			// Our store has corrupt messages, so fetch good copies from a healthy peer.
+			// Only followers can catchup, a leader holds on to the repair until it is no longer leader.
+			if isLeader {
+				prepair = bad
+				continue
+			}
+			if err := mset.repairFromPeer(bad); err != nil {
+				if err == errCatchupStreamStopped || err == ErrServerNotRunning {
+					return
+				}
+				// Our store is left as it was, so the next scrub will try again.
+				s.Warnf("Error repairing stream '%s > %s' from a peer: %v", accName, sa.Config.Name, err)
+			}
+
 		case <-uch:
 			// keep stream assignment current
 			sa = mset.streamAssignment()
@@ -2747,6 +2817,7 @@ func (js *jetStream) monitorStream(mset *stream, sa *streamAssignment, sendSnaps
 					mset.setStreamAssignment(sa)
 					// Make sure to update our updateC which would have been nil.
 					uch = mset.updateC()
+					rpch = mset.repairC()
 					// Also update our mqch
 					mqch = mset.monitorQuitC()
 					// Setup a periodic check here if we are interest based as well.
@@ -2983,6 +3054,8 @@ func (js *jetStream) applyStreamEntries(mset *stream, ce *CommittedEntry, isReco
 					}
 					panic(err.Error())
 				}
+				// A batch being staged was only partly proposed by a previous leader.
+				mset.batchApply = nil
 
 				// Check for flowcontrol here.
 				if len(msg) == 0 && len(hdr) > 0 && reply != _EMPTY_ && isControlHdr(hdr) {
@@ -3079,6 +3152,75 @@ func (js *jetStream) applyStreamEntries(mset *stream, ce *CommittedEntry, isReco
 						mset.account(), mset.name(), err)
 				}
 
+			case batchMsgOp:
+				if mset == nil {
+					continue
+				}
+				s := js.srv
+
+				subject, reply, hdr, msg, lseq, ts, _, err := decodeStreamMsg(buf[1:])
+				if err != nil {
+					if node := mset.raftNode(); node != nil {
+						s.Errorf("JetStream cluster could not decode stream batch msg for '%s > %s' [%s]",
+							mset.account(), mset.name(), node.Group())
+					}
+					panic(err.Error())
+				}
+
+				// Wait for the rest of the batch, it is applied as a whole once committed.
+				b := mset.stageBatchMsg(subject, reply, hdr, msg, lseq, ts)
+				if b == nil {
+					continue
+				}
+				batchId, msgs, lseq, ts := b.id, b.msgs, b.lseq, b.ts
+
+				// Grab last sequence and CLFS.
+				last, clfs := mset.lastSeqAndCLFS()
+
+				// We can skip if we know this is less than what we already have.
+				if lseq-clfs < last {
+					s.Debugf("Apply stream entries for '%s > %s' skipping batch with sequence %d with last of %d",
+						mset.account(), mset.name(), lseq+1-clfs, last)
+					mset.mu.Lock()
+					for i := range msgs {
+						mset.clearAllPreAcks(lseq + 1 - clfs + uint64(i))
+					}
+					mset.mu.Unlock()
+					continue
+				}
+
+				// Process the batch as a whole.
+				err = mset.processJetStreamBatch(batchId, msgs, lseq, ts)
+
+				// If we have inflight make sure to clear after processing.
+				if mset.inflight != nil {
+					mset.clMu.Lock()
+					for i := range msgs {
+						delete(mset.inflight, lseq+uint64(i))
+					}
+					mset.clMu.Unlock()
+				}
+
+				if err != nil {
+					if err == errLastSeqMismatch {
+						var state StreamState
+						mset.store.FastState(&state)
+						// Same as for single messages, reset if we have no msgs and the other side is past where we should be.
+						if state.Msgs == 0 {
+							mset.store.Compact(lseq + 1)
+							// Retry
+							err = mset.processJetStreamBatch(batchId, msgs, lseq, ts)
+						}
+					}
+
+					// Only return in place if we are going to reset our stream or we are out of space, or we are closed.
+					if isClusterResetErr(err) || isOutOfSpaceErr(err) || err == errStreamClosed {
+						return err
+					}
+					s.Debugf("Apply stream entries for '%s > %s' got error processing batch: %v",
+						mset.account(), mset.name(), err)
+				}
+
 			case deleteMsgOp:
 				md, err := decodeMsgDelete(buf[1:])
 				if err != nil {
@@ -5136,6 +5278,49 @@ func (js *jetStream) applyConsumerEntries(o *consumer, ce *CommittedEntry, isLea
 					}
 				}
 				o.mu.Unlock()
+			case resetConsumerOp:
+				cr, err := decodeConsumerReset(buf[1:])
+				if err != nil {
+					if mset, node := o.streamAndNode(); mset != nil && node != nil {
+						s := js.srv
+						s.Errorf("JetStream cluster could not decode consumer reset for '%s > %s > %s' [%s]",
+							mset.account(), mset.name(), o, node.Group())
+					}
+					panic(err.Error())
+				}
+				o.mu.Lock()
+				// The leader could have delivered more messages since proposing,
+				// those delivery sequences should not be handed out again.
+				dseq := cr.DSeq
+				if isLeader {
+					dseq = max(dseq, o.dseq)
+				}
+				o.applyReset(cr.Sequence, dseq)
+				if err = o.resetStoreState(cr.Sequence, dseq); err != nil && o.srv != nil && o.mset != nil {
+					// Sync the store with the state we just reset to instead.
+					s, acc, mset, name := o.srv, o.acc, o.mset, o.name
+					s.Warnf("Consumer '%s > %s > %s' error on store reset: %v", acc, mset.name(), name, err)
+					if err = o.writeStoreStateUnlocked(); err != nil {
+						s.Warnf("Consumer '%s > %s > %s' error on write store state from reset: %v", acc, mset.name(), name, err)
+					}
+				}
+				if isLeader {
+					o.sendResetAdvisoryLocked(cr.Sequence)
+					o.signalNewMessages()
+				}
+				o.mu.Unlock()
+				// Moving forward may allow messages to be removed from interest based streams.
+				if mset := o.getStream(); mset != nil {
+					var ss StreamState
+					mset.store.FastState(&ss)
+					o.checkStateForInterestStream(&ss)
+				}
+				if isLeader && cr.Reply != _EMPTY_ {
+					s := js.srv
+					resp := JSApiConsumerResetResponse{ApiResponse: ApiResponse{Type: JSApiConsumerResetResponseType}}
+					resp.ConsumerInfo = setDynamicConsumerInfoMetadata(o.info())
+					s.sendAPIResponse(cr.Client, o.account(), cr.Subject, cr.Reply, _EMPTY_, s.jsonResponse(resp))
+				}
 			case addPendingRequest:
 				o.mu.Lock()
 				if !o.isLeader() {
@@ -5207,6 +5392,7 @@ func (o *consumer) processReplicatedAck(dseq, sseq uint64) error {
 			sagap = sseq - state.AckFloor.Stream
 		}
 	}
+	hdrFiltered := len(o.cfg.HeaderFilters) > 0
 	o.mu.Unlock()
 
 	if sagap > 1 {
@@ -5217,11 +5403,33 @@ func (o *consumer) processReplicatedAck(dseq, sseq uint64) error {
 	} else {
 		mset.ackMsg(o, sseq)
 	}
+	// Messages skipped by our header filters are never acked, release them based on our ack floor.
+	if hdrFiltered {
+		var ss StreamState
+		mset.store.FastState(&ss)
+		o.checkStateForInterestStream(&ss)
+	}
 	return nil
 }
 
 var errBadAckUpdate = errors.New("jetstream cluster bad replicated ack update")
 var errBadDeliveredUpdate = errors.New("jetstream cluster bad replicated delivered update")
+var errBadConsumerReset = errors.New("jetstream cluster bad replicated consumer reset")
+
+func encodeConsumerReset(cr *consumerReset) []byte {
+	var bb bytes.Buffer
+	bb.WriteByte(byte(resetConsumerOp))
+	json.NewEncoder(&bb).Encode(cr)
+	return bb.Bytes()
+}
+
+func decodeConsumerReset(buf []byte) (*consumerReset, error) {
+	var cr consumerReset
+	if err := json.Unmarshal(buf, &cr); err != nil || cr.Sequence == 0 || cr.DSeq == 0 {
+		return nil, errBadConsumerReset
+	}
+	return &cr, nil
+}
 
 func decodeAckUpdate(buf []byte) (dseq, sseq uint64, err error) {
 	var bi, n int
@@ -5453,7 +5661,7 @@ func (js *jetStream) processStreamAssignmentResults(sub *subscription, c *client
 				// If we have additional clusters to try we can retry.
 				// We have already verified that ci != nil.
 				if len(ci.Alternates) > 0 {
-					if rg, err := js.createGroupForStream(ci, cfg); err != nil {
+					if rg, err := js.createGroupForStream(ci, cfg, nil); err != nil {
 						s.Warnf("Retrying cluster placement for stream '%s > %s' failed due to placement error: %+v", result.Account, result.Stream, err)
 					} else {
 						if org := sa.Group; org != nil && len(org.Peers) > 0 {
@@ -5687,8 +5895,27 @@ func (js *jetStream) processLeaderChange(isLeader bool) {
 
 // Lock should be held.
 func (cc *jetStreamCluster) remapStreamAssignment(sa *streamAssignment, removePeer string) bool {
+	// Learners are replaced separately, and do not affect the peers.
+	if slices.Contains(sa.Group.Learners, removePeer) {
+		var retain []string
+		for _, v := range sa.Group.Learners {
+			if v != removePeer {
+				retain = append(retain, v)
+			}
+		}
+		ignore := append(copyStrings(sa.Group.Peers), removePeer)
+		if learners, err := cc.selectLearners(sa.Group.Cluster, sa.Config, retain, ignore); err == nil {
+			sa.Group.Learners = learners
+			return true
+		}
+		sa.Group.Learners = retain
+		return false
+	}
+
 	// Invoke placement algo passing RG peers that stay (existing) and the peer that is being removed (ignore)
 	var retain, ignore []string
+	// Our learners can not become peers.
+	ignore = append(ignore, sa.Group.Learners...)
 	for _, v := range sa.Group.Peers {
 		if v == removePeer {
 			ignore = append(ignore, v)
@@ -5894,6 +6121,11 @@ func (cc *jetStreamCluster) selectPeerGroup(r int, cluster string, cfg *StreamCo
 					peerHA[peer]++
 				}
 			}
+			// Learners host the stream as well.
+			for _, peer := range sa.Group.Learners {
+				peerStreams[peer]++
+				peerHA[peer]++
+			}
 		}
 	}
 
@@ -6081,8 +6313,9 @@ func tieredStreamAndReservationCount(asa map[string]*streamAssignment, tier stri
 }
 
 // createGroupForStream will create a group for assignment for the stream.
+// Peers to ignore will not be selected.
 // Lock should be held.
-func (js *jetStream) createGroupForStream(ci *ClientInfo, cfg *StreamConfig) (*raftGroup, *selectPeerError) {
+func (js *jetStream) createGroupForStream(ci *ClientInfo, cfg *StreamConfig, ignore []string) (*raftGroup, *selectPeerError) {
 	replicas := cfg.Replicas
 	if replicas == 0 {
 		replicas = 1
@@ -6103,16 +6336,43 @@ func (js *jetStream) createGroupForStream(ci *ClientInfo, cfg *StreamConfig) (*r
 	// Need to create a group here.
 	errs := &selectPeerError{}
 	for _, cn := range clusters {
-		peers, err := cc.selectPeerGroup(replicas, cn, cfg, nil, 0, nil)
+		peers, err := cc.selectPeerGroup(replicas, cn, cfg, nil, 0, ignore)
 		if len(peers) < replicas {
 			errs.accumulate(err)
 			continue
 		}
-		return &raftGroup{Name: groupNameForStream(peers, cfg.Storage), Storage: cfg.Storage, Peers: peers, Cluster: cn}, nil
+		learners, err := cc.selectLearners(cn, cfg, nil, append(copyStrings(peers), ignore...))
+		if err != nil {
+			errs.accumulate(err)
+			continue
+		}
+		return &raftGroup{Name: groupNameForStream(peers, cfg.Storage), Storage: cfg.Storage, Peers: peers, Learners: learners, Cluster: cn}, nil
 	}
 	return nil, errs
 }
 
+// selectLearners selects the learners for a stream based on its learner placement,
+// keeping any existing ones. Learners default to the cluster of the stream and
+// should never be one of its peers, so those need to be ignored.
+// Lock should be held.
+func (cc *jetStreamCluster) selectLearners(cluster string, cfg *StreamConfig, existing, ignore []string) ([]string, *selectPeerError) {
+	if cfg.Placement == nil || cfg.Placement.Learners == nil {
+		return nil, nil
+	}
+	lp := cfg.Placement.Learners
+	if lp.Cluster != _EMPTY_ {
+		cluster = lp.Cluster
+	}
+	lcfg := cfg.clone()
+	lcfg.Replicas = lp.Replicas
+	lcfg.Placement = &Placement{Cluster: cluster, Tags: lp.Tags}
+	learners, err := cc.selectPeerGroup(lp.Replicas, cluster, lcfg, existing, 0, ignore)
+	if len(learners) < lp.Replicas {
+		return nil, err
+	}
+	return learners, nil
+}
+
 func (acc *Account) selectLimits(replicas int) (*JetStreamAccountLimits, string, *jsAccount, *ApiError) {
 	// Grab our jetstream account info.
 	acc.mu.RLock()
@@ -6236,7 +6496,7 @@ func (s *Server) jsClusteredStreamRequest(ci *ClientInfo, acc *Account, subject,
 	}
 	// Create a new one here if needed.
 	if rg == nil {
-		nrg, err := js.createGroupForStream(ci, cfg)
+		nrg, err := js.createGroupForStream(ci, cfg, nil)
 		if err != nil {
 			resp.Error = NewJSClusterNoPeersError(err)
 			s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
@@ -6270,6 +6530,11 @@ var (
 // blocking utility call to perform requests on the system account
 // returns (synchronized) v or error
 func sysRequest[T any](s *Server, subjFormat string, args ...any) (*T, error) {
+	return sysRequestMsg[T](s, nil, subjFormat, args...)
+}
+
+// same as sysRequest, but sends msg as the body of the request
+func sysRequestMsg[T any](s *Server, msg any, subjFormat string, args ...any) (*T, error) {
 	isubj := fmt.Sprintf(subjFormat, args...)
 
 	s.mu.Lock()
@@ -6293,7 +6558,7 @@ func sysRequest[T any](s *Server, subjFormat string, args ...any) (*T, error) {
 	}
 	s.mu.Unlock()
 
-	s.sendInternalMsgLocked(isubj, inbox, nil, nil)
+	s.sendInternalMsgLocked(isubj, inbox, nil, msg)
 
 	defer func() {
 		s.mu.Lock()
@@ -6465,7 +6730,7 @@ func (s *Server) jsClusteredStreamUpdateRequest(ci *ClientInfo, acc *Account, su
 					rg.Cluster = ci.Cluster
 				}
 			}
-			peers, err := cc.selectPeerGroup(newCfg.Replicas, rg.Cluster, newCfg, rg.Peers, 0, nil)
+			peers, err := cc.selectPeerGroup(newCfg.Replicas, rg.Cluster, newCfg, rg.Peers, 0, rg.Learners)
 			if err != nil {
 				resp.Error = NewJSClusterNoPeersError(err)
 				s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
@@ -6600,7 +6865,8 @@ func (s *Server) jsClusteredStreamUpdateRequest(ci *ClientInfo, acc *Account, su
 
 	} else if isMoveRequest {
 		if len(peerSet) == 0 {
-			nrg, err := js.createGroupForStream(ci, newCfg)
+			// Our learners can not become peers.
+			nrg, err := js.createGroupForStream(ci, newCfg, rg.Learners)
 			if err != nil {
 				resp.Error = NewJSClusterNoPeersError(err)
 				s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
@@ -6791,7 +7057,7 @@ func (s *Server) jsClusteredStreamRestoreRequest(
 	}
 
 	// Raft group selection and placement.
-	rg, err := js.createGroupForStream(ci, cfg)
+	rg, err := js.createGroupForStream(ci, cfg, nil)
 	if err != nil {
 		resp.Error = NewJSClusterNoPeersError(err)
 		s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
@@ -7289,6 +7555,7 @@ func decodeDeleteRange(buf []byte) (*DeleteRange, error) {
 }
 
 // createGroupForConsumer will create a new group from same peer set as the stream.
+// Consumers with less replicas than the stream can also be placed on its learners.
 func (cc *jetStreamCluster) createGroupForConsumer(cfg *ConsumerConfig, sa *streamAssignment) *raftGroup {
 	if len(sa.Group.Peers) == 0 || cfg.Replicas > len(sa.Group.Peers) {
 		return nil
@@ -7299,11 +7566,13 @@ func (cc *jetStreamCluster) createGroupForConsumer(cfg *ConsumerConfig, sa *stre
 	active := _ss[:0]
 
 	// Calculate all active peers.
+	isActive := func(peer string) bool {
+		sir, ok := cc.s.nodeToInfo.Load(peer)
+		return ok && sir != nil && !sir.(nodeInfo).offline
+	}
 	for _, peer := range peers {
-		if sir, ok := cc.s.nodeToInfo.Load(peer); ok && sir != nil {
-			if !sir.(nodeInfo).offline {
-				active = append(active, peer)
-			}
+		if isActive(peer) {
+			active = append(active, peer)
 		}
 	}
 	if quorum := cfg.Replicas/2 + 1; quorum > len(active) {
@@ -7313,6 +7582,14 @@ func (cc *jetStreamCluster) createGroupForConsumer(cfg *ConsumerConfig, sa *stre
 
 	// If we want less then our parent stream, select from active.
 	if cfg.Replicas > 0 && cfg.Replicas < len(peers) {
+		// Learners host the stream as well, unless consumers need peer parity with the stream.
+		if sa.Config.Retention == LimitsPolicy {
+			for _, peer := range sa.Group.Learners {
+				if isActive(peer) {
+					active = append(active, peer)
+				}
+			}
+		}
 		// Pedantic in case stream is say R5 and consumer is R3 and 3 or more offline, etc.
 		if len(active) < cfg.Replicas {
 			return nil
@@ -7793,14 +8070,37 @@ func encodeStreamMsgAllowCompress(subject, reply string, hdr, msg []byte, lseq u
 	elen := int(1 + 8 + 8 + total)
 	elen += (2 + 2 + 2 + 4 + 8) // Encoded lengths, 4bytes, flags are up to 8 bytes
 
+	buf := make([]byte, 1, elen)
+	buf[0] = byte(streamMsgOp)
+	buf = appendStreamMsg(buf, subject, reply, hdr, msg, lseq, ts, sourced)
+
+	// Check if we should compress.
+	if shouldCompress {
+		nbuf := make([]byte, s2.MaxEncodedLen(elen))
+		nbuf[0] = byte(compressedStreamMsgOp)
+		ebuf := s2.Encode(nbuf[1:], buf[1:])
+		// Only pay the cost of decode on the other side if we compressed.
+		// S2 will allow us to try without major penalty for non-compressable data.
+		if len(ebuf) < len(buf) {
+			buf = nbuf[:len(ebuf)+1]
+		}
+	}
+
+	return buf
+}
+
+// appendStreamMsg appends the encoded stream msg to buf, as decoded by decodeStreamMsg.
+func appendStreamMsg(buf []byte, subject, reply string, hdr, msg []byte, lseq uint64, ts int64, sourced bool) []byte {
+	slen := min(uint64(len(subject)), math.MaxUint16)
+	rlen := min(uint64(len(reply)), math.MaxUint16)
+	hlen := min(uint64(len(hdr)), math.MaxUint16)
+	mlen := min(uint64(len(msg)), math.MaxUint32)
+
 	var flags uint64
 	if sourced {
 		flags |= msgFlagFromSourceOrMirror
 	}
 
-	buf := make([]byte, 1, elen)
-	buf[0] = byte(streamMsgOp)
-
 	var le = binary.LittleEndian
 	buf = le.AppendUint64(buf, lseq)
 	buf = le.AppendUint64(buf, uint64(ts))
@@ -7813,20 +8113,18 @@ func encodeStreamMsgAllowCompress(subject, reply string, hdr, msg []byte, lseq u
 	buf = le.AppendUint32(buf, uint32(mlen))
 	buf = append(buf, msg[:mlen]...)
 	buf = binary.AppendUvarint(buf, flags)
+	return buf
+}
 
-	// Check if we should compress.
-	if shouldCompress {
-		nbuf := make([]byte, s2.MaxEncodedLen(elen))
-		nbuf[0] = byte(compressedStreamMsgOp)
-		ebuf := s2.Encode(nbuf[1:], buf[1:])
-		// Only pay the cost of decode on the other side if we compressed.
-		// S2 will allow us to try without major penalty for non-compressable data.
-		if len(ebuf) < len(buf) {
-			buf = nbuf[:len(ebuf)+1]
-		}
-	}
+// encodeStreamBatchMsg encodes a message of an atomic batch, to be decoded with decodeStreamMsg.
+// The batch it belongs to is taken from its headers.
+func encodeStreamBatchMsg(im *inMsg, lseq uint64, ts int64) []byte {
+	elen := 1 + 8 + 8 + len(im.subj) + len(im.rply) + len(im.hdr) + len(im.msg)
+	elen += (2 + 2 + 2 + 4 + 8) // Encoded lengths, 4bytes, flags are up to 8 bytes
 
-	return buf
+	buf := make([]byte, 1, elen)
+	buf[0] = byte(batchMsgOp)
+	return appendStreamMsg(buf, im.subj, im.rply, im.hdr, im.msg, lseq, ts, false)
 }
 
 // Determine if all peers in our set support the binary snapshot.
@@ -7916,7 +8214,8 @@ func (mset *stream) processClusteredInboundMsg(subject, reply string, hdr, msg [
 	s, js, jsa, st, r, tierName, outq, node := mset.srv, mset.js, mset.jsa, mset.cfg.Storage, mset.cfg.Replicas, mset.tier, mset.outq, mset.node
 	maxMsgSize, lseq := int(mset.cfg.MaxMsgSize), mset.lseq
 	interestPolicy, discard, maxMsgs, maxBytes := mset.cfg.Retention != LimitsPolicy, mset.cfg.Discard, mset.cfg.MaxMsgs, mset.cfg.MaxBytes
-	isLeader, isSealed, allowTTL := mset.isLeader(), mset.cfg.Sealed, mset.cfg.AllowMsgTTL
+	isLeader, isSealed, allowTTL, allowSchedules := mset.isLeader(), mset.cfg.Sealed, mset.cfg.AllowMsgTTL, mset.cfg.AllowMsgSchedules
+	allowCounter := mset.cfg.AllowMsgCounter
 	mset.mu.RUnlock()
 
 	// This should not happen but possible now that we allow scale up, and scale down where this could trigger.
@@ -7994,6 +8293,28 @@ func (mset *stream) processClusteredInboundMsg(subject, reply string, hdr, msg [
 		return err
 	}
 
+	// Counter increments need to be valid for the stream's counter status.
+	if apiErr, err := checkMsgCounter(hdr, allowCounter, sourced); apiErr != nil {
+		if canRespond {
+			var resp = &JSPubAckResponse{PubAck: &PubAck{Stream: name}, Error: apiErr}
+			b, _ := json.Marshal(resp)
+			outq.sendMsg(reply, b)
+		}
+		return err
+	}
+
+	// Payloads must validate against any schemas bound to the subject, otherwise every replica would reject them.
+	if !sourced {
+		if apiErr, err := mset.checkSchemas(subject, msg); apiErr != nil {
+			if canRespond {
+				var resp = &JSPubAckResponse{PubAck: &PubAck{Stream: name}, Error: apiErr}
+				b, _ := json.Marshal(resp)
+				outq.sendMsg(reply, b)
+			}
+			return err
+		}
+	}
+
 	// Some header checks can be checked pre proposal. Most can not.
 	var msgId string
 	if len(hdr) > 0 {
@@ -8061,6 +8382,25 @@ func (mset *stream) processClusteredInboundMsg(subject, reply string, hdr, msg [
 			}
 			return errMsgTTLDisabled
 		}
+
+		// Same for scheduled messages, which also need a valid schedule.
+		if !sourced && isScheduledMsg(hdr) {
+			var apiErr *ApiError
+			var err error
+			if !allowSchedules {
+				apiErr, err = NewJSMessageSchedulesDisabledError(), errMsgSchedulesDisabled
+			} else if _, err = getMessageSchedule(hdr, 0); err != nil {
+				apiErr = NewJSMessageSchedulesInvalidError()
+			}
+			if apiErr != nil {
+				if canRespond {
+					var resp = &JSPubAckResponse{PubAck: &PubAck{Stream: name}, Error: apiErr}
+					b, _ := json.Marshal(resp)
+					outq.sendMsg(reply, b)
+				}
+				return err
+			}
+		}
 	}
 
 	// Proceed with proposing this message.
@@ -8751,6 +9091,12 @@ func (mset *stream) processCatchupMsg(msg []byte) (uint64, error) {
 	// Update our lseq.
 	mset.setLastSeq(seq)
 
+	// Track held messages, in case we become leader.
+	if mset.cfg.AllowMsgSchedules && isScheduledMsg(hdr) {
+		due, _ := getMessageSchedule(hdr, ts)
+		mset.held.add(seq, subj, due)
+	}
+
 	// Check for MsgId and if we have one here make sure to update our internal map.
 	if len(hdr) > 0 {
 		if msgId := getMsgId(hdr); msgId != _EMPTY_ {
@@ -8838,6 +9184,7 @@ func (js *jetStream) clusterInfo(rg *raftGroup) *ClusterInfo {
 				Active:  lastSeen,
 				Lag:     rp.Lag,
 				Peer:    rp.ID,
+				Learner: rp.Learner || slices.Contains(rg.Learners, rp.ID),
 			}
 			// If node is found, complete/update the settings.
 			if sir, ok := s.nodeToInfo.Load(rp.ID); ok && sir != nil {
@@ -9377,4 +9724,7 @@ const (
 	clusterConsumerInfoT = "$JSC.CI.%s.%s.%s"
 	jsaUpdatesSubT       = "$JSC.ARU.%s.*"
 	jsaUpdatesPubT       = "$JSC.ARU.%s.%s"
+	// Used by the meta leader to balance stream and consumer leaders.
+	clusterLeaderReportT   = "$JSC.LR.%s"
+	clusterLeaderTransferT = "$JSC.LT.%s"
 )
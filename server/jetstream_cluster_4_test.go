@@ -5856,3 +5856,258 @@ func TestJetStreamClusterMessageTTLDisabled(t *testing.T) {
 		require_Equal(t, stream.getCLFS(), 0)
 	}
 }
+
+func TestJetStreamClusterLinearizableMsgGet(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:        "TEST",
+		Subjects:    []string{"foo"},
+		Replicas:    3,
+		AllowDirect: true,
+	})
+	require_NoError(t, err)
+	_, err = js.Publish("foo", []byte("OK"))
+	require_NoError(t, err)
+
+	sl := c.streamLeader(globalAccountName, "TEST")
+	mset, err := sl.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		if !mset.leaseValid() {
+			return errors.New("stream leader does not hold a lease yet")
+		}
+		return nil
+	})
+
+	req, err := json.Marshal(&JSApiMsgGetRequest{Seq: 1, Linearizable: true})
+	require_NoError(t, err)
+	msgGet := func() *JSApiMsgGetResponse {
+		t.Helper()
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiMsgGetT, "TEST"), req, time.Second)
+		require_NoError(t, err)
+		var resp JSApiMsgGetResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+	resp := msgGet()
+	require_True(t, resp.Error == nil)
+	require_Equal(t, string(resp.Message.Data), "OK")
+
+	// Direct gets are answered by any replica, but linearizable ones only by the leader.
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			mset.mu.RLock()
+			ok := mset.directSub != nil
+			mset.mu.RUnlock()
+			if !ok {
+				return fmt.Errorf("direct gets not enabled on %s", s)
+			}
+		}
+		return nil
+	})
+	// Control which replicas answer direct gets.
+	answerDirect := func(leader bool) {
+		t.Helper()
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			require_NoError(t, err)
+			mset.mu.Lock()
+			if (s == sl) == leader {
+				require_NoError(t, mset.subscribeToDirect())
+			} else {
+				mset.unsubscribeToDirect()
+			}
+			mset.mu.Unlock()
+		}
+	}
+	directGet := func() *nats.Msg {
+		t.Helper()
+		rmsg, err := nc.Request(fmt.Sprintf(JSDirectMsgGetT, "TEST"), req, time.Second)
+		require_NoError(t, err)
+		return rmsg
+	}
+	answerDirect(true)
+	rmsg := directGet()
+	require_Equal(t, string(rmsg.Data), "OK")
+	answerDirect(false)
+	rmsg = directGet()
+	require_Equal(t, rmsg.Header.Get("Status"), "409")
+	answerDirect(true)
+
+	// Without a lease the leader should reject these.
+	// Pretend one of the peers does not support pre-votes, which disables leases.
+	peer := c.randomNonStreamLeader(globalAccountName, "TEST").NodeName()
+	ni, ok := sl.nodeToInfo.Load(peer)
+	require_True(t, ok)
+	old := ni.(nodeInfo)
+	old.preVote = false
+	sl.nodeToInfo.Store(peer, old)
+
+	resp = msgGet()
+	require_True(t, resp.Error != nil)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamLeaderLeaseNotHeldErr))
+	rmsg = directGet()
+	require_Equal(t, rmsg.Header.Get("Status"), "409")
+
+	// Regular reads are still served.
+	req, err = json.Marshal(&JSApiMsgGetRequest{Seq: 1})
+	require_NoError(t, err)
+	resp = msgGet()
+	require_True(t, resp.Error == nil)
+	require_Equal(t, string(resp.Message.Data), "OK")
+}
+
+func TestJetStreamClusterStreamLearners(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R5S", 5)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	// Learners join the raft group, so the stream needs to be replicated.
+	cfg := &StreamConfig{
+		Name:        "TEST",
+		Subjects:    []string{"foo"},
+		Storage:     FileStorage,
+		Replicas:    1,
+		AllowDirect: true,
+		Placement:   &Placement{Learners: &LearnerPlacement{Replicas: 2}},
+	}
+	_, apiErr := addStreamWithError(t, nc, cfg)
+	require_True(t, apiErr != nil)
+	require_Equal(t, apiErr.ErrCode, uint16(JSStreamInvalidConfigF))
+
+	cfg.Replicas = 3
+	addStream(t, nc, cfg)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	ml := c.leader()
+	mjs := ml.getJetStream()
+	mjs.mu.RLock()
+	sa := mjs.streamAssignment(globalAccountName, "TEST")
+	peers, learners := copyStrings(sa.Group.Peers), copyStrings(sa.Group.Learners)
+	mjs.mu.RUnlock()
+	require_Len(t, len(peers), 3)
+	require_Len(t, len(learners), 2)
+	for _, l := range learners {
+		require_False(t, slices.Contains(peers, l))
+	}
+	serverForPeer := func(peer string) *Server {
+		for _, s := range c.servers {
+			if s.NodeName() == peer {
+				return s
+			}
+		}
+		t.Fatalf("No server for peer %q", peer)
+		return nil
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := js.Publish("foo", []byte("OK"))
+		require_NoError(t, err)
+	}
+
+	// Learners receive the stream, serve direct gets and do not count towards our quorum.
+	for _, l := range learners {
+		s := serverForPeer(l)
+		c.waitOnStreamCurrent(s, globalAccountName, "TEST")
+		mset, err := s.GlobalAccount().lookupStream("TEST")
+		require_NoError(t, err)
+		require_Equal(t, mset.state().Msgs, 10)
+		require_True(t, mset.raftNode().IsLearner())
+		checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+			mset.mu.RLock()
+			defer mset.mu.RUnlock()
+			if mset.directSub == nil {
+				return errors.New("direct gets not enabled")
+			}
+			return nil
+		})
+	}
+	for _, p := range peers {
+		mset, err := serverForPeer(p).GlobalAccount().lookupStream("TEST")
+		require_NoError(t, err)
+		rn := mset.raftNode()
+		require_False(t, rn.IsLearner())
+		require_Equal(t, rn.(*raft).quorumNeeded(), 2)
+	}
+
+	// Stream info reports our learners, which includes their lag.
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamInfoT, "TEST"), nil, time.Second)
+	require_NoError(t, err)
+	var resp JSApiStreamInfoResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	require_True(t, resp.Error == nil)
+	require_Len(t, len(resp.Cluster.Replicas), 4)
+	var nl int
+	for _, r := range resp.Cluster.Replicas {
+		if r.Learner {
+			nl++
+			require_True(t, slices.Contains(learners, r.Peer))
+		}
+	}
+	require_Equal(t, nl, 2)
+
+	// Learners never become the stream leader.
+	for i := 0; i < 3; i++ {
+		sl := c.streamLeader(globalAccountName, "TEST")
+		_, err := nc.Request(fmt.Sprintf(JSApiStreamLeaderStepDownT, "TEST"), nil, time.Second)
+		require_NoError(t, err)
+		checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+			if nl := c.streamLeader(globalAccountName, "TEST"); nl == nil || nl == sl {
+				return errors.New("no new stream leader yet")
+			}
+			return nil
+		})
+		require_True(t, slices.Contains(peers, c.streamLeader(globalAccountName, "TEST").NodeName()))
+	}
+
+	// Learners also host consumers with less replicas than the stream.
+	var onLearner string
+	for i := 0; i < 50 && onLearner == _EMPTY_; i++ {
+		name := fmt.Sprintf("C%d", i)
+		_, err := js.AddConsumer("TEST", &nats.ConsumerConfig{Durable: name, Replicas: 1, AckPolicy: nats.AckExplicitPolicy})
+		require_NoError(t, err)
+		mjs.mu.RLock()
+		ca := mjs.consumerAssignment(globalAccountName, "TEST", name)
+		require_Len(t, len(ca.Group.Peers), 1)
+		if slices.Contains(learners, ca.Group.Peers[0]) {
+			onLearner = name
+		}
+		mjs.mu.RUnlock()
+	}
+	require_NotEqual(t, onLearner, _EMPTY_)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", onLearner)
+	sub, err := js.PullSubscribe("foo", onLearner, nats.Bind("TEST", onLearner))
+	require_NoError(t, err)
+	msgs, err := sub.Fetch(10)
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 10)
+	for _, m := range msgs {
+		require_NoError(t, m.AckSync())
+	}
+
+	// Learners can not be changed after the stream was created.
+	cfg.Placement.Learners.Replicas = 1
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_Error(t, err)
+
+	// Writes do not need our learners.
+	for _, l := range learners {
+		serverForPeer(l).Shutdown()
+	}
+	nc.Close()
+	nc, js = jsClientConnect(t, serverForPeer(peers[0]))
+	defer nc.Close()
+	_, err = js.Publish("foo", []byte("OK"))
+	require_NoError(t, err)
+}
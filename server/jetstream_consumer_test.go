@@ -2714,3 +2714,2229 @@ func TestJetStreamConsumerMessageDeletedDuringRedelivery(t *testing.T) {
 		})
 	}
 }
+
+func TestJetStreamConsumerDeadLetter(t *testing.T) {
+	for _, retention := range []RetentionPolicy{LimitsPolicy, WorkQueuePolicy} {
+		t.Run(retention.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:      "TEST",
+				Storage:   FileStorage,
+				Subjects:  []string{"foo"},
+				Retention: retention,
+			})
+			require_NoError(t, err)
+			_, err = jsStreamCreate(t, nc, &StreamConfig{
+				Name:     "DLQ",
+				Storage:  FileStorage,
+				Subjects: []string{"dlq.>"},
+			})
+			require_NoError(t, err)
+
+			_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+				Durable:           "CONSUMER",
+				AckPolicy:         AckExplicit,
+				AckWait:           250 * time.Millisecond,
+				MaxDeliver:        2,
+				DeadLetterSubject: "dlq.TEST",
+			})
+			require_NoError(t, err)
+
+			m := nats.NewMsg("foo")
+			m.Header.Set("X-Order", "1")
+			m.Header.Set(JSExpectedLastSeq, "0")
+			m.Data = []byte("exhausted")
+			_, err = js.PublishMsg(m)
+			require_NoError(t, err)
+			_, err = js.Publish("foo", []byte("terminated"))
+			require_NoError(t, err)
+
+			sub, err := js.PullSubscribe("foo", "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+			require_NoError(t, err)
+			defer sub.Unsubscribe()
+
+			// Terminate the second message, and let the first one exhaust its deliveries.
+			for i := 0; i < 2; i++ {
+				msgs, err := sub.Fetch(2, nats.MaxWait(time.Second))
+				require_NoError(t, err)
+				for _, msg := range msgs {
+					if string(msg.Data) == "terminated" {
+						require_NoError(t, msg.Term())
+					}
+				}
+			}
+			_, err = sub.Fetch(1, nats.MaxWait(500*time.Millisecond))
+			require_Error(t, err, nats.ErrTimeout)
+
+			checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+				si, err := js.StreamInfo("DLQ")
+				if err != nil {
+					return err
+				}
+				if si.State.Msgs != 2 {
+					return fmt.Errorf("expected 2 dead-lettered messages, got %d", si.State.Msgs)
+				}
+				return nil
+			})
+
+			rsm, err := js.GetMsg("DLQ", 1)
+			require_NoError(t, err)
+			require_Equal(t, string(rsm.Data), "terminated")
+			require_Equal(t, rsm.Header.Get(JSStream), "TEST")
+			require_Equal(t, rsm.Header.Get(JSSubject), "foo")
+			require_Equal(t, rsm.Header.Get(JSSequence), "2")
+			require_Equal(t, rsm.Header.Get(JSDeadLetterConsumer), "CONSUMER")
+			require_Equal(t, rsm.Header.Get(JSDeadLetterDeliveries), "1")
+			require_Equal(t, rsm.Header.Get(JSDeadLetterReason), deadLetterTerminatedReason)
+
+			rsm, err = js.GetMsg("DLQ", 2)
+			require_NoError(t, err)
+			require_Equal(t, string(rsm.Data), "exhausted")
+			require_Equal(t, rsm.Header.Get("X-Order"), "1")
+			require_Equal(t, rsm.Header.Get(JSExpectedLastSeq), _EMPTY_)
+			require_Equal(t, rsm.Header.Get(JSSequence), "1")
+			require_Equal(t, rsm.Header.Get(JSDeadLetterDeliveries), "2")
+			require_Equal(t, rsm.Header.Get(JSDeadLetterReason), deadLetterMaxDeliveriesReason)
+
+			// Work queues remove the original once dead-lettered.
+			var expected uint64 = 2
+			if retention == WorkQueuePolicy {
+				expected = 0
+			}
+			checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+				si, err := js.StreamInfo("TEST")
+				if err != nil {
+					return err
+				}
+				if si.State.Msgs != expected {
+					return fmt.Errorf("expected %d messages, got %d", expected, si.State.Msgs)
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestJetStreamConsumerDeadLetterConfig(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, _ := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"foo.>"},
+	})
+	require_NoError(t, err)
+
+	for _, test := range []struct {
+		cfg *ConsumerConfig
+		err string
+	}{
+		{&ConsumerConfig{AckPolicy: AckExplicit, DeadLetterSubject: "dlq.*"}, "must be a valid literal subject"},
+		{&ConsumerConfig{AckPolicy: AckAll, DeadLetterSubject: "dlq"}, "requires explicit ack policy"},
+		{&ConsumerConfig{AckPolicy: AckExplicit, DeadLetterSubject: "foo.dlq"}, `can not be a subject of stream "TEST"`},
+	} {
+		_, err = jsConsumerCreate(t, nc, "TEST", test.cfg)
+		require_Error(t, err, NewJSConsumerDeadLetterInvalidError(errors.New(test.err)))
+	}
+}
+
+func TestJetStreamConsumerDeadLetterNotStored(t *testing.T) {
+	old := deadLetterTimeout
+	deadLetterTimeout = 250 * time.Millisecond
+	defer func() { deadLetterTimeout = old }()
+
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:      "TEST",
+		Storage:   FileStorage,
+		Subjects:  []string{"foo"},
+		Retention: WorkQueuePolicy,
+	})
+	require_NoError(t, err)
+	_, err = jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "DLQ",
+		Storage:  FileStorage,
+		Subjects: []string{"dlq"},
+		MaxMsgs:  1,
+		Discard:  DiscardNew,
+	})
+	require_NoError(t, err)
+	_, err = js.Publish("dlq", []byte("full"))
+	require_NoError(t, err)
+
+	asub, err := nc.SubscribeSync(JSAdvisoryConsumerDeadLetterFailedPre + ".TEST.*")
+	require_NoError(t, err)
+	defer asub.Unsubscribe()
+
+	// Neither the full dead-letter stream nor a subject without a stream store the message.
+	for _, dlsubj := range []string{"dlq", "nowhere"} {
+		_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+			Durable:           dlsubj,
+			AckPolicy:         AckExplicit,
+			DeadLetterSubject: dlsubj,
+		})
+		require_NoError(t, err)
+		_, err = js.Publish("foo", []byte("msg"))
+		require_NoError(t, err)
+
+		sub, err := js.PullSubscribe("foo", dlsubj, nats.Bind("TEST", dlsubj))
+		require_NoError(t, err)
+		msgs, err := sub.Fetch(1, nats.MaxWait(time.Second))
+		require_NoError(t, err)
+		require_NoError(t, msgs[0].Term())
+
+		m, err := asub.NextMsg(2 * time.Second)
+		require_NoError(t, err)
+		var adv JSConsumerDeadLetterFailedAdvisory
+		require_NoError(t, json.Unmarshal(m.Data, &adv))
+		require_Equal(t, adv.Consumer, dlsubj)
+		require_Equal(t, adv.Subject, dlsubj)
+		require_True(t, adv.Reason != _EMPTY_)
+
+		// The original is left in place.
+		si, err := js.StreamInfo("TEST")
+		require_NoError(t, err)
+		require_Equal(t, si.State.Msgs, 1)
+		require_NoError(t, sub.Unsubscribe())
+		require_NoError(t, js.DeleteConsumer("TEST", dlsubj))
+		require_NoError(t, js.PurgeStream("TEST"))
+	}
+}
+
+func TestJetStreamClusterConsumerDeadLetterWorkQueue(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	for _, cfg := range []*StreamConfig{
+		{Name: "TEST", Storage: FileStorage, Subjects: []string{"foo"}, Replicas: 3, Retention: WorkQueuePolicy},
+		{Name: "DLQ", Storage: FileStorage, Subjects: []string{"dlq"}, Replicas: 3},
+	} {
+		_, err := jsStreamCreate(t, nc, cfg)
+		require_NoError(t, err)
+	}
+	_, err := jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:           "CONSUMER",
+		AckPolicy:         AckExplicit,
+		AckWait:           250 * time.Millisecond,
+		MaxDeliver:        1,
+		DeadLetterSubject: "dlq",
+		Replicas:          3,
+	})
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "CONSUMER")
+
+	for i := 0; i < 5; i++ {
+		_, err = js.Publish("foo", []byte("msg"))
+		require_NoError(t, err)
+	}
+	sub, err := js.PullSubscribe("foo", "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(5, nats.MaxWait(time.Second))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 5)
+
+	// All replicas remove the dead-lettered messages.
+	checkFor(t, 5*time.Second, 200*time.Millisecond, func() error {
+		si, err := js.StreamInfo("DLQ")
+		if err != nil {
+			return err
+		}
+		if si.State.Msgs != 5 {
+			return fmt.Errorf("expected 5 dead-lettered messages, got %d", si.State.Msgs)
+		}
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if state := mset.state(); state.Msgs != 0 {
+				return fmt.Errorf("expected no messages on %s, got %d", s.Name(), state.Msgs)
+			}
+		}
+		return nil
+	})
+}
+
+// fetchData fetches up to batch messages and returns their data.
+func fetchData(t *testing.T, sub *nats.Subscription, batch int, wait time.Duration) []string {
+	t.Helper()
+	msgs, err := sub.Fetch(batch, nats.MaxWait(wait))
+	if err == nats.ErrTimeout {
+		return nil
+	}
+	require_NoError(t, err)
+	var data []string
+	for _, msg := range msgs {
+		data = append(data, string(msg.Data))
+	}
+	return data
+}
+
+func TestJetStreamConsumerOrderingKey(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		key  *ConsumerOrderingKey
+	}{
+		{"SubjectToken", &ConsumerOrderingKey{SubjectToken: 2}},
+		{"Header", &ConsumerOrderingKey{Header: "Order-Id"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:     "TEST",
+				Storage:  FileStorage,
+				Subjects: []string{"orders.*"},
+			})
+			require_NoError(t, err)
+			_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+				Durable:     "CONSUMER",
+				AckPolicy:   AckExplicit,
+				AckWait:     time.Minute,
+				OrderingKey: test.key,
+			})
+			require_NoError(t, err)
+
+			for _, data := range []string{"A1", "A2", "B1", "A3", "B2", "C1"} {
+				m := nats.NewMsg("orders." + data[:1])
+				m.Header.Set("Order-Id", data[:1])
+				m.Data = []byte(data)
+				_, err = js.PublishMsg(m)
+				require_NoError(t, err)
+			}
+			// Messages without a key are not ordered.
+			_, err = js.Publish("orders", []byte("X1"))
+			require_Error(t, err)
+			if test.key.Header != _EMPTY_ {
+				_, err = js.Publish("orders.X", []byte("X1"))
+				require_NoError(t, err)
+				_, err = js.Publish("orders.X", []byte("X2"))
+				require_NoError(t, err)
+			}
+
+			sub, err := js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+			require_NoError(t, err)
+			defer sub.Unsubscribe()
+
+			// Only the first message per key is delivered.
+			msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+			require_NoError(t, err)
+			var data []string
+			byData := make(map[string]*nats.Msg)
+			for _, msg := range msgs {
+				data = append(data, string(msg.Data))
+				byData[string(msg.Data)] = msg
+			}
+			expected := []string{"A1", "B1", "C1"}
+			if test.key.Header != _EMPTY_ {
+				expected = append(expected, "X1", "X2")
+			}
+			require_Equal(t, strings.Join(data, ","), strings.Join(expected, ","))
+			require_Len(t, len(fetchData(t, sub, 10, 100*time.Millisecond)), 0)
+
+			// Held messages are neither pending an ack nor delivered.
+			ci, err := js.ConsumerInfo("TEST", "CONSUMER")
+			require_NoError(t, err)
+			require_Equal(t, ci.NumAckPending, len(expected))
+			require_Equal(t, ci.NumPending, 3)
+			require_Equal(t, ci.Delivered.Consumer, uint64(len(expected)))
+
+			// Acking releases the next message for that key only.
+			require_NoError(t, byData["A1"].AckSync())
+			msgs, err = sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+			require_NoError(t, err)
+			require_Len(t, len(msgs), 1)
+			require_Equal(t, string(msgs[0].Data), "A2")
+			meta, err := msgs[0].Metadata()
+			require_NoError(t, err)
+			require_Equal(t, meta.NumDelivered, 1)
+
+			require_NoError(t, msgs[0].Term())
+			require_NoError(t, byData["B1"].AckSync())
+			require_Equal(t, strings.Join(fetchData(t, sub, 10, 250*time.Millisecond), ","), "A3,B2")
+		})
+	}
+}
+
+func TestJetStreamConsumerOrderingKeyRedelivery(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"orders.*"},
+	})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:     "CONSUMER",
+		AckPolicy:   AckExplicit,
+		AckWait:     250 * time.Millisecond,
+		OrderingKey: &ConsumerOrderingKey{SubjectToken: 2},
+	})
+	require_NoError(t, err)
+
+	for _, data := range []string{"A1", "A2", "A3"} {
+		_, err = js.Publish("orders."+data[:1], []byte(data))
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// Held messages don't expire, only the outstanding one is redelivered.
+	require_Equal(t, strings.Join(fetchData(t, sub, 10, 100*time.Millisecond), ","), "A1")
+	time.Sleep(300 * time.Millisecond)
+	msgs, err := sub.Fetch(10, nats.MaxWait(500*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 1)
+	require_Equal(t, string(msgs[0].Data), "A1")
+	require_NoError(t, msgs[0].AckSync())
+	require_Equal(t, strings.Join(fetchData(t, sub, 10, 100*time.Millisecond), ","), "A2")
+}
+
+func TestJetStreamConsumerOrderingKeyMaxAckPending(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"orders.*"},
+	})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:       "CONSUMER",
+		AckPolicy:     AckExplicit,
+		AckWait:       time.Minute,
+		MaxAckPending: 2,
+		OrderingKey:   &ConsumerOrderingKey{SubjectToken: 2},
+	})
+	require_NoError(t, err)
+
+	// A hot key does not hold up the other keys.
+	for _, data := range []string{"A1", "A2", "A3", "A4", "A5", "B1", "C1"} {
+		_, err = js.Publish("orders."+data[:1], []byte(data))
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 2)
+	require_Equal(t, string(msgs[0].Data), "A1")
+	require_Equal(t, string(msgs[1].Data), "B1")
+
+	// Released messages count against max ack pending once delivered.
+	require_NoError(t, msgs[0].AckSync())
+	require_Equal(t, strings.Join(fetchData(t, sub, 10, 250*time.Millisecond), ","), "A2")
+	require_NoError(t, msgs[1].AckSync())
+	require_Equal(t, strings.Join(fetchData(t, sub, 10, 250*time.Millisecond), ","), "C1")
+
+	ci, err := js.ConsumerInfo("TEST", "CONSUMER")
+	require_NoError(t, err)
+	require_Equal(t, ci.NumAckPending, 2)
+	require_Equal(t, ci.NumPending, 3)
+	require_Equal(t, ci.Delivered.Consumer, 4)
+}
+
+func TestJetStreamClusterConsumerOrderingKeyLeaderChange(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:      "TEST",
+		Storage:   FileStorage,
+		Subjects:  []string{"orders.*"},
+		Retention: WorkQueuePolicy,
+		Replicas:  3,
+	})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:     "CONSUMER",
+		AckPolicy:   AckExplicit,
+		AckWait:     time.Minute,
+		OrderingKey: &ConsumerOrderingKey{SubjectToken: 2},
+		Replicas:    3,
+	})
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "CONSUMER")
+
+	for _, data := range []string{"A1", "A2", "B1", "A3"} {
+		_, err = js.Publish("orders."+data[:1], []byte(data))
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 2)
+	require_Equal(t, string(msgs[0].Data), "A1")
+
+	// The new leader rebuilds which messages are held back from the replicated pending state.
+	stepDown := func() {
+		t.Helper()
+		cl := c.consumerLeader(globalAccountName, "TEST", "CONSUMER")
+		mset, err := cl.GlobalAccount().lookupStream("TEST")
+		require_NoError(t, err)
+		o := mset.lookupConsumer("CONSUMER")
+		require_NotNil(t, o)
+		require_NoError(t, o.raftNode().StepDown())
+		c.waitOnConsumerLeader(globalAccountName, "TEST", "CONSUMER")
+		require_NotEqual(t, cl, c.consumerLeader(globalAccountName, "TEST", "CONSUMER"))
+	}
+	stepDown()
+
+	ci, err := js.ConsumerInfo("TEST", "CONSUMER")
+	require_NoError(t, err)
+	require_Equal(t, ci.NumAckPending, 2)
+	require_Equal(t, ci.NumPending, 2)
+	require_Len(t, len(fetchData(t, sub, 10, 250*time.Millisecond)), 0)
+
+	// Released messages are not lost when the leader changes before they are delivered.
+	require_NoError(t, msgs[0].AckSync())
+	stepDown()
+	a2, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(a2), 1)
+	require_Equal(t, string(a2[0].Data), "A2")
+
+	// Held messages are not removed from the work queue when messages after them are acked.
+	require_NoError(t, msgs[1].AckSync())
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if state := mset.state(); state.Msgs != 2 {
+				return fmt.Errorf("expected 2 messages, got %d", state.Msgs)
+			}
+		}
+		return nil
+	})
+	stepDown()
+	require_NoError(t, a2[0].AckSync())
+	require_Equal(t, strings.Join(fetchData(t, sub, 10, 250*time.Millisecond), ","), "A3")
+}
+
+func TestJetStreamConsumerOrderingKeyConfig(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, _ := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"orders.*"},
+	})
+	require_NoError(t, err)
+
+	for _, test := range []struct {
+		cfg *ConsumerConfig
+		err string
+	}{
+		{&ConsumerConfig{AckPolicy: AckExplicit, OrderingKey: &ConsumerOrderingKey{}}, "requires either a subject token or a header"},
+		{&ConsumerConfig{AckPolicy: AckExplicit, OrderingKey: &ConsumerOrderingKey{SubjectToken: 1, Header: "a"}}, "requires either a subject token or a header"},
+		{&ConsumerConfig{AckPolicy: AckExplicit, OrderingKey: &ConsumerOrderingKey{SubjectToken: -1}}, "requires either a subject token or a header"},
+		{&ConsumerConfig{AckPolicy: AckNone, OrderingKey: &ConsumerOrderingKey{SubjectToken: 2}}, "requires explicit ack policy"},
+		{&ConsumerConfig{AckPolicy: AckExplicit, DeliverSubject: "deliver", OrderingKey: &ConsumerOrderingKey{SubjectToken: 2}}, "requires a pull consumer"},
+	} {
+		_, err = jsConsumerCreate(t, nc, "TEST", test.cfg)
+		require_Error(t, err, NewJSConsumerOrderingKeyInvalidError(errors.New(test.err)))
+	}
+
+	cfg := &ConsumerConfig{Durable: "CONSUMER", AckPolicy: AckExplicit, OrderingKey: &ConsumerOrderingKey{SubjectToken: 2}}
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_NoError(t, err)
+	cfg.OrderingKey = &ConsumerOrderingKey{Header: "Order-Id"}
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_Error(t, err, errors.New("ordering key can not be updated"))
+}
+
+func jsConsumerReset(t *testing.T, nc *nats.Conn, stream, consumer string, req *JSApiConsumerResetRequest) (*ConsumerInfo, error) {
+	t.Helper()
+	j, err := json.Marshal(req)
+	require_NoError(t, err)
+	msg, err := nc.Request(fmt.Sprintf(JSApiConsumerResetT, stream, consumer), j, 2*time.Second)
+	require_NoError(t, err)
+	var resp JSApiConsumerResetResponse
+	require_NoError(t, json.Unmarshal(msg.Data, &resp))
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	require_NotNil(t, resp.ConsumerInfo)
+	return resp.ConsumerInfo, nil
+}
+
+func TestJetStreamConsumerReset(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:   "CONSUMER",
+		AckPolicy: AckExplicit,
+		Metadata:  map[string]string{"owner": "test"},
+	})
+	require_NoError(t, err)
+
+	sub, err := js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+
+	msgs, err := sub.Fetch(5)
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 5)
+	for _, msg := range msgs[:3] {
+		require_NoError(t, msg.AckSync())
+	}
+
+	asub, err := nc.SubscribeSync(JSAdvisoryConsumerResetPre + ".TEST.CONSUMER")
+	require_NoError(t, err)
+	defer asub.Unsubscribe()
+
+	// Move back, dropping everything that was pending.
+	ci, err := jsConsumerReset(t, nc, "TEST", "CONSUMER", &JSApiConsumerResetRequest{Sequence: 2})
+	require_NoError(t, err)
+	require_Equal(t, ci.Delivered.Stream, 1)
+	require_Equal(t, ci.AckFloor.Stream, 1)
+	require_Equal(t, ci.Delivered.Consumer, 5)
+	require_Equal(t, ci.AckFloor.Consumer, 5)
+	require_Equal(t, ci.NumAckPending, 0)
+	require_Equal(t, ci.NumRedelivered, 0)
+	require_Equal(t, ci.NumPending, 9)
+	require_Equal(t, ci.Config.Metadata["owner"], "test")
+
+	amsg, err := asub.NextMsg(time.Second)
+	require_NoError(t, err)
+	var adv JSConsumerResetAdvisory
+	require_NoError(t, json.Unmarshal(amsg.Data, &adv))
+	require_Equal(t, adv.Type, JSConsumerResetAdvisoryType)
+	require_Equal(t, adv.Consumer, "CONSUMER")
+	require_Equal(t, adv.Sequence, 2)
+
+	// Acks for messages delivered before the reset are ignored.
+	require_NoError(t, msgs[4].AckSync())
+	nci, err := js.ConsumerInfo("TEST", "CONSUMER")
+	require_NoError(t, err)
+	require_Equal(t, nci.AckFloor.Stream, 1)
+
+	msgs, err = sub.Fetch(1)
+	require_NoError(t, err)
+	require_Equal(t, string(msgs[0].Data), "2")
+	meta, err := msgs[0].Metadata()
+	require_NoError(t, err)
+	require_Equal(t, meta.NumDelivered, 1)
+	require_Equal(t, meta.Sequence.Consumer, 6)
+	require_NoError(t, msgs[0].AckSync())
+
+	// Move forward by time.
+	sm, err := js.GetMsg("TEST", 8)
+	require_NoError(t, err)
+	_, err = jsConsumerReset(t, nc, "TEST", "CONSUMER", &JSApiConsumerResetRequest{StartTime: &sm.Time})
+	require_NoError(t, err)
+
+	// The new position survives a restart.
+	sd := s.JetStreamConfig().StoreDir
+	nc.Close()
+	s.Shutdown()
+	s = RunJetStreamServerOnPort(-1, sd)
+	defer s.Shutdown()
+
+	nc, js = jsClientConnect(t, s)
+	defer nc.Close()
+
+	nci, err = js.ConsumerInfo("TEST", "CONSUMER")
+	require_NoError(t, err)
+	require_Equal(t, nci.Delivered.Stream, 7)
+	require_Equal(t, nci.AckFloor.Stream, 7)
+	require_Equal(t, nci.NumPending, 3)
+
+	sub, err = js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err = sub.Fetch(1)
+	require_NoError(t, err)
+	require_Equal(t, string(msgs[0].Data), "8")
+
+	// Moving past the end only skips the messages already stored.
+	ci, err = jsConsumerReset(t, nc, "TEST", "CONSUMER", &JSApiConsumerResetRequest{Sequence: 100})
+	require_NoError(t, err)
+	require_Equal(t, ci.Delivered.Stream, 10)
+	require_Equal(t, ci.NumPending, 0)
+	_, err = js.Publish("foo", []byte("11"))
+	require_NoError(t, err)
+	msgs, err = sub.Fetch(1)
+	require_NoError(t, err)
+	require_Equal(t, string(msgs[0].Data), "11")
+}
+
+func TestJetStreamConsumerResetInterestPolicy(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Retention: nats.InterestPolicy})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "CONSUMER", AckPolicy: AckExplicit})
+	require_NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+
+	// Skipping ahead releases the interest in the skipped messages.
+	_, err = jsConsumerReset(t, nc, "TEST", "CONSUMER", &JSApiConsumerResetRequest{Sequence: 6})
+	require_NoError(t, err)
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 5)
+	require_Equal(t, si.State.FirstSeq, 6)
+}
+
+func TestJetStreamConsumerResetErrors(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "CONSUMER", AckPolicy: AckExplicit})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Name: "EPHEMERAL", AckPolicy: AckExplicit})
+	require_NoError(t, err)
+
+	now := time.Now()
+	for _, test := range []struct {
+		name     string
+		stream   string
+		consumer string
+		req      *JSApiConsumerResetRequest
+		err      error
+	}{
+		{"empty", "TEST", "CONSUMER", &JSApiConsumerResetRequest{},
+			NewJSConsumerResetInvalidError(errors.New("stream sequence or start time required"))},
+		{"both", "TEST", "CONSUMER", &JSApiConsumerResetRequest{Sequence: 1, StartTime: &now},
+			NewJSConsumerResetInvalidError(errors.New("stream sequence and start time are mutually exclusive"))},
+		{"missing stream", "NOT_EXIST", "CONSUMER", &JSApiConsumerResetRequest{Sequence: 1}, NewJSStreamNotFoundError()},
+		{"missing consumer", "TEST", "NOT_EXIST", &JSApiConsumerResetRequest{Sequence: 1}, NewJSConsumerNotFoundError()},
+		{"ephemeral", "TEST", "EPHEMERAL", &JSApiConsumerResetRequest{Sequence: 1}, NewJSConsumerResetNotDurableError()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := jsConsumerReset(t, nc, test.stream, test.consumer, test.req)
+			require_Error(t, err, test.err)
+		})
+	}
+}
+
+func TestJetStreamClusterConsumerReset(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "CONSUMER", AckPolicy: AckExplicit, Replicas: 3})
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "CONSUMER")
+
+	sub, err := js.PullSubscribe(_EMPTY_, "CONSUMER", nats.Bind("TEST", "CONSUMER"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(8)
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 8)
+	require_NoError(t, msgs[0].AckSync())
+
+	// The response is sent once the reset is applied.
+	ci, err := jsConsumerReset(t, nc, "TEST", "CONSUMER", &JSApiConsumerResetRequest{Sequence: 3})
+	require_NoError(t, err)
+	require_Equal(t, ci.Delivered.Stream, 2)
+	require_Equal(t, ci.NumAckPending, 0)
+
+	// All replicas move to the new position.
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			o := mset.lookupConsumer("CONSUMER")
+			if o == nil {
+				return errors.New("consumer not found")
+			}
+			state, err := o.store.State()
+			if err != nil {
+				return err
+			}
+			if state.Delivered.Stream != 2 || state.AckFloor.Stream != 2 || len(state.Pending) != 0 {
+				return fmt.Errorf("unexpected state on %s: %+v", s, state)
+			}
+		}
+		return nil
+	})
+
+	// A new leader continues from the reset position.
+	cl := c.consumerLeader(globalAccountName, "TEST", "CONSUMER")
+	mset, err := cl.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	require_NoError(t, mset.lookupConsumer("CONSUMER").raftNode().StepDown())
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "CONSUMER")
+
+	nci, err := js.ConsumerInfo("TEST", "CONSUMER")
+	require_NoError(t, err)
+	require_Equal(t, nci.NumAckPending, 0)
+	require_Equal(t, nci.NumPending, 8)
+
+	msgs, err = sub.Fetch(1)
+	require_NoError(t, err)
+	require_Equal(t, string(msgs[0].Data), "3")
+}
+
+func TestJetStreamConsumerPartitioned(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}})
+	require_NoError(t, err)
+	cfg := &ConsumerConfig{
+		Durable:            "C",
+		FilterSubject:      "orders.*",
+		AckPolicy:          AckExplicit,
+		PriorityGroups:     []string{"A"},
+		PriorityPolicy:     PriorityPartitioned,
+		PinnedTTL:          time.Second,
+		Partitions:         4,
+		PartitionWildcards: []int{1},
+	}
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_NoError(t, err)
+
+	tr, err := NewSubjectTransform("orders.*", "{{partition(4,1)}}")
+	require_NoError(t, err)
+	partition := func(subj string) int {
+		p, err := strconv.Atoi(tr.TransformSubject(subj))
+		require_NoError(t, err)
+		return p
+	}
+
+	publish := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			_, err := js.Publish(fmt.Sprintf("orders.%d", i), nil)
+			require_NoError(t, err)
+		}
+	}
+
+	// Pulls for a member until the request expires, acking everything received.
+	fetch := func(member string) map[int][]uint64 {
+		t.Helper()
+		sub, err := nc.SubscribeSync(nats.NewInbox())
+		require_NoError(t, err)
+		defer sub.Unsubscribe()
+		req, err := json.Marshal(&JSApiConsumerGetNextRequest{
+			Batch:         100,
+			Expires:       250 * time.Millisecond,
+			PriorityGroup: PriorityGroup{Group: "A", Id: member},
+		})
+		require_NoError(t, err)
+		require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), sub.Subject, req))
+		received := make(map[int][]uint64)
+		for {
+			msg, err := sub.NextMsg(time.Second)
+			require_NoError(t, err)
+			if len(msg.Data) == 0 && msg.Header.Get("Status") != _EMPTY_ {
+				return received
+			}
+			meta, err := msg.Metadata()
+			require_NoError(t, err)
+			p := partition(msg.Subject)
+			received[p] = append(received[p], meta.Sequence.Stream)
+			require_NoError(t, msg.AckSync())
+		}
+	}
+	count := func(received map[int][]uint64) (n int) {
+		for p, seqs := range received {
+			require_True(t, slices.IsSorted(seqs))
+			n += len(received[p])
+		}
+		return n
+	}
+
+	// A single member receives all partitions.
+	publish(20)
+	require_Equal(t, count(fetch("m1")), 20)
+
+	// A second member takes over half of the partitions. Messages for partitions of the
+	// first member are parked until it pulls again.
+	publish(20)
+	received := fetch("m2")
+	for p := range received {
+		require_True(t, p == 1 || p == 3)
+	}
+	n := count(received)
+	received = fetch("m1")
+	for p := range received {
+		require_True(t, p == 0 || p == 2)
+	}
+	require_Equal(t, n+count(received), 20)
+
+	ci, err := js.ConsumerInfo("TEST", "C")
+	require_NoError(t, err)
+	require_Equal(t, ci.NumAckPending, 0)
+	require_Equal(t, ci.NumPending, 0)
+
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	o := mset.lookupConsumer("C")
+	o.mu.RLock()
+	assignments := o.partitionAssignments()
+	o.mu.RUnlock()
+	require_Len(t, len(assignments), 2)
+	require_True(t, slices.Equal(assignments["m1"], []int{0, 2}))
+	require_True(t, slices.Equal(assignments["m2"], []int{1, 3}))
+
+	// Once the second member stops pulling, its partitions move back to the first one.
+	checkFor(t, 3*time.Second, 100*time.Millisecond, func() error {
+		fetch("m1")
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		if members := len(o.partitionAssignments()); members != 1 {
+			return fmt.Errorf("expected 1 member, got %d", members)
+		}
+		return nil
+	})
+	publish(20)
+	require_Equal(t, count(fetch("m1")), 20)
+
+	// Requests need to identify the member.
+	sub, err := nc.SubscribeSync(nats.NewInbox())
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	req, err := json.Marshal(&JSApiConsumerGetNextRequest{Batch: 1, PriorityGroup: PriorityGroup{Group: "A"}})
+	require_NoError(t, err)
+	require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), sub.Subject, req))
+	msg, err := sub.NextMsg(time.Second)
+	require_NoError(t, err)
+	require_Equal(t, msg.Header.Get("Status"), "400")
+	require_Equal(t, msg.Header.Get("Description"), "Bad Request - Partition Member Id missing")
+}
+
+func TestJetStreamConsumerPartitionedHandover(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:            "C",
+		FilterSubject:      "orders.*",
+		AckPolicy:          AckExplicit,
+		AckWait:            time.Minute,
+		PriorityGroups:     []string{"A"},
+		PriorityPolicy:     PriorityPartitioned,
+		Partitions:         2,
+		PartitionWildcards: []int{1},
+	})
+	require_NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		_, err := js.Publish(fmt.Sprintf("orders.%d", i), nil)
+		require_NoError(t, err)
+	}
+
+	// Pulls for a member until the request expires, without acking.
+	fetch := func(member string) []*nats.Msg {
+		t.Helper()
+		sub, err := nc.SubscribeSync(nats.NewInbox())
+		require_NoError(t, err)
+		defer sub.Unsubscribe()
+		req, err := json.Marshal(&JSApiConsumerGetNextRequest{
+			Batch:         100,
+			Expires:       250 * time.Millisecond,
+			PriorityGroup: PriorityGroup{Group: "A", Id: member},
+		})
+		require_NoError(t, err)
+		require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), sub.Subject, req))
+		var msgs []*nats.Msg
+		for {
+			msg, err := sub.NextMsg(time.Second)
+			require_NoError(t, err)
+			if len(msg.Data) == 0 && msg.Header.Get("Status") != _EMPTY_ {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+
+	// The first member gets all messages, but does not ack them yet.
+	first := fetch("m1")
+	require_Len(t, len(first), 20)
+
+	// The second member joins, but the partition moving to it is only handed
+	// over once the first member is done with its messages.
+	_, err = js.Publish("orders.1", nil)
+	require_NoError(t, err)
+	_, err = js.Publish("orders.2", nil)
+	require_NoError(t, err)
+	require_Len(t, len(fetch("m2")), 0)
+
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	o := mset.lookupConsumer("C")
+	o.mu.RLock()
+	require_Len(t, len(o.phandover), 1)
+	o.mu.RUnlock()
+
+	for _, msg := range first {
+		require_NoError(t, msg.AckSync())
+	}
+	require_Len(t, len(fetch("m2")), 1)
+	require_Len(t, len(fetch("m1")), 1)
+
+	o.mu.RLock()
+	require_Len(t, len(o.phandover), 0)
+	assignments := o.partitionAssignments()
+	o.mu.RUnlock()
+	require_Len(t, len(assignments), 2)
+}
+
+func TestJetStreamConsumerPartitionedParkingRebuilt(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:            "C",
+		FilterSubject:      "orders.*",
+		AckPolicy:          AckExplicit,
+		AckWait:            250 * time.Millisecond,
+		PriorityGroups:     []string{"A"},
+		PriorityPolicy:     PriorityPartitioned,
+		Partitions:         2,
+		PartitionWildcards: []int{1},
+	})
+	require_NoError(t, err)
+
+	sub, err := nc.SubscribeSync(nats.NewInbox())
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	pull := func(member string, expires time.Duration) {
+		t.Helper()
+		req, err := json.Marshal(&JSApiConsumerGetNextRequest{
+			Batch:         10,
+			Expires:       expires,
+			PriorityGroup: PriorityGroup{Group: "A", Id: member},
+		})
+		require_NoError(t, err)
+		require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), sub.Subject, req))
+	}
+	// Drains the received messages and the final status, returning how many there were.
+	receive := func() (n int) {
+		t.Helper()
+		for {
+			msg, err := sub.NextMsg(2 * time.Second)
+			require_NoError(t, err)
+			if len(msg.Data) == 0 && msg.Header.Get("Status") != _EMPTY_ {
+				return n
+			}
+			meta, err := msg.Metadata()
+			require_NoError(t, err)
+			require_Equal(t, meta.NumDelivered, 1)
+			require_NoError(t, msg.AckSync())
+			n++
+		}
+	}
+
+	// Both members join, but only the second one is pulling, so the messages
+	// of the first member's partition are parked.
+	pull("m1", 100*time.Millisecond)
+	require_Equal(t, receive(), 0)
+	pull("m2", 500*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		_, err := js.Publish(fmt.Sprintf("orders.%d", i), nil)
+		require_NoError(t, err)
+	}
+	parked := 10 - receive()
+	require_True(t, parked > 0)
+
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	o := mset.lookupConsumer("C")
+	o.mu.RLock()
+	require_Len(t, len(o.pparked), parked)
+	o.mu.RUnlock()
+
+	// Parked messages survive a leader change, and are not redelivered.
+	o.setLeader(false)
+	o.setLeader(true)
+	o.mu.RLock()
+	require_Len(t, len(o.pparked), parked)
+	o.mu.RUnlock()
+
+	time.Sleep(500 * time.Millisecond)
+	pull("m1", 500*time.Millisecond)
+	require_Equal(t, receive(), parked)
+}
+
+func TestJetStreamConsumerPartitionedConfig(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}})
+	require_NoError(t, err)
+
+	valid := func() *ConsumerConfig {
+		return &ConsumerConfig{
+			Durable:            "C",
+			FilterSubject:      "orders.*",
+			AckPolicy:          AckExplicit,
+			PriorityGroups:     []string{"A"},
+			PriorityPolicy:     PriorityPartitioned,
+			Partitions:         4,
+			PartitionWildcards: []int{1},
+		}
+	}
+	for _, test := range []struct {
+		name   string
+		modify func(cfg *ConsumerConfig)
+		err    string
+	}{
+		{"no policy", func(cfg *ConsumerConfig) { cfg.PriorityPolicy = PriorityNone }, "requires the partitioned priority policy"},
+		{"no partitions", func(cfg *ConsumerConfig) { cfg.Partitions = 0 }, "number of partitions must be positive"},
+		{"ack none", func(cfg *ConsumerConfig) { cfg.AckPolicy = AckNone }, "requires explicit ack policy"},
+		{"ordering key", func(cfg *ConsumerConfig) { cfg.OrderingKey = &ConsumerOrderingKey{SubjectToken: 2} }, "can not be combined with an ordering key"},
+		{"no wildcards", func(cfg *ConsumerConfig) { cfg.PartitionWildcards = nil }, "requires a filter subject and the wildcards to partition by"},
+		{"bad wildcard", func(cfg *ConsumerConfig) { cfg.PartitionWildcards = []int{2} }, "invalid mapping destination: wildcard index out of range in {{partition(4,2)}}: [2]"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := valid()
+			test.modify(cfg)
+			_, err := jsConsumerCreate(t, nc, "TEST", cfg)
+			require_Error(t, err, NewJSConsumerPartitionsInvalidError(errors.New(test.err)))
+		})
+	}
+
+	cfg := valid()
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_NoError(t, err)
+	cfg.Partitions = 8
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_Error(t, err, errors.New("partitions can not be updated"))
+}
+
+func TestJetStreamClusterConsumerPartitionedLeaderChange(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}, Replicas: 3})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:            "C",
+		FilterSubject:      "orders.*",
+		AckPolicy:          AckExplicit,
+		AckWait:            500 * time.Millisecond,
+		PriorityGroups:     []string{"A"},
+		PriorityPolicy:     PriorityPartitioned,
+		Partitions:         2,
+		PartitionWildcards: []int{1},
+		Replicas:           3,
+	})
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "C")
+
+	fetch := func(member string) int {
+		t.Helper()
+		sub, err := nc.SubscribeSync(nats.NewInbox())
+		require_NoError(t, err)
+		defer sub.Unsubscribe()
+		req, err := json.Marshal(&JSApiConsumerGetNextRequest{
+			Batch:         100,
+			Expires:       250 * time.Millisecond,
+			PriorityGroup: PriorityGroup{Group: "A", Id: member},
+		})
+		require_NoError(t, err)
+		require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), sub.Subject, req))
+		var n int
+		for {
+			msg, err := sub.NextMsg(time.Second)
+			require_NoError(t, err)
+			if len(msg.Data) == 0 && msg.Header.Get("Status") != _EMPTY_ {
+				return n
+			}
+			require_NoError(t, msg.AckSync())
+			n++
+		}
+	}
+
+	// Register both members, then park messages for the partition of the one not pulling.
+	fetch("m1")
+	fetch("m2")
+	for i := 0; i < 20; i++ {
+		_, err = js.Publish(fmt.Sprintf("orders.%d", i), nil)
+		require_NoError(t, err)
+	}
+	received := fetch("m1")
+	require_True(t, received > 0 && received < 20)
+
+	// Parked messages are pending in the replicated state, so a new leader redelivers them.
+	cl := c.consumerLeader(globalAccountName, "TEST", "C")
+	mset, err := cl.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	require_NoError(t, mset.lookupConsumer("C").raftNode().StepDown())
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "C")
+
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		received += fetch("m1") + fetch("m2")
+		if received != 20 {
+			return fmt.Errorf("expected 20 messages, got %d", received)
+		}
+		return nil
+	})
+}
+
+func TestJetStreamConsumerHeaderFilters(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{Name: "TEST", Subjects: []string{"foo.*"}, Storage: storage})
+			require_NoError(t, err)
+
+			publish := func(subj, tenant, typ, data string) {
+				t.Helper()
+				m := nats.NewMsg(subj)
+				if tenant != _EMPTY_ {
+					m.Header.Set("Tenant", tenant)
+				}
+				if typ != _EMPTY_ {
+					m.Header.Set("Type", typ)
+				}
+				m.Data = []byte(data)
+				_, err := js.PublishMsg(m)
+				require_NoError(t, err)
+			}
+			publish("foo.1", "a", "x", "1")
+			publish("foo.1", "c", "x", "2")
+			publish("foo.2", "b", "y", "3")
+			publish("foo.2", "a", _EMPTY_, "4")
+			publish("foo.1", _EMPTY_, "x", "5")
+			publish("foo.2", "b", "z", "6")
+
+			cfg := &ConsumerConfig{
+				Durable:   "C",
+				AckPolicy: AckExplicit,
+				HeaderFilters: []HeaderFilter{
+					{Header: "Tenant", Values: []string{"a", "b"}},
+					{Header: "Type"},
+				},
+			}
+			ci, err := jsConsumerCreate(t, nc, "TEST", cfg)
+			require_NoError(t, err)
+			require_Equal(t, ci.NumPending, 3)
+
+			sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+			require_NoError(t, err)
+			defer sub.Unsubscribe()
+
+			require_Equal(t, strings.Join(fetchData(t, sub, 10, 250*time.Millisecond), ","), "1,3,6")
+
+			// Only matching messages count towards pending.
+			publish("foo.1", "a", "x", "7")
+			publish("foo.1", "d", "x", "8")
+			nci, err := js.ConsumerInfo("TEST", "C")
+			require_NoError(t, err)
+			require_Equal(t, nci.NumPending, 1)
+
+			// Deleting a non-matching message doesn't change pending.
+			require_NoError(t, js.DeleteMsg("TEST", 8))
+			nci, err = js.ConsumerInfo("TEST", "C")
+			require_NoError(t, err)
+			require_Equal(t, nci.NumPending, 1)
+			require_NoError(t, js.DeleteMsg("TEST", 7))
+			nci, err = js.ConsumerInfo("TEST", "C")
+			require_NoError(t, err)
+			require_Equal(t, nci.NumPending, 0)
+
+			// Pending is kept track of as messages come and go, instead of being recalculated.
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			require_NoError(t, err)
+			require_Equal(t, mset.hfcons.Load(), 1)
+			o := mset.lookupConsumer("C")
+			require_NotNil(t, o)
+			publish("foo.2", "b", "x", "extra")
+			checkFor(t, time.Second, 10*time.Millisecond, func() error {
+				o.mu.RLock()
+				defer o.mu.RUnlock()
+				if o.npcstale || o.npc != 1 || !o.hfpend.Exists(9) {
+					return fmt.Errorf("unexpected pending %d %v", o.npc, o.npcstale)
+				}
+				return nil
+			})
+			require_NoError(t, js.DeleteMsg("TEST", 9))
+			o.mu.RLock()
+			require_False(t, o.npcstale)
+			require_Equal(t, o.npc, 0)
+			o.mu.RUnlock()
+
+			// The filters can be updated.
+			publish("foo.2", "c", _EMPTY_, "9")
+			publish("foo.2", "a", _EMPTY_, "10")
+			cfg.HeaderFilters = []HeaderFilter{{Header: "Tenant", Values: []string{"c"}}}
+			_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+			require_NoError(t, err)
+			nci, err = js.ConsumerInfo("TEST", "C")
+			require_NoError(t, err)
+			require_Equal(t, nci.NumPending, 1)
+			require_Equal(t, strings.Join(fetchData(t, sub, 10, 250*time.Millisecond), ","), "9")
+
+			// And removed.
+			cfg.HeaderFilters = nil
+			_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+			require_NoError(t, err)
+			require_Equal(t, mset.hfcons.Load(), 0)
+		})
+	}
+}
+
+func TestJetStreamConsumerHeaderFiltersPurge(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{Name: "TEST", Subjects: []string{"foo.*"}, Storage: storage})
+			require_NoError(t, err)
+			for i := 1; i <= 10; i++ {
+				m := nats.NewMsg(fmt.Sprintf("foo.%d", 2-i%2))
+				m.Header.Set("Tenant", "a")
+				_, err = js.PublishMsg(m)
+				require_NoError(t, err)
+			}
+			cfg := &ConsumerConfig{
+				Durable:       "C",
+				AckPolicy:     AckExplicit,
+				HeaderFilters: []HeaderFilter{{Header: "Tenant", Values: []string{"a"}}},
+			}
+			ci, err := jsConsumerCreate(t, nc, "TEST", cfg)
+			require_NoError(t, err)
+			require_Equal(t, ci.NumPending, 10)
+
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			require_NoError(t, err)
+			o := mset.lookupConsumer("C")
+			require_NotNil(t, o)
+			checkPending := func(expected ...uint64) {
+				t.Helper()
+				nci, err := js.ConsumerInfo("TEST", "C")
+				require_NoError(t, err)
+				require_Equal(t, nci.NumPending, uint64(len(expected)))
+				o.mu.RLock()
+				defer o.mu.RUnlock()
+				var seqs []uint64
+				o.hfpend.Range(func(seq uint64) bool {
+					seqs = append(seqs, seq)
+					return true
+				})
+				require_True(t, slices.Equal(seqs, expected))
+			}
+
+			// Removals from the front of the stream.
+			require_NoError(t, js.PurgeStream("TEST", &nats.StreamPurgeRequest{Sequence: 3}))
+			checkPending(3, 4, 5, 6, 7, 8, 9, 10)
+			// Leaving interior deletes.
+			require_NoError(t, js.PurgeStream("TEST", &nats.StreamPurgeRequest{Subject: "foo.2"}))
+			checkPending(3, 5, 7, 9)
+			require_NoError(t, js.PurgeStream("TEST", &nats.StreamPurgeRequest{Subject: "foo.1", Keep: 2}))
+			checkPending(7, 9)
+			require_NoError(t, js.PurgeStream("TEST", nil))
+			checkPending()
+		})
+	}
+}
+
+func TestJetStreamConsumerHeaderFiltersInterestPolicy(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Retention: nats.InterestPolicy})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:       "C",
+		AckPolicy:     AckExplicit,
+		HeaderFilters: []HeaderFilter{{Header: "Tenant", Values: []string{"a"}}},
+	})
+	require_NoError(t, err)
+
+	for i, tenant := range []string{"a", "b", "a", "b"} {
+		m := nats.NewMsg("foo")
+		m.Header.Set("Tenant", tenant)
+		m.Data = []byte(strconv.Itoa(i + 1))
+		_, err = js.PublishMsg(m)
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 2)
+	for _, msg := range msgs {
+		require_NoError(t, msg.AckSync())
+	}
+
+	// Messages the consumer is not interested in are removed as well.
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		si, err := js.StreamInfo("TEST")
+		if err != nil {
+			return err
+		}
+		if si.State.Msgs != 0 {
+			return fmt.Errorf("expected no messages, got %d (%d-%d)", si.State.Msgs, si.State.FirstSeq, si.State.LastSeq)
+		}
+		return nil
+	})
+}
+
+func TestJetStreamConsumerHeaderFiltersConfig(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo.*"}})
+	require_NoError(t, err)
+
+	for _, test := range []struct {
+		name string
+		cfg  *ConsumerConfig
+		err  string
+	}{
+		{"empty header", &ConsumerConfig{HeaderFilters: []HeaderFilter{{}}}, `header name "" is invalid`},
+		{"bad header", &ConsumerConfig{HeaderFilters: []HeaderFilter{{Header: "Ten ant"}}}, `header name "Ten ant" is invalid`},
+		{"last per subject", &ConsumerConfig{
+			FilterSubject: "foo.*",
+			DeliverPolicy: DeliverLastPerSubject,
+			HeaderFilters: []HeaderFilter{{Header: "Tenant"}},
+		}, "can not be combined with deliver last per subject policy"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.cfg.Durable = "C"
+			test.cfg.AckPolicy = AckExplicit
+			_, err := jsConsumerCreate(t, nc, "TEST", test.cfg)
+			require_Error(t, err, NewJSConsumerHeaderFilterInvalidError(errors.New(test.err)))
+		})
+	}
+}
+
+func TestJetStreamClusterConsumerHeaderFiltersInterestPolicy(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Retention: nats.InterestPolicy, Replicas: 3})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:       "C",
+		AckPolicy:     AckExplicit,
+		Replicas:      3,
+		HeaderFilters: []HeaderFilter{{Header: "Tenant", Values: []string{"a"}}},
+	})
+	require_NoError(t, err)
+
+	for i, tenant := range []string{"a", "b", "a", "b"} {
+		m := nats.NewMsg("foo")
+		m.Header.Set("Tenant", tenant)
+		m.Data = []byte(strconv.Itoa(i + 1))
+		_, err = js.PublishMsg(m)
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 2)
+	for _, msg := range msgs {
+		require_NoError(t, msg.AckSync())
+	}
+
+	checkFor(t, 5*time.Second, 200*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			var state StreamState
+			mset.store.FastState(&state)
+			if state.Msgs != 0 {
+				return fmt.Errorf("expected no messages on %s, got %d", s, state.Msgs)
+			}
+		}
+		return nil
+	})
+}
+
+func jsConsumerPending(t *testing.T, nc *nats.Conn, stream, consumer string, offset int) *JSApiConsumerPendingResponse {
+	t.Helper()
+	j, err := json.Marshal(&JSApiConsumerPendingRequest{ApiPagedRequest: ApiPagedRequest{Offset: offset}})
+	require_NoError(t, err)
+	msg, err := nc.Request(fmt.Sprintf(JSApiConsumerPendingT, stream, consumer), j, 2*time.Second)
+	require_NoError(t, err)
+	var resp JSApiConsumerPendingResponse
+	require_NoError(t, json.Unmarshal(msg.Data, &resp))
+	require_True(t, resp.Error == nil)
+	return &resp
+}
+
+func jsConsumerPendingAction(t *testing.T, nc *nats.Conn, stream, consumer string, req *JSApiConsumerPendingActionRequest) error {
+	t.Helper()
+	j, err := json.Marshal(req)
+	require_NoError(t, err)
+	msg, err := nc.Request(fmt.Sprintf(JSApiConsumerPendingActionT, stream, consumer), j, 2*time.Second)
+	require_NoError(t, err)
+	var resp JSApiConsumerPendingActionResponse
+	require_NoError(t, json.Unmarshal(msg.Data, &resp))
+	if resp.Error != nil {
+		return resp.Error
+	}
+	require_True(t, resp.Success)
+	return nil
+}
+
+func TestJetStreamConsumerPendingAcks(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Retention: nats.WorkQueuePolicy})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, AckWait: time.Hour})
+	require_NoError(t, err)
+	for i := 1; i <= 5; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	require_Equal(t, strings.Join(fetchData(t, sub, 3, time.Second), ","), "1,2,3")
+
+	resp := jsConsumerPending(t, nc, "TEST", "C", 0)
+	require_Equal(t, resp.Total, 3)
+	require_Len(t, len(resp.Pending), 3)
+	for i, pa := range resp.Pending {
+		require_Equal(t, pa.Sequence, uint64(i+1))
+		require_Equal(t, pa.ConsumerSeq, uint64(i+1))
+		require_Equal(t, pa.NumDelivered, 1)
+		require_True(t, strings.HasPrefix(pa.DeliverSubject, nats.InboxPrefix))
+		require_False(t, pa.Delivered.IsZero())
+	}
+	resp = jsConsumerPending(t, nc, "TEST", "C", 2)
+	require_Equal(t, resp.Total, 3)
+	require_Equal(t, resp.Offset, 2)
+	require_Len(t, len(resp.Pending), 1)
+	require_Equal(t, resp.Pending[0].Sequence, 3)
+
+	// Redelivered right away, even though the ack wait is far away.
+	require_NoError(t, jsConsumerPendingAction(t, nc, "TEST", "C", &JSApiConsumerPendingActionRequest{Sequence: 2, Action: ConsumerPendingRedeliver}))
+	require_Equal(t, strings.Join(fetchData(t, sub, 1, time.Second), ","), "2")
+	resp = jsConsumerPending(t, nc, "TEST", "C", 0)
+	require_Equal(t, resp.Pending[1].Sequence, 2)
+	require_Equal(t, resp.Pending[1].NumDelivered, 2)
+
+	// Skipping acks the message, which removes it from the work queue.
+	require_NoError(t, jsConsumerPendingAction(t, nc, "TEST", "C", &JSApiConsumerPendingActionRequest{Sequence: 1, Action: ConsumerPendingSkip}))
+	require_NoError(t, jsConsumerPendingAction(t, nc, "TEST", "C", &JSApiConsumerPendingActionRequest{Sequence: 3, Action: ConsumerPendingTerm, Reason: "stuck"}))
+	resp = jsConsumerPending(t, nc, "TEST", "C", 0)
+	require_Equal(t, resp.Total, 1)
+	require_Equal(t, resp.Pending[0].Sequence, 2)
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 3)
+
+	ci, err := js.ConsumerInfo("TEST", "C")
+	require_NoError(t, err)
+	require_Equal(t, ci.NumAckPending, 1)
+	require_Equal(t, ci.NumRedelivered, 1)
+
+	for _, test := range []struct {
+		req *JSApiConsumerPendingActionRequest
+		err string
+	}{
+		{&JSApiConsumerPendingActionRequest{Action: ConsumerPendingSkip}, "stream sequence required"},
+		{&JSApiConsumerPendingActionRequest{Sequence: 2, Action: "bogus"}, `unknown action "bogus"`},
+		{&JSApiConsumerPendingActionRequest{Sequence: 1, Action: ConsumerPendingRedeliver}, "stream sequence 1 is not pending"},
+	} {
+		err = jsConsumerPendingAction(t, nc, "TEST", "C", test.req)
+		require_Error(t, err, NewJSConsumerPendingActionInvalidError(errors.New(test.err)))
+	}
+
+	// A stream named ACTION can still list its pending messages.
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ACTION", Subjects: []string{"bar"}})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "ACTION", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, AckWait: time.Hour})
+	require_NoError(t, err)
+	_, err = js.Publish("bar", []byte("1"))
+	require_NoError(t, err)
+	sub, err = js.PullSubscribe(_EMPTY_, "C", nats.Bind("ACTION", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	require_Equal(t, strings.Join(fetchData(t, sub, 1, time.Second), ","), "1")
+	resp = jsConsumerPending(t, nc, "ACTION", "C", 0)
+	require_Equal(t, resp.Total, 1)
+	require_NoError(t, jsConsumerPendingAction(t, nc, "ACTION", "C", &JSApiConsumerPendingActionRequest{Sequence: 1, Action: ConsumerPendingSkip}))
+	require_Equal(t, jsConsumerPending(t, nc, "ACTION", "C", 0).Total, 0)
+}
+
+func TestJetStreamClusterConsumerPendingAcks(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, AckWait: time.Hour, Replicas: 3})
+	require_NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	require_Equal(t, strings.Join(fetchData(t, sub, 3, time.Second), ","), "1,2,3")
+
+	require_NoError(t, jsConsumerPendingAction(t, nc, "TEST", "C", &JSApiConsumerPendingActionRequest{Sequence: 2, Action: ConsumerPendingSkip}))
+	require_NoError(t, jsConsumerPendingAction(t, nc, "TEST", "C", &JSApiConsumerPendingActionRequest{Sequence: 3, Action: ConsumerPendingRedeliver}))
+	require_Equal(t, strings.Join(fetchData(t, sub, 1, time.Second), ","), "3")
+
+	// All replicas agree on the outcome.
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			o := mset.lookupConsumer("C")
+			if o == nil {
+				return errors.New("consumer not found")
+			}
+			state, err := o.store.State()
+			if err != nil {
+				return err
+			}
+			if _, ok := state.Pending[2]; ok || len(state.Pending) != 2 {
+				return fmt.Errorf("unexpected pending on %s: %+v", s, state.Pending)
+			}
+			if state.Redelivered[3] != 1 {
+				return fmt.Errorf("expected sequence 3 to be redelivered on %s, got %+v", s, state.Redelivered)
+			}
+		}
+		return nil
+	})
+
+	// A new leader picks up where the old one left off.
+	cl := c.consumerLeader(globalAccountName, "TEST", "C")
+	_, err = nc.Request(fmt.Sprintf(JSApiConsumerLeaderStepDownT, "TEST", "C"), nil, time.Second)
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "C")
+	require_NotEqual(t, c.consumerLeader(globalAccountName, "TEST", "C"), cl)
+
+	resp := jsConsumerPending(t, nc, "TEST", "C", 0)
+	require_Equal(t, resp.Total, 2)
+	require_Equal(t, resp.Pending[0].Sequence, 1)
+	require_Equal(t, resp.Pending[1].Sequence, 3)
+	require_Equal(t, resp.Pending[1].NumDelivered, 2)
+
+	// A redelivery nobody was waiting for is not lost when the leader changes.
+	require_NoError(t, jsConsumerPendingAction(t, nc, "TEST", "C", &JSApiConsumerPendingActionRequest{Sequence: 1, Action: ConsumerPendingRedeliver}))
+	cl = c.consumerLeader(globalAccountName, "TEST", "C")
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			if s == cl {
+				continue
+			}
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			state, err := mset.lookupConsumer("C").store.State()
+			if err != nil {
+				return err
+			}
+			if p := state.Pending[1]; p == nil || time.Since(time.Unix(0, p.Timestamp)) < time.Hour {
+				return fmt.Errorf("expected sequence 1 to be expired on %s, got %+v", s, p)
+			}
+		}
+		return nil
+	})
+	_, err = nc.Request(fmt.Sprintf(JSApiConsumerLeaderStepDownT, "TEST", "C"), nil, time.Second)
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "C")
+	require_NotEqual(t, c.consumerLeader(globalAccountName, "TEST", "C"), cl)
+	require_Equal(t, strings.Join(fetchData(t, sub, 1, 5*time.Second), ","), "1")
+}
+
+func jsConsumerInfo(t *testing.T, nc *nats.Conn, stream, consumer string) *ConsumerInfo {
+	t.Helper()
+	msg, err := nc.Request(fmt.Sprintf(JSApiConsumerInfoT, stream, consumer), nil, 2*time.Second)
+	require_NoError(t, err)
+	var resp JSApiConsumerInfoResponse
+	require_NoError(t, json.Unmarshal(msg.Data, &resp))
+	require_True(t, resp.Error == nil)
+	return resp.ConsumerInfo
+}
+
+func TestJetStreamConsumerBoundedStopSeq(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 1; i <= 10; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+
+	ci, err := jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:       "C",
+		AckPolicy:     AckExplicit,
+		DeliverPolicy: DeliverByStartSequence,
+		OptStartSeq:   3,
+		OptStopSeq:    6,
+	})
+	require_NoError(t, err)
+	require_Equal(t, ci.NumPending, 4)
+	require_False(t, ci.Complete)
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 4)
+
+	// Not complete until all messages are acked, as they could still be redelivered.
+	nci, err := js.ConsumerInfo("TEST", "C")
+	require_NoError(t, err)
+	require_Equal(t, nci.NumPending, 0)
+	require_Equal(t, nci.NumAckPending, 4)
+	ci = jsConsumerInfo(t, nc, "TEST", "C")
+	require_False(t, ci.Complete)
+
+	// Pull requests waiting for more are released once complete.
+	psub, err := nc.SubscribeSync(nats.NewInbox())
+	require_NoError(t, err)
+	defer psub.Unsubscribe()
+	req, err := json.Marshal(&JSApiConsumerGetNextRequest{Batch: 1, Expires: 5 * time.Second})
+	require_NoError(t, err)
+	require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), psub.Subject, req))
+
+	for i, msg := range msgs {
+		require_Equal(t, string(msg.Data), strconv.Itoa(i+3))
+		require_NoError(t, msg.AckSync())
+	}
+	msg, err := psub.NextMsg(time.Second)
+	require_NoError(t, err)
+	require_Equal(t, msg.Header.Get("Status"), "409")
+	require_Equal(t, msg.Header.Get("Description"), "Consumer Complete")
+
+	ci = jsConsumerInfo(t, nc, "TEST", "C")
+	require_True(t, ci.Complete)
+	require_Equal(t, ci.NumPending, 0)
+
+	// New messages don't change that, and new requests are released right away.
+	_, err = js.Publish("foo", []byte("11"))
+	require_NoError(t, err)
+	ci = jsConsumerInfo(t, nc, "TEST", "C")
+	require_True(t, ci.Complete)
+	require_Equal(t, ci.NumPending, 0)
+	require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "C"), psub.Subject, req))
+	msg, err = psub.NextMsg(time.Second)
+	require_NoError(t, err)
+	require_Equal(t, msg.Header.Get("Status"), "409")
+}
+
+func TestJetStreamConsumerBoundedStopTime(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	stop := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	for i := 4; i <= 5; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+
+	// Ephemeral consumers are removed once complete.
+	ci, err := jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Name:        "C",
+		AckPolicy:   AckExplicit,
+		OptStopTime: &stop,
+	})
+	require_NoError(t, err)
+	require_Equal(t, ci.NumPending, 3)
+
+	sub, err := js.PullSubscribe(_EMPTY_, _EMPTY_, nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 3)
+	for _, msg := range msgs {
+		require_NoError(t, msg.AckSync())
+	}
+	_, err = sub.Fetch(1, nats.MaxWait(time.Second))
+	require_Error(t, err)
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		if _, err := js.ConsumerInfo("TEST", "C"); err != nats.ErrConsumerNotFound {
+			return fmt.Errorf("expected consumer to be removed, got %v", err)
+		}
+		return nil
+	})
+
+	// A stop time in the future is waited for.
+	stop = time.Now().Add(500 * time.Millisecond)
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+		Durable:       "D",
+		AckPolicy:     AckNone,
+		DeliverPolicy: DeliverNew,
+		OptStopTime:   &stop,
+	})
+	require_NoError(t, err)
+	psub, err := nc.SubscribeSync(nats.NewInbox())
+	require_NoError(t, err)
+	defer psub.Unsubscribe()
+	req, err := json.Marshal(&JSApiConsumerGetNextRequest{Batch: 10, Expires: 5 * time.Second})
+	require_NoError(t, err)
+	require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", "D"), psub.Subject, req))
+	_, err = js.Publish("foo", []byte("6"))
+	require_NoError(t, err)
+	msg, err := psub.NextMsg(time.Second)
+	require_NoError(t, err)
+	require_Equal(t, string(msg.Data), "6")
+	msg, err = psub.NextMsg(2 * time.Second)
+	require_NoError(t, err)
+	require_Equal(t, msg.Header.Get("Status"), "409")
+	require_Equal(t, msg.Header.Get("Description"), "Consumer Complete")
+}
+
+func TestJetStreamConsumerBoundedConfig(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo.*"}})
+	require_NoError(t, err)
+
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	for _, test := range []struct {
+		name string
+		cfg  *ConsumerConfig
+		err  string
+	}{
+		{"seq and time", &ConsumerConfig{OptStopSeq: 10, OptStopTime: &now}, "consumer optional stop sequence and time are mutually exclusive"},
+		{"seq before start", &ConsumerConfig{DeliverPolicy: DeliverByStartSequence, OptStartSeq: 10, OptStopSeq: 5}, "consumer optional stop sequence is before the start sequence"},
+		{"time before start", &ConsumerConfig{DeliverPolicy: DeliverByStartTime, OptStartTime: &now, OptStopTime: &before}, "consumer optional stop time is before the start time"},
+		{"last per subject", &ConsumerConfig{DeliverPolicy: DeliverLastPerSubject, FilterSubject: "foo.*", OptStopSeq: 5}, "consumer optional stop can not be combined with deliver last per subject policy"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.cfg.Durable = "C"
+			_, err := jsConsumerCreate(t, nc, "TEST", test.cfg)
+			require_Error(t, err, NewJSConsumerInvalidPolicyError(errors.New(test.err)))
+		})
+	}
+
+	cfg := &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, OptStopSeq: 5}
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_NoError(t, err)
+	cfg.OptStopSeq = 10
+	_, err = jsConsumerCreate(t, nc, "TEST", cfg)
+	require_Error(t, err, errors.New("stop sequence can not be updated"))
+}
+
+func TestJetStreamClusterConsumerBoundedLeaderChange(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	for i := 1; i <= 5; i++ {
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, OptStopSeq: 3, Replicas: 3})
+	require_NoError(t, err)
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	msgs, err := sub.Fetch(10, nats.MaxWait(250*time.Millisecond))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 3)
+	for _, msg := range msgs {
+		require_NoError(t, msg.AckSync())
+	}
+	require_True(t, jsConsumerInfo(t, nc, "TEST", "C").Complete)
+
+	// The new leader knows it is complete as well.
+	cl := c.consumerLeader(globalAccountName, "TEST", "C")
+	_, err = nc.Request(fmt.Sprintf(JSApiConsumerLeaderStepDownT, "TEST", "C"), nil, time.Second)
+	require_NoError(t, err)
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "C")
+	require_NotEqual(t, c.consumerLeader(globalAccountName, "TEST", "C"), cl)
+
+	ci := jsConsumerInfo(t, nc, "TEST", "C")
+	require_True(t, ci.Complete)
+	require_Equal(t, ci.NumPending, 0)
+	_, err = sub.Fetch(1, nats.MaxWait(time.Second))
+	require_Error(t, err)
+}
+
+func TestJetStreamConsumerReplaySpeed(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		if i > 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, err = js.Publish("foo", []byte(strconv.Itoa(i)))
+		require_NoError(t, err)
+	}
+
+	create := func(name string, speed float64) {
+		t.Helper()
+		ci, err := jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{
+			Durable:      name,
+			AckPolicy:    AckNone,
+			ReplayPolicy: ReplayOriginal,
+			ReplaySpeed:  speed,
+		})
+		require_NoError(t, err)
+		require_Equal(t, ci.Config.ReplaySpeed, speed)
+	}
+	// Returns how long it took to receive all messages, or how many were received in time.
+	fetch := func(name string, maxWait time.Duration) (time.Duration, int) {
+		t.Helper()
+		sub, err := js.PullSubscribe(_EMPTY_, name, nats.Bind("TEST", name))
+		require_NoError(t, err)
+		defer sub.Unsubscribe()
+		start, received := time.Now(), 0
+		for received < 3 && time.Since(start) < maxWait {
+			msgs, err := sub.Fetch(3-received, nats.MaxWait(maxWait-time.Since(start)))
+			if err == nats.ErrTimeout {
+				break
+			}
+			require_NoError(t, err)
+			received += len(msgs)
+		}
+		return time.Since(start), received
+	}
+	replay := func(name string, speed float64) time.Duration {
+		t.Helper()
+		create(name, speed)
+		elapsed, received := fetch(name, 2*time.Second)
+		require_Equal(t, received, 3)
+		return elapsed
+	}
+
+	// The original timing takes 400ms.
+	if elapsed := replay("FAST", 10); elapsed > 250*time.Millisecond {
+		t.Fatalf("Expected a fast replay, took %v", elapsed)
+	}
+	if elapsed := replay("SLOW", 0.5); elapsed < 700*time.Millisecond {
+		t.Fatalf("Expected a slow replay, took %v", elapsed)
+	}
+
+	// The speed can be updated, which applies to a replay in progress.
+	// At the original speed this would take 1.6s.
+	create("UPDATED", 0.25)
+	create("UPDATED", 10)
+	if elapsed, received := fetch("UPDATED", 2*time.Second); received != 3 || elapsed > 250*time.Millisecond {
+		t.Fatalf("Expected a fast replay after the update, got %d messages in %v", received, elapsed)
+	}
+
+	// Very slow speeds do not overflow into no delay at all.
+	create("TINY", 1e-300)
+	_, received := fetch("TINY", 500*time.Millisecond)
+	require_Equal(t, received, 1)
+
+	for _, test := range []struct {
+		cfg *ConsumerConfig
+		err string
+	}{
+		{&ConsumerConfig{Durable: "C", ReplayPolicy: ReplayOriginal, ReplaySpeed: -1}, "consumer replay speed can not be negative"},
+		{&ConsumerConfig{Durable: "C", ReplayPolicy: ReplayInstant, ReplaySpeed: 2}, "consumer replay speed requires replay original policy"},
+	} {
+		_, err = jsConsumerCreate(t, nc, "TEST", test.cfg)
+		require_Error(t, err, NewJSConsumerInvalidPolicyError(errors.New(test.err)))
+	}
+}
+
+func TestJetStreamConsumerPullRateLimit(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 0; i < 6; i++ {
+		_, err = js.Publish("foo", make([]byte, 400))
+		require_NoError(t, err)
+	}
+
+	// Pull from the consumer with the given requests in parallel, returns
+	// the elapsed time and the number of rate limited heartbeats seen.
+	pull := func(consumer string, hb time.Duration, batches ...int) (time.Duration, int) {
+		t.Helper()
+		sub, err := nc.SubscribeSync(nats.NewInbox())
+		require_NoError(t, err)
+		defer sub.Unsubscribe()
+		start, expected := time.Now(), 0
+		for _, batch := range batches {
+			req, err := json.Marshal(&JSApiConsumerGetNextRequest{Batch: batch, Expires: 5 * time.Second, Heartbeat: hb})
+			require_NoError(t, err)
+			require_NoError(t, nc.PublishRequest(fmt.Sprintf(JSApiRequestNextT, "TEST", consumer), sub.Subject, req))
+			expected += batch
+		}
+		var limited int
+		for received := 0; received < expected; {
+			msg, err := sub.NextMsg(5 * time.Second)
+			require_NoError(t, err)
+			if len(msg.Data) > 0 {
+				received++
+			} else if msg.Header.Get("Status") == "100" && msg.Header.Get(JSConsumerRateLimited) != _EMPTY_ {
+				limited++
+			}
+		}
+		return time.Since(start), limited
+	}
+
+	// The message limit is shared by all requesters, 6 messages at 4 per second takes at least 1.25s.
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckNone, RateLimitMsgs: 4})
+	require_NoError(t, err)
+	elapsed, limited := pull("C", 100*time.Millisecond, 3, 3)
+	if elapsed < time.Second {
+		t.Fatalf("Expected message rate limit to be enforced, took %v", elapsed)
+	}
+	require_True(t, limited > 0)
+
+	// Bytes limit, a second worth of bytes is allowed up front.
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "D", AckPolicy: AckNone, RateLimitBytes: 1000})
+	require_NoError(t, err)
+	elapsed, limited = pull("D", 100*time.Millisecond, 6)
+	if elapsed < time.Second {
+		t.Fatalf("Expected byte rate limit to be enforced, took %v", elapsed)
+	}
+	require_True(t, limited > 0)
+
+	// Requests without idle heartbeats are told once that we are rate limited.
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "E", AckPolicy: AckNone, RateLimitMsgs: 4})
+	require_NoError(t, err)
+	_, limited = pull("E", 0, 3, 3)
+	require_Equal(t, limited, 2)
+
+	// Limits can be updated and removed.
+	ci, err := jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "D", AckPolicy: AckNone})
+	require_NoError(t, err)
+	require_Equal(t, ci.Config.RateLimitBytes, 0)
+	_, err = jsConsumerReset(t, nc, "TEST", "D", &JSApiConsumerResetRequest{Sequence: 1})
+	require_NoError(t, err)
+	elapsed, _ = pull("D", 100*time.Millisecond, 6)
+	if elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected no rate limit, took %v", elapsed)
+	}
+}
+
+func TestJetStreamConsumerLatencyStats(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = js.Publish("foo", []byte("ok"))
+		require_NoError(t, err)
+	}
+
+	// Not tracked unless asked for.
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, AckWait: 250 * time.Millisecond})
+	require_NoError(t, err)
+	require_True(t, jsConsumerInfo(t, nc, "TEST", "C").Latency == nil)
+
+	_, err = jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, AckWait: 250 * time.Millisecond, LatencyStats: true})
+	require_NoError(t, err)
+
+	sub, err := js.PullSubscribe(_EMPTY_, "C", nats.Bind("TEST", "C"))
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// Ack all but one right away, the last one needs to be redelivered.
+	msgs, err := sub.Fetch(10, nats.MaxWait(2*time.Second))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 10)
+	for _, m := range msgs[:9] {
+		require_NoError(t, m.AckSync())
+	}
+	msgs, err = sub.Fetch(1, nats.MaxWait(2*time.Second))
+	require_NoError(t, err)
+	require_Len(t, len(msgs), 1)
+	md, err := msgs[0].Metadata()
+	require_NoError(t, err)
+	require_Equal(t, md.NumDelivered, 2)
+	time.Sleep(100 * time.Millisecond)
+	require_NoError(t, msgs[0].AckSync())
+
+	lat := jsConsumerInfo(t, nc, "TEST", "C").Latency
+	require_NotNil(t, lat)
+	require_Equal(t, lat.Delivery.Count, 10)
+	require_True(t, lat.Delivery.Max > 0 && lat.Delivery.P50 <= lat.Delivery.Max)
+	require_Equal(t, lat.Ack.Count, 10)
+	require_True(t, lat.Ack.Max >= 100*time.Millisecond)
+	require_True(t, lat.Ack.P50 < 100*time.Millisecond)
+	require_Equal(t, lat.Redeliveries.Count, 10)
+	require_Equal(t, lat.Redeliveries.P50, 0)
+	require_Equal(t, lat.Redeliveries.Max, 1)
+
+	// Also available from the monitoring endpoint.
+	jsz, err := s.Jsz(&JSzOptions{Accounts: true, Streams: true, Consumer: true})
+	require_NoError(t, err)
+	require_Len(t, len(jsz.AccountDetails), 1)
+	require_Len(t, len(jsz.AccountDetails[0].Streams), 1)
+	require_Len(t, len(jsz.AccountDetails[0].Streams[0].Consumer), 1)
+	lat = jsz.AccountDetails[0].Streams[0].Consumer[0].Latency
+	require_NotNil(t, lat)
+	require_Equal(t, lat.Ack.Count, 10)
+
+	// Can be turned off again.
+	ci, err := jsConsumerCreate(t, nc, "TEST", &ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, AckWait: 250 * time.Millisecond})
+	require_NoError(t, err)
+	require_True(t, ci.Latency == nil)
+}
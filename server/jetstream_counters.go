@@ -0,0 +1,147 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// CounterValue is the body of a message in a counter stream, holding the total for its subject.
+type CounterValue struct {
+	Value string `json:"val"`
+}
+
+// CounterSources tracks the last total seen per source and subject, which is used to merge
+// sourced counters. Stored in the `Nats-Counter-Sources` header of every counter message.
+type CounterSources map[string]map[string]string
+
+var (
+	errMsgCounterDisabled = errors.New("message counters disabled")
+	errMsgIncrInvalid     = errors.New("message counter increment invalid")
+	errMsgIncrMissing     = errors.New("message counter increment missing")
+	errMsgCounterBroken   = errors.New("message counter broken")
+)
+
+// getMessageIncr returns the counter increment from the headers, or nil if not present.
+func getMessageIncr(hdr []byte) (*big.Int, error) {
+	incr := getHeader(JSMsgIncr, hdr)
+	if len(incr) == 0 {
+		return nil, nil
+	}
+	var v big.Int
+	if _, ok := v.SetString(bytesToString(incr), 10); !ok {
+		return nil, errMsgIncrInvalid
+	}
+	return &v, nil
+}
+
+// checkMsgCounter validates the counter increment of a message against the counter status of the stream.
+// Sourced messages are validated when merged, since they hold a total instead of an increment.
+func checkMsgCounter(hdr []byte, allowCounter, sourced bool) (*ApiError, error) {
+	if sourced {
+		return nil, nil
+	}
+	incr, err := getMessageIncr(hdr)
+	switch {
+	case !allowCounter:
+		if incr != nil || err != nil {
+			return NewJSMessageCounterDisabledError(), errMsgCounterDisabled
+		}
+	case err != nil:
+		return NewJSMessageIncrInvalidError(), err
+	case incr == nil:
+		return NewJSMessageIncrMissingError(), errMsgIncrMissing
+	}
+	return nil, nil
+}
+
+// applyMsgCounterLocked adds the increment to the last total for the subject, and returns the headers
+// and body to store along with the new total. For sourced messages the increment is the difference
+// between their total and the last total seen for that source, so sourced counters merge correctly
+// even if some of the source's messages were never received.
+// Lock should be held.
+func (mset *stream) applyMsgCounterLocked(subject string, hdr, msg []byte, sourced bool) ([]byte, []byte, string, *ApiError, error) {
+	var incr *big.Int
+	var source string
+	var total big.Int
+	if sourced {
+		var sv CounterValue
+		if err := json.Unmarshal(msg, &sv); err != nil {
+			return nil, nil, _EMPTY_, NewJSMessageIncrInvalidError(), errMsgIncrInvalid
+		}
+		if _, ok := total.SetString(sv.Value, 10); !ok {
+			return nil, nil, _EMPTY_, NewJSMessageIncrInvalidError(), errMsgIncrInvalid
+		}
+		sname, iname, _ := streamAndSeq(bytesToString(getHeader(JSStreamSource, hdr)))
+		if source = iname; source == _EMPTY_ {
+			source = sname
+		}
+	} else {
+		var err error
+		if incr, err = getMessageIncr(hdr); err != nil || incr == nil {
+			return nil, nil, _EMPTY_, NewJSMessageIncrInvalidError(), errMsgIncrInvalid
+		}
+	}
+
+	// Grab the last total and sources for this subject.
+	var current big.Int
+	var sources CounterSources
+	var smv StoreMsg
+	if sm, err := mset.store.LoadLastMsg(subject, &smv); err == nil {
+		var cv CounterValue
+		if err = json.Unmarshal(sm.msg, &cv); err != nil {
+			return nil, nil, _EMPTY_, NewJSMessageCounterBrokenError(), errMsgCounterBroken
+		}
+		if _, ok := current.SetString(cv.Value, 10); !ok {
+			return nil, nil, _EMPTY_, NewJSMessageCounterBrokenError(), errMsgCounterBroken
+		}
+		if src := getHeader(JSMsgCounterSources, sm.hdr); len(src) > 0 {
+			if err = json.Unmarshal(src, &sources); err != nil {
+				return nil, nil, _EMPTY_, NewJSMessageCounterBrokenError(), errMsgCounterBroken
+			}
+		}
+	} else if err != ErrStoreMsgNotFound {
+		return nil, nil, _EMPTY_, NewJSStreamStoreFailedError(err, Unless(err)), err
+	}
+
+	if sourced {
+		var last big.Int
+		if lv, ok := sources[source][subject]; ok {
+			last.SetString(lv, 10)
+		}
+		if sources == nil {
+			sources = make(CounterSources)
+		}
+		if sources[source] == nil {
+			sources[source] = make(map[string]string)
+		}
+		sources[source][subject] = total.String()
+		incr = total.Sub(&total, &last)
+	}
+	current.Add(&current, incr)
+
+	// Store the applied increment, and carry over the sources for the next merge.
+	hdr = removeHeaderIfPresent(copyBytes(hdr), JSMsgIncr)
+	hdr = removeHeaderIfPresent(hdr, JSMsgCounterSources)
+	hdr = genHeader(hdr, JSMsgIncr, incr.String())
+	if len(sources) > 0 {
+		b, _ := json.Marshal(sources)
+		hdr = genHeader(hdr, JSMsgCounterSources, string(b))
+	}
+	value := current.String()
+	msg, _ = json.Marshal(CounterValue{Value: value})
+	return hdr, msg, value, nil, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// incrCounter publishes an increment and returns the response.
+func incrCounter(t *testing.T, nc *nats.Conn, subject, incr string) *JSPubAckResponse {
+	t.Helper()
+	m := nats.NewMsg(subject)
+	m.Header.Set(JSMsgIncr, incr)
+	rmsg, err := nc.RequestMsg(m, time.Second)
+	require_NoError(t, err)
+	var resp JSPubAckResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	return &resp
+}
+
+// getCounter returns the total stored in the last message for the subject.
+func getCounter(t *testing.T, js nats.JetStreamContext, stream, subject string) string {
+	t.Helper()
+	rsm, err := js.GetLastMsg(stream, subject)
+	require_NoError(t, err)
+	var cv CounterValue
+	require_NoError(t, json.Unmarshal(rsm.Data, &cv))
+	return cv.Value
+}
+
+func TestJetStreamMsgCounter(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:            "TEST",
+				Storage:         storage,
+				Subjects:        []string{"foo.*"},
+				AllowMsgCounter: true,
+			})
+			require_NoError(t, err)
+
+			for _, test := range []struct {
+				subject, incr, total string
+			}{
+				{"foo.a", "1", "1"},
+				{"foo.a", "5", "6"},
+				{"foo.b", "3", "3"},
+				{"foo.a", "-2", "4"},
+				{"foo.b", "99999999999999999999", "100000000000000000002"},
+			} {
+				resp := incrCounter(t, nc, test.subject, test.incr)
+				require_True(t, resp.Error == nil)
+				require_Equal(t, resp.Value, test.total)
+				require_Equal(t, getCounter(t, js, "TEST", test.subject), test.total)
+			}
+
+			// The increment is kept in the stored message.
+			rsm, err := js.GetLastMsg("TEST", "foo.a")
+			require_NoError(t, err)
+			require_Equal(t, rsm.Header.Get(JSMsgIncr), "-2")
+		})
+	}
+}
+
+func TestJetStreamMsgCounterErrors(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "DISABLED",
+		Storage:  FileStorage,
+		Subjects: []string{"disabled"},
+	})
+	require_NoError(t, err)
+
+	cfg := &StreamConfig{
+		Name:            "TEST",
+		Storage:         FileStorage,
+		Subjects:        []string{"foo"},
+		AllowMsgCounter: true,
+	}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_NoError(t, err)
+
+	resp := incrCounter(t, nc, "disabled", "1")
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSMessageCounterDisabledErr))
+
+	resp = incrCounter(t, nc, "foo", "one")
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSMessageIncrInvalidErr))
+
+	_, err = js.Publish("foo", []byte("1"))
+	require_Error(t, err, NewJSMessageIncrMissingError())
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 0)
+
+	// The counter status can't be changed.
+	cfg.AllowMsgCounter = false
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_Error(t, err, errors.New("message counter status can not be changed"))
+
+	for _, test := range []struct {
+		cfg *StreamConfig
+		err string
+	}{
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, AllowMsgCounter: true, AllowAtomicPublish: true}, "message counters can not be combined with atomic publish"},
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, AllowMsgCounter: true, AllowMsgSchedules: true}, "message counters can not be combined with message schedules"},
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, AllowMsgCounter: true, Retention: InterestPolicy}, "message counters require limits retention policy"},
+	} {
+		_, err = jsStreamCreate(t, nc, test.cfg)
+		require_Error(t, err, errors.New(test.err))
+	}
+}
+
+func TestJetStreamMsgCounterSources(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	for _, name := range []string{"A", "B"} {
+		_, err := jsStreamCreate(t, nc, &StreamConfig{
+			Name:            name,
+			Storage:         FileStorage,
+			Subjects:        []string{fmt.Sprintf("%s.>", name)},
+			AllowMsgCounter: true,
+		})
+		require_NoError(t, err)
+	}
+
+	incrCounter(t, nc, "A.cnt", "1")
+	incrCounter(t, nc, "A.cnt", "2")
+	incrCounter(t, nc, "B.cnt", "10")
+
+	// Both sources are merged into a single counter.
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:            "AGG",
+		Storage:         FileStorage,
+		Subjects:        []string{"agg.>"},
+		AllowMsgCounter: true,
+		Sources: []*StreamSource{
+			{Name: "A", SubjectTransforms: []SubjectTransformConfig{{Source: "A.>", Destination: "agg.>"}}},
+			{Name: "B", SubjectTransforms: []SubjectTransformConfig{{Source: "B.>", Destination: "agg.>"}}},
+		},
+	})
+	require_NoError(t, err)
+
+	checkCounter := func(expected string) {
+		t.Helper()
+		checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+			rsm, err := js.GetLastMsg("AGG", "agg.cnt")
+			if err != nil {
+				return err
+			}
+			var cv CounterValue
+			if err = json.Unmarshal(rsm.Data, &cv); err != nil {
+				return err
+			}
+			if cv.Value != expected {
+				return fmt.Errorf("expected total %s, got %s", expected, cv.Value)
+			}
+			return nil
+		})
+	}
+	checkCounter("13")
+
+	incrCounter(t, nc, "B.cnt", "-4")
+	incrCounter(t, nc, "A.cnt", "7")
+	checkCounter("16")
+
+	// Increments only made to the aggregate are kept when merging.
+	resp := incrCounter(t, nc, "agg.cnt", "100")
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Value, "116")
+	incrCounter(t, nc, "A.cnt", "1")
+	checkCounter("117")
+}
+
+func TestJetStreamClusterMsgCounter(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, _ := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:            "TEST",
+		Storage:         FileStorage,
+		Subjects:        []string{"foo"},
+		Replicas:        3,
+		AllowMsgCounter: true,
+	})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	for i := 1; i <= 10; i++ {
+		resp := incrCounter(t, nc, "foo", "2")
+		require_True(t, resp.Error == nil)
+		require_Equal(t, resp.Value, fmt.Sprintf("%d", i*2))
+	}
+
+	// Invalid increments are rejected before being proposed.
+	resp := incrCounter(t, nc, "foo", "bad")
+	require_NotNil(t, resp.Error)
+	resp = incrCounter(t, nc, "foo", "1")
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Value, "21")
+
+	checkFor(t, 2*time.Second, 200*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			sm, err := mset.store.LoadLastMsg("foo", nil)
+			if err != nil {
+				return err
+			}
+			var cv CounterValue
+			if err = json.Unmarshal(sm.msg, &cv); err != nil {
+				return err
+			}
+			if cv.Value != "21" || sm.seq != 11 {
+				return fmt.Errorf("expected total 21 at sequence 11, got %s at %d", cv.Value, sm.seq)
+			}
+		}
+		return nil
+	})
+}
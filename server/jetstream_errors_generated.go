@@ -8,6 +8,27 @@ const (
 	// JSAccountResourcesExceededErr resource limits exceeded for account
 	JSAccountResourcesExceededErr ErrorIdentifier = 10002
 
+	// JSAtomicPublishContainsDuplicateMessageErr atomic publish batch contains duplicate message id
+	JSAtomicPublishContainsDuplicateMessageErr ErrorIdentifier = 10174
+
+	// JSAtomicPublishDisabledErr atomic publish is disabled
+	JSAtomicPublishDisabledErr ErrorIdentifier = 10168
+
+	// JSAtomicPublishIncompleteBatchErr atomic publish batch is incomplete
+	JSAtomicPublishIncompleteBatchErr ErrorIdentifier = 10170
+
+	// JSAtomicPublishInvalidBatchIDErr atomic publish batch ID is invalid
+	JSAtomicPublishInvalidBatchIDErr ErrorIdentifier = 10172
+
+	// JSAtomicPublishMissingSeqErr atomic publish sequence is missing
+	JSAtomicPublishMissingSeqErr ErrorIdentifier = 10169
+
+	// JSAtomicPublishTooLargeBatchErrF atomic publish batch is too large: {size}
+	JSAtomicPublishTooLargeBatchErrF ErrorIdentifier = 10171
+
+	// JSAtomicPublishUnsupportedHeaderBatchErr atomic publish unsupported header used: {header}
+	JSAtomicPublishUnsupportedHeaderBatchErr ErrorIdentifier = 10173
+
 	// JSBadRequestErr bad request
 	JSBadRequestErr ErrorIdentifier = 10003
 
@@ -62,6 +83,9 @@ const (
 	// JSConsumerCreateFilterSubjectMismatchErr Consumer create request did not match filtered subject from create subject
 	JSConsumerCreateFilterSubjectMismatchErr ErrorIdentifier = 10131
 
+	// JSConsumerDeadLetterInvalidErrF consumer dead letter subject is invalid: {err}
+	JSConsumerDeadLetterInvalidErrF ErrorIdentifier = 10182
+
 	// JSConsumerDeliverCycleErr consumer deliver subject forms a cycle
 	JSConsumerDeliverCycleErr ErrorIdentifier = 10081
 
@@ -116,6 +140,9 @@ const (
 	// JSConsumerHBRequiresPushErr consumer idle heartbeat requires a push based consumer
 	JSConsumerHBRequiresPushErr ErrorIdentifier = 10088
 
+	// JSConsumerHeaderFilterInvalidErrF consumer header filter is invalid: {err}
+	JSConsumerHeaderFilterInvalidErrF ErrorIdentifier = 10186
+
 	// JSConsumerInactiveThresholdExcess consumer inactive threshold exceeds system limit of {limit}
 	JSConsumerInactiveThresholdExcess ErrorIdentifier = 10153
 
@@ -179,9 +206,18 @@ const (
 	// JSConsumerOnMappedErr consumer direct on a mapped consumer
 	JSConsumerOnMappedErr ErrorIdentifier = 10092
 
+	// JSConsumerOrderingKeyInvalidErrF consumer ordering key is invalid: {err}
+	JSConsumerOrderingKeyInvalidErrF ErrorIdentifier = 10183
+
 	// JSConsumerOverlappingSubjectFilters consumer subject filters cannot overlap
 	JSConsumerOverlappingSubjectFilters ErrorIdentifier = 10138
 
+	// JSConsumerPartitionsInvalidErrF consumer partitions are invalid: {err}
+	JSConsumerPartitionsInvalidErrF ErrorIdentifier = 10185
+
+	// JSConsumerPendingActionInvalidErrF consumer pending action is invalid: {err}
+	JSConsumerPendingActionInvalidErrF ErrorIdentifier = 10187
+
 	// JSConsumerPriorityPolicyWithoutGroup Setting PriorityPolicy requires at least one PriorityGroup to be set
 	JSConsumerPriorityPolicyWithoutGroup ErrorIdentifier = 10159
 
@@ -206,6 +242,12 @@ const (
 	// JSConsumerReplicasShouldMatchStream consumer config replicas must match interest retention stream's replicas
 	JSConsumerReplicasShouldMatchStream ErrorIdentifier = 10134
 
+	// JSConsumerResetInvalidErrF consumer reset request is invalid: {err}
+	JSConsumerResetInvalidErrF ErrorIdentifier = 10184
+
+	// JSConsumerResetNotDurableErr consumer reset requires a durable consumer
+	JSConsumerResetNotDurableErr ErrorIdentifier = 10189
+
 	// JSConsumerSmallHeartbeatErr consumer idle heartbeat needs to be >= 100ms
 	JSConsumerSmallHeartbeatErr ErrorIdentifier = 10083
 
@@ -242,6 +284,24 @@ const (
 	// JSMemoryResourcesExceededErr insufficient memory resources available
 	JSMemoryResourcesExceededErr ErrorIdentifier = 10028
 
+	// JSMessageCounterBrokenErr message counter is broken
+	JSMessageCounterBrokenErr ErrorIdentifier = 10180
+
+	// JSMessageCounterDisabledErr message counters are disabled
+	JSMessageCounterDisabledErr ErrorIdentifier = 10177
+
+	// JSMessageIncrInvalidErr message counter increment is invalid
+	JSMessageIncrInvalidErr ErrorIdentifier = 10178
+
+	// JSMessageIncrMissingErr message counter increment is missing
+	JSMessageIncrMissingErr ErrorIdentifier = 10179
+
+	// JSMessageSchedulesDisabledErr message schedules are disabled
+	JSMessageSchedulesDisabledErr ErrorIdentifier = 10175
+
+	// JSMessageSchedulesInvalidErr invalid message schedule
+	JSMessageSchedulesInvalidErr ErrorIdentifier = 10176
+
 	// JSMessageTTLDisabledErr per-message TTL is disabled
 	JSMessageTTLDisabledErr ErrorIdentifier = 10166
 
@@ -389,6 +449,9 @@ const (
 	// JSStreamInvalidExternalDeliverySubjErrF stream external delivery prefix {prefix} must not contain wildcards
 	JSStreamInvalidExternalDeliverySubjErrF ErrorIdentifier = 10024
 
+	// JSStreamLeaderLeaseNotHeldErr stream leader does not hold a lease for linearizable reads
+	JSStreamLeaderLeaseNotHeldErr ErrorIdentifier = 10188
+
 	// JSStreamLimitsErrF General stream limits exceeded error string ({err})
 	JSStreamLimitsErrF ErrorIdentifier = 10053
 
@@ -452,6 +515,9 @@ const (
 	// JSStreamRollupFailedF Generic stream rollup failure error string ({err})
 	JSStreamRollupFailedF ErrorIdentifier = 10111
 
+	// JSStreamSchemaValidationFailedF message schema validation failed: {err}
+	JSStreamSchemaValidationFailedF ErrorIdentifier = 10181
+
 	// JSStreamSealedErr invalid operation on sealed stream
 	JSStreamSealedErr ErrorIdentifier = 10109
 
@@ -507,6 +573,13 @@ const (
 var (
 	ApiErrors = map[ErrorIdentifier]*ApiError{
 		JSAccountResourcesExceededErr:              {Code: 400, ErrCode: 10002, Description: "resource limits exceeded for account"},
+		JSAtomicPublishContainsDuplicateMessageErr: {Code: 400, ErrCode: 10174, Description: "atomic publish batch contains duplicate message id"},
+		JSAtomicPublishDisabledErr:                 {Code: 400, ErrCode: 10168, Description: "atomic publish is disabled"},
+		JSAtomicPublishIncompleteBatchErr:          {Code: 400, ErrCode: 10170, Description: "atomic publish batch is incomplete"},
+		JSAtomicPublishInvalidBatchIDErr:           {Code: 400, ErrCode: 10172, Description: "atomic publish batch ID is invalid"},
+		JSAtomicPublishMissingSeqErr:               {Code: 400, ErrCode: 10169, Description: "atomic publish sequence is missing"},
+		JSAtomicPublishTooLargeBatchErrF:           {Code: 400, ErrCode: 10171, Description: "atomic publish batch is too large: {size}"},
+		JSAtomicPublishUnsupportedHeaderBatchErr:   {Code: 400, ErrCode: 10173, Description: "atomic publish unsupported header used: {header}"},
 		JSBadRequestErr:                            {Code: 400, ErrCode: 10003, Description: "bad request"},
 		JSClusterIncompleteErr:                     {Code: 503, ErrCode: 10004, Description: "incomplete results"},
 		JSClusterNoPeersErrF:                       {Code: 400, ErrCode: 10005, Description: "{err}"},
@@ -525,6 +598,7 @@ var (
 		JSConsumerCreateDurableAndNameMismatch:     {Code: 400, ErrCode: 10132, Description: "Consumer Durable and Name have to be equal if both are provided"},
 		JSConsumerCreateErrF:                       {Code: 500, ErrCode: 10012, Description: "{err}"},
 		JSConsumerCreateFilterSubjectMismatchErr:   {Code: 400, ErrCode: 10131, Description: "Consumer create request did not match filtered subject from create subject"},
+		JSConsumerDeadLetterInvalidErrF:            {Code: 400, ErrCode: 10182, Description: "consumer dead letter subject is invalid: {err}"},
 		JSConsumerDeliverCycleErr:                  {Code: 400, ErrCode: 10081, Description: "consumer deliver subject forms a cycle"},
 		JSConsumerDeliverToWildcardsErr:            {Code: 400, ErrCode: 10079, Description: "consumer deliver subject has wildcards"},
 		JSConsumerDescriptionTooLongErrF:           {Code: 400, ErrCode: 10107, Description: "consumer description is too long, maximum allowed is {max}"},
@@ -543,6 +617,7 @@ var (
 		JSConsumerFCRequiresPushErr:                {Code: 400, ErrCode: 10089, Description: "consumer flow control requires a push based consumer"},
 		JSConsumerFilterNotSubsetErr:               {Code: 400, ErrCode: 10093, Description: "consumer filter subject is not a valid subset of the interest subjects"},
 		JSConsumerHBRequiresPushErr:                {Code: 400, ErrCode: 10088, Description: "consumer idle heartbeat requires a push based consumer"},
+		JSConsumerHeaderFilterInvalidErrF:          {Code: 400, ErrCode: 10186, Description: "consumer header filter is invalid: {err}"},
 		JSConsumerInactiveThresholdExcess:          {Code: 400, ErrCode: 10153, Description: "consumer inactive threshold exceeds system limit of {limit}"},
 		JSConsumerInvalidDeliverSubject:            {Code: 400, ErrCode: 10112, Description: "invalid push consumer deliver subject"},
 		JSConsumerInvalidGroupNameErr:              {Code: 400, ErrCode: 10162, Description: "Valid priority group name must match A-Z, a-z, 0-9, -_/=)+ and may not exceed 16 characters"},
@@ -564,7 +639,10 @@ var (
 		JSConsumerNotFoundErr:                      {Code: 404, ErrCode: 10014, Description: "consumer not found"},
 		JSConsumerOfflineErr:                       {Code: 500, ErrCode: 10119, Description: "consumer is offline"},
 		JSConsumerOnMappedErr:                      {Code: 400, ErrCode: 10092, Description: "consumer direct on a mapped consumer"},
+		JSConsumerOrderingKeyInvalidErrF:           {Code: 400, ErrCode: 10183, Description: "consumer ordering key is invalid: {err}"},
 		JSConsumerOverlappingSubjectFilters:        {Code: 400, ErrCode: 10138, Description: "consumer subject filters cannot overlap"},
+		JSConsumerPartitionsInvalidErrF:            {Code: 400, ErrCode: 10185, Description: "consumer partitions are invalid: {err}"},
+		JSConsumerPendingActionInvalidErrF:         {Code: 400, ErrCode: 10187, Description: "consumer pending action is invalid: {err}"},
 		JSConsumerPriorityPolicyWithoutGroup:       {Code: 400, ErrCode: 10159, Description: "Setting PriorityPolicy requires at least one PriorityGroup to be set"},
 		JSConsumerPullNotDurableErr:                {Code: 400, ErrCode: 10085, Description: "consumer in pull mode requires a durable name"},
 		JSConsumerPullRequiresAckErr:               {Code: 400, ErrCode: 10084, Description: "consumer in pull mode requires explicit ack policy on workqueue stream"},
@@ -573,6 +651,8 @@ var (
 		JSConsumerReplacementWithDifferentNameErr:  {Code: 400, ErrCode: 10106, Description: "consumer replacement durable config not the same"},
 		JSConsumerReplicasExceedsStream:            {Code: 400, ErrCode: 10126, Description: "consumer config replica count exceeds parent stream"},
 		JSConsumerReplicasShouldMatchStream:        {Code: 400, ErrCode: 10134, Description: "consumer config replicas must match interest retention stream's replicas"},
+		JSConsumerResetInvalidErrF:                 {Code: 400, ErrCode: 10184, Description: "consumer reset request is invalid: {err}"},
+		JSConsumerResetNotDurableErr:               {Code: 400, ErrCode: 10189, Description: "consumer reset requires a durable consumer"},
 		JSConsumerSmallHeartbeatErr:                {Code: 400, ErrCode: 10083, Description: "consumer idle heartbeat needs to be >= 100ms"},
 		JSConsumerStoreFailedErrF:                  {Code: 500, ErrCode: 10104, Description: "error creating store for consumer: {err}"},
 		JSConsumerWQConsumerNotDeliverAllErr:       {Code: 400, ErrCode: 10101, Description: "consumer must be deliver all on workqueue stream"},
@@ -585,6 +665,12 @@ var (
 		JSMaximumConsumersLimitErr:                 {Code: 400, ErrCode: 10026, Description: "maximum consumers limit reached"},
 		JSMaximumStreamsLimitErr:                   {Code: 400, ErrCode: 10027, Description: "maximum number of streams reached"},
 		JSMemoryResourcesExceededErr:               {Code: 500, ErrCode: 10028, Description: "insufficient memory resources available"},
+		JSMessageCounterBrokenErr:                  {Code: 400, ErrCode: 10180, Description: "message counter is broken"},
+		JSMessageCounterDisabledErr:                {Code: 400, ErrCode: 10177, Description: "message counters are disabled"},
+		JSMessageIncrInvalidErr:                    {Code: 400, ErrCode: 10178, Description: "message counter increment is invalid"},
+		JSMessageIncrMissingErr:                    {Code: 400, ErrCode: 10179, Description: "message counter increment is missing"},
+		JSMessageSchedulesDisabledErr:              {Code: 400, ErrCode: 10175, Description: "message schedules are disabled"},
+		JSMessageSchedulesInvalidErr:               {Code: 400, ErrCode: 10176, Description: "invalid message schedule"},
 		JSMessageTTLDisabledErr:                    {Code: 400, ErrCode: 10166, Description: "per-message TTL is disabled"},
 		JSMessageTTLInvalidErr:                     {Code: 400, ErrCode: 10165, Description: "invalid per-message TTL"},
 		JSMirrorConsumerSetupFailedErrF:            {Code: 500, ErrCode: 10029, Description: "{err}"},
@@ -634,6 +720,7 @@ var (
 		JSStreamInvalidConfigF:                     {Code: 500, ErrCode: 10052, Description: "{err}"},
 		JSStreamInvalidErr:                         {Code: 500, ErrCode: 10096, Description: "stream not valid"},
 		JSStreamInvalidExternalDeliverySubjErrF:    {Code: 400, ErrCode: 10024, Description: "stream external delivery prefix {prefix} must not contain wildcards"},
+		JSStreamLeaderLeaseNotHeldErr:              {Code: 503, ErrCode: 10188, Description: "stream leader does not hold a lease for linearizable reads"},
 		JSStreamLimitsErrF:                         {Code: 500, ErrCode: 10053, Description: "{err}"},
 		JSStreamMaxBytesRequired:                   {Code: 400, ErrCode: 10113, Description: "account requires a stream config to have max bytes set"},
 		JSStreamMaxStreamBytesExceeded:             {Code: 400, ErrCode: 10122, Description: "stream max bytes exceeds account limit max stream bytes"},
@@ -655,6 +742,7 @@ var (
 		JSStreamReplicasNotUpdatableErr:            {Code: 400, ErrCode: 10061, Description: "Replicas configuration can not be updated"},
 		JSStreamRestoreErrF:                        {Code: 500, ErrCode: 10062, Description: "restore failed: {err}"},
 		JSStreamRollupFailedF:                      {Code: 500, ErrCode: 10111, Description: "{err}"},
+		JSStreamSchemaValidationFailedF:            {Code: 400, ErrCode: 10181, Description: "message schema validation failed: {err}"},
 		JSStreamSealedErr:                          {Code: 400, ErrCode: 10109, Description: "invalid operation on sealed stream"},
 		JSStreamSequenceNotMatchErr:                {Code: 503, ErrCode: 10063, Description: "expected stream sequence does not match"},
 		JSStreamSnapshotErrF:                       {Code: 500, ErrCode: 10064, Description: "snapshot failed: {err}"},
@@ -707,6 +795,88 @@ func NewJSAccountResourcesExceededError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSAccountResourcesExceededErr]
 }
 
+// NewJSAtomicPublishContainsDuplicateMessageError creates a new JSAtomicPublishContainsDuplicateMessageErr error: "atomic publish batch contains duplicate message id"
+func NewJSAtomicPublishContainsDuplicateMessageError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSAtomicPublishContainsDuplicateMessageErr]
+}
+
+// NewJSAtomicPublishDisabledError creates a new JSAtomicPublishDisabledErr error: "atomic publish is disabled"
+func NewJSAtomicPublishDisabledError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSAtomicPublishDisabledErr]
+}
+
+// NewJSAtomicPublishIncompleteBatchError creates a new JSAtomicPublishIncompleteBatchErr error: "atomic publish batch is incomplete"
+func NewJSAtomicPublishIncompleteBatchError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSAtomicPublishIncompleteBatchErr]
+}
+
+// NewJSAtomicPublishInvalidBatchIDError creates a new JSAtomicPublishInvalidBatchIDErr error: "atomic publish batch ID is invalid"
+func NewJSAtomicPublishInvalidBatchIDError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSAtomicPublishInvalidBatchIDErr]
+}
+
+// NewJSAtomicPublishMissingSeqError creates a new JSAtomicPublishMissingSeqErr error: "atomic publish sequence is missing"
+func NewJSAtomicPublishMissingSeqError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSAtomicPublishMissingSeqErr]
+}
+
+// NewJSAtomicPublishTooLargeBatchError creates a new JSAtomicPublishTooLargeBatchErrF error: "atomic publish batch is too large: {size}"
+func NewJSAtomicPublishTooLargeBatchError(size interface{}, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSAtomicPublishTooLargeBatchErrF]
+	args := e.toReplacerArgs([]interface{}{"{size}", size})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
+// NewJSAtomicPublishUnsupportedHeaderBatchError creates a new JSAtomicPublishUnsupportedHeaderBatchErr error: "atomic publish unsupported header used: {header}"
+func NewJSAtomicPublishUnsupportedHeaderBatchError(header interface{}, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSAtomicPublishUnsupportedHeaderBatchErr]
+	args := e.toReplacerArgs([]interface{}{"{header}", header})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSBadRequestError creates a new JSBadRequestErr error: "bad request"
 func NewJSBadRequestError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -899,6 +1069,22 @@ func NewJSConsumerCreateFilterSubjectMismatchError(opts ...ErrorOption) *ApiErro
 	return ApiErrors[JSConsumerCreateFilterSubjectMismatchErr]
 }
 
+// NewJSConsumerDeadLetterInvalidError creates a new JSConsumerDeadLetterInvalidErrF error: "consumer dead letter subject is invalid: {err}"
+func NewJSConsumerDeadLetterInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerDeadLetterInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSConsumerDeliverCycleError creates a new JSConsumerDeliverCycleErr error: "consumer deliver subject forms a cycle"
 func NewJSConsumerDeliverCycleError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1085,6 +1271,22 @@ func NewJSConsumerHBRequiresPushError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSConsumerHBRequiresPushErr]
 }
 
+// NewJSConsumerHeaderFilterInvalidError creates a new JSConsumerHeaderFilterInvalidErrF error: "consumer header filter is invalid: {err}"
+func NewJSConsumerHeaderFilterInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerHeaderFilterInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSConsumerInactiveThresholdExcessError creates a new JSConsumerInactiveThresholdExcess error: "consumer inactive threshold exceeds system limit of {limit}"
 func NewJSConsumerInactiveThresholdExcessError(limit interface{}, opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1337,6 +1539,22 @@ func NewJSConsumerOnMappedError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSConsumerOnMappedErr]
 }
 
+// NewJSConsumerOrderingKeyInvalidError creates a new JSConsumerOrderingKeyInvalidErrF error: "consumer ordering key is invalid: {err}"
+func NewJSConsumerOrderingKeyInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerOrderingKeyInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSConsumerOverlappingSubjectFiltersError creates a new JSConsumerOverlappingSubjectFilters error: "consumer subject filters cannot overlap"
 func NewJSConsumerOverlappingSubjectFiltersError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1347,6 +1565,38 @@ func NewJSConsumerOverlappingSubjectFiltersError(opts ...ErrorOption) *ApiError
 	return ApiErrors[JSConsumerOverlappingSubjectFilters]
 }
 
+// NewJSConsumerPartitionsInvalidError creates a new JSConsumerPartitionsInvalidErrF error: "consumer partitions are invalid: {err}"
+func NewJSConsumerPartitionsInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerPartitionsInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
+// NewJSConsumerPendingActionInvalidError creates a new JSConsumerPendingActionInvalidErrF error: "consumer pending action is invalid: {err}"
+func NewJSConsumerPendingActionInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerPendingActionInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSConsumerPriorityPolicyWithoutGroupError creates a new JSConsumerPriorityPolicyWithoutGroup error: "Setting PriorityPolicy requires at least one PriorityGroup to be set"
 func NewJSConsumerPriorityPolicyWithoutGroupError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1427,6 +1677,32 @@ func NewJSConsumerReplicasShouldMatchStreamError(opts ...ErrorOption) *ApiError
 	return ApiErrors[JSConsumerReplicasShouldMatchStream]
 }
 
+// NewJSConsumerResetInvalidError creates a new JSConsumerResetInvalidErrF error: "consumer reset request is invalid: {err}"
+func NewJSConsumerResetInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerResetInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
+// NewJSConsumerResetNotDurableError creates a new JSConsumerResetNotDurableErr error: "consumer reset requires a durable consumer"
+func NewJSConsumerResetNotDurableError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSConsumerResetNotDurableErr]
+}
+
 // NewJSConsumerSmallHeartbeatError creates a new JSConsumerSmallHeartbeatErr error: "consumer idle heartbeat needs to be >= 100ms"
 func NewJSConsumerSmallHeartbeatError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1559,6 +1835,66 @@ func NewJSMemoryResourcesExceededError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSMemoryResourcesExceededErr]
 }
 
+// NewJSMessageCounterBrokenError creates a new JSMessageCounterBrokenErr error: "message counter is broken"
+func NewJSMessageCounterBrokenError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSMessageCounterBrokenErr]
+}
+
+// NewJSMessageCounterDisabledError creates a new JSMessageCounterDisabledErr error: "message counters are disabled"
+func NewJSMessageCounterDisabledError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSMessageCounterDisabledErr]
+}
+
+// NewJSMessageIncrInvalidError creates a new JSMessageIncrInvalidErr error: "message counter increment is invalid"
+func NewJSMessageIncrInvalidError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSMessageIncrInvalidErr]
+}
+
+// NewJSMessageIncrMissingError creates a new JSMessageIncrMissingErr error: "message counter increment is missing"
+func NewJSMessageIncrMissingError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSMessageIncrMissingErr]
+}
+
+// NewJSMessageSchedulesDisabledError creates a new JSMessageSchedulesDisabledErr error: "message schedules are disabled"
+func NewJSMessageSchedulesDisabledError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSMessageSchedulesDisabledErr]
+}
+
+// NewJSMessageSchedulesInvalidError creates a new JSMessageSchedulesInvalidErr error: "invalid message schedule"
+func NewJSMessageSchedulesInvalidError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSMessageSchedulesInvalidErr]
+}
+
 // NewJSMessageTTLDisabledError creates a new JSMessageTTLDisabledErr error: "per-message TTL is disabled"
 func NewJSMessageTTLDisabledError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -2157,6 +2493,16 @@ func NewJSStreamInvalidExternalDeliverySubjError(prefix interface{}, opts ...Err
 	}
 }
 
+// NewJSStreamLeaderLeaseNotHeldError creates a new JSStreamLeaderLeaseNotHeldErr error: "stream leader does not hold a lease for linearizable reads"
+func NewJSStreamLeaderLeaseNotHeldError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSStreamLeaderLeaseNotHeldErr]
+}
+
 // NewJSStreamLimitsError creates a new JSStreamLimitsErrF error: "{err}"
 func NewJSStreamLimitsError(err error, opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -2403,6 +2749,22 @@ func NewJSStreamRollupFailedError(err error, opts ...ErrorOption) *ApiError {
 	}
 }
 
+// NewJSStreamSchemaValidationFailedError creates a new JSStreamSchemaValidationFailedF error: "message schema validation failed: {err}"
+func NewJSStreamSchemaValidationFailedError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSStreamSchemaValidationFailedF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSStreamSealedError creates a new JSStreamSealedErr error: "invalid operation on sealed stream"
 func NewJSStreamSealedError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -102,6 +102,17 @@ type JSConsumerPauseAdvisory struct {
 
 const JSConsumerPauseAdvisoryType = "io.nats.jetstream.advisory.v1.consumer_pause"
 
+// JSConsumerResetAdvisory indicates that a consumer was moved to a new starting position
+type JSConsumerResetAdvisory struct {
+	TypedEvent
+	Stream   string `json:"stream"`
+	Consumer string `json:"consumer"`
+	Sequence uint64 `json:"stream_seq"`
+	Domain   string `json:"domain,omitempty"`
+}
+
+const JSConsumerResetAdvisoryType = "io.nats.jetstream.advisory.v1.consumer_reset"
+
 // JSConsumerAckMetric is a metric published when a user acknowledges a message, the
 // number of these that will be published is dependent on SampleFrequency
 type JSConsumerAckMetric struct {
@@ -163,6 +174,23 @@ type JSConsumerDeliveryTerminatedAdvisory struct {
 // JSConsumerDeliveryTerminatedAdvisoryType is the schema type for JSConsumerDeliveryTerminatedAdvisory
 const JSConsumerDeliveryTerminatedAdvisoryType = "io.nats.jetstream.advisory.v1.terminated"
 
+// JSConsumerDeadLetterFailedAdvisory is an advisory informing that a message could not
+// be stored on the consumer's dead-letter subject, so the original was left in place
+type JSConsumerDeadLetterFailedAdvisory struct {
+	TypedEvent
+	Stream      string `json:"stream"`
+	Consumer    string `json:"consumer"`
+	ConsumerSeq uint64 `json:"consumer_seq"`
+	StreamSeq   uint64 `json:"stream_seq"`
+	Deliveries  uint64 `json:"deliveries"`
+	Subject     string `json:"subject"`
+	Reason      string `json:"reason"`
+	Domain      string `json:"domain,omitempty"`
+}
+
+// JSConsumerDeadLetterFailedAdvisoryType is the schema type for JSConsumerDeadLetterFailedAdvisory
+const JSConsumerDeadLetterFailedAdvisoryType = "io.nats.jetstream.advisory.v1.dead_letter_failed"
+
 // JSSnapshotCreateAdvisory is an advisory sent after a snapshot is successfully started
 type JSSnapshotCreateAdvisory struct {
 	TypedEvent
@@ -321,6 +349,42 @@ type JSServerOutOfSpaceAdvisory struct {
 	Domain   string `json:"domain,omitempty"`
 }
 
+// JSStreamCorruptAdvisoryType is sent when corrupt messages are found in a stream's store.
+const JSStreamCorruptAdvisoryType = "io.nats.jetstream.advisory.v1.stream_corrupt"
+
+// JSStreamCorruptAdvisory indicates that the integrity scrubber found corrupt messages in a stream's store.
+type JSStreamCorruptAdvisory struct {
+	TypedEvent
+	Server   string `json:"server"`
+	ServerID string `json:"server_id"`
+	Account  string `json:"account"`
+	Stream   string `json:"stream"`
+	Cluster  string `json:"cluster,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Block    uint32 `json:"block"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+	Error    string `json:"error"`
+	// Set when a repair from a healthy peer was requested.
+	Repair bool `json:"repair,omitempty"`
+}
+
+// JSStreamRebalanceAdvisoryType is sent when the rebalancer starts or finishes moving a stream.
+const JSStreamRebalanceAdvisoryType = "io.nats.jetstream.advisory.v1.stream_rebalance"
+
+// JSStreamRebalanceAdvisory indicates that the replica rebalancer is moving a stream and its consumers
+// from one server to another. Action is one of started, completed or failed.
+type JSStreamRebalanceAdvisory struct {
+	TypedEvent
+	Account string `json:"account"`
+	Stream  string `json:"stream"`
+	Cluster string `json:"cluster,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Action  string `json:"action"`
+}
+
 // JSServerRemovedAdvisoryType is sent when the server has been removed and JS disabled.
 const JSServerRemovedAdvisoryType = "io.nats.jetstream.advisory.v1.server_removed"
 
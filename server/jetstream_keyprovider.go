@@ -0,0 +1,171 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JetStreamKeyProvider returns the encryption key for an account's JetStream assets.
+// When configured, the streams and consumers of each account, along with their raft logs,
+// are encrypted with the account's own key instead of the server wide key.
+// Revoking the key for an account makes its data unreadable without affecting other accounts.
+// Keys are cached once requested, until revoked with Server.RevokeJetStreamAccountKey or
+// the configuration is reloaded. Assets already running keep using the key they were set up with.
+type JetStreamKeyProvider interface {
+	AccountKey(account string) (string, error)
+}
+
+// Extension for account key files used by the FileKeyProvider.
+const keyProviderFileExt = ".key"
+
+// Default time to wait for an ExecKeyProvider command.
+const defaultKeyProviderTimeout = 5 * time.Second
+
+var (
+	errAccountKeyUnavailable = errors.New("encryption key unavailable")
+	errAccountKeyEmpty       = errors.New("empty key")
+	errAccountKeyBadName     = errors.New("invalid account name")
+)
+
+// FileKeyProvider reads the key for an account from "<Dir>/<account>.key".
+// Removing the file revokes the key.
+type FileKeyProvider struct {
+	Dir string
+}
+
+// AccountKey returns the contents of the account's key file.
+func (p *FileKeyProvider) AccountKey(account string) (string, error) {
+	// Make sure we can not escape our directory.
+	if account == _EMPTY_ || strings.ContainsAny(account, `/\`) || account == "." || account == ".." {
+		return _EMPTY_, errAccountKeyBadName
+	}
+	buf, err := os.ReadFile(filepath.Join(p.Dir, account+keyProviderFileExt))
+	if err != nil {
+		return _EMPTY_, err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// ExecKeyProvider runs a command with the account name as its last argument
+// and uses its standard output as the key. A failing command revokes the key.
+type ExecKeyProvider struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+// AccountKey runs our command for the account and returns its output.
+func (p *ExecKeyProvider) AccountKey(account string) (string, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultKeyProviderTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, append(slices.Clone(p.Args), account)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != _EMPTY_ {
+			return _EMPTY_, fmt.Errorf("%v: %s", err, msg)
+		}
+		return _EMPTY_, err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// A cached key from the key provider. Only one request for it is made at a time.
+type jsAccountKeyEntry struct {
+	mu  sync.Mutex
+	key string
+}
+
+// RevokeJetStreamAccountKey drops the cached key for an account, so the key provider
+// is asked for it again the next time it is needed.
+func (s *Server) RevokeJetStreamAccountKey(account string) {
+	s.jsKeysMu.Lock()
+	delete(s.jsKeys, account)
+	s.jsKeysMu.Unlock()
+}
+
+// Drops all cached keys from the key provider.
+func (s *Server) clearJetStreamAccountKeys() {
+	s.jsKeysMu.Lock()
+	s.jsKeys = nil
+	s.jsKeysMu.Unlock()
+}
+
+// Returns the key used to encrypt the JetStream assets of an account.
+// This is the account's own key when a key provider is configured, otherwise the server key.
+// Since the key provider could be slow, this should not be called while holding any locks.
+func (s *Server) jsAccountKey(acc string) (string, error) {
+	opts := s.getOpts()
+	kp := opts.JetStreamKeyProvider
+	if kp == nil {
+		return opts.JetStreamKey, nil
+	}
+
+	s.jsKeysMu.Lock()
+	ke := s.jsKeys[acc]
+	if ke == nil {
+		if s.jsKeys == nil {
+			s.jsKeys = make(map[string]*jsAccountKeyEntry)
+		}
+		ke = &jsAccountKeyEntry{}
+		s.jsKeys[acc] = ke
+	}
+	s.jsKeysMu.Unlock()
+
+	ke.mu.Lock()
+	defer ke.mu.Unlock()
+	if ke.key != _EMPTY_ {
+		return ke.key, nil
+	}
+	// Failures are not cached, so restoring a key doesn't need a refresh.
+	key, err := kp.AccountKey(acc)
+	if err == nil && key == _EMPTY_ {
+		err = errAccountKeyEmpty
+	}
+	if err != nil {
+		return _EMPTY_, fmt.Errorf("%w for account %q: %v", errAccountKeyUnavailable, acc, err)
+	}
+	ke.key = key
+	return key, nil
+}
+
+// Returns the current and old key generators for JetStream assets of an account.
+// Old keys only apply to the server key, account keys are managed by the key provider.
+func (s *Server) jsAccountKeyGen(acc, info string) (keyGen, keyGen, error) {
+	key, err := s.jsAccountKey(acc)
+	if err != nil {
+		return nil, nil, err
+	}
+	var oldprf keyGen
+	if opts := s.getOpts(); opts.JetStreamKeyProvider == nil {
+		oldprf = s.jsKeyGen(opts.JetStreamOldKey, info)
+	}
+	return s.jsKeyGen(key, info), oldprf, nil
+}
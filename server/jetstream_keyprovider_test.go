@@ -0,0 +1,258 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+const keyProviderConfT = `
+	server_name: S1
+	listen: 127.0.0.1:-1
+	jetstream: {
+		store_dir: %q
+		key_provider: { %s }
+	}
+	accounts: {
+		A: { jetstream: enabled, users: [{user: a, password: a}] }
+		B: { jetstream: enabled, users: [{user: b, password: b}] }
+	}
+`
+
+func writeAccountKey(t *testing.T, dir, acc, key string) {
+	t.Helper()
+	require_NoError(t, os.WriteFile(filepath.Join(dir, acc+keyProviderFileExt), []byte(key+"\n"), 0600))
+}
+
+// Creates a stream with some messages for the given user.
+func setupKeyProviderStream(t *testing.T, s *Server, user string) {
+	t.Helper()
+	nc, js := jsClientConnect(t, s, nats.UserInfo(user, user))
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = js.Publish("foo", []byte("secret"))
+		require_NoError(t, err)
+	}
+	_, err = js.AddConsumer("TEST", &nats.ConsumerConfig{Durable: "C", AckPolicy: nats.AckExplicitPolicy})
+	require_NoError(t, err)
+}
+
+func checkKeyProviderStream(t *testing.T, s *Server, user string) {
+	t.Helper()
+	nc, js := jsClientConnect(t, s, nats.UserInfo(user, user))
+	defer nc.Close()
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 10)
+	m, err := js.GetMsg("TEST", 10)
+	require_NoError(t, err)
+	require_Equal(t, string(m.Data), "secret")
+	_, err = js.ConsumerInfo("TEST", "C")
+	require_NoError(t, err)
+}
+
+// Makes sure no stored file contains our message payload in the clear.
+func checkNoPlaintext(t *testing.T, dir string) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(buf, []byte("secret")) {
+			return fmt.Errorf("found plaintext in %q", path)
+		}
+		return nil
+	})
+	require_NoError(t, err)
+}
+
+func TestJetStreamAccountKeyProviderFile(t *testing.T) {
+	storeDir, keysDir := t.TempDir(), t.TempDir()
+	writeAccountKey(t, keysDir, "A", "akey")
+	writeAccountKey(t, keysDir, "B", "bkey")
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyProviderConfT, storeDir, fmt.Sprintf("type: file, dir: %q", keysDir))))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	setupKeyProviderStream(t, s, "a")
+	setupKeyProviderStream(t, s, "b")
+	s.Shutdown()
+	checkNoPlaintext(t, storeDir)
+
+	// Each account is encrypted with its own key.
+	for acc, keys := range map[string][2]string{"A": {"akey", "bkey"}, "B": {"bkey", "akey"}} {
+		ekey, err := os.ReadFile(filepath.Join(storeDir, JetStreamStoreDir, acc, streamsDir, "TEST", JetStreamMetaFileKey))
+		require_NoError(t, err)
+		_, err = openKeySeed(ekey, "TEST", ChaCha, s.jsKeyGen(keys[0], acc))
+		require_NoError(t, err)
+		_, err = openKeySeed(ekey, "TEST", ChaCha, s.jsKeyGen(keys[1], acc))
+		require_Error(t, err)
+	}
+
+	// Revoke the key for B, A is not affected.
+	require_NoError(t, os.Remove(filepath.Join(keysDir, "B"+keyProviderFileExt)))
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	checkKeyProviderStream(t, s, "a")
+
+	nc, js := jsClientConnect(t, s, nats.UserInfo("b", "b"))
+	defer nc.Close()
+	_, err := js.StreamInfo("TEST")
+	require_Error(t, err, nats.ErrStreamNotFound)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "OTHER", Subjects: []string{"bar"}})
+	require_Error(t, err)
+	require_True(t, strings.Contains(err.Error(), "error creating store"))
+	nc.Close()
+
+	// The data is still there, so restoring the key brings it back.
+	s.Shutdown()
+	writeAccountKey(t, keysDir, "B", "bkey")
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	checkKeyProviderStream(t, s, "a")
+	checkKeyProviderStream(t, s, "b")
+}
+
+func TestJetStreamAccountKeyProviderExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	storeDir, keysDir := t.TempDir(), t.TempDir()
+	writeAccountKey(t, keysDir, "A", "akey")
+	writeAccountKey(t, keysDir, "B", "bkey")
+
+	// The account name is passed as the last argument.
+	kp := fmt.Sprintf(`type: exec, command: "/bin/sh", args: ["-c", "cat \"$1/$2.key\"", "sh", %q], timeout: "2s"`, keysDir)
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyProviderConfT, storeDir, kp)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	setupKeyProviderStream(t, s, "a")
+	setupKeyProviderStream(t, s, "b")
+	s.Shutdown()
+	checkNoPlaintext(t, storeDir)
+
+	// A different key for B can not read its data.
+	writeAccountKey(t, keysDir, "B", "wrongkey")
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	checkKeyProviderStream(t, s, "a")
+	nc, js := jsClientConnect(t, s, nats.UserInfo("b", "b"))
+	defer nc.Close()
+	_, err := js.StreamInfo("TEST")
+	require_Error(t, err, nats.ErrStreamNotFound)
+}
+
+func TestJetStreamAccountKeyProviderCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	storeDir, keysDir := t.TempDir(), t.TempDir()
+	writeAccountKey(t, keysDir, "A", "akey")
+
+	// Every call to the provider is recorded.
+	calls := filepath.Join(keysDir, "A.calls")
+	kp := fmt.Sprintf(`type: exec, command: "/bin/sh", args: ["-c", "echo >> \"$1/$2.calls\"; cat \"$1/$2.key\"", "sh", %q], timeout: "2s"`, keysDir)
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyProviderConfT, storeDir, kp)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	checkCalls := func(expected int) {
+		t.Helper()
+		buf, err := os.ReadFile(calls)
+		require_NoError(t, err)
+		require_Equal(t, bytes.Count(buf, []byte("\n")), expected)
+	}
+
+	nc, js := jsClientConnect(t, s, nats.UserInfo("a", "a"))
+	defer nc.Close()
+	addStream := func(name string) {
+		t.Helper()
+		_, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{name}})
+		require_NoError(t, err)
+	}
+
+	// The key is only requested once per account.
+	for i := 0; i < 5; i++ {
+		addStream(fmt.Sprintf("S%d", i))
+	}
+	checkCalls(1)
+
+	// Once revoked it is requested again.
+	s.RevokeJetStreamAccountKey("A")
+	addStream("REVOKED")
+	addStream("CACHED")
+	checkCalls(2)
+
+	// A reload drops all cached keys.
+	require_NoError(t, s.Reload())
+	addStream("RELOADED")
+	checkCalls(3)
+}
+
+func TestJetStreamAccountKeyProviderConfig(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		kp   string
+		err  string
+	}{
+		{"unknown type", `type: vault`, "Unknown key provider type"},
+		{"file without dir", `type: file`, "requires a 'dir'"},
+		{"exec without command", `type: exec`, "requires a 'command'"},
+		{"bad args", `type: exec, command: "/bin/true", args: "foo"`, "Expected an array"},
+		{"bad timeout", `type: exec, command: "/bin/true", timeout: "soon"`, "error parsing timeout"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := createConfFile(t, []byte(fmt.Sprintf(keyProviderConfT, t.TempDir(), test.kp)))
+			_, err := ProcessConfigFile(conf)
+			require_Error(t, err)
+			require_True(t, strings.Contains(err.Error(), test.err))
+		})
+	}
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyProviderConfT, t.TempDir(), `type: exec, command: "/bin/echo", args: ["-n"], timeout: "1s"`)))
+	opts, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_Equal(t, opts.JetStreamKeyProvider.(*ExecKeyProvider).Command, "/bin/echo")
+
+	// File keys can not escape their directory.
+	fkp := &FileKeyProvider{Dir: t.TempDir()}
+	for _, acc := range []string{_EMPTY_, "..", "../A", `a\b`} {
+		_, err := fkp.AccountKey(acc)
+		require_Error(t, err, errAccountKeyBadName)
+	}
+}
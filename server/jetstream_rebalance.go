@@ -0,0 +1,336 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+const (
+	// Default maximum number of stream moves in progress at once.
+	defaultRebalanceMaxMoves = 1
+	// How long we wait for a move we requested to show up in the stream assignment.
+	rebalanceMoveStartTimeout = 30 * time.Second
+)
+
+// Actions reported in stream rebalance advisories.
+const (
+	rebalanceStarted   = "started"
+	rebalanceCompleted = "completed"
+	rebalanceFailed    = "failed"
+)
+
+// RebalanceStatus reports on the replica rebalancer, which runs on the meta leader.
+type RebalanceStatus struct {
+	LastRun   time.Time              `json:"last_run"`
+	Started   uint64                 `json:"started"`
+	Completed uint64                 `json:"completed"`
+	Failed    uint64                 `json:"failed"`
+	Moves     []*StreamRebalanceMove `json:"moves,omitempty"`
+}
+
+// StreamRebalanceMove is a stream move requested by the rebalancer that has not finished yet.
+type StreamRebalanceMove struct {
+	Account string    `json:"account"`
+	Stream  string    `json:"stream"`
+	Cluster string    `json:"cluster"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Started time.Time `json:"started"`
+	// Set once the new replica was added to the stream's peers.
+	Moving bool `json:"moving,omitempty"`
+	// The peers we are moving from and to.
+	fromPeer, toPeer string
+}
+
+// A stream move we decided on, requested once we released the lock.
+type plannedMove struct {
+	move  *StreamRebalanceMove
+	cfg   *StreamConfig
+	peers []string
+}
+
+// Returns a copy of the rebalancer status if we are running it.
+func (js *jetStream) rebalanceStatus() *RebalanceStatus {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	if js.rebalance == nil {
+		return nil
+	}
+	rs := *js.rebalance
+	rs.Moves = make([]*StreamRebalanceMove, 0, len(js.rebalance.Moves))
+	for _, m := range js.rebalance.Moves {
+		cm := *m
+		rs.Moves = append(rs.Moves, &cm)
+	}
+	return &rs
+}
+
+// Periodically move replicas onto underused servers when we are the meta leader.
+func (s *Server) runJetStreamRebalancer(js *jetStream, interval time.Duration) {
+	defer s.grWG.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.quitCh:
+			return
+		case <-t.C:
+		}
+		// Stop if JetStream was disabled, a new rebalancer is started if re-enabled.
+		if s.getJetStream() != js || !js.isEnabled() {
+			return
+		}
+		s.rebalanceJetStream(js)
+	}
+}
+
+// Move stream replicas from servers that host more than their share of assets to servers that
+// host less, such as servers that just joined the cluster. Assets are the stream and consumer
+// replicas on a server, and consumers move along with their stream. This uses the same peer
+// selection and move machinery as a stream move requested through the API, so placement tags,
+// unique tags and exclusions are honored. Only the configured number of moves are in progress
+// at any time, including moves that were not started by us.
+func (s *Server) rebalanceJetStream(js *jetStream) {
+	opts := s.getOpts().JetStreamRebalance
+	maxMoves := opts.MaxMoves
+	if maxMoves <= 0 {
+		maxMoves = defaultRebalanceMaxMoves
+	}
+
+	js.mu.Lock()
+	cc := js.cluster
+	if cc == nil || cc.meta == nil || !cc.isLeader() {
+		// Our status is only meaningful while we are the meta leader.
+		js.rebalance = nil
+		js.mu.Unlock()
+		return
+	}
+	if js.rebalance == nil {
+		js.rebalance = &RebalanceStatus{}
+	}
+	rs := js.rebalance
+	rs.LastRun = time.Now().UTC()
+
+	// Check on the moves we requested.
+	var completed, failed []*StreamRebalanceMove
+	var inProgress int
+	pending := make(map[*StreamRebalanceMove]int)
+	moves := rs.Moves[:0]
+	for _, m := range rs.Moves {
+		sa := js.streamAssignment(m.Account, m.Stream)
+		switch {
+		case sa == nil:
+			failed = append(failed, m)
+			continue
+		case len(sa.Group.Peers) > sa.Config.Replicas:
+			m.Moving = true
+		case !slices.Contains(sa.Group.Peers, m.fromPeer):
+			completed = append(completed, m)
+			continue
+		case m.Moving || time.Since(m.Started) > rebalanceMoveStartTimeout:
+			// Cancelled, or never started.
+			failed = append(failed, m)
+			continue
+		default:
+			// Not applied yet, so not counted below.
+			inProgress++
+			pending[m] = 1 + len(sa.consumers)
+		}
+		moves = append(moves, m)
+	}
+	rs.Moves = moves
+	rs.Completed += uint64(len(completed))
+	rs.Failed += uint64(len(failed))
+
+	// Count all moves in progress, and the assets hosted by each server.
+	tracked := make(map[string]struct{}, len(rs.Moves))
+	for _, m := range rs.Moves {
+		tracked[m.Account+tsep+m.Stream] = struct{}{}
+	}
+	assets := make(map[string]int)
+	for _, asa := range cc.streams {
+		for _, sa := range asa {
+			peers := sa.Group.Peers
+			// Count moving streams where they end up, peers are dropped from the left.
+			if r := sa.Config.Replicas; len(peers) > r {
+				inProgress++
+				peers = peers[len(peers)-r:]
+			}
+			for _, peer := range peers {
+				assets[peer] += 1 + len(sa.consumers)
+			}
+			for _, peer := range sa.Group.Learners {
+				assets[peer] += 1 + len(sa.consumers)
+			}
+		}
+	}
+
+	for m, weight := range pending {
+		assets[m.fromPeer] -= weight
+		assets[m.toPeer] += weight
+	}
+
+	var planned []*plannedMove
+	if avail := maxMoves - inProgress; avail > 0 {
+		planned = s.planRebalanceMoves(cc, assets, tracked, opts.Threshold, avail)
+	}
+	for _, pm := range planned {
+		rs.Moves = append(rs.Moves, pm.move)
+		rs.Started++
+	}
+	js.mu.Unlock()
+
+	for _, m := range completed {
+		s.Noticef("Rebalancing stream '%s > %s' from %q to %q completed", m.Account, m.Stream, m.From, m.To)
+		s.sendRebalanceAdvisory(m, rebalanceCompleted)
+	}
+	for _, m := range failed {
+		s.Warnf("Rebalancing stream '%s > %s' from %q to %q failed", m.Account, m.Stream, m.From, m.To)
+		s.sendRebalanceAdvisory(m, rebalanceFailed)
+	}
+
+	for _, pm := range planned {
+		m := pm.move
+		acc, err := s.LookupAccount(m.Account)
+		if err != nil {
+			continue
+		}
+		s.Noticef("Rebalancing stream '%s > %s' from %q to %q", m.Account, m.Stream, m.From, m.To)
+		s.sendRebalanceAdvisory(m, rebalanceStarted)
+		ci := &ClientInfo{Account: m.Account, Cluster: m.Cluster}
+		subject := fmt.Sprintf(JSApiServerStreamMoveT, m.Account, m.Stream)
+		// We always have peers so this does not block on a callout.
+		s.jsClusteredStreamUpdateRequest(ci, acc, subject, _EMPTY_, nil, pm.cfg, pm.peers, false)
+	}
+}
+
+// Pick up to avail streams to move from overloaded to underused servers, per cluster.
+// Lock should be held.
+func (s *Server) planRebalanceMoves(cc *jetStreamCluster, assets map[string]int, tracked map[string]struct{}, threshold, avail int) []*plannedMove {
+	clusters := make(map[string][]string)
+	for _, p := range cc.meta.Peers() {
+		si, ok := s.nodeToInfo.Load(p.ID)
+		if !ok || si == nil {
+			continue
+		}
+		ni := si.(nodeInfo)
+		if ni.offline || !ni.js || ni.cfg == nil || ni.stats == nil || ni.tags.Contains(jsExcludePlacement) {
+			continue
+		}
+		clusters[ni.cluster] = append(clusters[ni.cluster], p.ID)
+	}
+
+	var planned []*plannedMove
+	for cluster, nodes := range clusters {
+		if len(nodes) < 2 {
+			continue
+		}
+		var total int
+		for _, node := range nodes {
+			total += assets[node]
+		}
+		// Each server should not host more than the average, rounded up.
+		avg := (total + len(nodes) - 1) / len(nodes)
+
+		var over []string
+		for _, node := range nodes {
+			if assets[node] > avg+threshold {
+				over = append(over, node)
+			}
+		}
+		slices.SortFunc(over, func(i, j string) int { return assets[j] - assets[i] })
+
+		for _, from := range over {
+			for accName, asa := range cc.streams {
+				for _, sa := range asa {
+					if len(planned) >= avail || assets[from] <= avg+threshold {
+						break
+					}
+					rg := sa.Group
+					if rg.Cluster != cluster || len(rg.Peers) != sa.Config.Replicas || !slices.Contains(rg.Peers, from) {
+						continue
+					}
+					if _, ok := tracked[accName+tsep+sa.Config.Name]; ok {
+						continue
+					}
+					// Only pick from servers below the average.
+					ignore := slices.Clone(rg.Learners)
+					for _, node := range nodes {
+						if assets[node] >= avg {
+							ignore = append(ignore, node)
+						}
+					}
+					// The peer we move from goes first, the move drops peers from the left.
+					existing := append([]string{from}, slices.DeleteFunc(slices.Clone(rg.Peers), func(p string) bool { return p == from })...)
+					cfg := sa.Config.clone()
+					peers, err := cc.selectPeerGroup(cfg.Replicas+1, cluster, cfg, existing, 1, ignore)
+					if err != nil || len(peers) <= cfg.Replicas {
+						continue
+					}
+					to, weight := peers[cfg.Replicas], 1+len(sa.consumers)
+					// Make sure we do not just move the imbalance around.
+					if assets[to]+weight >= assets[from] {
+						continue
+					}
+					assets[from] -= weight
+					assets[to] += weight
+					tracked[accName+tsep+sa.Config.Name] = struct{}{}
+					planned = append(planned, &plannedMove{
+						move: &StreamRebalanceMove{
+							Account:  accName,
+							Stream:   sa.Config.Name,
+							Cluster:  cluster,
+							From:     s.serverNameForNode(from),
+							To:       s.serverNameForNode(to),
+							Started:  time.Now().UTC(),
+							fromPeer: from,
+							toPeer:   to,
+						},
+						cfg:   cfg,
+						peers: peers,
+					})
+				}
+			}
+		}
+		if len(planned) >= avail {
+			break
+		}
+	}
+	return planned
+}
+
+// Send an advisory to the system account about a stream move of the rebalancer.
+func (s *Server) sendRebalanceAdvisory(m *StreamRebalanceMove, action string) {
+	s.publishAdvisory(nil, JSAdvisoryStreamRebalancePre+"."+m.Stream, &JSStreamRebalanceAdvisory{
+		TypedEvent: TypedEvent{
+			Type: JSStreamRebalanceAdvisoryType,
+			ID:   nuid.Next(),
+			Time: time.Now().UTC(),
+		},
+		Account: m.Account,
+		Stream:  m.Stream,
+		Cluster: m.Cluster,
+		Domain:  s.getOpts().JetStreamDomain,
+		From:    m.From,
+		To:      m.To,
+		Action:  action,
+	})
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestJetStreamClusterRebalanceOntoNewServer(t *testing.T) {
+	tmpl := strings.Replace(jsClusterTempl, "store_dir:", `rebalance: {interval: "1h", max_moves: 2}, store_dir:`, 1)
+	c := createJetStreamClusterWithTemplate(t, tmpl, "R3S", 3)
+	defer c.shutdown()
+
+	rb := c.randomServer().getOpts().JetStreamRebalance
+	require_Equal(t, rb.Interval, time.Hour)
+	require_Equal(t, rb.MaxMoves, 2)
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("S%d", i)
+		_, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{name}, Replicas: 3})
+		require_NoError(t, err)
+		_, err = js.AddConsumer(name, &nats.ConsumerConfig{Durable: "C", AckPolicy: nats.AckExplicitPolicy})
+		require_NoError(t, err)
+		for j := 0; j < 10; j++ {
+			_, err = js.Publish(name, []byte("OK"))
+			require_NoError(t, err)
+		}
+	}
+
+	// A new server joins and stays empty.
+	ns := c.addInNewServer()
+	c.waitOnPeerCount(4)
+	ml := c.leader()
+	checkFor(t, 10*time.Second, 250*time.Millisecond, func() error {
+		if si, ok := ml.nodeToInfo.Load(ns.NodeName()); !ok || si.(nodeInfo).stats == nil {
+			return errors.New("no stats for our new server yet")
+		}
+		return nil
+	})
+
+	sysnc, err := nats.Connect(ml.ClientURL(), nats.UserInfo("admin", "s3cr3t!"))
+	require_NoError(t, err)
+	defer sysnc.Close()
+	sub, err := sysnc.SubscribeSync(JSAdvisoryStreamRebalancePre + ".>")
+	require_NoError(t, err)
+	require_NoError(t, sysnc.Flush())
+
+	hosted := func(s *Server) []string {
+		mjs := ml.getJetStream()
+		mjs.mu.RLock()
+		defer mjs.mu.RUnlock()
+		var streams []string
+		for _, sa := range mjs.cluster.streams[globalAccountName] {
+			if sa.Group.isMember(s.NodeName()) && len(sa.Group.Peers) == sa.Config.Replicas {
+				streams = append(streams, sa.Config.Name)
+				for _, ca := range sa.consumers {
+					require_True(t, ca.Group.isMember(s.NodeName()))
+				}
+			}
+		}
+		return streams
+	}
+	require_Len(t, len(hosted(ns)), 0)
+
+	// Only two moves are in progress at once.
+	if ml == ns {
+		opts := ml.getOpts().Clone()
+		opts.JetStreamRebalance.MaxMoves = 2
+		ml.setOpts(opts)
+	}
+	mjs := ml.getJetStream()
+	ml.rebalanceJetStream(mjs)
+	rs := mjs.rebalanceStatus()
+	require_True(t, rs != nil)
+	require_Equal(t, rs.Started, 2)
+	require_Len(t, len(rs.Moves), 2)
+	for _, m := range rs.Moves {
+		require_Equal(t, m.To, ns.Name())
+	}
+	ml.rebalanceJetStream(mjs)
+	require_Equal(t, mjs.rebalanceStatus().Started, 2)
+
+	for i := 0; i < 2; i++ {
+		msg, err := sub.NextMsg(5 * time.Second)
+		require_NoError(t, err)
+		var adv JSStreamRebalanceAdvisory
+		require_NoError(t, json.Unmarshal(msg.Data, &adv))
+		require_Equal(t, adv.Type, JSStreamRebalanceAdvisoryType)
+		require_Equal(t, adv.Action, "started")
+		require_Equal(t, adv.To, ns.Name())
+	}
+
+	// Keep going until our new server hosts its share, the streams and their consumers move together.
+	checkFor(t, 60*time.Second, time.Second, func() error {
+		ml.rebalanceJetStream(mjs)
+		if rs := mjs.rebalanceStatus(); rs.Completed != 3 || len(rs.Moves) != 0 {
+			return fmt.Errorf("rebalance not done yet: %+v", rs)
+		}
+		return nil
+	})
+	streams := hosted(ns)
+	require_Len(t, len(streams), 3)
+	for _, s := range c.servers {
+		require_Len(t, len(hosted(s)), 3)
+	}
+	require_Equal(t, mjs.rebalanceStatus().Failed, 0)
+
+	// All replicas are still intact.
+	for _, name := range streams {
+		c.waitOnStreamLeader(globalAccountName, name)
+		si, err := js.StreamInfo(name)
+		require_NoError(t, err)
+		require_Equal(t, si.State.Msgs, 10)
+		require_Len(t, len(si.Cluster.Replicas), 2)
+	}
+
+	var completed int
+	for completed < 3 {
+		msg, err := sub.NextMsg(5 * time.Second)
+		require_NoError(t, err)
+		var adv JSStreamRebalanceAdvisory
+		require_NoError(t, json.Unmarshal(msg.Data, &adv))
+		require_NotEqual(t, adv.Action, "failed")
+		if adv.Action == "completed" {
+			require_True(t, slices.Contains(streams, adv.Stream))
+			completed++
+		}
+	}
+
+	// Progress is visible in /jsz on the meta leader.
+	jsz, err := ml.Jsz(nil)
+	require_NoError(t, err)
+	require_True(t, jsz.Rebalance != nil)
+	require_Equal(t, jsz.Rebalance.Completed, 3)
+}
@@ -0,0 +1,232 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Default number of key files and message blocks rotated per second during an online key rotation.
+	defaultKeyRotationRate = 500
+	// Default number of message block bytes re-encrypted per second during an online key rotation.
+	defaultKeyRotationByteRate = 32 * 1024 * 1024
+)
+
+// JSKeyRotateRequest starts an online rotation of the JetStream encryption key on a server.
+// The new key needs to be placed in the server configuration before the next restart,
+// ideally with the prior key as the old key in case the rotation has not completed.
+type JSKeyRotateRequest struct {
+	Key string `json:"key"`
+	// Rate is the number of key files and message blocks rotated per second.
+	Rate int `json:"rate,omitempty"`
+}
+
+// KeyRotationStatus reports the progress of an online JetStream encryption key rotation.
+type KeyRotationStatus struct {
+	Started    time.Time  `json:"started"`
+	Completed  *time.Time `json:"completed,omitempty"`
+	Stores     int        `json:"stores"`
+	StoresDone int        `json:"stores_done"`
+	KeyFiles   int        `json:"key_files"`
+	Blocks     int        `json:"blocks"`
+	Error      string     `json:"error,omitempty"`
+}
+
+var (
+	errKeyRotationNoKey      = errors.New("jetstream key rotation requires a new key")
+	errKeyRotationNotEnabled = errors.New("jetstream encryption is not enabled")
+	errKeyRotationInProgress = errors.New("jetstream key rotation already in progress")
+)
+
+// A store to rotate, along with the info used to derive its key.
+type keyRotationStore struct {
+	fs   *fileStore
+	info string
+}
+
+// Returns a copy of the status of the last key rotation, if any.
+func (js *jetStream) keyRotationStatus() *KeyRotationStatus {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	if js.krs == nil {
+		return nil
+	}
+	krs := *js.krs
+	return &krs
+}
+
+// Returns whether a key rotation is still running.
+func (js *jetStream) keyRotationInProgress() bool {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	return js.krs != nil && js.krs.Completed == nil
+}
+
+// Start rotating the JetStream encryption key in the background.
+// All file based streams, their consumers and raft logs are rotated,
+// unless they are encrypted with account keys from a key provider.
+func (s *Server) rotateJetStreamKey(key string, rps int) (*KeyRotationStatus, error) {
+	js := s.getJetStream()
+	if js == nil || !js.isEnabled() {
+		return nil, NewJSNotEnabledError()
+	}
+	if key == _EMPTY_ {
+		return nil, errKeyRotationNoKey
+	}
+	if rps <= 0 {
+		rps = defaultKeyRotationRate
+	}
+
+	js.mu.Lock()
+	if js.krs != nil && js.krs.Completed == nil {
+		js.mu.Unlock()
+		return nil, errKeyRotationInProgress
+	}
+	// Make sure new assets are created with the new key from here on.
+	opts := s.getOpts()
+	if opts.JetStreamKey == _EMPTY_ {
+		js.mu.Unlock()
+		return nil, errKeyRotationNotEnabled
+	}
+	if opts.JetStreamKey != key {
+		nopts := opts.Clone()
+		nopts.JetStreamOldKey, nopts.JetStreamKey = opts.JetStreamKey, key
+		s.setOpts(nopts)
+	}
+
+	// With a key provider, account assets use their own keys and only the meta layer uses ours.
+	accountKeys := opts.JetStreamKeyProvider != nil
+
+	var stores []keyRotationStore
+	for _, jsa := range js.accounts {
+		if accountKeys {
+			continue
+		}
+		acc := jsa.acc()
+		for _, mset := range acc.streams() {
+			mset.mu.RLock()
+			fs, ok := mset.store.(*fileStore)
+			mset.mu.RUnlock()
+			if ok {
+				stores = append(stores, keyRotationStore{fs, acc.Name})
+			}
+		}
+	}
+	js.krs = &KeyRotationStatus{Started: time.Now().UTC()}
+	js.mu.Unlock()
+
+	// Raft logs use the group name.
+	s.rnMu.RLock()
+	for group, n := range s.raftNodes {
+		if accountKeys && group != defaultMetaGroupName {
+			continue
+		}
+		if rn, ok := n.(*raft); ok {
+			if fs, ok := rn.wal.(*fileStore); ok {
+				stores = append(stores, keyRotationStore{fs, group})
+			}
+		}
+	}
+	s.rnMu.RUnlock()
+
+	js.mu.Lock()
+	js.krs.Stores = len(stores)
+	krs := *js.krs
+	js.mu.Unlock()
+
+	s.Noticef("Rotating JetStream encryption key for %d stores", len(stores))
+	if !s.startGoRoutine(func() {
+		defer s.grWG.Done()
+		s.runKeyRotation(js, key, stores, rps)
+	}) {
+		return nil, ErrServerNotRunning
+	}
+	return &krs, nil
+}
+
+// Rotates the key for each store, throttled to rps key files and message blocks per second,
+// and to defaultKeyRotationByteRate for the message blocks that are re-encrypted.
+func (s *Server) runKeyRotation(js *jetStream, key string, stores []keyRotationStore, rps int) {
+	limiter := rate.NewLimiter(rate.Limit(rps), 1)
+	blimiter := rate.NewLimiter(rate.Limit(defaultKeyRotationByteRate), defaultKeyRotationByteRate)
+	wait := func(n int) error {
+		d := limiter.Reserve().Delay()
+		if n > 0 {
+			d = max(d, blimiter.ReserveN(time.Now(), min(n, defaultKeyRotationByteRate)).Delay())
+		}
+		if d > 0 {
+			select {
+			case <-time.After(d):
+			case <-s.quitCh:
+				return ErrServerNotRunning
+			}
+		}
+		return nil
+	}
+
+	var failed bool
+	for _, st := range stores {
+		n, nb, err := st.fs.rotateEncryptionKey(s.jsKeyGen(key, st.info), wait)
+		js.mu.Lock()
+		js.krs.KeyFiles += n
+		js.krs.Blocks += nb
+		js.krs.StoresDone++
+		if err != nil && err != ErrStoreClosed && js.krs.Error == _EMPTY_ {
+			js.krs.Error = err.Error()
+		}
+		js.mu.Unlock()
+		if err == ErrServerNotRunning {
+			return
+		}
+		if err != nil && err != ErrStoreClosed {
+			failed = true
+			s.Warnf("Error rotating JetStream encryption key for '%s > %s': %v", st.info, st.fs.cfg.Name, err)
+		}
+	}
+
+	js.mu.Lock()
+	now := time.Now().UTC()
+	js.krs.Completed = &now
+	krs := *js.krs
+	js.mu.Unlock()
+
+	if failed {
+		s.Warnf("JetStream encryption key rotation completed with errors, %d key files and %d message blocks rotated", krs.KeyFiles, krs.Blocks)
+	} else {
+		s.Noticef("JetStream encryption key rotation completed, %d key files and %d message blocks rotated", krs.KeyFiles, krs.Blocks)
+	}
+}
+
+// Handles system requests to rotate the JetStream encryption key.
+func (s *Server) jsKeyRotate(_ *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+	if !s.eventsRunning() {
+		return
+	}
+
+	var req JSKeyRotateRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		s.sys.client.Errorf("Error unmarshalling key rotate request: %v", err)
+		return
+	}
+
+	optz := &EventFilterOptions{}
+	s.zReq(c, reply, hdr, msg, optz, optz, func() (any, error) {
+		return s.rotateJetStreamKey(req.Key, req.Rate)
+	})
+}
@@ -0,0 +1,269 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const keyRotationConfT = `
+	server_name: S1
+	listen: 127.0.0.1:-1
+	jetstream: {
+		key: %q,
+		%s
+		store_dir: %q
+	}
+	accounts: {
+		A: { jetstream: enabled, users: [{user: a, password: a}] }
+		SYS: { users: [{user: sys, password: sys}] }
+	}
+	system_account: SYS
+	no_auth_user: a
+`
+
+// Creates a stream and durable consumer with some state on the given server.
+func setupKeyRotationStream(t *testing.T, s *Server) {
+	t.Helper()
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = js.Publish("foo", []byte("secret"))
+		require_NoError(t, err)
+	}
+	sub, err := js.PullSubscribe("foo", "C")
+	require_NoError(t, err)
+	msgs, err := sub.Fetch(10)
+	require_NoError(t, err)
+	for _, m := range msgs {
+		require_NoError(t, m.AckSync())
+	}
+}
+
+// Checks the stream and consumer created by setupKeyRotationStream are intact.
+func checkKeyRotationStream(t *testing.T, s *Server, expected uint64) {
+	t.Helper()
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, expected)
+	for _, seq := range []uint64{1, expected} {
+		m, err := js.GetMsg("TEST", seq)
+		require_NoError(t, err)
+		require_Equal(t, string(m.Data), "secret")
+	}
+	ci, err := js.ConsumerInfo("TEST", "C")
+	require_NoError(t, err)
+	require_Equal(t, ci.AckFloor.Stream, 10)
+}
+
+// Waits for the key rotation to complete and returns its status.
+func waitForKeyRotation(t *testing.T, s *Server) *KeyRotationStatus {
+	t.Helper()
+	var krs *KeyRotationStatus
+	checkFor(t, 10*time.Second, 50*time.Millisecond, func() error {
+		jsz, err := s.Jsz(nil)
+		if err != nil {
+			return err
+		}
+		if krs = jsz.KeyRotation; krs == nil || krs.Completed == nil {
+			return fmt.Errorf("key rotation not completed")
+		}
+		return nil
+	})
+	return krs
+}
+
+func TestJetStreamKeyRotationOnReload(t *testing.T) {
+	storeDir := t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyRotationConfT, "firstkey", _EMPTY_, storeDir)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	setupKeyRotationStream(t, s)
+
+	// Changing the key on reload rotates it online.
+	reloadUpdateConfig(t, s, conf, fmt.Sprintf(keyRotationConfT, "secondkey", `prev_key: "firstkey",`, storeDir))
+	krs := waitForKeyRotation(t, s)
+	require_Equal(t, krs.Error, _EMPTY_)
+	require_Equal(t, krs.StoresDone, krs.Stores)
+	// Stream meta and the consumer, and at least one block.
+	require_True(t, krs.KeyFiles >= 2)
+	require_True(t, krs.Blocks >= 1)
+
+	// We kept working and new data uses the new key.
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+	_, err := js.Publish("foo", []byte("secret"))
+	require_NoError(t, err)
+	checkKeyRotationStream(t, s, 101)
+	nc.Close()
+	s.Shutdown()
+
+	// Only the new key is needed from here on.
+	require_NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf(keyRotationConfT, "secondkey", _EMPTY_, storeDir)), 0666))
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+	checkKeyRotationStream(t, s, 101)
+
+	// Encryption can not be turned off with a reload.
+	require_NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf(keyRotationConfT, _EMPTY_, _EMPTY_, storeDir)), 0666))
+	require_Error(t, s.Reload())
+}
+
+func TestJetStreamKeyRotationReloadWhileInProgress(t *testing.T) {
+	storeDir := t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyRotationConfT, "firstkey", _EMPTY_, storeDir)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	setupKeyRotationStream(t, s)
+
+	// Pretend a rotation started through the system API is still running.
+	js := s.getJetStream()
+	js.mu.Lock()
+	js.krs = &KeyRotationStatus{Started: time.Now().UTC()}
+	js.mu.Unlock()
+
+	// A reload with another key is rejected, and we keep creating assets with our key.
+	require_NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf(keyRotationConfT, "secondkey", `prev_key: "firstkey",`, storeDir)), 0666))
+	require_Error(t, s.Reload())
+	require_Equal(t, s.getOpts().JetStreamKey, "firstkey")
+
+	// Should a rotation start in between, the key is rolled back when ours fails to start.
+	opts := s.getOpts().Clone()
+	opts.JetStreamKey = "secondkey"
+	s.setOpts(opts)
+	(&jetStreamKeyOption{oldValue: "firstkey", newValue: "secondkey"}).Apply(s)
+	require_Equal(t, s.getOpts().JetStreamKey, "firstkey")
+}
+
+func TestJetStreamKeyRotationSystemAPI(t *testing.T) {
+	storeDir := t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(keyRotationConfT, "firstkey", _EMPTY_, storeDir)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	setupKeyRotationStream(t, s)
+
+	nc, err := nats.Connect(s.ClientURL(), nats.UserInfo("sys", "sys"))
+	require_NoError(t, err)
+	defer nc.Close()
+
+	rotate := func(req *JSKeyRotateRequest) *ServerAPIResponse {
+		t.Helper()
+		b, err := json.Marshal(req)
+		require_NoError(t, err)
+		msg, err := nc.Request(fmt.Sprintf(serverJSKeyRotateReqSubj, s.ID()), b, time.Second)
+		require_NoError(t, err)
+		var resp ServerAPIResponse
+		require_NoError(t, json.Unmarshal(msg.Data, &resp))
+		return &resp
+	}
+
+	// Throttled to a key file or block per second, so still in progress for the second request.
+	resp := rotate(&JSKeyRotateRequest{Key: "secondkey", Rate: 1})
+	require_True(t, resp.Error == nil)
+	resp = rotate(&JSKeyRotateRequest{Key: "thirdkey"})
+	require_NotNil(t, resp.Error)
+	require_Contains(t, resp.Error.Description, errKeyRotationInProgress.Error())
+
+	krs := waitForKeyRotation(t, s)
+	require_Equal(t, krs.Error, _EMPTY_)
+	require_True(t, krs.KeyFiles >= 2)
+	require_True(t, krs.Blocks >= 1)
+	checkKeyRotationStream(t, s, 100)
+	nc.Close()
+	s.Shutdown()
+
+	require_NoError(t, os.WriteFile(conf, []byte(fmt.Sprintf(keyRotationConfT, "secondkey", _EMPTY_, storeDir)), 0666))
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+	checkKeyRotationStream(t, s, 100)
+}
+
+func TestJetStreamClusterKeyRotation(t *testing.T) {
+	c := createJetStreamClusterWithTemplate(t, jsClusterEncryptedTempl, "JSC", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	for i := 0; i < 50; i++ {
+		_, err = js.Publish("foo", []byte("secret"))
+		require_NoError(t, err)
+	}
+	_, err = js.AddConsumer("TEST", &nats.ConsumerConfig{Durable: "C", AckPolicy: nats.AckExplicitPolicy, Replicas: 3})
+	require_NoError(t, err)
+
+	// Each server rotates its own key, including the raft logs.
+	for _, s := range c.servers {
+		_, err := s.rotateJetStreamKey("n3wk3y!", 0)
+		require_NoError(t, err)
+	}
+	for _, s := range c.servers {
+		krs := waitForKeyRotation(t, s)
+		require_Equal(t, krs.Error, _EMPTY_)
+		// Stream plus the meta, stream and consumer raft logs.
+		require_True(t, krs.Stores >= 4)
+	}
+	_, err = js.Publish("foo", []byte("secret"))
+	require_NoError(t, err)
+	nc.Close()
+
+	// Restart with only the new key.
+	for i, s := range c.servers {
+		s.Shutdown()
+		s.WaitForShutdown()
+		buf, err := os.ReadFile(c.opts[i].ConfigFile)
+		require_NoError(t, err)
+		buf = bytes.Replace(buf, []byte(`key: "s3cr3t!"`), []byte(`key: "n3wk3y!"`), 1)
+		require_NoError(t, os.WriteFile(c.opts[i].ConfigFile, buf, 0640))
+	}
+	c.restartAll()
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+	c.waitOnConsumerLeader(globalAccountName, "TEST", "C")
+
+	nc, js = jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		si, err := js.StreamInfo("TEST")
+		if err != nil {
+			return err
+		}
+		if si.State.Msgs != 51 {
+			return fmt.Errorf("expected 51 msgs, got %d", si.State.Msgs)
+		}
+		return nil
+	})
+	m, err := js.GetMsg("TEST", 51)
+	require_NoError(t, err)
+	require_Equal(t, string(m.Data), "secret")
+}
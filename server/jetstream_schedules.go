@@ -0,0 +1,309 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server/thw"
+)
+
+// How long to wait before retrying the release of a held message that could not be published.
+const scheduleRetryInterval = time.Second
+
+// Headers that only apply to the original publish of a held message, and are removed from its released copy.
+var scheduleReleaseRemovedHeaders = []string{
+	JSMsgDeliverAt,
+	JSMsgDelay,
+	JSMsgId,
+	JSExpectedStream,
+	JSExpectedLastSeq,
+	JSExpectedLastSubjSeq,
+	JSExpectedLastSubjSeqSubj,
+	JSExpectedLastMsgId,
+}
+
+// isScheduledMsg returns whether the headers hold back the message until a later time.
+func isScheduledMsg(hdr []byte) bool {
+	if len(hdr) == 0 {
+		return false
+	}
+	return len(getHeader(JSMsgDeliverAt, hdr)) > 0 || len(getHeader(JSMsgDelay, hdr)) > 0
+}
+
+// getMessageSchedule returns when a held message is due in unix nanoseconds,
+// with a delay being relative to the timestamp the message was stored with.
+// Returns zero if the message is not held.
+func getMessageSchedule(hdr []byte, ts int64) (int64, error) {
+	at, delay := getHeader(JSMsgDeliverAt, hdr), getHeader(JSMsgDelay, hdr)
+	switch {
+	case len(at) == 0 && len(delay) == 0:
+		return 0, nil
+	case len(at) > 0 && len(delay) > 0:
+		return 0, NewJSMessageSchedulesInvalidError()
+	case len(at) > 0:
+		t, err := time.Parse(time.RFC3339, bytesToString(at))
+		if err != nil {
+			return 0, NewJSMessageSchedulesInvalidError()
+		}
+		return t.UnixNano(), nil
+	default:
+		d, err := time.ParseDuration(bytesToString(delay))
+		if err != nil || d <= 0 {
+			return 0, NewJSMessageSchedulesInvalidError()
+		}
+		return ts + int64(d), nil
+	}
+}
+
+// getScheduledSequence returns the sequence of the held message this is a released copy of, if any.
+func getScheduledSequence(hdr []byte) uint64 {
+	if len(hdr) == 0 {
+		return 0
+	}
+	seq := parseInt64(getHeader(JSScheduledSequence, hdr))
+	if seq <= 0 {
+		return 0
+	}
+	return uint64(seq)
+}
+
+// loadLastVisibleMsg loads the last message for the subject that is not held back by a schedule.
+func loadLastVisibleMsg(store StreamStore, subj string, smp *StoreMsg) (*StoreMsg, error) {
+	sm, err := store.LoadLastMsg(subj, smp)
+	for err == nil && isScheduledMsg(sm.hdr) {
+		if sm.seq <= 1 {
+			return nil, ErrStoreMsgNotFound
+		}
+		// Walk back, skipping over messages for other subjects.
+		sm, err = store.LoadPrevMsg(sm.seq-1, smp)
+		for err == nil && subj != _EMPTY_ && subj != fwcs && !subjectIsSubsetMatch(sm.subj, subj) {
+			if sm.seq <= 1 {
+				return nil, ErrStoreMsgNotFound
+			}
+			sm, err = store.LoadPrevMsg(sm.seq-1, smp)
+		}
+	}
+	if err == ErrStoreEOF {
+		err = ErrStoreMsgNotFound
+	}
+	return sm, err
+}
+
+// heldMsg is a message held back by a schedule.
+type heldMsg struct {
+	subj string
+	due  int64
+}
+
+// heldMsgs are the messages held back by a schedule. These are tracked on all replicas as
+// messages are stored and removed, so a new leader doesn't need to scan the store to release
+// them, and consumers don't count them as pending.
+// This has its own lock since store updates can be called with the stream lock held.
+type heldMsgs struct {
+	mu   sync.Mutex
+	msgs map[uint64]heldMsg
+}
+
+func (h *heldMsgs) add(seq uint64, subj string, due int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.msgs == nil {
+		h.msgs = make(map[uint64]heldMsg)
+	}
+	h.msgs[seq] = heldMsg{subj, due}
+}
+
+// remove returns whether the message was held.
+func (h *heldMsgs) remove(seq uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.msgs[seq]; !ok {
+		return false
+	}
+	delete(h.msgs, seq)
+	return true
+}
+
+// prune removes held messages that are no longer in the store, used after
+// removing messages in bulk, like purges and truncates.
+func (h *heldMsgs) prune(store StreamStore) {
+	h.mu.Lock()
+	if len(h.msgs) == 0 {
+		h.mu.Unlock()
+		return
+	}
+	seqs := make([]uint64, 0, len(h.msgs))
+	for seq := range h.msgs {
+		seqs = append(seqs, seq)
+	}
+	h.mu.Unlock()
+
+	var smv StoreMsg
+	for _, seq := range seqs {
+		if sm, err := store.LoadMsg(seq, &smv); err != nil || !isScheduledMsg(sm.hdr) {
+			h.remove(seq)
+		}
+	}
+}
+
+// numPending returns how many held messages at or after the sequence match the filter.
+func (h *heldMsgs) numPending(sseq uint64, isMatch func(subj string) bool) (np uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for seq, hm := range h.msgs {
+		if seq >= sseq && isMatch(hm.subj) {
+			np++
+		}
+	}
+	return np
+}
+
+// loadHeldMsgs walks the store for held messages, used when the stream is created or recovered.
+// Lock should not be held.
+func (mset *stream) loadHeldMsgs() {
+	store := mset.store
+	var state StreamState
+	store.FastState(&state)
+
+	var smv StoreMsg
+	for seq := state.FirstSeq; seq <= state.LastSeq; seq++ {
+		sm, nseq, err := store.LoadNextMsg(fwcs, true, seq, &smv)
+		if err != nil {
+			break
+		}
+		seq = nseq
+		if due, err := getMessageSchedule(sm.hdr, sm.ts); err == nil && due != 0 {
+			mset.held.add(sm.seq, sm.subj, due)
+		}
+	}
+}
+
+// trackScheduledMsgLocked tracks a held message, so it's released once due.
+// Lock should be held.
+func (mset *stream) trackScheduledMsgLocked(seq uint64, due int64) {
+	if mset.scheduled == nil {
+		mset.scheduled = thw.NewHashWheel()
+	}
+	mset.scheduled.Add(seq, due)
+	mset.resetScheduleTimerLocked()
+}
+
+// Lock should be held.
+func (mset *stream) resetScheduleTimerLocked() {
+	next := int64(math.MaxInt64)
+	if mset.scheduled != nil {
+		next = mset.scheduled.GetNextExpiration(math.MaxInt64)
+	}
+	if next == math.MaxInt64 {
+		if mset.schedTimer != nil {
+			mset.schedTimer.Stop()
+		}
+		return
+	}
+	fireIn := max(time.Until(time.Unix(0, next)), 0)
+	if mset.schedTimer == nil {
+		mset.schedTimer = time.AfterFunc(fireIn, mset.releaseScheduledMsgs)
+	} else {
+		mset.schedTimer.Reset(fireIn)
+	}
+}
+
+// clearScheduledMsgsLocked stops tracking held messages, used when no longer the leader.
+// Lock should be held.
+func (mset *stream) clearScheduledMsgsLocked() {
+	if mset.schedTimer != nil {
+		mset.schedTimer.Stop()
+		mset.schedTimer = nil
+	}
+	mset.scheduled = nil
+}
+
+// rebuildScheduledMsgsLocked tracks all held messages, used when becoming the leader.
+// Lock should be held.
+func (mset *stream) rebuildScheduledMsgsLocked() {
+	mset.clearScheduledMsgsLocked()
+	if !mset.cfg.AllowMsgSchedules {
+		return
+	}
+	mset.held.mu.Lock()
+	defer mset.held.mu.Unlock()
+	if len(mset.held.msgs) == 0 {
+		return
+	}
+	mset.scheduled = thw.NewHashWheel()
+	for seq, hm := range mset.held.msgs {
+		mset.scheduled.Add(seq, hm.due)
+	}
+	mset.resetScheduleTimerLocked()
+}
+
+// releaseScheduledMsgs releases all held messages that are due.
+func (mset *stream) releaseScheduledMsgs() {
+	mset.mu.Lock()
+	if mset.scheduled == nil || !mset.isLeader() || mset.closed.Load() {
+		mset.mu.Unlock()
+		return
+	}
+	var due []uint64
+	mset.scheduled.ExpireTasks(func(seq uint64, _ int64) {
+		due = append(due, seq)
+	})
+	mset.mu.Unlock()
+
+	var retry []uint64
+	for _, seq := range due {
+		if err := mset.releaseScheduledMsg(seq); err != nil {
+			mset.srv.RateLimitWarnf("JetStream failed to release scheduled msg %d on stream '%s > %s': %v",
+				seq, mset.accName(), mset.name(), err)
+			retry = append(retry, seq)
+		}
+	}
+
+	mset.mu.Lock()
+	defer mset.mu.Unlock()
+	if mset.scheduled == nil {
+		return
+	}
+	for _, seq := range retry {
+		mset.scheduled.Add(seq, time.Now().Add(scheduleRetryInterval).UnixNano())
+	}
+	mset.resetScheduleTimerLocked()
+}
+
+// releaseScheduledMsg publishes a copy of the held message into the stream. Once the copy is
+// stored the held message is removed, on all replicas if clustered.
+func (mset *stream) releaseScheduledMsg(seq uint64) error {
+	var smv StoreMsg
+	sm, err := mset.store.LoadMsg(seq, &smv)
+	if err != nil || !isScheduledMsg(sm.hdr) {
+		// Already released or removed in the meantime.
+		return nil
+	}
+
+	hdr := copyBytes(sm.hdr)
+	for _, key := range scheduleReleaseRemovedHeaders {
+		hdr = removeHeaderIfPresent(hdr, key)
+	}
+	hdr = genHeader(hdr, JSScheduledSequence, strconv.FormatUint(seq, 10))
+	msg := copyBytes(sm.msg)
+
+	if mset.IsClustered() {
+		return mset.processClusteredInboundMsg(sm.subj, _EMPTY_, hdr, msg, nil, false)
+	}
+	return mset.processJetStreamMsg(sm.subj, _EMPTY_, hdr, msg, 0, 0, nil, false)
+}
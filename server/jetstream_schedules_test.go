@@ -0,0 +1,374 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestJetStreamMsgSchedules(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:              "TEST",
+				Storage:           storage,
+				Subjects:          []string{"foo"},
+				AllowDirect:       true,
+				AllowMsgSchedules: true,
+			})
+			require_NoError(t, err)
+
+			m := nats.NewMsg("foo")
+			m.Header.Set(JSMsgDelay, "1s")
+			m.Data = []byte("delayed")
+			_, err = js.PublishMsg(m)
+			require_NoError(t, err)
+			_, err = js.Publish("foo", []byte("now"))
+			require_NoError(t, err)
+
+			// The held message is stored, but not visible.
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 2)
+
+			_, err = js.GetMsg("TEST", 1)
+			require_Error(t, err, nats.ErrMsgNotFound)
+			_, err = js.GetMsg("TEST", 1, nats.DirectGet())
+			require_Error(t, err, nats.ErrMsgNotFound)
+
+			// Only the other message is the last for the subject, until the held message is released.
+			rsm, err := js.GetLastMsg("TEST", "foo", nats.DirectGet())
+			require_NoError(t, err)
+			require_Equal(t, rsm.Sequence, 2)
+
+			sub, err := js.PullSubscribe("foo", "C")
+			require_NoError(t, err)
+
+			// The held message is not pending for consumers until released.
+			ci, err := js.ConsumerInfo("TEST", "C")
+			require_NoError(t, err)
+			require_Equal(t, ci.NumPending, 1)
+
+			msgs, err := sub.Fetch(2, nats.MaxWait(250*time.Millisecond))
+			require_NoError(t, err)
+			require_Len(t, len(msgs), 1)
+			require_Equal(t, string(msgs[0].Data), "now")
+
+			// Once due, a copy is published and the held message is removed.
+			msgs, err = sub.Fetch(1, nats.MaxWait(3*time.Second))
+			require_NoError(t, err)
+			require_Len(t, len(msgs), 1)
+			require_Equal(t, string(msgs[0].Data), "delayed")
+			require_Equal(t, msgs[0].Header.Get(JSScheduledSequence), "1")
+			require_Equal(t, msgs[0].Header.Get(JSMsgDelay), _EMPTY_)
+
+			ci, err = js.ConsumerInfo("TEST", "C")
+			require_NoError(t, err)
+			require_Equal(t, ci.NumPending, 0)
+
+			si, err = js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 2)
+			require_Equal(t, si.State.LastSeq, 3)
+			_, err = js.GetMsg("TEST", 1)
+			require_Error(t, err, nats.ErrMsgNotFound)
+
+			rsm, err = js.GetLastMsg("TEST", "foo", nats.DirectGet())
+			require_NoError(t, err)
+			require_Equal(t, rsm.Sequence, 3)
+		})
+	}
+}
+
+func TestJetStreamMsgSchedulesDeliverAt(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:              "TEST",
+		Storage:           FileStorage,
+		Subjects:          []string{"foo"},
+		AllowMsgSchedules: true,
+	})
+	require_NoError(t, err)
+
+	m := nats.NewMsg("foo")
+	m.Header.Set(JSMsgDeliverAt, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	_, err = js.PublishMsg(m)
+	require_NoError(t, err)
+
+	// A time in the past is released right away.
+	m = nats.NewMsg("foo")
+	m.Header.Set(JSMsgDeliverAt, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	_, err = js.PublishMsg(m)
+	require_NoError(t, err)
+
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		si, err := js.StreamInfo("TEST")
+		if err != nil {
+			return err
+		}
+		if si.State.Msgs != 2 || si.State.LastSeq != 3 {
+			return fmt.Errorf("expected 2 msgs with last sequence 3, got %d (last %d)", si.State.Msgs, si.State.LastSeq)
+		}
+		return nil
+	})
+
+	rsm, err := js.GetMsg("TEST", 3)
+	require_NoError(t, err)
+	require_Equal(t, rsm.Header.Get(JSScheduledSequence), "2")
+	_, err = js.GetMsg("TEST", 1)
+	require_Error(t, err, nats.ErrMsgNotFound)
+}
+
+func TestJetStreamMsgSchedulesErrors(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "DISABLED",
+		Storage:  FileStorage,
+		Subjects: []string{"disabled"},
+	})
+	require_NoError(t, err)
+
+	cfg := &StreamConfig{
+		Name:              "TEST",
+		Storage:           FileStorage,
+		Subjects:          []string{"foo"},
+		AllowMsgSchedules: true,
+	}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_NoError(t, err)
+
+	m := nats.NewMsg("disabled")
+	m.Header.Set(JSMsgDelay, "1s")
+	_, err = js.PublishMsg(m)
+	require_Error(t, err, NewJSMessageSchedulesDisabledError())
+
+	for _, hdr := range []nats.Header{
+		{JSMsgDelay: []string{"bad"}},
+		{JSMsgDelay: []string{"-1s"}},
+		{JSMsgDeliverAt: []string{"tomorrow"}},
+		{JSMsgDelay: []string{"1s"}, JSMsgDeliverAt: []string{time.Now().UTC().Format(time.RFC3339)}},
+	} {
+		_, err = js.PublishMsg(&nats.Msg{Subject: "foo", Header: hdr})
+		require_Error(t, err, NewJSMessageSchedulesInvalidError())
+	}
+
+	// Clients can't pretend to release a held message.
+	m = nats.NewMsg("foo")
+	m.Header.Set(JSMsgDelay, "1h")
+	_, err = js.PublishMsg(m)
+	require_NoError(t, err)
+	m = nats.NewMsg("foo")
+	m.Header.Set(JSScheduledSequence, "1")
+	_, err = js.PublishMsg(m)
+	require_NoError(t, err)
+
+	rsm, err := js.GetMsg("TEST", 2)
+	require_NoError(t, err)
+	require_Equal(t, rsm.Header.Get(JSScheduledSequence), _EMPTY_)
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 2)
+
+	// The schedules status can't be changed.
+	cfg.AllowMsgSchedules = false
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_Error(t, err, errors.New("message schedules status can not be changed"))
+
+	_, err = jsStreamCreate(t, nc, &StreamConfig{
+		Name:              "WQ",
+		Storage:           FileStorage,
+		Subjects:          []string{"wq"},
+		Retention:         WorkQueuePolicy,
+		AllowMsgSchedules: true,
+	})
+	require_Error(t, err, errors.New("message schedules require limits retention policy"))
+
+	_, err = jsStreamCreate(t, nc, &StreamConfig{
+		Name:              "M",
+		Storage:           FileStorage,
+		Mirror:            &StreamSource{Name: "TEST"},
+		AllowMsgSchedules: true,
+	})
+	require_Error(t, err, errors.New("message schedules are not supported for mirrors"))
+}
+
+func TestJetStreamMsgSchedulesRestart(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:              "TEST",
+		Storage:           FileStorage,
+		Subjects:          []string{"foo"},
+		AllowMsgSchedules: true,
+	})
+	require_NoError(t, err)
+
+	m := nats.NewMsg("foo")
+	m.Header.Set(JSMsgDelay, "1s")
+	_, err = js.PublishMsg(m)
+	require_NoError(t, err)
+	nc.Close()
+
+	// Held messages are recovered from the store.
+	sd := s.JetStreamConfig().StoreDir
+	s.Shutdown()
+	s = RunJetStreamServerOnPort(-1, sd)
+	defer s.Shutdown()
+
+	nc, js = jsClientConnect(t, s)
+	defer nc.Close()
+
+	checkFor(t, 3*time.Second, 100*time.Millisecond, func() error {
+		si, err := js.StreamInfo("TEST")
+		if err != nil {
+			return err
+		}
+		if si.State.Msgs != 1 || si.State.LastSeq != 2 {
+			return fmt.Errorf("expected 1 msg with last sequence 2, got %d (last %d)", si.State.Msgs, si.State.LastSeq)
+		}
+		return nil
+	})
+}
+
+func TestJetStreamMsgSchedulesPurge(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:              "TEST",
+		Storage:           FileStorage,
+		Subjects:          []string{"foo", "bar"},
+		AllowMsgSchedules: true,
+	})
+	require_NoError(t, err)
+
+	for _, subj := range []string{"foo", "bar"} {
+		m := nats.NewMsg(subj)
+		m.Header.Set(JSMsgDelay, "1h")
+		_, err = js.PublishMsg(m)
+		require_NoError(t, err)
+	}
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	all := func(string) bool { return true }
+	require_Equal(t, mset.held.numPending(0, all), 2)
+
+	// Purged held messages are no longer tracked.
+	require_NoError(t, js.PurgeStream("TEST", &nats.StreamPurgeRequest{Subject: "foo"}))
+	require_Equal(t, mset.held.numPending(0, all), 1)
+	require_Equal(t, mset.held.numPending(0, func(subj string) bool { return subj == "bar" }), 1)
+}
+
+func TestJetStreamClusterMsgSchedules(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:              "TEST",
+		Storage:           FileStorage,
+		Subjects:          []string{"foo"},
+		Replicas:          3,
+		AllowMsgSchedules: true,
+	})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	m := nats.NewMsg("foo")
+	m.Header.Set(JSMsgDelay, "2s")
+	_, err = js.PublishMsg(m)
+	require_NoError(t, err)
+
+	// All replicas know which messages are held, so a new leader doesn't need to look for them.
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if np := mset.held.numPending(0, func(string) bool { return true }); np != 1 {
+				return fmt.Errorf("expected 1 held msg, got %d", np)
+			}
+		}
+		return nil
+	})
+
+	// The new leader takes over releasing the held message.
+	sl := c.streamLeader(globalAccountName, "TEST")
+	_, err = nc.Request(fmt.Sprintf(JSApiStreamLeaderStepDownT, "TEST"), nil, time.Second)
+	require_NoError(t, err)
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		if nsl := c.streamLeader(globalAccountName, "TEST"); nsl == nil || nsl == sl {
+			return errors.New("no new stream leader yet")
+		}
+		return nil
+	})
+
+	checkFor(t, 5*time.Second, 200*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			var state StreamState
+			mset.store.FastState(&state)
+			if state.Msgs != 1 || state.LastSeq != 2 {
+				return fmt.Errorf("expected 1 msg with last sequence 2, got %d (last %d)", state.Msgs, state.LastSeq)
+			}
+			sm, err := mset.store.LoadMsg(2, nil)
+			if err != nil {
+				return err
+			}
+			if seq := getScheduledSequence(sm.hdr); seq != 1 {
+				return fmt.Errorf("expected scheduled sequence 1, got %d", seq)
+			}
+			if np := mset.held.numPending(0, func(string) bool { return true }); np != 0 {
+				return fmt.Errorf("expected no held msgs, got %d", np)
+			}
+		}
+		return nil
+	})
+}
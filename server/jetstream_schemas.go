@@ -0,0 +1,635 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamSchema binds a JSON Schema document to the messages stored under a subject filter.
+type StreamSchema struct {
+	Subject string          `json:"subject"`
+	Schema  json.RawMessage `json:"schema"`
+}
+
+// msgSchema is a compiled StreamSchema.
+type msgSchema struct {
+	subject string
+	schema  *jsonSchema
+}
+
+// jsonSchema is a compiled JSON Schema. Only the validation keywords below are supported,
+// references and conditional keywords are rejected when the schema is compiled.
+type jsonSchema struct {
+	// Set for the boolean schemas true and false.
+	always *bool
+
+	types    []string
+	enum     []any
+	cnst     any
+	hasConst bool
+
+	// Numbers.
+	minimum, maximum                   *big.Float
+	exclusiveMinimum, exclusiveMaximum *big.Float
+	multipleOf                         *big.Float
+
+	// Strings.
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	// Arrays.
+	minItems, maxItems *int
+	uniqueItems        bool
+	items              *jsonSchema
+
+	// Objects.
+	minProperties, maxProperties *int
+	required                     []string
+	properties                   map[string]*jsonSchema
+	additionalProperties         *jsonSchema
+
+	// Combinators.
+	allOf, anyOf, oneOf []*jsonSchema
+	not                 *jsonSchema
+}
+
+var errMsgSchemaInvalid = errors.New("message failed schema validation")
+
+// Keywords that only annotate a schema and are ignored during validation.
+var jsonSchemaAnnotations = map[string]struct{}{
+	"$schema": {}, "$id": {}, "$comment": {}, "$defs": {}, "definitions": {},
+	"title": {}, "description": {}, "default": {}, "examples": {}, "format": {},
+	"deprecated": {}, "readOnly": {}, "writeOnly": {}, "contentEncoding": {}, "contentMediaType": {},
+}
+
+var jsonSchemaTypes = map[string]struct{}{
+	"null": {}, "boolean": {}, "object": {}, "array": {}, "number": {}, "integer": {}, "string": {},
+}
+
+// compileStreamSchemas compiles the schemas of a stream configuration.
+func compileStreamSchemas(schemas []*StreamSchema) ([]*msgSchema, error) {
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+	compiled := make([]*msgSchema, 0, len(schemas))
+	for _, ss := range schemas {
+		if ss == nil {
+			return nil, errors.New("schema can not be empty")
+		}
+		if !IsValidSubject(ss.Subject) {
+			return nil, fmt.Errorf("schema subject %q is not a valid subject filter", ss.Subject)
+		}
+		var doc any
+		if err := decodeJSON(ss.Schema, &doc); err != nil {
+			return nil, fmt.Errorf("schema for subject %q is not valid JSON: %v", ss.Subject, err)
+		}
+		js, err := compileJSONSchema(doc, _EMPTY_)
+		if err != nil {
+			return nil, fmt.Errorf("schema for subject %q is invalid: %v", ss.Subject, err)
+		}
+		compiled = append(compiled, &msgSchema{subject: ss.Subject, schema: js})
+	}
+	return compiled, nil
+}
+
+// checkSchemas validates the message body against the schemas of the stream. The stream lock
+// is only held to snapshot the schemas, the payload is decoded without it.
+func (mset *stream) checkSchemas(subject string, msg []byte) (*ApiError, error) {
+	mset.mu.RLock()
+	schemas, itr := mset.schemas, mset.itr
+	mset.mu.RUnlock()
+	if len(schemas) == 0 {
+		return nil, nil
+	}
+	// Schemas are bound to the subject the message will be stored under.
+	if itr != nil {
+		if tsubj, err := itr.Match(subject); err == nil {
+			subject = tsubj
+		}
+	}
+	return checkMsgSchemas(schemas, subject, msg)
+}
+
+// checkMsgSchemas validates the message body against all schemas bound to the subject.
+func checkMsgSchemas(schemas []*msgSchema, subject string, msg []byte) (*ApiError, error) {
+	var doc any
+	var decoded bool
+	for _, ms := range schemas {
+		if !subjectIsSubsetMatch(subject, ms.subject) {
+			continue
+		}
+		if !decoded {
+			if err := decodeJSON(msg, &doc); err != nil {
+				err = fmt.Errorf("payload is not valid JSON: %v", err)
+				return NewJSStreamSchemaValidationFailedError(err), errMsgSchemaInvalid
+			}
+			decoded = true
+		}
+		if err := ms.schema.validate(doc, _EMPTY_); err != nil {
+			return NewJSStreamSchemaValidationFailedError(err), errMsgSchemaInvalid
+		}
+	}
+	return nil, nil
+}
+
+// decodeJSON decodes a single JSON document. Numbers are kept as json.Number,
+// so large integers keep their precision.
+func decodeJSON(data []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after top-level value")
+	}
+	return nil
+}
+
+func compileJSONSchema(doc any, path string) (*jsonSchema, error) {
+	switch v := doc.(type) {
+	case bool:
+		return &jsonSchema{always: &v}, nil
+	case map[string]any:
+		js := &jsonSchema{}
+		// Walk the keywords in order so errors are reported consistently.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := js.compileKeyword(k, v[k], path); err != nil {
+				return nil, err
+			}
+		}
+		return js, nil
+	default:
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", schemaPath(path))
+	}
+}
+
+func (js *jsonSchema) compileKeyword(k string, v any, path string) error {
+	if _, ok := jsonSchemaAnnotations[k]; ok {
+		return nil
+	}
+	var err error
+	kpath := path + "/" + k
+	switch k {
+	case "type":
+		switch t := v.(type) {
+		case string:
+			js.types = []string{t}
+		case []any:
+			for _, e := range t {
+				s, ok := e.(string)
+				if !ok {
+					return fmt.Errorf("%s: must be a string or array of strings", kpath)
+				}
+				js.types = append(js.types, s)
+			}
+		default:
+			return fmt.Errorf("%s: must be a string or array of strings", kpath)
+		}
+		for _, t := range js.types {
+			if _, ok := jsonSchemaTypes[t]; !ok {
+				return fmt.Errorf("%s: unknown type %q", kpath, t)
+			}
+		}
+	case "enum":
+		a, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array", kpath)
+		}
+		js.enum = a
+	case "const":
+		js.cnst, js.hasConst = v, true
+	case "minimum":
+		js.minimum, err = schemaNumber(v, kpath)
+	case "maximum":
+		js.maximum, err = schemaNumber(v, kpath)
+	case "exclusiveMinimum":
+		js.exclusiveMinimum, err = schemaNumber(v, kpath)
+	case "exclusiveMaximum":
+		js.exclusiveMaximum, err = schemaNumber(v, kpath)
+	case "multipleOf":
+		if js.multipleOf, err = schemaNumber(v, kpath); err == nil && js.multipleOf.Sign() <= 0 {
+			err = fmt.Errorf("%s: must be greater than 0", kpath)
+		}
+	case "minLength":
+		js.minLength, err = schemaCount(v, kpath)
+	case "maxLength":
+		js.maxLength, err = schemaCount(v, kpath)
+	case "pattern":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", kpath)
+		}
+		if js.pattern, err = regexp.Compile(s); err != nil {
+			err = fmt.Errorf("%s: %v", kpath, err)
+		}
+	case "minItems":
+		js.minItems, err = schemaCount(v, kpath)
+	case "maxItems":
+		js.maxItems, err = schemaCount(v, kpath)
+	case "uniqueItems":
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("%s: must be a boolean", kpath)
+		}
+		js.uniqueItems = b
+	case "items":
+		js.items, err = compileJSONSchema(v, kpath)
+	case "minProperties":
+		js.minProperties, err = schemaCount(v, kpath)
+	case "maxProperties":
+		js.maxProperties, err = schemaCount(v, kpath)
+	case "required":
+		a, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an array of strings", kpath)
+		}
+		for _, e := range a {
+			s, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("%s: must be an array of strings", kpath)
+			}
+			js.required = append(js.required, s)
+		}
+	case "properties":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: must be an object", kpath)
+		}
+		js.properties = make(map[string]*jsonSchema, len(m))
+		for name, pv := range m {
+			if js.properties[name], err = compileJSONSchema(pv, kpath+"/"+name); err != nil {
+				return err
+			}
+		}
+	case "additionalProperties":
+		js.additionalProperties, err = compileJSONSchema(v, kpath)
+	case "allOf":
+		js.allOf, err = compileJSONSchemas(v, kpath)
+	case "anyOf":
+		js.anyOf, err = compileJSONSchemas(v, kpath)
+	case "oneOf":
+		js.oneOf, err = compileJSONSchemas(v, kpath)
+	case "not":
+		js.not, err = compileJSONSchema(v, kpath)
+	default:
+		// Unknown keywords would be silently ignored by a validator,
+		// which is not what anyone relying on them would expect.
+		return fmt.Errorf("%s: unsupported keyword", kpath)
+	}
+	return err
+}
+
+func compileJSONSchemas(v any, path string) ([]*jsonSchema, error) {
+	a, ok := v.([]any)
+	if !ok || len(a) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty array", path)
+	}
+	schemas := make([]*jsonSchema, 0, len(a))
+	for i, e := range a {
+		js, err := compileJSONSchema(e, path+"/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, js)
+	}
+	return schemas, nil
+}
+
+func schemaNumber(v any, path string) (*big.Float, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", path)
+	}
+	f, ok := jsonNumber(n)
+	if !ok || f.IsInf() {
+		return nil, fmt.Errorf("%s: must be a number", path)
+	}
+	return f, nil
+}
+
+func schemaCount(v any, path string) (*int, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", path)
+	}
+	f, ok := jsonNumber(n)
+	if !ok || !f.IsInt() || f.Sign() < 0 || f.Cmp(big.NewFloat(math.MaxInt32)) > 0 {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", path)
+	}
+	i, _ := f.Int64()
+	c := int(i)
+	return &c, nil
+}
+
+// jsonNumber returns the value of a decoded number. Integers are parsed exactly, anything
+// else with float64 precision.
+func jsonNumber(n json.Number) (*big.Float, bool) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, false
+		}
+		return new(big.Float).SetInt(i), true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, false
+	}
+	return big.NewFloat(f), true
+}
+
+// jsonEqual reports whether two decoded values are equal. Numbers are compared by value,
+// so 1 and 1.0 are equal.
+func jsonEqual(a, b any) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aok := jsonNumber(av)
+		bf, bok := jsonNumber(bv)
+		return aok && bok && af.Cmp(bf) == 0
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, ae := range av {
+			be, ok := bv[k]
+			if !ok || !jsonEqual(ae, be) {
+				return false
+			}
+		}
+		return true
+	case nil, bool, string:
+		return a == b
+	}
+	return false
+}
+
+func schemaPath(path string) string {
+	if path == _EMPTY_ {
+		return "/"
+	}
+	return path
+}
+
+func jsonTypeOf(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if f, ok := jsonNumber(t); ok && f.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+// validate checks the decoded JSON value against the schema. The path is a JSON pointer
+// to the value, used to report where validation failed.
+func (js *jsonSchema) validate(v any, path string) error {
+	if js.always != nil {
+		if !*js.always {
+			return fmt.Errorf("%s: not allowed", schemaPath(path))
+		}
+		return nil
+	}
+
+	if len(js.types) > 0 {
+		vt, ok := jsonTypeOf(v), false
+		for _, t := range js.types {
+			if t == vt || (t == "number" && vt == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %s", schemaPath(path), strings.Join(js.types, " or "), vt)
+		}
+	}
+	if js.enum != nil {
+		var ok bool
+		for _, e := range js.enum {
+			if jsonEqual(e, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: value is not one of the allowed values", schemaPath(path))
+		}
+	}
+	if js.hasConst && !jsonEqual(js.cnst, v) {
+		return fmt.Errorf("%s: value does not match constant", schemaPath(path))
+	}
+
+	switch t := v.(type) {
+	case json.Number:
+		f, ok := jsonNumber(t)
+		if !ok {
+			return fmt.Errorf("%s: invalid number %q", schemaPath(path), t)
+		}
+		if err := js.validateNumber(f, path); err != nil {
+			return err
+		}
+	case string:
+		if err := js.validateString(t, path); err != nil {
+			return err
+		}
+	case []any:
+		if err := js.validateArray(t, path); err != nil {
+			return err
+		}
+	case map[string]any:
+		if err := js.validateObject(t, path); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range js.allOf {
+		if err := s.validate(v, path); err != nil {
+			return err
+		}
+	}
+	if len(js.anyOf) > 0 {
+		var ok bool
+		for _, s := range js.anyOf {
+			if s.validate(v, path) == nil {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: value does not match any of the schemas in anyOf", schemaPath(path))
+		}
+	}
+	if len(js.oneOf) > 0 {
+		var n int
+		for _, s := range js.oneOf {
+			if s.validate(v, path) == nil {
+				n++
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("%s: value matches %d of the schemas in oneOf, expected exactly 1", schemaPath(path), n)
+		}
+	}
+	if js.not != nil && js.not.validate(v, path) == nil {
+		return fmt.Errorf("%s: value matches schema in not", schemaPath(path))
+	}
+	return nil
+}
+
+func (js *jsonSchema) validateNumber(f *big.Float, path string) error {
+	switch {
+	case js.minimum != nil && f.Cmp(js.minimum) < 0:
+		return fmt.Errorf("%s: %s is less than minimum %s", schemaPath(path), f.Text('g', -1), js.minimum.Text('g', -1))
+	case js.maximum != nil && f.Cmp(js.maximum) > 0:
+		return fmt.Errorf("%s: %s is greater than maximum %s", schemaPath(path), f.Text('g', -1), js.maximum.Text('g', -1))
+	case js.exclusiveMinimum != nil && f.Cmp(js.exclusiveMinimum) <= 0:
+		return fmt.Errorf("%s: %s is not greater than %s", schemaPath(path), f.Text('g', -1), js.exclusiveMinimum.Text('g', -1))
+	case js.exclusiveMaximum != nil && f.Cmp(js.exclusiveMaximum) >= 0:
+		return fmt.Errorf("%s: %s is not less than %s", schemaPath(path), f.Text('g', -1), js.exclusiveMaximum.Text('g', -1))
+	}
+	if js.multipleOf != nil && !isMultipleOf(f, js.multipleOf) {
+		return fmt.Errorf("%s: %s is not a multiple of %s", schemaPath(path), f.Text('g', -1), js.multipleOf.Text('g', -1))
+	}
+	return nil
+}
+
+// isMultipleOf checks integers exactly, and falls back to float64 division otherwise.
+func isMultipleOf(f, m *big.Float) bool {
+	if f.IsInt() && m.IsInt() {
+		fi, _ := f.Int(nil)
+		mi, _ := m.Int(nil)
+		return new(big.Int).Rem(fi, mi).Sign() == 0
+	}
+	ff, _ := f.Float64()
+	mf, _ := m.Float64()
+	q := ff / mf
+	return q == math.Trunc(q) && !math.IsInf(q, 0)
+}
+
+func (js *jsonSchema) validateString(s string, path string) error {
+	n := utf8.RuneCountInString(s)
+	switch {
+	case js.minLength != nil && n < *js.minLength:
+		return fmt.Errorf("%s: length %d is less than %d", schemaPath(path), n, *js.minLength)
+	case js.maxLength != nil && n > *js.maxLength:
+		return fmt.Errorf("%s: length %d is greater than %d", schemaPath(path), n, *js.maxLength)
+	case js.pattern != nil && !js.pattern.MatchString(s):
+		return fmt.Errorf("%s: does not match pattern %q", schemaPath(path), js.pattern.String())
+	}
+	return nil
+}
+
+func (js *jsonSchema) validateArray(a []any, path string) error {
+	switch {
+	case js.minItems != nil && len(a) < *js.minItems:
+		return fmt.Errorf("%s: %d items is less than %d", schemaPath(path), len(a), *js.minItems)
+	case js.maxItems != nil && len(a) > *js.maxItems:
+		return fmt.Errorf("%s: %d items is greater than %d", schemaPath(path), len(a), *js.maxItems)
+	}
+	if js.uniqueItems {
+		for i := range a {
+			for j := i + 1; j < len(a); j++ {
+				if jsonEqual(a[i], a[j]) {
+					return fmt.Errorf("%s: items %d and %d are equal", schemaPath(path), i, j)
+				}
+			}
+		}
+	}
+	if js.items != nil {
+		for i, e := range a {
+			if err := js.items.validate(e, path+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (js *jsonSchema) validateObject(m map[string]any, path string) error {
+	switch {
+	case js.minProperties != nil && len(m) < *js.minProperties:
+		return fmt.Errorf("%s: %d properties is less than %d", schemaPath(path), len(m), *js.minProperties)
+	case js.maxProperties != nil && len(m) > *js.maxProperties:
+		return fmt.Errorf("%s: %d properties is greater than %d", schemaPath(path), len(m), *js.maxProperties)
+	}
+	for _, name := range js.required {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", schemaPath(path), name)
+		}
+	}
+	// Check properties in order so the reported error is stable.
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ppath := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+		if ps, ok := js.properties[name]; ok {
+			if err := ps.validate(m[name], ppath); err != nil {
+				return err
+			}
+		} else if js.additionalProperties != nil {
+			if err := js.additionalProperties.validate(m[name], ppath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
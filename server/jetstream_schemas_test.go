@@ -0,0 +1,284 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const testOrderSchema = `{
+	"type": "object",
+	"required": ["id", "qty"],
+	"properties": {
+		"id": {"type": "string", "pattern": "^ord-[0-9]+$"},
+		"qty": {"type": "integer", "minimum": 1},
+		"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
+	},
+	"additionalProperties": false
+}`
+
+func TestJSONSchemaValidate(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		doc    string
+		err    string
+	}{
+		{testOrderSchema, `{"id":"ord-1","qty":2}`, _EMPTY_},
+		{testOrderSchema, `{"id":"ord-1","qty":2,"tags":["a","b"]}`, _EMPTY_},
+		{testOrderSchema, `{"id":"ord-1"}`, `/: missing required property "qty"`},
+		{testOrderSchema, `{"id":"order","qty":1}`, `/id: does not match pattern`},
+		{testOrderSchema, `{"id":"ord-1","qty":1.5}`, `/qty: expected integer, got number`},
+		{testOrderSchema, `{"id":"ord-1","qty":0}`, `/qty: 0 is less than minimum 1`},
+		{testOrderSchema, `{"id":"ord-1","qty":1,"tags":["a","a"]}`, `/tags: items 0 and 1 are equal`},
+		{testOrderSchema, `{"id":"ord-1","qty":1,"tags":[1]}`, `/tags/0: expected string, got integer`},
+		{testOrderSchema, `{"id":"ord-1","qty":1,"x":1}`, `/x: not allowed`},
+		{testOrderSchema, `[]`, `/: expected object, got array`},
+		{`{"enum":["a",1,null]}`, `null`, _EMPTY_},
+		{`{"enum":["a",1,null]}`, `"b"`, `not one of the allowed values`},
+		{`{"const":{"a":[1]}}`, `{"a":[1]}`, _EMPTY_},
+		{`{"type":["string","null"],"maxLength":2}`, `"abc"`, `/: length 3 is greater than 2`},
+		{`{"type":"number","exclusiveMaximum":10,"multipleOf":2.5}`, `7.5`, _EMPTY_},
+		{`{"type":"number","exclusiveMaximum":10,"multipleOf":2.5}`, `10`, `not less than 10`},
+		{`{"type":"number","exclusiveMaximum":10,"multipleOf":2.5}`, `3`, `not a multiple of 2.5`},
+		{`{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `true`, `does not match any`},
+		{`{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`, `matches 2 of the schemas in oneOf`},
+		{`{"not":{"type":"string"}}`, `"a"`, `matches schema in not`},
+		{`{"allOf":[{"minProperties":1},{"maxProperties":1}]}`, `{}`, `0 properties is less than 1`},
+		{`{"title":"annotated","description":"ignored","format":"email"}`, `"x"`, _EMPTY_},
+		{`false`, `{}`, `/: not allowed`},
+		// Large integers keep their precision.
+		{`{"const":9007199254740993}`, `9007199254740993`, _EMPTY_},
+		{`{"const":9007199254740993}`, `9007199254740992`, `value does not match constant`},
+		{`{"maximum":9007199254740992}`, `9007199254740993`, `is greater than maximum`},
+		{`{"multipleOf":3}`, `9007199254740993`, _EMPTY_},
+		{`{"enum":[1]}`, `1.0`, _EMPTY_},
+		{`{"type":"integer"}`, `123456789012345678901234567890`, _EMPTY_},
+	} {
+		var sdoc, doc any
+		require_NoError(t, decodeJSON([]byte(test.schema), &sdoc))
+		require_NoError(t, decodeJSON([]byte(test.doc), &doc))
+		js, err := compileJSONSchema(sdoc, _EMPTY_)
+		require_NoError(t, err)
+		err = js.validate(doc, _EMPTY_)
+		if test.err == _EMPTY_ {
+			require_NoError(t, err)
+		} else if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Expected error containing %q for %s, got %v", test.err, test.doc, err)
+		}
+	}
+}
+
+func TestJSONSchemaCompileErrors(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		err    string
+	}{
+		{`"string"`, `/: schema must be an object or boolean`},
+		{`{"type":"text"}`, `/type: unknown type "text"`},
+		{`{"$ref":"#/$defs/a"}`, `/$ref: unsupported keyword`},
+		{`{"properties":{"a":{"if":{}}}}`, `/properties/a/if: unsupported keyword`},
+		{`{"minLength":-1}`, `/minLength: must be a non-negative integer`},
+		{`{"pattern":"("}`, `/pattern: error parsing regexp`},
+		{`{"multipleOf":0}`, `/multipleOf: must be greater than 0`},
+		{`{"anyOf":[]}`, `/anyOf: must be a non-empty array`},
+		{`{"required":[1]}`, `/required: must be an array of strings`},
+	} {
+		var sdoc any
+		require_NoError(t, decodeJSON([]byte(test.schema), &sdoc))
+		_, err := compileJSONSchema(sdoc, _EMPTY_)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Expected error containing %q for %s, got %v", test.err, test.schema, err)
+		}
+	}
+}
+
+func TestJetStreamSchemas(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			cfg := &StreamConfig{
+				Name:     "TEST",
+				Storage:  storage,
+				Subjects: []string{"orders.>", "logs.>"},
+				Schemas:  []*StreamSchema{{Subject: "orders.*", Schema: json.RawMessage(testOrderSchema)}},
+			}
+			_, err := jsStreamCreate(t, nc, cfg)
+			require_NoError(t, err)
+
+			pa, err := js.Publish("orders.new", []byte(`{"id":"ord-1","qty":2}`))
+			require_NoError(t, err)
+			require_Equal(t, pa.Sequence, 1)
+
+			// Subjects without a schema are not validated.
+			_, err = js.Publish("logs.a", []byte("not json"))
+			require_NoError(t, err)
+
+			for _, msg := range []string{"not json", `{"id":"ord-2"}`, `{"id":"ord-2","qty":-1}`} {
+				rmsg, err := nc.Request("orders.new", []byte(msg), time.Second)
+				require_NoError(t, err)
+				var resp JSPubAckResponse
+				require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+				require_NotNil(t, resp.Error)
+				require_Equal(t, resp.Error.ErrCode, uint16(JSStreamSchemaValidationFailedF))
+			}
+			_, err = js.Publish("orders.new", []byte(`{"id":"ord-2"}`))
+			require_Error(t, err, NewJSStreamSchemaValidationFailedError(errors.New(`/: missing required property "qty"`)))
+
+			// Rejected messages are never stored or sequenced.
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 2)
+			require_Equal(t, si.State.LastSeq, 2)
+
+			// Schemas can be changed.
+			cfg.Schemas = append(cfg.Schemas, &StreamSchema{Subject: "logs.>", Schema: json.RawMessage(`{"type":"object"}`)})
+			_, err = jsStreamUpdate(t, nc, cfg)
+			require_NoError(t, err)
+			_, err = js.Publish("logs.a", []byte("not json"))
+			require_Error(t, err)
+			_, err = js.Publish("logs.a", []byte(`{"level":"info"}`))
+			require_NoError(t, err)
+
+			cfg.Schemas = nil
+			_, err = jsStreamUpdate(t, nc, cfg)
+			require_NoError(t, err)
+			_, err = js.Publish("orders.new", []byte("not json"))
+			require_NoError(t, err)
+		})
+	}
+}
+
+func TestJetStreamSchemasBatch(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:               "TEST",
+		Storage:            FileStorage,
+		Subjects:           []string{"orders.*"},
+		AllowAtomicPublish: true,
+		Schemas:            []*StreamSchema{{Subject: "orders.*", Schema: json.RawMessage(testOrderSchema)}},
+	})
+	require_NoError(t, err)
+
+	// A single invalid message rejects the whole batch.
+	for i, msg := range []string{`{"id":"ord-1","qty":1}`, `{"id":"ord-2"}`} {
+		m := nats.NewMsg("orders.new")
+		m.Header.Set(JSBatchId, "uuid")
+		m.Header.Set(JSBatchSeq, fmt.Sprintf("%d", i+1))
+		m.Data = []byte(msg)
+		if i == 0 {
+			require_NoError(t, nc.PublishMsg(m))
+			continue
+		}
+		m.Header.Set(JSBatchCommit, "1")
+		rmsg, err := nc.RequestMsg(m, time.Second)
+		require_NoError(t, err)
+		var resp JSPubAckResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		require_NotNil(t, resp.Error)
+		require_Equal(t, resp.Error.ErrCode, uint16(JSStreamSchemaValidationFailedF))
+	}
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 0)
+}
+
+func TestJetStreamSchemasConfigErrors(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, _ := jsClientConnect(t, s)
+	defer nc.Close()
+
+	schema := func(subject, doc string) []*StreamSchema {
+		return []*StreamSchema{{Subject: subject, Schema: json.RawMessage(doc)}}
+	}
+	for _, test := range []struct {
+		cfg *StreamConfig
+		err string
+	}{
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, Schemas: schema("a.", `true`)}, `schema subject "a." is not a valid subject filter`},
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, Schemas: schema("a", `{"type":1}`)}, `schema for subject "a" is invalid: /type: must be a string or array of strings`},
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, Schemas: schema("a", `{"$ref":"#"}`)}, `schema for subject "a" is invalid: /$ref: unsupported keyword`},
+		{&StreamConfig{Name: "A", Storage: FileStorage, Subjects: []string{"a"}, AllowMsgCounter: true, Schemas: schema("a", `true`)}, "schemas can not be combined with message counters"},
+		{&StreamConfig{Name: "A", Storage: FileStorage, Mirror: &StreamSource{Name: "B"}, Schemas: schema("a", `true`)}, "schemas are not supported for mirrors"},
+	} {
+		_, err := jsStreamCreate(t, nc, test.cfg)
+		require_Error(t, err, errors.New(test.err))
+	}
+}
+
+func TestJetStreamClusterSchemas(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"orders.*"},
+		Replicas: 3,
+		Schemas:  []*StreamSchema{{Subject: "orders.*", Schema: json.RawMessage(testOrderSchema)}},
+	})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	for i := 1; i <= 10; i++ {
+		_, err = js.Publish("orders.new", []byte(fmt.Sprintf(`{"id":"ord-%d","qty":%d}`, i, i)))
+		require_NoError(t, err)
+		_, err = js.Publish("orders.new", []byte(fmt.Sprintf(`{"id":"ord-%d","qty":0}`, i)))
+		require_Error(t, err)
+	}
+
+	// Invalid messages are rejected before they are proposed, so no replica has to skip them.
+	checkFor(t, 2*time.Second, 200*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.GlobalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if state := mset.state(); state.Msgs != 10 || state.LastSeq != 10 {
+				return fmt.Errorf("expected 10 msgs up to sequence 10, got %d up to %d", state.Msgs, state.LastSeq)
+			}
+			if clfs := mset.getCLFS(); clfs != 0 {
+				return fmt.Errorf("expected no failed sequences, got %d", clfs)
+			}
+		}
+		return nil
+	})
+	pa, err := js.Publish("orders.new", []byte(`{"id":"ord-11","qty":1}`))
+	require_NoError(t, err)
+	require_Equal(t, pa.Sequence, 11)
+}
@@ -0,0 +1,367 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/nuid"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Default number of bytes read per second by the integrity scrubber.
+	defaultScrubRate = 32 * 1024 * 1024
+	// Maximum number of corrupt ranges we keep in the scrub status.
+	maxScrubCorruptReports = 100
+)
+
+// ScrubStatus reports on the last run of the JetStream integrity scrubber.
+type ScrubStatus struct {
+	Started   time.Time          `json:"started"`
+	Completed *time.Time         `json:"completed,omitempty"`
+	Streams   int                `json:"streams"`
+	Blocks    int                `json:"blocks"`
+	Records   int                `json:"records"`
+	Corrupt   []*StoreCorruption `json:"corrupt,omitempty"`
+}
+
+// StoreCorruption describes a range of corrupt messages found by the integrity scrubber.
+type StoreCorruption struct {
+	Account  string    `json:"account"`
+	Stream   string    `json:"stream"`
+	Block    uint32    `json:"block"`
+	FirstSeq uint64    `json:"first_seq"`
+	LastSeq  uint64    `json:"last_seq"`
+	Error    string    `json:"error"`
+	Detected time.Time `json:"detected"`
+	// Set when a repair from a healthy peer was requested.
+	Repair bool `json:"repair,omitempty"`
+}
+
+// Returns a copy of the status of the last scrub, if any.
+func (js *jetStream) scrubStatus() *ScrubStatus {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	if js.scrub == nil {
+		return nil
+	}
+	ss := *js.scrub
+	ss.Corrupt = make([]*StoreCorruption, 0, len(js.scrub.Corrupt))
+	for _, sc := range js.scrub.Corrupt {
+		csc := *sc
+		ss.Corrupt = append(ss.Corrupt, &csc)
+	}
+	return &ss
+}
+
+// Periodically scrub all file based streams.
+func (s *Server) runJetStreamScrubber(js *jetStream, interval time.Duration) {
+	defer s.grWG.Done()
+
+	t := time.NewTimer(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.quitCh:
+			return
+		case <-t.C:
+		}
+		// Stop if JetStream was disabled, a new scrubber is started if re-enabled.
+		if s.getJetStream() != js || !js.isEnabled() {
+			return
+		}
+		s.scrubJetStream(js)
+		t.Reset(interval)
+	}
+}
+
+// Scrub all file based streams once, reading at most the configured rate.
+// Corrupt messages are reported in the scrub status, logged and sent as advisories
+// to the system account. For replicated streams a repair from a healthy peer is requested.
+func (s *Server) scrubJetStream(js *jetStream) {
+	rps := int(s.getOpts().JetStreamScrubRate)
+	if rps <= 0 {
+		rps = defaultScrubRate
+	}
+	limiter := rate.NewLimiter(rate.Limit(rps), rps)
+	wait := func(n int) error {
+		if d := limiter.ReserveN(time.Now(), min(n, rps)).Delay(); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-s.quitCh:
+				return ErrServerNotRunning
+			}
+		}
+		return nil
+	}
+
+	var streams []*stream
+	js.mu.Lock()
+	for _, jsa := range js.accounts {
+		for _, mset := range jsa.acc().streams() {
+			if mset.Store().Type() == FileStorage {
+				streams = append(streams, mset)
+			}
+		}
+	}
+	js.scrub = &ScrubStatus{Started: time.Now().UTC()}
+	js.mu.Unlock()
+
+	s.Debugf("Scrubbing %d JetStream streams", len(streams))
+	var corrupt int
+	for _, mset := range streams {
+		fs, ok := mset.Store().(*fileStore)
+		if !ok {
+			continue
+		}
+		blocks, records, bad, err := fs.scrub(wait)
+		if err == ErrServerNotRunning {
+			return
+		}
+		if err != nil && err != ErrStoreClosed {
+			s.Warnf("Error scrubbing stream '%s > %s': %v", mset.accName(), mset.name(), err)
+		}
+
+		// Repair the corrupt ranges from a healthy peer if we can.
+		var repair bool
+		if len(bad) > 0 {
+			repair = mset.requestRepair(bad)
+		}
+
+		now := time.Now().UTC()
+		js.mu.Lock()
+		js.scrub.Streams++
+		js.scrub.Blocks += blocks
+		js.scrub.Records += records
+		for _, r := range bad {
+			if len(js.scrub.Corrupt) >= maxScrubCorruptReports {
+				break
+			}
+			js.scrub.Corrupt = append(js.scrub.Corrupt, &StoreCorruption{
+				Account:  mset.accName(),
+				Stream:   mset.name(),
+				Block:    r.blk,
+				FirstSeq: r.first,
+				LastSeq:  r.last,
+				Error:    r.err.Error(),
+				Detected: now,
+				Repair:   repair,
+			})
+		}
+		js.mu.Unlock()
+
+		for _, r := range bad {
+			corrupt++
+			s.Warnf("Detected corrupt messages [%d-%d] in block %d of stream '%s > %s': %v",
+				r.first, r.last, r.blk, mset.accName(), mset.name(), r.err)
+			s.publishAdvisory(nil, JSAdvisoryStreamCorruptPre+"."+mset.name(), &JSStreamCorruptAdvisory{
+				TypedEvent: TypedEvent{
+					Type: JSStreamCorruptAdvisoryType,
+					ID:   nuid.Next(),
+					Time: now,
+				},
+				Server:   s.Name(),
+				ServerID: s.ID(),
+				Account:  mset.accName(),
+				Stream:   mset.name(),
+				Cluster:  s.cachedClusterName(),
+				Domain:   s.getOpts().JetStreamDomain,
+				Block:    r.blk,
+				FirstSeq: r.first,
+				LastSeq:  r.last,
+				Error:    r.err.Error(),
+				Repair:   repair,
+			})
+		}
+		if repair {
+			s.Noticef("Requested repair of %d corrupt ranges of stream '%s > %s'", len(bad), mset.accName(), mset.name())
+		}
+	}
+
+	js.mu.Lock()
+	now := time.Now().UTC()
+	js.scrub.Completed = &now
+	ss := *js.scrub
+	js.mu.Unlock()
+
+	if corrupt > 0 {
+		s.Warnf("JetStream scrub completed, %d corrupt ranges in %d blocks of %d streams", corrupt, ss.Blocks, ss.Streams)
+	} else {
+		s.Debugf("JetStream scrub completed, %d records in %d blocks of %d streams", ss.Records, ss.Blocks, ss.Streams)
+	}
+}
+
+// Ask our monitor routine to repair the corrupt ranges of our store from a healthy peer.
+// Returns false if we are not replicated, or a repair is already pending.
+func (mset *stream) requestRepair(bad []scrubRange) bool {
+	mset.mu.RLock()
+	n, rpch := mset.node, mset.rpch
+	mset.mu.RUnlock()
+	if n == nil || len(n.Peers()) <= 1 {
+		return false
+	}
+	select {
+	case rpch <- bad:
+		return true
+	default:
+		return false
+	}
+}
+
+// Repair the corrupt ranges of our store. Good copies of only the corrupt messages are
+// fetched from the leader through the catchup path, and written back in place so the
+// rest of the store is left alone.
+// Should only be called from the monitor routine when we are not the leader.
+func (mset *stream) repairFromPeer(bad []scrubRange) error {
+	fs, ok := mset.store.(*fileStore)
+	if !ok {
+		return nil
+	}
+	s := mset.srv
+	for _, r := range bad {
+		msgs, err := mset.fetchFromLeader(r.first, r.last)
+		if err != nil {
+			return err
+		}
+		if err := fs.repairRange(r.blk, r.first, r.last, msgs); err != nil {
+			return err
+		}
+		s.Noticef("Repaired messages [%d-%d] in block %d of stream '%s > %s' from a peer",
+			r.first, r.last, r.blk, mset.accName(), mset.name())
+	}
+	return nil
+}
+
+// Fetch the messages in [first, last] from the leader through the catchup path, without
+// storing them. Deleted messages are not returned.
+func (mset *stream) fetchFromLeader(first, last uint64) ([]*StoreMsg, error) {
+	mset.mu.RLock()
+	s, subject, n := mset.srv, mset.sa.Sync, mset.node
+	qname := fmt.Sprintf("[ACC:%s] stream '%s' repair", mset.acc.Name, mset.cfg.Name)
+	mset.mu.RUnlock()
+
+	if subject == _EMPTY_ || n == nil {
+		return nil, errCatchupCorruptSnapshot
+	}
+	const (
+		startInterval    = 5 * time.Second
+		activityInterval = 30 * time.Second
+	)
+
+	// We could have just stepped down, so give the new leader a moment to be elected.
+	for deadline := time.Now().Add(startInterval); n.Leaderless(); {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w for stream '%s > %s'", errCatchupAbortedNoLeader, mset.account(), mset.name())
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-s.quitCh:
+			return nil, ErrServerNotRunning
+		case <-n.QuitC():
+			return nil, errCatchupStreamStopped
+		}
+	}
+
+	type im struct {
+		msg   []byte
+		reply string
+	}
+	msgsQ := newIPQueue[*im](s, qname)
+	defer msgsQ.unregister()
+
+	reply := syncReplySubject()
+	sub, err := s.sysSubscribe(reply, func(_ *subscription, _ *client, _ *Account, _, reply string, msg []byte) {
+		// Make copy since we are using a buffer from the inbound client/route.
+		msgsQ.push(&im{copyBytes(msg), reply})
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer s.sysUnsubscribe(sub)
+
+	sreq := &streamSyncRequest{FirstSeq: first, LastSeq: last, Peer: n.ID(), DeleteRangesOk: true}
+	b, _ := json.Marshal(sreq)
+	s.sendInternalMsgLocked(subject, reply, nil, b)
+
+	notActive := time.NewTimer(startInterval)
+	defer notActive.Stop()
+
+	var msgs []*StoreMsg
+	for qch := n.QuitC(); ; {
+		select {
+		case <-msgsQ.ch:
+			mrecs := msgsQ.pop()
+			for _, mrec := range mrecs {
+				// Check for eof signaling.
+				if len(mrec.msg) == 0 {
+					msgsQ.recycle(&mrecs)
+					return msgs, nil
+				}
+				sm, err := decodeRepairMsg(mrec.msg)
+				if err != nil {
+					if mrec.reply != _EMPTY_ {
+						s.sendInternalMsgLocked(mrec.reply, _EMPTY_, nil, err.Error())
+					}
+					msgsQ.recycle(&mrecs)
+					return nil, err
+				}
+				if sm != nil && sm.seq >= first && sm.seq <= last {
+					msgs = append(msgs, sm)
+				}
+				if mrec.reply != _EMPTY_ {
+					s.sendInternalMsgLocked(mrec.reply, _EMPTY_, nil, nil)
+				}
+			}
+			msgsQ.recycle(&mrecs)
+			notActive.Reset(activityInterval)
+		case <-notActive.C:
+			return nil, errCatchupStalled
+		case <-s.quitCh:
+			return nil, ErrServerNotRunning
+		case <-qch:
+			return nil, errCatchupStreamStopped
+		}
+	}
+}
+
+// Decode a catchup message for a repair. Returns nil for deleted messages.
+func decodeRepairMsg(msg []byte) (*StoreMsg, error) {
+	op, mbuf := entryOp(msg[0]), msg[1:]
+	switch op {
+	case deleteRangeOp:
+		return nil, nil
+	case compressedStreamMsgOp:
+		var err error
+		if mbuf, err = s2.Decode(nil, mbuf); err != nil {
+			return nil, errCatchupBadMsg
+		}
+	case streamMsgOp:
+	default:
+		return nil, errCatchupBadMsg
+	}
+	subj, _, hdr, data, seq, ts, _, err := decodeStreamMsg(mbuf)
+	if err != nil {
+		return nil, errCatchupBadMsg
+	}
+	// Messages that were skipped have no subject or timestamp.
+	if subj == _EMPTY_ && ts == 0 {
+		return nil, nil
+	}
+	return &StoreMsg{subj: subj, hdr: hdr, msg: data, seq: seq, ts: ts}, nil
+}
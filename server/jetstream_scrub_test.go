@@ -0,0 +1,193 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestJetStreamScrubReportsCorruption(t *testing.T) {
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: { store_dir: %q, scrub_interval: "1h", scrub_rate: 1MB }
+		accounts: {
+			A: { jetstream: enabled, users: [{user: a, password: a}] }
+			SYS: { users: [{user: sys, password: sys}] }
+		}
+		system_account: SYS
+		no_auth_user: a
+	`, t.TempDir())))
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+	require_Equal(t, opts.JetStreamScrubInterval, time.Hour)
+	require_Equal(t, opts.JetStreamScrubRate, 1024*1024)
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	for _, name := range []string{"TEST", "OTHER"} {
+		_, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{name}})
+		require_NoError(t, err)
+		for i := 0; i < 20; i++ {
+			_, err = js.Publish(name, []byte("Hello World"))
+			require_NoError(t, err)
+		}
+	}
+	_, err := js.AddStream(&nats.StreamConfig{Name: "MEM", Subjects: []string{"mem"}, Storage: nats.MemoryStorage})
+	require_NoError(t, err)
+
+	sysnc, err := nats.Connect(s.ClientURL(), nats.UserInfo("sys", "sys"))
+	require_NoError(t, err)
+	defer sysnc.Close()
+	sub, err := sysnc.SubscribeSync(JSAdvisoryStreamCorruptPre + ".>")
+	require_NoError(t, err)
+	require_NoError(t, sysnc.Flush())
+
+	sjs := s.getJetStream()
+	s.scrubJetStream(sjs)
+	ss := sjs.scrubStatus()
+	require_True(t, ss != nil && ss.Completed != nil)
+	require_Equal(t, ss.Streams, 2)
+	require_Equal(t, ss.Records, 40)
+	require_Len(t, len(ss.Corrupt), 0)
+
+	acc, err := s.LookupAccount("A")
+	require_NoError(t, err)
+	mset, err := acc.lookupStream("TEST")
+	require_NoError(t, err)
+	corruptStoredMsg(t, mset.store.(*fileStore), 7)
+
+	s.scrubJetStream(sjs)
+	jsz, err := s.Jsz(nil)
+	require_NoError(t, err)
+	require_True(t, jsz.Scrub != nil)
+	require_Len(t, len(jsz.Scrub.Corrupt), 1)
+	sc := jsz.Scrub.Corrupt[0]
+	require_Equal(t, sc.Account, "A")
+	require_Equal(t, sc.Stream, "TEST")
+	require_Equal(t, sc.FirstSeq, 7)
+	require_Equal(t, sc.LastSeq, 7)
+	// Not replicated, so nothing to repair from.
+	require_False(t, sc.Repair)
+
+	msg, err := sub.NextMsg(time.Second)
+	require_NoError(t, err)
+	require_Equal(t, msg.Subject, JSAdvisoryStreamCorruptPre+".TEST")
+	var adv JSStreamCorruptAdvisory
+	require_NoError(t, json.Unmarshal(msg.Data, &adv))
+	require_Equal(t, adv.Type, JSStreamCorruptAdvisoryType)
+	require_Equal(t, adv.Account, "A")
+	require_Equal(t, adv.FirstSeq, 7)
+	require_Equal(t, adv.LastSeq, 7)
+	require_Equal(t, adv.ServerID, s.ID())
+}
+
+func TestJetStreamClusterScrubRepair(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = js.Publish("foo", []byte("Hello World"))
+		require_NoError(t, err)
+	}
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		return checkState(t, c, globalAccountName, "TEST")
+	})
+
+	// Corrupt a message on a follower.
+	s := c.randomNonStreamLeader(globalAccountName, "TEST")
+	mset, err := s.globalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	fs := mset.store.(*fileStore)
+	corruptStoredMsg(t, fs, 50)
+
+	sjs := s.getJetStream()
+	s.scrubJetStream(sjs)
+	ss := sjs.scrubStatus()
+	require_Len(t, len(ss.Corrupt), 1)
+	require_Equal(t, ss.Corrupt[0].FirstSeq, 50)
+	require_True(t, ss.Corrupt[0].Repair)
+
+	// Only the corrupt message is fetched from a healthy peer.
+	checkFor(t, 10*time.Second, 100*time.Millisecond, func() error {
+		_, _, bad, err := fs.scrub(func(int) error { return nil })
+		if err != nil {
+			return err
+		}
+		if len(bad) > 0 {
+			return fmt.Errorf("still corrupt: %+v", bad)
+		}
+		if state := fs.State(); state.Msgs != 100 || state.LastSeq != 100 {
+			return fmt.Errorf("unexpected state: %+v", state)
+		}
+		return nil
+	})
+	sm, err := fs.LoadMsg(50, nil)
+	require_NoError(t, err)
+	require_Equal(t, string(sm.msg), "Hello World")
+
+	// Still in sync with the others.
+	_, err = js.Publish("foo", []byte("Hello World"))
+	require_NoError(t, err)
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		return checkState(t, c, globalAccountName, "TEST")
+	})
+
+	// A leader does not step down because of a scrub, it repairs once it is no longer leader.
+	sl := c.streamLeader(globalAccountName, "TEST")
+	mset, err = sl.globalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	fs = mset.store.(*fileStore)
+	corruptStoredMsg(t, fs, 20)
+
+	sjs = sl.getJetStream()
+	sl.scrubJetStream(sjs)
+	ss = sjs.scrubStatus()
+	require_Len(t, len(ss.Corrupt), 1)
+	require_True(t, ss.Corrupt[0].Repair)
+	time.Sleep(250 * time.Millisecond)
+	require_True(t, mset.isLeader())
+
+	_, err = nc.Request(fmt.Sprintf(JSApiStreamLeaderStepDownT, "TEST"), nil, time.Second)
+	require_NoError(t, err)
+	checkFor(t, 10*time.Second, 100*time.Millisecond, func() error {
+		if mset, err = sl.globalAccount().lookupStream("TEST"); err != nil {
+			return err
+		}
+		fs = mset.store.(*fileStore)
+		_, _, bad, err := fs.scrub(func(int) error { return nil })
+		if err != nil {
+			return err
+		}
+		if len(bad) > 0 {
+			return fmt.Errorf("still corrupt: %+v", bad)
+		}
+		return nil
+	})
+	sm, err = fs.LoadMsg(20, nil)
+	require_NoError(t, err)
+	require_Equal(t, string(sm.msg), "Hello World")
+}
@@ -41,6 +41,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nats-server/v2/server/sysmem"
 	"github.com/nats-io/nats.go"
@@ -22640,6 +22641,175 @@ func TestJetStreamDirectGetBatch(t *testing.T) {
 	checkResponses(sub, 3, "foo.foo", "foo.bar", "foo.baz", _EMPTY_)
 }
 
+func TestJetStreamZstdCompression(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	var samples [][]byte
+	for i := 0; i < 100; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"order":%d,"customer":"customer-%d","status":"shipped","items":[{"sku":"sku-%d"}]}`, i, i%7, i%13)))
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{ID: 1, Contents: samples, History: bytes.Join(samples, nil)})
+	require_NoError(t, err)
+
+	cfg := &StreamConfig{
+		Name:            "TEST",
+		Storage:         FileStorage,
+		Subjects:        []string{"foo"},
+		Compression:     ZstdCompression,
+		CompressionOpts: &StoreCompressionOpts{Level: 3, Dictionary: dict},
+	}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_NoError(t, err)
+
+	for _, msg := range samples {
+		_, err = js.Publish("foo", msg)
+		require_NoError(t, err)
+	}
+	rsm, err := js.GetMsg("TEST", 10)
+	require_NoError(t, err)
+	require_True(t, bytes.Equal(rsm.Data, samples[9]))
+
+	// The level can be changed, but not the dictionary.
+	cfg.CompressionOpts = &StoreCompressionOpts{Level: 9, Dictionary: dict}
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_NoError(t, err)
+	cfg.CompressionOpts = &StoreCompressionOpts{Level: 9}
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_Error(t, err, errors.New("compression dictionary can not be changed after stream creation"))
+
+	// Since the dictionary is kept, so is the algorithm.
+	cfg.Compression = S2Compression
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_Error(t, err, errors.New("compression options require zstd compression"))
+
+	// Without a dictionary switching algorithms is allowed, existing blocks stay readable.
+	cfg = &StreamConfig{
+		Name:            "LEVEL",
+		Storage:         FileStorage,
+		Subjects:        []string{"bar"},
+		Compression:     ZstdCompression,
+		CompressionOpts: &StoreCompressionOpts{Level: 3},
+	}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_NoError(t, err)
+	_, err = js.Publish("bar", samples[0])
+	require_NoError(t, err)
+	cfg.Compression, cfg.CompressionOpts = S2Compression, nil
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_NoError(t, err)
+	rsm, err = js.GetMsg("LEVEL", 1)
+	require_NoError(t, err)
+	require_True(t, bytes.Equal(rsm.Data, samples[0]))
+
+	for _, bad := range []struct {
+		cmp  StoreCompression
+		opts *StoreCompressionOpts
+	}{
+		{ZstdCompression, &StoreCompressionOpts{Level: 23}},
+		{ZstdCompression, &StoreCompressionOpts{Dictionary: []byte("not a dictionary")}},
+		{S2Compression, &StoreCompressionOpts{Level: 3}},
+		{NoCompression, &StoreCompressionOpts{Dictionary: dict}},
+	} {
+		_, err = jsStreamCreate(t, nc, &StreamConfig{
+			Name:            "BAD",
+			Storage:         FileStorage,
+			Subjects:        []string{"bad"},
+			Compression:     bad.cmp,
+			CompressionOpts: bad.opts,
+		})
+		require_NotNil(t, err)
+	}
+}
+
+func TestJetStreamColdTier(t *testing.T) {
+	storeDir, coldDir := t.TempDir(), t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		server_name: S1
+		listen: 127.0.0.1:-1
+		jetstream: {store_dir: %q, cold_store_dir: %q}
+	`, storeDir, coldDir)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	cfg := &StreamConfig{
+		Name:     "TEST",
+		Storage:  FileStorage,
+		Subjects: []string{"foo"},
+		ColdTier: &StreamColdTier{MaxBytes: 1024},
+	}
+	_, err := jsStreamCreate(t, nc, cfg)
+	require_NoError(t, err)
+
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	fs := mset.store.(*fileStore)
+	fs.mu.Lock()
+	fs.fcfg.BlockSize = 1024
+	fs.mu.Unlock()
+
+	msg := bytes.Repeat([]byte("Z"), 100)
+	for i := 0; i < 50; i++ {
+		_, err = js.Publish("foo", msg)
+		require_NoError(t, err)
+	}
+	fs.syncBlocks()
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 50)
+	state := mset.state()
+	require_True(t, state.ColdBytes > 0)
+	require_True(t, state.HotBytes > 0)
+	require_Equal(t, state.HotBytes+state.ColdBytes, state.Bytes)
+
+	// Blocks are kept per server, since replicas could share the cold store.
+	entries, err := os.ReadDir(filepath.Join(coldDir, s.NodeName(), globalAccountName, streamsDir, "TEST"))
+	require_NoError(t, err)
+	require_True(t, len(entries) > 0)
+
+	// Messages in the cold tier can still be consumed.
+	sub, err := js.SubscribeSync("foo")
+	require_NoError(t, err)
+	defer sub.Unsubscribe()
+	for i := 0; i < 50; i++ {
+		m, err := sub.NextMsg(time.Second)
+		require_NoError(t, err)
+		require_True(t, bytes.Equal(m.Data, msg))
+	}
+
+	// The server's blocks are found again after a restart.
+	nc.Close()
+	s.Shutdown()
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+	nc, js = jsClientConnect(t, s)
+	defer nc.Close()
+	for _, seq := range []uint64{1, 50} {
+		m, err := js.GetMsg("TEST", seq)
+		require_NoError(t, err)
+		require_True(t, bytes.Equal(m.Data, msg))
+	}
+
+	for _, test := range []struct {
+		cfg *StreamConfig
+		err string
+	}{
+		{&StreamConfig{Name: "MEM", Storage: MemoryStorage, ColdTier: &StreamColdTier{MaxAge: time.Hour}}, "cold tier requires file storage"},
+		{&StreamConfig{Name: "NEG", Storage: FileStorage, ColdTier: &StreamColdTier{MaxBytes: -1}}, "cold tier thresholds can not be negative"},
+		{&StreamConfig{Name: "NONE", Storage: FileStorage, ColdTier: &StreamColdTier{}}, "cold tier requires a max age or max bytes threshold"},
+	} {
+		_, err = jsStreamCreate(t, nc, test.cfg)
+		require_Error(t, err, errors.New(test.err))
+	}
+}
+
 func TestJetStreamDirectGetBatchMaxBytes(t *testing.T) {
 	s := RunBasicJetStreamServer(t)
 	defer s.Shutdown()
@@ -17,7 +17,7 @@ import "strconv"
 
 const (
 	// JSApiLevel is the maximum supported JetStream API level for this server.
-	JSApiLevel int = 1
+	JSApiLevel int = 2
 
 	JSRequiredLevelMetadataKey = "_nats.req.level"
 	JSServerVersionMetadataKey = "_nats.ver"
@@ -49,6 +49,14 @@ func setStaticStreamMetadata(cfg *StreamConfig, _ *StreamConfig) {
 		requires(1)
 	}
 
+	// Atomic batch publishing, message schedules, counters, zstd compression, the cold tier,
+	// payload schemas and learners were added in v2.12 and require API level 2.
+	if cfg.AllowAtomicPublish || cfg.AllowMsgSchedules || cfg.AllowMsgCounter ||
+		cfg.Compression == ZstdCompression || cfg.CompressionOpts != nil || cfg.ColdTier != nil ||
+		len(cfg.Schemas) > 0 || cfg.Placement != nil && cfg.Placement.Learners != nil {
+		requires(2)
+	}
+
 	cfg.Metadata[JSRequiredLevelMetadataKey] = strconv.Itoa(requiredApiLevel)
 }
 
@@ -95,6 +103,14 @@ func setStaticConsumerMetadata(cfg *ConsumerConfig, _ *ConsumerConfig) {
 		requires(1)
 	}
 
+	// Dead-letter subjects, ordering keys, partitions, header filters, stop positions, replay speed,
+	// message or byte rate limits and latency stats were added in v2.12 and require API level 2.
+	if cfg.DeadLetterSubject != _EMPTY_ || cfg.OrderingKey != nil || cfg.PriorityPolicy == PriorityPartitioned || cfg.Partitions > 0 ||
+		len(cfg.HeaderFilters) > 0 || cfg.OptStopSeq > 0 || cfg.OptStopTime != nil || cfg.ReplaySpeed > 0 ||
+		cfg.RateLimitMsgs > 0 || cfg.RateLimitBytes > 0 || cfg.LatencyStats {
+		requires(2)
+	}
+
 	cfg.Metadata[JSRequiredLevelMetadataKey] = strconv.Itoa(requiredApiLevel)
 }
 
@@ -84,6 +84,48 @@ func TestJetStreamSetStaticStreamMetadata(t *testing.T) {
 			prev:             nil,
 			expectedMetadata: metadataAtLevel("1"),
 		},
+		{
+			desc:             "create/AllowAtomicPublish",
+			cfg:              &StreamConfig{AllowAtomicPublish: true},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/AllowMsgSchedules",
+			cfg:              &StreamConfig{AllowMsgSchedules: true},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/AllowMsgCounter",
+			cfg:              &StreamConfig{AllowMsgCounter: true},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/ZstdCompression",
+			cfg:              &StreamConfig{Compression: ZstdCompression},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/ColdTier",
+			cfg:              &StreamConfig{ColdTier: &StreamColdTier{MaxAge: time.Hour}},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/Schemas",
+			cfg:              &StreamConfig{Schemas: []*StreamSchema{{Subject: "foo", Schema: json.RawMessage(`true`)}}},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/Learners",
+			cfg:              &StreamConfig{Placement: &Placement{Learners: &LearnerPlacement{Replicas: 2}}},
+			prev:             nil,
+			expectedMetadata: metadataAtLevel("2"),
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			setStaticStreamMetadata(test.cfg, test.prev)
@@ -179,6 +221,54 @@ func TestJetStreamSetStaticConsumerMetadata(t *testing.T) {
 			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
 			expectedMetadata: metadataAtLevel("1"),
 		},
+		{
+			desc:             "create/DeadLetterSubject",
+			cfg:              &ConsumerConfig{DeadLetterSubject: "dlq"},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/PriorityPartitioned",
+			cfg:              &ConsumerConfig{PriorityPolicy: PriorityPartitioned, Partitions: 4},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/HeaderFilters",
+			cfg:              &ConsumerConfig{HeaderFilters: []HeaderFilter{{Header: "Tenant"}}},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/OptStopSeq",
+			cfg:              &ConsumerConfig{OptStopSeq: 10},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/ReplaySpeed",
+			cfg:              &ConsumerConfig{ReplayPolicy: ReplayOriginal, ReplaySpeed: 2},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/RateLimitMsgs",
+			cfg:              &ConsumerConfig{RateLimitMsgs: 100},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/LatencyStats",
+			cfg:              &ConsumerConfig{LatencyStats: true},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
+		{
+			desc:             "create/OrderingKey",
+			cfg:              &ConsumerConfig{OrderingKey: &ConsumerOrderingKey{SubjectToken: 1}},
+			prev:             &ConsumerConfig{Metadata: metadataPrevious()},
+			expectedMetadata: metadataAtLevel("2"),
+		},
 		{
 			desc:             "update/empty-prev-metadata",
 			cfg:              &ConsumerConfig{},
@@ -1913,6 +1913,15 @@ func (ms *memStore) SyncDeleted(dbs DeleteBlocks) {
 }
 
 func (o *consumerMemStore) Update(state *ConsumerState) error {
+	return o.setState(state, false)
+}
+
+// Reset replaces our state, allowing the stream positions to move backwards.
+func (o *consumerMemStore) Reset(state *ConsumerState) error {
+	return o.setState(state, true)
+}
+
+func (o *consumerMemStore) setState(state *ConsumerState, reset bool) error {
 	// Sanity checks.
 	if state.AckFloor.Consumer > state.Delivered.Consumer {
 		return fmt.Errorf("bad ack floor for consumer")
@@ -1945,7 +1954,7 @@ func (o *consumerMemStore) Update(state *ConsumerState) error {
 	defer o.mu.Unlock()
 
 	// Check to see if this is an outdated update.
-	if state.Delivered.Consumer < o.state.Delivered.Consumer || state.AckFloor.Stream < o.state.AckFloor.Stream {
+	if !reset && (state.Delivered.Consumer < o.state.Delivered.Consumer || state.AckFloor.Stream < o.state.AckFloor.Stream) {
 		return fmt.Errorf("old update ignored")
 	}
 
@@ -1978,7 +1987,13 @@ func (o *consumerMemStore) UpdateDelivered(dseq, sseq, dc uint64, ts int64) erro
 		return ErrNoAckPolicy
 	}
 
-	if dseq <= o.state.AckFloor.Consumer {
+	// On restarts the old leader may get a replay from the raft logs that are old.
+	// Messages held back from delivery have no consumer sequence, so check their pending record instead.
+	if dseq == 0 {
+		if sseq <= o.state.Delivered.Stream && !o.state.isHeldPending(sseq) {
+			return nil
+		}
+	} else if dseq <= o.state.AckFloor.Consumer {
 		return nil
 	}
 
@@ -2044,9 +2059,14 @@ func (o *consumerMemStore) UpdateAcks(dseq, sseq uint64) error {
 	}
 
 	// On restarts the old leader may get a replay from the raft logs that are old.
-	if dseq <= o.state.AckFloor.Consumer {
+	if dseq == 0 {
+		if !o.state.isHeldPending(sseq) {
+			return nil
+		}
+	} else if dseq <= o.state.AckFloor.Consumer {
 		return nil
 	}
+	prev := o.state.AckFloor.Stream
 
 	// Match leader logic on checking if ack is ahead of delivered.
 	// This could happen on a cooperative takeover with high speed deliveries.
@@ -2109,6 +2129,10 @@ func (o *consumerMemStore) UpdateAcks(dseq, sseq uint64) error {
 			}
 		}
 	}
+	// Messages held back for their ordering key are delivered out of order.
+	if o.cfg.OrderingKey != nil {
+		o.state.holdAckFloor(prev)
+	}
 	// We do these regardless.
 	delete(o.state.Redelivered, sseq)
 
@@ -2843,6 +2843,12 @@ type JSInfo struct {
 	Messages  uint64           `json:"messages"`
 	Bytes     uint64           `json:"bytes"`
 	Meta      *MetaClusterInfo `json:"meta_cluster,omitempty"`
+	// Progress of the last online encryption key rotation, if any.
+	KeyRotation *KeyRotationStatus `json:"key_rotation,omitempty"`
+	// Results of the last integrity scrub, if any.
+	Scrub *ScrubStatus `json:"scrub,omitempty"`
+	// Progress of the replica rebalancer, only reported by the meta leader.
+	Rebalance *RebalanceStatus `json:"rebalance,omitempty"`
 
 	// aggregate raft info
 	AccountDetails []*AccountDetail `json:"account_details,omitempty"`
@@ -3037,6 +3043,9 @@ func (s *Server) Jsz(opts *JSzOptions) (*JSInfo, error) {
 	}
 
 	jsi.JetStreamStats = *js.usageStats()
+	jsi.KeyRotation = js.keyRotationStatus()
+	jsi.Scrub = js.scrubStatus()
+	jsi.Rebalance = js.rebalanceStatus()
 
 	filterIdx := -1
 	for i, jsa := range accounts {
@@ -3396,7 +3405,7 @@ func (s *Server) healthz(opts *HealthzOptions) *HealthStatus {
 		fis, _ := os.ReadDir(sdir)
 		var accFound, streamFound, consumerFound bool
 		for _, fi := range fis {
-			if fi.Name() == snapStagingDir {
+			if !fi.IsDir() || fi.Name() == snapStagingDir {
 				continue
 			}
 			if opts.Account != _EMPTY_ {
@@ -261,6 +261,29 @@ type JSTpmOpts struct {
 	Pcr         int
 }
 
+// JSLeaderBalanceOpts configures how the meta leader spreads stream and consumer leaders by their load.
+type JSLeaderBalanceOpts struct {
+	// Interval between balancing rounds, zero disables balancing.
+	Interval time.Duration
+	// MaxTransfers is the maximum number of leaders moved per round.
+	MaxTransfers int
+	// Threshold is how far the load of a server can be above the average before we move any
+	// of its leaders. Each leader adds one to the load, plus more for its message and byte rates.
+	Threshold int
+	// Tags limits balancing to servers that have all of these tags.
+	Tags jwt.TagList
+}
+
+// JSRebalanceOpts configures how the meta leader moves stream replicas onto underused servers.
+type JSRebalanceOpts struct {
+	// Interval between rebalancing rounds, zero disables rebalancing.
+	Interval time.Duration
+	// MaxMoves is the maximum number of stream moves in progress at any time.
+	MaxMoves int
+	// Threshold is how many assets a server can host above the average before we move any.
+	Threshold int
+}
+
 // AuthCallout option used to map external AuthN to NATS based AuthZ.
 type AuthCallout struct {
 	// Must be a public account Nkey.
@@ -335,11 +358,17 @@ type Options struct {
 	JetStreamUniqueTag         string
 	JetStreamLimits            JSLimitOpts
 	JetStreamTpm               JSTpmOpts
+	JetStreamKeyProvider       JetStreamKeyProvider
+	JetStreamScrubInterval     time.Duration
+	JetStreamScrubRate         int64
+	JetStreamLeaderBalance     JSLeaderBalanceOpts
+	JetStreamRebalance         JSRebalanceOpts
 	JetStreamMaxCatchup        int64
 	JetStreamRequestQueueLimit int64
 	StreamMaxBufferedMsgs      int               `json:"-"`
 	StreamMaxBufferedSize      int64             `json:"-"`
 	StoreDir                   string            `json:"-"`
+	JetStreamColdStoreDir      string            `json:"-"`
 	SyncInterval               time.Duration     `json:"-"`
 	SyncAlways                 bool              `json:"-"`
 	JsAccDefaultDomain         map[string]string `json:"-"` // account to domain name mapping
@@ -404,6 +433,15 @@ type Options struct {
 	// CheckConfig configuration file syntax test was successful and exit.
 	CheckConfig bool `json:"-"`
 
+	// StoreInspect is a JetStream store directory to inspect offline, see InspectStore.
+	StoreInspect string `json:"-"`
+	// StoreRepair rebuilds the index and truncates torn tails of all streams being inspected.
+	StoreRepair bool `json:"-"`
+	// StoreExport is a stream, as <account>/<stream>, to export from the store being inspected.
+	StoreExport string `json:"-"`
+	// StoreExportFile is the snapshot file the stream is exported to.
+	StoreExportFile string `json:"-"`
+
 	// DisableJetStreamBanner will not print the ascii art on startup for JetStream enabled servers
 	DisableJetStreamBanner bool `json:"-"`
 
@@ -2327,6 +2365,180 @@ func parseJetStreamTPM(v interface{}, opts *Options, errors *[]error) error {
 	return nil
 }
 
+// Parse the JetStream leader balancing options.
+func parseJetStreamLeaderBalance(v any, opts *Options, errors, warnings *[]error) error {
+	var lt token
+	tk, v := unwrapValue(v, &lt)
+
+	lb := JSLeaderBalanceOpts{}
+
+	vv, ok := v.(map[string]any)
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected a map to define JetStream leader balancing, got %T", v)}
+	}
+	for mk, mv := range vv {
+		tk, mv = unwrapValue(mv, &lt)
+		switch strings.ToLower(mk) {
+		case "interval":
+			lb.Interval = parseDuration(mk, tk, mv, errors, warnings)
+		case "max_transfers":
+			lb.MaxTransfers = int(mv.(int64))
+		case "threshold":
+			lb.Threshold = int(mv.(int64))
+		case "tags":
+			switch tv := mv.(type) {
+			case string:
+				lb.Tags.Add(tv)
+			case []any:
+				for _, t := range tv {
+					tk, t = unwrapValue(t, &lt)
+					ts, ok := t.(string)
+					if !ok {
+						return &configErr{tk, fmt.Sprintf("error parsing leader balance tags: unsupported type %T where string is expected", t)}
+					}
+					lb.Tags.Add(ts)
+				}
+			default:
+				return &configErr{tk, fmt.Sprintf("error parsing leader balance tags: unsupported type %T", mv)}
+			}
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+				continue
+			}
+		}
+	}
+	if lb.MaxTransfers < 0 || lb.Threshold < 0 {
+		return &configErr{tk, "JetStream leader balancing max_transfers and threshold can not be negative"}
+	}
+	opts.JetStreamLeaderBalance = lb
+	return nil
+}
+
+// Parse the JetStream replica rebalancing options.
+func parseJetStreamRebalance(v any, opts *Options, errors, warnings *[]error) error {
+	var lt token
+	tk, v := unwrapValue(v, &lt)
+
+	rb := JSRebalanceOpts{}
+
+	vv, ok := v.(map[string]any)
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected a map to define JetStream rebalancing, got %T", v)}
+	}
+	for mk, mv := range vv {
+		tk, mv = unwrapValue(mv, &lt)
+		switch strings.ToLower(mk) {
+		case "interval":
+			rb.Interval = parseDuration(mk, tk, mv, errors, warnings)
+		case "max_moves":
+			rb.MaxMoves = int(mv.(int64))
+		case "threshold":
+			rb.Threshold = int(mv.(int64))
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+				continue
+			}
+		}
+	}
+	if rb.MaxMoves < 0 || rb.Threshold < 0 {
+		return &configErr{tk, "JetStream rebalancing max_moves and threshold can not be negative"}
+	}
+	opts.JetStreamRebalance = rb
+	return nil
+}
+
+func parseJetStreamKeyProvider(v interface{}, opts *Options, errors *[]error) error {
+	var lt token
+	tk, v := unwrapValue(v, &lt)
+
+	vv, ok := v.(map[string]interface{})
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected a map to define a JetStream key provider, got %T", v)}
+	}
+	var (
+		kind    string
+		dir     string
+		command string
+		args    []string
+		timeout time.Duration
+	)
+	for mk, mv := range vv {
+		tk, mv = unwrapValue(mv, &lt)
+		switch strings.ToLower(mk) {
+		case "type":
+			kind = strings.ToLower(mv.(string))
+		case "dir", "directory":
+			dir = mv.(string)
+		case "command", "cmd":
+			command = mv.(string)
+		case "args":
+			av, ok := mv.([]interface{})
+			if !ok {
+				return &configErr{tk, fmt.Sprintf("Expected an array of strings for %q, got %T", mk, mv)}
+			}
+			for _, a := range av {
+				atk, a := unwrapValue(a, &lt)
+				as, ok := a.(string)
+				if !ok {
+					return &configErr{atk, fmt.Sprintf("Expected a string for %q, got %T", mk, a)}
+				}
+				args = append(args, as)
+			}
+		case "timeout":
+			ts, ok := mv.(string)
+			if !ok {
+				return &configErr{tk, fmt.Sprintf("Expected a duration for %q, got %T", mk, mv)}
+			}
+			d, err := time.ParseDuration(ts)
+			if err != nil {
+				return &configErr{tk, fmt.Sprintf("error parsing %s: %v", mk, err)}
+			}
+			timeout = d
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+				continue
+			}
+		}
+	}
+
+	switch kind {
+	case "file":
+		if dir == _EMPTY_ {
+			return &configErr{tk, "File key provider requires a 'dir'"}
+		}
+		opts.JetStreamKeyProvider = &FileKeyProvider{Dir: dir}
+	case "exec":
+		if command == _EMPTY_ {
+			return &configErr{tk, "Exec key provider requires a 'command'"}
+		}
+		opts.JetStreamKeyProvider = &ExecKeyProvider{Command: command, Args: args, Timeout: timeout}
+	default:
+		return &configErr{tk, fmt.Sprintf("Unknown key provider type: %q", kind)}
+	}
+	return nil
+}
+
 func setJetStreamEkCipher(opts *Options, mv interface{}, tk token) error {
 	switch strings.ToLower(mv.(string)) {
 	case "chacha", "chachapoly":
@@ -2375,6 +2587,8 @@ func parseJetStream(v any, opts *Options, errors *[]error, warnings *[]error) er
 					return &configErr{tk, "Duplicate 'store_dir' configuration"}
 				}
 				opts.StoreDir = mv.(string)
+			case "cold_store_dir", "cold_store":
+				opts.JetStreamColdStoreDir = mv.(string)
 			case "sync", "sync_interval":
 				if v, ok := mv.(string); ok && strings.ToLower(v) == "always" {
 					opts.SyncInterval = defaultSyncInterval
@@ -2419,6 +2633,26 @@ func parseJetStream(v any, opts *Options, errors *[]error, warnings *[]error) er
 				if err := parseJetStreamTPM(tk, opts, errors); err != nil {
 					return err
 				}
+			case "key_provider":
+				if err := parseJetStreamKeyProvider(tk, opts, errors); err != nil {
+					return err
+				}
+			case "scrub_interval":
+				opts.JetStreamScrubInterval = parseDuration(mk, tk, mv, errors, warnings)
+			case "scrub_rate":
+				s, err := getStorageSize(mv)
+				if err != nil {
+					return &configErr{tk, fmt.Sprintf("%s %s", strings.ToLower(mk), err)}
+				}
+				opts.JetStreamScrubRate = s
+			case "leader_balance":
+				if err := parseJetStreamLeaderBalance(tk, opts, errors, warnings); err != nil {
+					return err
+				}
+			case "rebalance":
+				if err := parseJetStreamRebalance(tk, opts, errors, warnings); err != nil {
+					return err
+				}
 			case "unique_tag":
 				opts.JetStreamUniqueTag = strings.ToLower(strings.TrimSpace(mv.(string)))
 			case "max_outstanding_catchup":
@@ -5754,6 +5988,10 @@ func ConfigureOptions(fs *flag.FlagSet, args []string, printVersion, printHelp,
 	fs.BoolVar(&opts.JetStream, "jetstream", false, "Enable JetStream.")
 	fs.StringVar(&opts.StoreDir, "sd", _EMPTY_, "Storage directory.")
 	fs.StringVar(&opts.StoreDir, "store_dir", _EMPTY_, "Storage directory.")
+	fs.StringVar(&opts.StoreInspect, "store_inspect", _EMPTY_, "Inspect a JetStream storage directory offline and exit.")
+	fs.BoolVar(&opts.StoreRepair, "store_repair", false, "Rebuild indexes and truncate torn tails of the store being inspected.")
+	fs.StringVar(&opts.StoreExport, "store_export", _EMPTY_, "Export a stream, as <account>/<stream>, from the store being inspected.")
+	fs.StringVar(&opts.StoreExportFile, "store_export_file", _EMPTY_, "Snapshot file to export the stream to.")
 
 	// The flags definition above set "default" values to some of the options.
 	// Calling Parse() here will override the default options with any value
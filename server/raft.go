@@ -26,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -53,11 +54,14 @@ type RaftNode interface {
 	Healthy() bool
 	Term() uint64
 	Leaderless() bool
+	LeaseValid() bool
 	GroupLeader() string
 	HadPreviousLeader() bool
 	StepDown(preferred ...string) error
 	SetObserver(isObserver bool)
 	IsObserver() bool
+	SetLearners(learners []string)
+	IsLearner() bool
 	Campaign() error
 	ID() string
 	Group() string
@@ -102,6 +106,7 @@ type Peer struct {
 	Current bool
 	Last    time.Time
 	Lag     uint64
+	Learner bool
 }
 
 type RaftState uint8
@@ -173,6 +178,11 @@ type raft struct {
 	leader string // The ID of the leader
 	vote   string // Our current vote state
 	lxfer  bool   // Are we doing a leadership transfer?
+	xferto string // The peer our leader is transferring leadership to
+	pvote  bool   // Are we a candidate checking if we could win with a pre-vote?
+
+	sent  []aeSent // Append entries sent as leader within the lease period
+	sento uint64   // Ordinal of sent[0]
 
 	hcbehind bool // Were we falling behind at the last health check? (see: isCurrent)
 
@@ -185,7 +195,8 @@ type raft struct {
 	pleader   atomic.Bool // Has the group ever had a leader?
 	isSysAcc  atomic.Bool // Are we utilizing the system account?
 
-	observer bool // The node is observing, i.e. not participating in voting
+	observer bool                // The node is observing, i.e. not participating in voting
+	learners map[string]struct{} // Peers that replicate our log but do not vote
 
 	extSt extensionState // Extension state
 
@@ -240,8 +251,26 @@ type lps struct {
 	ts int64  // Last timestamp
 	li uint64 // Last index replicated
 	kp bool   // Known peer
+	la int64  // Send time of the latest append entry they acknowledged us as leader for
+	lm uint64 // Ordinal of the sent entry their last acknowledgement was matched to
+	lr uint64 // Index of their last matched acknowledgement
+}
+
+// aeSent records when we sent append entries as leader, so acknowledgements can be
+// credited to our lease from the time we sent the entry, not when the response arrived.
+// Entries sent close together with increasing indexes are merged, keeping the first
+// send time. Heartbeats always get their own record.
+type aeSent struct {
+	lo, hi uint64 // Range of indexes sent
+	ts     int64  // First send time
 }
 
+// How long apart append entries can be sent and still share an aeSent record.
+const aeSentMergeWindow = 10 * time.Millisecond
+
+// Upper bound on the number of aeSent records we keep.
+const aeSentMax = 1024
+
 const (
 	minElectionTimeoutDefault      = 4 * time.Second
 	maxElectionTimeoutDefault      = 9 * time.Second
@@ -251,6 +280,8 @@ const (
 	lostQuorumIntervalDefault      = hbIntervalDefault * 10 // 10 seconds
 	lostQuorumCheckIntervalDefault = hbIntervalDefault * 10 // 10 seconds
 	observerModeIntervalDefault    = 48 * time.Hour
+	// Margin for clock drift between a leader and its followers, as a fraction of the vote refusal window.
+	leaseDriftDivisor = 8
 )
 
 var (
@@ -264,12 +295,30 @@ var (
 	observerModeInterval = observerModeIntervalDefault
 )
 
+// Returns how long followers refuse to help elect anyone else after hearing from their leader.
+func voteRefusalWindow() time.Duration {
+	return minElectionTimeout / 2
+}
+
+// Returns how much the clocks of a leader and its followers are allowed to drift apart within a lease.
+func leaseClockDrift() time.Duration {
+	return voteRefusalWindow() / leaseDriftDivisor
+}
+
+// Returns how long a leader lease lasts. This has to expire before our followers would vote for
+// someone else, so is kept strictly below the vote refusal window minus the allowed clock drift.
+// Derived from the election timeout, since that can be tuned.
+func leaderLease() time.Duration {
+	return voteRefusalWindow() - 2*leaseClockDrift()
+}
+
 type RaftConfig struct {
 	Name     string
 	Store    string
 	Log      WAL
 	Track    bool
 	Observer bool
+	Learners []string
 }
 
 var (
@@ -497,16 +546,21 @@ func (s *Server) initRaftNode(accName string, cfg *RaftConfig, labels pprofLabel
 	}
 
 	// Make sure to track ourselves.
-	n.peers[n.id] = &lps{time.Now().UnixNano(), 0, true}
+	n.peers[n.id] = &lps{time.Now().UnixNano(), 0, true, 0, 0, 0}
 
 	// Track known peers
 	for _, peer := range ps.knownPeers {
 		if peer != n.id {
 			// Set these to 0 to start but mark as known peer.
-			n.peers[peer] = &lps{0, 0, true}
+			n.peers[peer] = &lps{0, 0, true, 0, 0, 0}
 		}
 	}
 
+	// Learners do not vote, so need to be known before we campaign.
+	if len(cfg.Learners) > 0 {
+		n.SetLearners(cfg.Learners)
+	}
+
 	n.debug("Started")
 
 	// Check if we need to start in observer mode due to lame duck status.
@@ -956,7 +1010,7 @@ func (n *raft) AdjustBootClusterSize(csz int) error {
 	// Adjust the cluster size and the number of nodes needed to establish
 	// a quorum.
 	n.csz = csz
-	n.qn = n.csz/2 + 1
+	n.updateQuorumLocked()
 
 	return nil
 }
@@ -976,7 +1030,7 @@ func (n *raft) AdjustClusterSize(csz int) error {
 	// Adjust the cluster size and the number of nodes needed to establish
 	// a quorum.
 	n.csz = csz
-	n.qn = n.csz/2 + 1
+	n.updateQuorumLocked()
 	n.Unlock()
 
 	n.sendPeerState()
@@ -1541,7 +1595,7 @@ func (n *raft) Leaderless() bool {
 func (n *raft) selectNextLeader() string {
 	nextLeader, hli := noLeader, uint64(0)
 	for peer, ps := range n.peers {
-		if peer == n.id || ps.li <= hli {
+		if peer == n.id || ps.li <= hli || n.isLearnerLocked(peer) {
 			continue
 		}
 		hli = ps.li
@@ -1576,8 +1630,8 @@ func (n *raft) StepDown(preferred ...string) error {
 		}
 	}
 
-	// Can't pick ourselves.
-	if maybeLeader == n.id {
+	// Can't pick ourselves, or a learner.
+	if maybeLeader == n.id || n.isLearnerLocked(maybeLeader) {
 		maybeLeader = noLeader
 		preferred = nil
 	}
@@ -1600,7 +1654,7 @@ func (n *raft) StepDown(preferred ...string) error {
 	// Make sure not ourselves.
 	if maybeLeader == noLeader {
 		for peer, ps := range n.peers {
-			if peer == n.id {
+			if peer == n.id || n.isLearnerLocked(peer) {
 				continue
 			}
 			si, ok := n.s.nodeToInfo.Load(peer)
@@ -1722,6 +1776,7 @@ func (n *raft) Peers() []*Peer {
 			Current: id == n.leader || ps.li >= n.applied,
 			Last:    time.Unix(0, ps.ts),
 			Lag:     lag,
+			Learner: n.isLearnerLocked(id),
 		}
 		peers = append(peers, p)
 	}
@@ -2027,6 +2082,54 @@ func (n *raft) IsObserver() bool {
 	return n.observer
 }
 
+// SetLearners sets the peers that replicate our log without voting.
+// Learners do not count towards our quorum and never become the leader,
+// so they do not add to the latency of committing entries.
+func (n *raft) SetLearners(learners []string) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.learners = nil
+	for _, peer := range learners {
+		if n.learners == nil {
+			n.learners = make(map[string]struct{}, len(learners))
+		}
+		n.learners[peer] = struct{}{}
+	}
+	n.updateQuorumLocked()
+
+	// Make sure we do not try to become the leader if we are one.
+	if n.isLearnerLocked(n.id) && n.State() == Leader {
+		n.stepdownLocked(n.selectNextLeader())
+	}
+}
+
+// IsLearner returns whether we replicate the log without voting.
+func (n *raft) IsLearner() bool {
+	n.RLock()
+	defer n.RUnlock()
+	return n.isLearnerLocked(n.id)
+}
+
+// Lock should be held.
+func (n *raft) isLearnerLocked(peer string) bool {
+	_, ok := n.learners[peer]
+	return ok
+}
+
+// Update the number of nodes needed to establish a quorum.
+// Learners that are part of our cluster do not vote.
+// Lock should be held.
+func (n *raft) updateQuorumLocked() {
+	voters := n.csz
+	for peer := range n.learners {
+		if _, ok := n.peers[peer]; ok {
+			voters--
+		}
+	}
+	n.qn = max(voters, 1)/2 + 1
+}
+
 // Sets the state to observer only.
 func (n *raft) SetObserver(isObserver bool) {
 	n.setObserver(isObserver, extUndetermined)
@@ -2597,6 +2700,37 @@ func (n *raft) runAsLeader() {
 	}
 }
 
+// LeaseValid reports whether we are the leader and hold a leader lease, meaning
+// a quorum acknowledged us as their leader within the lease period. Since our
+// followers refuse to help elect anyone else while they hear from us, no other
+// leader can exist while we hold the lease, so reads can be served locally.
+// Leases require all peers to support pre-votes, and us to have applied all
+// entries from prior terms.
+func (n *raft) LeaseValid() bool {
+	n.RLock()
+	defer n.RUnlock()
+
+	if n.State() != Leader || n.aflr > 0 {
+		return false
+	}
+	if len(n.peers) > 1 && !n.preVoteSupportedLocked() {
+		return false
+	}
+	now, nc, lease := time.Now().UnixNano(), 0, leaderLease()
+	for id, peer := range n.peers {
+		if n.isLearnerLocked(id) {
+			continue
+		}
+		if id == n.id || time.Duration(now-peer.la) < lease {
+			nc++
+			if nc >= n.qn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Quorum reports the quorum status. Will be called on former leaders.
 func (n *raft) Quorum() bool {
 	n.RLock()
@@ -2604,6 +2738,9 @@ func (n *raft) Quorum() bool {
 
 	now, nc := time.Now().UnixNano(), 0
 	for id, peer := range n.peers {
+		if n.isLearnerLocked(id) {
+			continue
+		}
 		if id == n.id || time.Duration(now-peer.ts) < lostQuorumInterval {
 			nc++
 			if nc >= n.qn {
@@ -2630,6 +2767,9 @@ func (n *raft) lostQuorumLocked() bool {
 
 	now, nc := time.Now().UnixNano(), 0
 	for id, peer := range n.peers {
+		if n.isLearnerLocked(id) {
+			continue
+		}
 		if id == n.id || time.Duration(now-peer.ts) < lostQuorumInterval {
 			nc++
 			if nc >= n.qn {
@@ -2940,7 +3080,7 @@ func (n *raft) applyCommit(index uint64) error {
 
 			if lp, ok := n.peers[newPeer]; !ok {
 				// We are not tracking this one automatically so we need to bump cluster size.
-				n.peers[newPeer] = &lps{time.Now().UnixNano(), 0, true}
+				n.peers[newPeer] = &lps{time.Now().UnixNano(), 0, true, 0, 0, 0}
 			} else {
 				// Mark as added.
 				lp.kp = true
@@ -3001,9 +3141,15 @@ func (n *raft) trackResponse(ar *appendEntryResponse) {
 
 	n.Lock()
 
-	// Update peer's last index.
-	if ps := n.peers[ar.peer]; ps != nil && ar.index > ps.li {
-		ps.li = ar.index
+	// Update peer's last index, this also acknowledges us as their leader
+	// as of when we sent the entry they are responding to.
+	if ps := n.peers[ar.peer]; ps != nil {
+		if ar.index > ps.li {
+			ps.li = ar.index
+		}
+		if ts := n.matchSentLocked(ps, ar.index); ts > ps.la {
+			ps.la = ts
+		}
 	}
 
 	// If we are tracking this peer as a catchup follower, update that here.
@@ -3020,7 +3166,8 @@ func (n *raft) trackResponse(ar *appendEntryResponse) {
 	// See if we have items to apply.
 	var sendHB bool
 
-	if results := n.acks[ar.index]; results != nil {
+	// Learners do not count towards committing entries.
+	if results := n.acks[ar.index]; results != nil && !n.isLearnerLocked(ar.peer) {
 		results[ar.peer] = struct{}{}
 		if nr := len(results); nr >= n.qn {
 			// We have a quorum.
@@ -3050,7 +3197,7 @@ func (n *raft) adjustClusterSizeAndQuorum() {
 		}
 	}
 	n.csz = ncsz
-	n.qn = n.csz/2 + 1
+	n.updateQuorumLocked()
 
 	if ncsz > pcsz {
 		n.debug("Expanding our clustersize: %d -> %d", pcsz, ncsz)
@@ -3082,7 +3229,7 @@ func (n *raft) trackPeer(peer string) error {
 	if ps := n.peers[peer]; ps != nil {
 		ps.ts = time.Now().UnixNano()
 	} else if !isRemoved {
-		n.peers[peer] = &lps{time.Now().UnixNano(), 0, false}
+		n.peers[peer] = &lps{time.Now().UnixNano(), 0, false, 0, 0, 0}
 	}
 	n.Unlock()
 
@@ -3096,10 +3243,15 @@ func (n *raft) runAsCandidate() {
 	n.Lock()
 	// Drain old responses.
 	n.votes.drain()
+	pvote := n.pvote
 	n.Unlock()
 
-	// Send out our request for votes.
-	n.requestVote()
+	// Send out our request for votes, or pre-votes if we need to check we could win first.
+	if pvote {
+		n.requestPreVote()
+	} else {
+		n.requestVote()
+	}
 
 	// We vote for ourselves.
 	votes := map[string]struct{}{
@@ -3131,10 +3283,31 @@ func (n *raft) runAsCandidate() {
 				continue
 			}
 			n.RLock()
-			nterm := n.term
+			nterm, pvote, learner := n.term, n.pvote, n.isLearnerLocked(vresp.peer)
 			n.RUnlock()
 
-			if vresp.granted && nterm == vresp.term {
+			// Learners do not vote, but could still let us know about a higher term.
+			if learner && vresp.granted {
+				continue
+			}
+
+			// Responses to pre-votes are for the term we would campaign with.
+			if vresp.preVote != pvote {
+				// Left over from a prior round.
+				if vresp.granted || vresp.term <= nterm {
+					continue
+				}
+			} else if pvote && vresp.granted && vresp.term == nterm+1 {
+				votes[vresp.peer] = struct{}{}
+				if n.wonElection(len(votes)) {
+					n.debug("Won pre-vote, starting election")
+					n.startElection()
+					votes = map[string]struct{}{n.ID(): {}}
+				}
+				continue
+			}
+
+			if vresp.granted && nterm == vresp.term && !pvote {
 				// only track peers that would be our followers
 				n.trackPeer(vresp.peer)
 				votes[vresp.peer] = struct{}{}
@@ -3283,6 +3456,9 @@ func (n *raft) resetWAL() {
 // Lock should be held
 func (n *raft) updateLeader(newLeader string) {
 	n.leader = newLeader
+	if newLeader != noLeader {
+		n.xferto = noLeader
+	}
 	n.hasleader.Store(newLeader != _EMPTY_)
 	if !n.pleader.Load() && newLeader != noLeader {
 		n.pleader.Store(true)
@@ -3364,7 +3540,7 @@ func (n *raft) processAppendEntry(ae *appendEntry, sub *subscription) {
 		if ps := n.peers[ae.leader]; ps != nil {
 			ps.ts = time.Now().UnixNano()
 		} else {
-			n.peers[ae.leader] = &lps{time.Now().UnixNano(), 0, true}
+			n.peers[ae.leader] = &lps{time.Now().UnixNano(), 0, true, 0, 0, 0}
 		}
 	}
 
@@ -3582,17 +3758,24 @@ CONTINUE:
 				// This is us. We need to check if we can become the leader.
 				if maybeLeader == n.id {
 					// If not an observer and not paused we are good to go.
-					if !n.observer && !n.paused {
+					if n.isLearnerLocked(n.id) {
+						n.debug("Ignoring leader transfer, we are a learner")
+					} else if !n.observer && !n.paused {
 						n.lxfer = true
 						n.xferCampaign()
 					} else if n.paused && !n.pobserver {
 						// Here we can become a leader but need to wait for resume of the apply queue.
 						n.lxfer = true
 					}
-				} else if n.vote != noVote {
-					// Since we are here we are not the chosen one but we should clear any vote preference.
-					n.vote = noVote
-					n.writeTermVote()
+				} else {
+					// Remember who our leader picked, so we vote for them even while we still hear
+					// from our leader, also when they do not flag their vote request as a transfer.
+					n.xferto = maybeLeader
+					if n.vote != noVote {
+						// Since we are here we are not the chosen one but we should clear any vote preference.
+						n.vote = noVote
+						n.writeTermVote()
+					}
 				}
 			}
 		case EntryAddPeer:
@@ -3601,7 +3784,7 @@ CONTINUE:
 				if ps := n.peers[newPeer]; ps != nil {
 					ps.ts = time.Now().UnixNano()
 				} else {
-					n.peers[newPeer] = &lps{time.Now().UnixNano(), 0, false}
+					n.peers[newPeer] = &lps{time.Now().UnixNano(), 0, false, 0, 0, 0}
 				}
 				// Store our peer in our global peer map for all peers.
 				peers.LoadOrStore(newPeer, newPeer)
@@ -3647,7 +3830,6 @@ func (n *raft) processPeerState(ps *peerState) {
 	// Update our version of peers to that of the leader. Calculate
 	// the number of nodes needed to establish a quorum.
 	n.csz = ps.clusterSize
-	n.qn = n.csz/2 + 1
 
 	old := n.peers
 	n.peers = make(map[string]*lps)
@@ -3656,9 +3838,10 @@ func (n *raft) processPeerState(ps *peerState) {
 			lp.kp = true
 			n.peers[peer] = lp
 		} else {
-			n.peers[peer] = &lps{0, 0, true}
+			n.peers[peer] = &lps{0, 0, true, 0, 0, 0}
 		}
 	}
+	n.updateQuorumLocked()
 	n.debug("Update peers from leader to %+v", n.peers)
 	n.writePeerState(ps)
 }
@@ -3775,12 +3958,65 @@ func (n *raft) sendAppendEntry(entries []*Entry) {
 			n.warn("%d append entries pending", len(n.pae))
 		}
 	}
+	if n.State() == Leader {
+		n.trackSentLocked(n.pindex)
+	}
 	n.sendRPC(n.asubj, n.areply, ae.buf)
 	if !shouldStore {
 		ae.returnToPool()
 	}
 }
 
+// Record that we sent an append entry up to index, and forget records that are
+// too old to count towards our lease.
+// Lock should be held.
+func (n *raft) trackSentLocked(index uint64) {
+	now := time.Now().UnixNano()
+	if l := len(n.sent); l > 0 {
+		if last := &n.sent[l-1]; index > last.hi && time.Duration(now-last.ts) < aeSentMergeWindow {
+			last.hi = index
+			return
+		}
+	}
+	var drop int
+	for drop < len(n.sent) && (len(n.sent)-drop >= aeSentMax || time.Duration(now-n.sent[drop].ts) >= leaderLease()) {
+		drop++
+	}
+	if drop > 0 {
+		n.sent = append(n.sent[:0], n.sent[drop:]...)
+		n.sento += uint64(drop)
+	}
+	n.sent = append(n.sent, aeSent{index, index, now})
+}
+
+// Returns the send time of the earliest append entry the peer's acknowledgement for index
+// could be answering, or 0 if we can not tell. Since peers process and respond to our append
+// entries in order, an acknowledgement always answers an entry sent after the one matched
+// for their previous acknowledgement. Repeated acknowledgements for the same index, such as
+// for heartbeats, must therefore be matched to a later record.
+// Lock should be held.
+func (n *raft) matchSentLocked(ps *lps, index uint64) int64 {
+	start := ps.lm
+	if index <= ps.lr {
+		start++
+	}
+	if start < n.sento {
+		start = n.sento
+	}
+	off := int(start - n.sento)
+	if off >= len(n.sent) {
+		return 0
+	}
+	recs := n.sent[off:]
+	i := sort.Search(len(recs), func(i int) bool { return recs[i].hi >= index })
+	if i == len(recs) || recs[i].lo > index {
+		// Either not sent recently, or a response to a catchup.
+		return 0
+	}
+	ps.lm, ps.lr = start+uint64(i), index
+	return recs[i].ts
+}
+
 type extensionState uint16
 
 const (
@@ -3868,34 +4104,63 @@ type voteRequest struct {
 	lastTerm  uint64
 	lastIndex uint64
 	candidate string
+	preVote   bool // Only asking if we would get the vote, term is the one we would campaign with.
+	xfer      bool // Campaigning because the leader transferred leadership to us.
 	// internal only.
 	reply string
 }
 
 const voteRequestLen = 24 + idLen
 
+// Pre-votes and leadership transfers carry an extra flag byte. Older servers reject
+// these, so they are only sent to groups where all peers support them.
+const preVoteRequestLen = voteRequestLen + 1
+
+// Flags carried in the extra vote request byte.
+const (
+	voteFlagPreVote  = 1 << 0
+	voteFlagTransfer = 1 << 1
+)
+
 func (vr *voteRequest) encode() []byte {
-	var buf [voteRequestLen]byte
+	var buf [preVoteRequestLen]byte
 	var le = binary.LittleEndian
 	le.PutUint64(buf[0:], vr.term)
 	le.PutUint64(buf[8:], vr.lastTerm)
 	le.PutUint64(buf[16:], vr.lastIndex)
 	copy(buf[24:24+idLen], vr.candidate)
 
+	var flags byte
+	if vr.preVote {
+		flags |= voteFlagPreVote
+	}
+	if vr.xfer {
+		flags |= voteFlagTransfer
+	}
+	if flags != 0 {
+		buf[voteRequestLen] = flags
+		return buf[:preVoteRequestLen]
+	}
 	return buf[:voteRequestLen]
 }
 
 func decodeVoteRequest(msg []byte, reply string) *voteRequest {
-	if len(msg) != voteRequestLen {
+	if len(msg) != voteRequestLen && len(msg) != preVoteRequestLen {
 		return nil
 	}
 
 	var le = binary.LittleEndian
+	var flags byte
+	if len(msg) == preVoteRequestLen {
+		flags = msg[voteRequestLen]
+	}
 	return &voteRequest{
 		term:      le.Uint64(msg[0:]),
 		lastTerm:  le.Uint64(msg[8:]),
 		lastIndex: le.Uint64(msg[16:]),
 		candidate: string(copyBytes(msg[24 : 24+idLen])),
+		preVote:   flags&voteFlagPreVote != 0,
+		xfer:      flags&voteFlagTransfer != 0,
 		reply:     reply,
 	}
 }
@@ -4049,12 +4314,16 @@ type voteResponse struct {
 	term    uint64
 	peer    string
 	granted bool
+	preVote bool // Response to a pre-vote.
 }
 
 const voteResponseLen = 8 + 8 + 1
 
+// Responses to pre-votes carry an extra flag byte, see preVoteRequestLen.
+const preVoteResponseLen = voteResponseLen + 1
+
 func (vr *voteResponse) encode() []byte {
-	var buf [voteResponseLen]byte
+	var buf [preVoteResponseLen]byte
 	var le = binary.LittleEndian
 	le.PutUint64(buf[0:], vr.term)
 	copy(buf[8:], vr.peer)
@@ -4063,16 +4332,21 @@ func (vr *voteResponse) encode() []byte {
 	} else {
 		buf[16] = 0
 	}
+	if vr.preVote {
+		buf[voteResponseLen] = 1
+		return buf[:preVoteResponseLen]
+	}
 	return buf[:voteResponseLen]
 }
 
 func decodeVoteResponse(msg []byte) *voteResponse {
-	if len(msg) != voteResponseLen {
+	if len(msg) != voteResponseLen && len(msg) != preVoteResponseLen {
 		return nil
 	}
 	var le = binary.LittleEndian
 	vr := &voteResponse{term: le.Uint64(msg[0:]), peer: string(msg[8:16])}
 	vr.granted = msg[16] == 1
+	vr.preVote = len(msg) == preVoteResponseLen && msg[voteResponseLen] == 1
 	return vr
 }
 
@@ -4104,9 +4378,14 @@ func (n *raft) processVoteRequest(vr *voteRequest) error {
 		return err
 	}
 
+	if vr.preVote {
+		n.processPreVoteRequest(vr)
+		return nil
+	}
+
 	n.Lock()
 
-	vresp := &voteResponse{n.term, n.id, false}
+	vresp := &voteResponse{n.term, n.id, false, false}
 	defer n.debug("Sending a voteResponse %+v -> %q", vresp, vr.reply)
 
 	// Ignore if we are newer. This is important so that we don't accidentally process
@@ -4117,6 +4396,19 @@ func (n *raft) processVoteRequest(vr *voteRequest) error {
 		return nil
 	}
 
+	// Refuse to help elect anyone else, and do not adopt their term, while we are hearing
+	// from our leader. Leader leases rely on this, and it also stops a candidate that skipped
+	// the pre-vote from disrupting the group. Leadership transfers are the only exception.
+	// Older peers do not flag their transfers and leases are not used with them, so only
+	// do this once all our peers support pre-votes.
+	if !vr.xfer && vr.candidate != n.leader && vr.candidate != n.xferto &&
+		n.preVoteSupportedLocked() && n.leaderActiveLocked() {
+		n.debug("Ignoring voteRequest from %q, leader is active", vr.candidate)
+		n.Unlock()
+		n.sendReply(vr.reply, vresp.encode())
+		return nil
+	}
+
 	// If this is a higher term go ahead and stepdown.
 	if vr.term > n.term {
 		if n.State() != Follower {
@@ -4155,6 +4447,57 @@ func (n *raft) processVoteRequest(vr *voteRequest) error {
 	return nil
 }
 
+// Pre-votes let a candidate check that it could win an election before it increments
+// its term, so a peer that has been partitioned away does not force the group to step
+// down once it rejoins. We grant a pre-vote if the candidate's log is at least as up
+// to date as ours and we have not heard from a leader recently. Unlike a real vote
+// this does not change our term or vote.
+func (n *raft) processPreVoteRequest(vr *voteRequest) {
+	n.RLock()
+	vresp := &voteResponse{n.term, n.id, false, true}
+	// A leader that stepped down and campaigns again is not disrupting anyone.
+	leaderActive := vr.candidate != n.leader && vr.candidate != n.xferto && n.leaderActiveLocked()
+	if vr.term > n.term && (vr.lastTerm > n.pterm || vr.lastTerm == n.pterm && vr.lastIndex >= n.pindex) && !leaderActive {
+		vresp.term, vresp.granted = vr.term, true
+	}
+	n.RUnlock()
+
+	n.debug("Sending a pre-voteResponse %+v -> %q", vresp, vr.reply)
+	n.sendReply(vr.reply, vresp.encode())
+}
+
+// Returns true if we are the leader, or have heard from the leader recently.
+// Lock should be held.
+func (n *raft) leaderActiveLocked() bool {
+	if n.State() == Leader {
+		return true
+	}
+	if n.leader == noLeader {
+		return false
+	}
+	ps := n.peers[n.leader]
+	return ps != nil && time.Since(time.Unix(0, ps.ts)) < voteRefusalWindow()
+}
+
+// Returns whether all peers in our group support pre-votes. Older servers drop
+// vote requests they do not understand, so we only use pre-votes once we know
+// all our peers do. Single node groups have no one to ask.
+// Lock should be held.
+func (n *raft) preVoteSupportedLocked() bool {
+	if n.s == nil || len(n.peers) <= 1 {
+		return false
+	}
+	for pn := range n.peers {
+		if pn == n.id {
+			continue
+		}
+		if si, ok := n.s.nodeToInfo.Load(pn); !ok || si == nil || !si.(nodeInfo).preVote {
+			return false
+		}
+	}
+	return true
+}
+
 func (n *raft) handleVoteRequest(sub *subscription, c *client, _ *Account, subject, reply string, msg []byte) {
 	vr := decodeVoteRequest(msg, reply)
 	if vr == nil {
@@ -4170,9 +4513,13 @@ func (n *raft) requestVote() {
 		n.Unlock()
 		return
 	}
+	n.pvote = false
 	n.vote = n.id
 	n.writeTermVote()
-	vr := voteRequest{n.term, n.pterm, n.pindex, n.id, _EMPTY_}
+	// Flag leadership transfers so our peers know to vote for us even though they
+	// heard from the old leader recently. Only if all peers understand the flag.
+	xfer := n.lxfer && n.preVoteSupportedLocked()
+	vr := voteRequest{n.term, n.pterm, n.pindex, n.id, false, xfer, _EMPTY_}
 	subj, reply := n.vsubj, n.vreply
 	n.Unlock()
 
@@ -4182,6 +4529,36 @@ func (n *raft) requestVote() {
 	n.sendRPC(subj, reply, vr.encode())
 }
 
+// Ask our peers if they would vote for us in the next term, without changing ours.
+func (n *raft) requestPreVote() {
+	n.RLock()
+	if n.State() != Candidate {
+		n.RUnlock()
+		return
+	}
+	vr := voteRequest{n.term + 1, n.pterm, n.pindex, n.id, true, false, _EMPTY_}
+	subj, reply := n.vsubj, n.vreply
+	n.RUnlock()
+
+	n.debug("Sending out pre-voteRequest %+v", vr)
+
+	n.sendRPC(subj, reply, vr.encode())
+}
+
+// Called when we won a pre-vote, we can now start the election for real.
+func (n *raft) startElection() {
+	n.Lock()
+	if n.State() != Candidate || !n.pvote {
+		n.Unlock()
+		return
+	}
+	n.term++
+	n.resetElectionTimeout()
+	n.Unlock()
+
+	n.requestVote()
+}
+
 func (n *raft) sendRPC(subject, reply string, msg []byte) {
 	if n.sq != nil {
 		n.sq.send(subject, reply, nil, msg)
@@ -4295,8 +4672,9 @@ func (n *raft) switchToCandidate() {
 	defer n.Unlock()
 
 	// If we are catching up or are in observer mode we can not switch.
+	// Learners never become the leader.
 	// Avoid petitioning to become leader if we're behind on applies.
-	if n.observer || n.paused || n.applied < n.commit {
+	if n.observer || n.isLearnerLocked(n.id) || n.paused || n.applied < n.commit {
 		n.resetElect(minElectionTimeout / 4)
 		return
 	}
@@ -4310,8 +4688,11 @@ func (n *raft) switchToCandidate() {
 			n.llqrt = time.Now()
 		}
 	}
-	// Increment the term.
-	n.term++
+	// Increment the term, unless we first need to check that we could win with a pre-vote.
+	// Leadership transfers skip this since our peers will still be following the old leader.
+	if n.pvote = !n.lxfer && n.preVoteSupportedLocked(); !n.pvote {
+		n.term++
+	}
 	// Clear current Leader.
 	n.updateLeader(noLeader)
 	n.switchState(Candidate)
@@ -4336,6 +4717,12 @@ func (n *raft) switchToLeader() {
 	n.updateLeader(n.id)
 	leadChange := n.switchState(Leader)
 
+	// Acknowledgements from any prior term we led do not count towards our lease.
+	for _, ps := range n.peers {
+		ps.la, ps.lm, ps.lr = 0, 0, 0
+	}
+	n.sent, n.sento = nil, 0
+
 	if leadChange {
 		// Wait for messages to be applied if we've stored more, otherwise signal immediately.
 		// It's important to wait signaling we're leader if we're not up-to-date yet, as that
@@ -373,6 +373,7 @@ func TestNRGSwitchStateClearsQueues(t *testing.T) {
 	s := c.servers[0] // RunBasicJetStreamServer not available
 
 	n := &raft{
+		sd:    t.TempDir(), // for the term and vote file written by switchState
 		prop:  newIPQueue[*proposedEntry](s, "prop"),
 		resp:  newIPQueue[*appendEntryResponse](s, "resp"),
 		leadc: make(chan bool, 1), // for switchState
@@ -570,13 +571,19 @@ func TestNRGAssumeHighTermAfterCandidateIsolation(t *testing.T) {
 	follower.requestVote()
 	time.Sleep(time.Millisecond * 100)
 
-	// The candidate will shortly send a vote request. When that happens,
-	// the rest of the nodes in the cluster should move up to that term,
-	// even though they will not grant the vote.
-	nterm := follower.term
-	for _, n := range rg {
-		require_Equal(t, n.node().Term(), nterm)
-	}
+	// The candidate will shortly send a vote request. The rest of the nodes
+	// are still hearing from their leader, so they will not grant the vote
+	// straight away. Once the leader hears back from the candidate it will
+	// step down though, and the rest of the nodes should move up to that term.
+	nterm := follower.Term()
+	checkFor(t, 10*time.Second, 50*time.Millisecond, func() error {
+		for _, n := range rg {
+			if term := n.node().Term(); term < nterm {
+				return fmt.Errorf("node still on term %d, expected %d", term, nterm)
+			}
+		}
+		return nil
+	})
 
 	// Have the leader send out a proposal, which will force the candidate
 	// back into follower state.
@@ -592,6 +599,368 @@ func TestNRGAssumeHighTermAfterCandidateIsolation(t *testing.T) {
 	}
 }
 
+func TestNRGPreVoteEncoding(t *testing.T) {
+	vr := &voteRequest{term: 2, lastTerm: 1, lastIndex: 10, candidate: "S1Nunr6R"}
+	buf := vr.encode()
+	require_Len(t, len(buf), voteRequestLen)
+	dvr := decodeVoteRequest(buf, "reply")
+	require_NotNil(t, dvr)
+	require_False(t, dvr.preVote)
+
+	vr.preVote = true
+	buf = vr.encode()
+	require_Len(t, len(buf), preVoteRequestLen)
+	dvr = decodeVoteRequest(buf, "reply")
+	require_NotNil(t, dvr)
+	require_True(t, dvr.preVote)
+	require_Equal(t, dvr.term, 2)
+	require_Equal(t, dvr.lastIndex, 10)
+	require_Equal(t, dvr.candidate, "S1Nunr6R")
+	require_False(t, dvr.xfer)
+
+	vr.preVote, vr.xfer = false, true
+	buf = vr.encode()
+	require_Len(t, len(buf), preVoteRequestLen)
+	dvr = decodeVoteRequest(buf, "reply")
+	require_NotNil(t, dvr)
+	require_False(t, dvr.preVote)
+	require_True(t, dvr.xfer)
+
+	vresp := &voteResponse{term: 2, peer: "S1Nunr6R", granted: true}
+	buf = vresp.encode()
+	require_Len(t, len(buf), voteResponseLen)
+	dvresp := decodeVoteResponse(buf)
+	require_NotNil(t, dvresp)
+	require_False(t, dvresp.preVote)
+
+	vresp.preVote = true
+	buf = vresp.encode()
+	require_Len(t, len(buf), preVoteResponseLen)
+	dvresp = decodeVoteResponse(buf)
+	require_NotNil(t, dvresp)
+	require_True(t, dvresp.preVote)
+	require_True(t, dvresp.granted)
+}
+
+func TestNRGPreVoteDoesNotDisruptLeader(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	rg := c.createRaftGroup("TEST", 3, newStateAdder)
+	rg.waitOnLeader()
+	leader := rg.leader().node().(*raft)
+	follower := rg.nonLeader().node().(*raft)
+
+	// All servers should advertise pre-vote support.
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		follower.RLock()
+		defer follower.RUnlock()
+		if !follower.preVoteSupportedLocked() {
+			return errors.New("pre-vote not supported by all peers yet")
+		}
+		return nil
+	})
+
+	// This is what happens when a follower stops hearing from the leader, for instance
+	// when it is partitioned away. It should not bump its term since it can not win,
+	// and our leader should not be forced to step down.
+	term := leader.Term()
+	for i := 0; i < 5; i++ {
+		follower.switchToCandidate()
+		require_Equal(t, follower.Term(), term)
+		time.Sleep(50 * time.Millisecond)
+	}
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		if state := follower.State(); state != Follower {
+			return fmt.Errorf("expected follower, got %v", state)
+		}
+		return nil
+	})
+	require_Equal(t, leader.State(), Leader)
+	for _, n := range rg {
+		require_Equal(t, n.node().Term(), term)
+	}
+
+	// Once the leader is gone an election should still happen.
+	leader.StepDown()
+	rg.waitOnLeader()
+	require_True(t, rg.leader().node().Term() > term)
+}
+
+func TestNRGLeaderTransferWithOlderPeers(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R5S", 5)
+	defer c.shutdown()
+
+	rg := c.createRaftGroup("TEST", 5, newStateAdder)
+	rg.waitOnLeader()
+	leader := rg.leader().node().(*raft)
+
+	var older, preferred *raft
+	for _, sm := range rg {
+		if n := sm.node().(*raft); n == leader {
+			continue
+		} else if older == nil {
+			older = n
+		} else if preferred == nil {
+			preferred = n
+		}
+	}
+
+	// Pretend one of the followers runs on a server that does not know about
+	// pre-votes, so the preferred peer will not flag its vote request as a transfer.
+	for _, s := range c.servers {
+		checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+			if ni, ok := s.nodeToInfo.Load(older.ID()); !ok || !ni.(nodeInfo).preVote {
+				return errors.New("no pre-vote capability yet")
+			}
+			return nil
+		})
+		ni, _ := s.nodeToInfo.Load(older.ID())
+		old := ni.(nodeInfo)
+		old.preVote = false
+		s.nodeToInfo.Store(older.ID(), old)
+	}
+
+	// The followers still hear from the old leader when the vote request
+	// arrives, but should vote for the preferred peer in the first election.
+	term := leader.Term()
+	require_NoError(t, leader.StepDown(preferred.ID()))
+	checkFor(t, 5*time.Second, 50*time.Millisecond, func() error {
+		if !preferred.Leader() {
+			return errors.New("preferred peer is not the leader yet")
+		}
+		return nil
+	})
+	require_Equal(t, preferred.Term(), term+1)
+}
+
+func TestNRGPreVoteNotUsedWithOlderPeers(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	rg := c.createRaftGroup("TEST", 3, newStateAdder)
+	rg.waitOnLeader()
+	leader := rg.leader().node().(*raft)
+	follower := rg.nonLeader().node().(*raft)
+
+	// Pretend the leader runs on a server that does not know about pre-votes.
+	s := follower.s
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		if ni, ok := s.nodeToInfo.Load(leader.ID()); !ok || !ni.(nodeInfo).preVote {
+			return errors.New("no pre-vote capability yet")
+		}
+		return nil
+	})
+	ni, _ := s.nodeToInfo.Load(leader.ID())
+	old := ni.(nodeInfo)
+	old.preVote = false
+	s.nodeToInfo.Store(leader.ID(), old)
+
+	// Should go straight to a regular election.
+	term := follower.Term()
+	follower.switchToCandidate()
+	require_Equal(t, follower.Term(), term+1)
+}
+
+func TestNRGLeaderLease(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	rg := c.createRaftGroup("TEST", 3, newStateAdder)
+	rg.waitOnLeader()
+	leader := rg.leader()
+
+	// Only the leader holds a lease once our followers acknowledged us.
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		if !leader.node().LeaseValid() {
+			return errors.New("leader does not hold a lease yet")
+		}
+		return nil
+	})
+	for _, sm := range rg {
+		if sm != leader {
+			require_False(t, sm.node().LeaseValid())
+		}
+	}
+
+	// Once our followers are gone we lose our lease, long before we notice we lost quorum.
+	var followers []stateMachine
+	for _, sm := range rg {
+		if sm != leader {
+			followers = append(followers, sm)
+			sm.stop()
+		}
+	}
+	checkFor(t, 2*leaderLease(), 50*time.Millisecond, func() error {
+		if leader.node().LeaseValid() {
+			return errors.New("leader still holds a lease")
+		}
+		return nil
+	})
+	require_True(t, leader.node().Leader())
+
+	// When one comes back we have quorum again.
+	followers[0].restart()
+	checkFor(t, 5*time.Second, 50*time.Millisecond, func() error {
+		if !leader.node().LeaseValid() {
+			return errors.New("leader does not hold a lease yet")
+		}
+		return nil
+	})
+
+	// No leases if a peer does not know about pre-votes, since it could vote for someone else.
+	n := leader.node().(*raft)
+	s := n.s
+	ni, ok := s.nodeToInfo.Load(followers[1].node().ID())
+	require_True(t, ok)
+	old := ni.(nodeInfo)
+	old.preVote = false
+	s.nodeToInfo.Store(followers[1].node().ID(), old)
+	require_False(t, n.LeaseValid())
+}
+
+func TestNRGLeaderLeaseBelowVoteRefusalWindow(t *testing.T) {
+	orig := minElectionTimeout
+	defer func() { minElectionTimeout = orig }()
+
+	for _, et := range []time.Duration{250 * time.Millisecond, 1500 * time.Millisecond, minElectionTimeoutDefault} {
+		minElectionTimeout = et
+		require_True(t, leaseClockDrift() > 0)
+		require_True(t, leaderLease() > 0)
+		require_True(t, leaderLease() < voteRefusalWindow()-leaseClockDrift())
+	}
+}
+
+func TestNRGVoteRefusedWhileLeaderActive(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	rg := c.createRaftGroup("TEST", 3, newStateAdder)
+	rg.waitOnLeader()
+	leader := rg.leader().node().(*raft)
+	var candidate, voter *raft
+	for _, sm := range rg {
+		if n := sm.node().(*raft); n != leader {
+			if candidate == nil {
+				candidate = n
+			} else {
+				voter = n
+			}
+		}
+	}
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		voter.RLock()
+		defer voter.RUnlock()
+		if !voter.leaderActiveLocked() {
+			return errors.New("voter has not heard from leader")
+		}
+		return nil
+	})
+
+	// A candidate that skipped the pre-vote, for instance because it did not know yet
+	// that all peers support them, must not be able to win while the leader holds a lease.
+	voter.RLock()
+	term, pterm, pindex := voter.term, voter.pterm, voter.pindex
+	voter.RUnlock()
+	vr := &voteRequest{term: term + 1, lastTerm: pterm, lastIndex: pindex, candidate: candidate.ID(), reply: "reply"}
+	require_NoError(t, voter.processVoteRequest(vr))
+	voter.RLock()
+	require_Equal(t, voter.term, term)
+	require_Equal(t, voter.vote, leader.ID())
+	voter.RUnlock()
+
+	// Leadership transfers are allowed though.
+	vr.xfer = true
+	require_NoError(t, voter.processVoteRequest(vr))
+	voter.RLock()
+	require_Equal(t, voter.term, term+1)
+	require_Equal(t, voter.vote, candidate.ID())
+	voter.RUnlock()
+}
+
+func TestNRGLeaseCountsFromSendTime(t *testing.T) {
+	n := &raft{}
+	ps := &lps{}
+
+	// Acknowledgements are credited from when we sent the entry, not when they arrived.
+	n.trackSentLocked(10)
+	sent := n.sent[0].ts
+	time.Sleep(20 * time.Millisecond)
+	n.trackSentLocked(10)
+	require_Equal(t, n.matchSentLocked(ps, 10), sent)
+
+	// A second acknowledgement for the same index answers a later heartbeat.
+	require_Equal(t, n.matchSentLocked(ps, 10), n.sent[1].ts)
+	// And there is none after that.
+	require_Equal(t, n.matchSentLocked(ps, 10), 0)
+
+	// Entries sent in quick succession are merged.
+	time.Sleep(2 * aeSentMergeWindow)
+	n.trackSentLocked(11)
+	n.trackSentLocked(12)
+	require_Len(t, len(n.sent), 3)
+	require_Equal(t, n.matchSentLocked(ps, 11), n.sent[2].ts)
+	require_Equal(t, n.matchSentLocked(ps, 12), n.sent[2].ts)
+
+	// Responses to catchups or to entries we no longer track are not credited.
+	require_Equal(t, n.matchSentLocked(ps, 5), 0)
+	require_Equal(t, n.matchSentLocked(ps, 20), 0)
+}
+
+func TestNRGLearners(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	rg := c.createRaftGroup("TEST", 3, newStateAdder)
+	rg.waitOnLeader()
+
+	learner := rg.nonLeader().(*stateAdder)
+	for _, sm := range rg {
+		sm.node().SetLearners([]string{learner.node().ID()})
+	}
+	require_True(t, learner.node().IsLearner())
+	require_False(t, rg.leader().node().IsLearner())
+
+	// Only the two voters make up our quorum.
+	for _, sm := range rg {
+		require_Equal(t, sm.node().(*raft).quorumNeeded(), 2)
+	}
+
+	// Learners still replicate.
+	rg.leader().(*stateAdder).proposeDelta(1)
+	rg.waitOnTotal(t, 1)
+
+	// Learners never become the leader.
+	for i := 0; i < 3; i++ {
+		require_NoError(t, rg.leader().node().StepDown())
+		rg.waitOnLeader()
+		require_True(t, rg.leader() != learner)
+	}
+	peers := rg.leader().node().Peers()
+	require_Len(t, len(peers), 3)
+	for _, p := range peers {
+		require_Equal(t, p.Learner, p.ID == learner.node().ID())
+	}
+
+	// Learners do not count towards committing entries.
+	leader := rg.leader().(*stateAdder)
+	var voter *stateAdder
+	for _, sm := range rg {
+		if sm != leader && sm != learner {
+			voter = sm.(*stateAdder)
+		}
+	}
+	voter.stop()
+	leader.proposeDelta(1)
+	time.Sleep(500 * time.Millisecond)
+	require_Equal(t, leader.total(), 1)
+	require_Equal(t, learner.total(), 1)
+
+	voter.restart()
+	voter.node().SetLearners([]string{learner.node().ID()})
+	rg.waitOnTotal(t, 2)
+}
+
 // Test to make sure this does not cause us to truncate our wal or enter catchup state.
 func TestNRGHeartbeatOnLeaderChange(t *testing.T) {
 	c := createJetStreamClusterExplicit(t, "R3S", 3)
@@ -1566,7 +1935,7 @@ func TestNRGCancelCatchupWhenDetectingHigherTermDuringVoteRequest(t *testing.T)
 	// Receiving a vote request should cancel our catchup.
 	// Otherwise, we could receive catchup messages after this that provides the previous leader with quorum.
 	// If the new leader doesn't have these entries, the previous leader would desync since it would commit them.
-	err := n.processVoteRequest(&voteRequest{2, 1, 1, nats0, "reply"})
+	err := n.processVoteRequest(&voteRequest{2, 1, 1, nats0, false, false, "reply"})
 	require_NoError(t, err)
 	require_True(t, n.catchup == nil)
 }
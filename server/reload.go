@@ -717,6 +717,26 @@ func (jso jetStreamOption) IsStatszChange() bool {
 	return true
 }
 
+// jetStreamKeyOption implements the option interface for the JetStream
+// encryption key. Changing the key starts an online key rotation.
+type jetStreamKeyOption struct {
+	noopOption
+	oldValue string
+	newValue string
+}
+
+func (o *jetStreamKeyOption) Apply(s *Server) {
+	if _, err := s.rotateJetStreamKey(o.newValue, 0); err != nil {
+		// Keep creating new assets with the key our stores are encrypted with.
+		nopts := s.getOpts().Clone()
+		nopts.JetStreamKey = o.oldValue
+		s.setOpts(nopts)
+		s.Warnf("Reloaded: JetStream encryption key rotation failed to start, keeping the previous key: %v", err)
+		return
+	}
+	s.Noticef("Reloaded: JetStream encryption key, rotating in the background")
+}
+
 type ocspOption struct {
 	tlsOption
 	newValue *OCSPConfig
@@ -998,6 +1018,8 @@ func (s *Server) Reload() error {
 	// Use the digest from the configuration to detect whether unnecessary to apply reload.
 	if s.getOpts().ConfigDigest() != "" && newOpts.ConfigDigest() == s.getOpts().ConfigDigest() {
 		s.Noticef("Config reload skipped. No changes detected.")
+		// A reload is also how keys from the key provider are refreshed.
+		s.clearJetStreamAccountKeys()
 		return nil
 	}
 
@@ -1133,6 +1155,8 @@ func (s *Server) reloadOptions(curOpts, newOpts *Options) error {
 	ctx := reloadContext{oldClusterPerms: curOpts.Cluster.Permissions}
 	s.setOpts(newOpts)
 	s.applyOptions(&ctx, changed)
+	// Keys are requested from the key provider again, in case they were revoked or rotated.
+	s.clearJetStreamAccountKeys()
 	return nil
 }
 
@@ -1163,7 +1187,7 @@ func imposeOrder(value any) error {
 		*OCSPConfig, map[string]string, JSLimitOpts, StoreCipher, *OCSPResponseCacheConfig:
 		// explicitly skipped types
 	case *AuthCallout:
-	case JSTpmOpts:
+	case JSTpmOpts, JSLeaderBalanceOpts, JSRebalanceOpts:
 	default:
 		// this will fail during unit tests
 		return fmt.Errorf("OnReload, sort or explicitly skip type: %s",
@@ -1514,6 +1538,21 @@ func (s *Server) diffOptions(newOpts *Options) ([]option, error) {
 					return nil, fmt.Errorf("config reload not supported for jetstream storage directory")
 				}
 			}
+		case "jetstreamkey":
+			// Encryption can not be turned on or off, but the key can be rotated online.
+			old, new := oldValue.(string), newValue.(string)
+			if jsEnabled && !disableJS && (old == _EMPTY_ || new == _EMPTY_) {
+				return nil, fmt.Errorf("config reload not supported for jetstream encryption")
+			}
+			if jsEnabled && old != _EMPTY_ && new != _EMPTY_ {
+				if js := s.getJetStream(); js != nil && js.keyRotationInProgress() {
+					return nil, fmt.Errorf("config reload not supported for jetstream encryption key while a key rotation is in progress")
+				}
+				diffOpts = append(diffOpts, &jetStreamKeyOption{oldValue: old, newValue: new})
+			}
+		case "jetstreamoldkey":
+			// Only used on startup, allow it to be set alongside a rotated key.
+			continue
 		case "jetstreammaxmemory", "jetstreammaxstore":
 			old := oldValue.(int64)
 			new := newValue.(int64)
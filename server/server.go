@@ -187,6 +187,8 @@ type Server struct {
 	js                  atomic.Pointer[jetStream]
 	isMetaLeader        atomic.Bool
 	jsClustered         atomic.Bool
+	jsKeysMu            sync.Mutex
+	jsKeys              map[string]*jsAccountKeyEntry // Keys from the JetStream key provider.
 	accounts            sync.Map
 	tmpAccounts         sync.Map // Temporarily stores accounts that are being built
 	activeAccounts      int32
@@ -387,6 +389,7 @@ type nodeInfo struct {
 	js              bool
 	binarySnapshots bool
 	accountNRG      bool
+	preVote         bool
 }
 
 // Make sure all are 64bits for atomic use
@@ -787,7 +790,7 @@ func NewServer(opts *Options) (*Server, error) {
 			opts.Tags,
 			&JetStreamConfig{MaxMemory: opts.JetStreamMaxMemory, MaxStore: opts.JetStreamMaxStore, CompressOK: true},
 			nil,
-			false, true, true, true,
+			false, true, true, true, true,
 		})
 	}
 
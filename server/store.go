@@ -165,6 +165,8 @@ type StreamState struct {
 	Deleted     []uint64          `json:"deleted,omitempty"`
 	Lost        *LostStreamData   `json:"lost,omitempty"`
 	Consumers   int               `json:"consumer_count"`
+	HotBytes    uint64            `json:"hot_bytes,omitempty"`
+	ColdBytes   uint64            `json:"cold_bytes,omitempty"`
 }
 
 // SimpleState for filtered subject specific state.
@@ -355,6 +357,7 @@ type ConsumerStore interface {
 	UpdateAcks(dseq, sseq uint64) error
 	UpdateConfig(cfg *ConsumerConfig) error
 	Update(*ConsumerState) error
+	Reset(*ConsumerState) error
 	State() (*ConsumerState, error)
 	BorrowState() (*ConsumerState, error)
 	EncodedState() ([]byte, error)
@@ -454,6 +457,39 @@ type Pending struct {
 	Timestamp int64
 }
 
+// Returns whether a message is pending without a consumer sequence. Messages held back from delivery,
+// e.g. for their ordering key, are recorded that way, and keep going without one once delivered since
+// they are delivered out of order.
+func (state *ConsumerState) isHeldPending(sseq uint64) bool {
+	p := state.Pending[sseq]
+	return p != nil && p.Sequence == 0
+}
+
+// Lowers a stream ack floor that moved up from prev to stay below messages that are still pending.
+// Messages held back from delivery can be pending below messages acknowledged after them.
+func (state *ConsumerState) holdAckFloor(prev uint64) {
+	floor := state.AckFloor.Stream
+	if floor <= prev {
+		return
+	}
+	// Check which is less to walk.
+	if floor-prev > uint64(len(state.Pending)) {
+		for seq := range state.Pending {
+			if seq > prev && seq <= floor {
+				floor = seq - 1
+			}
+		}
+	} else {
+		for seq := prev + 1; seq <= floor; seq++ {
+			if _, ok := state.Pending[seq]; ok {
+				floor = seq - 1
+				break
+			}
+		}
+	}
+	state.AckFloor.Stream = floor
+}
+
 // TemplateStore stores templates.
 type TemplateStore interface {
 	Store(*streamTemplate) error
@@ -0,0 +1,773 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/highwayhash"
+)
+
+var (
+	errStoreInspectNoDir = errors.New("store directory required")
+	errStoreInspectNoKey = errors.New("store is encrypted but no encryption key is configured")
+	errStoreInspectKeys  = errors.New("unable to recover keys")
+	errStoreInspectRO    = errors.New("cold store is read only while exporting")
+)
+
+// InspectStore inspects a JetStream store directory offline, without starting the server, and
+// writes a report to w. It lists the accounts, streams and consumers in the store, dumps their
+// index and message block metadata and verifies the checksum of every record. Encryption keys
+// are taken from the options. The directory to inspect is StoreInspect, or StoreDir if not set.
+//
+// With StoreRepair set, each stream is recovered before it is inspected, the same as the server
+// would on startup but with its index rebuilt from the message blocks. This truncates any torn or
+// corrupt tail of a block. With StoreExport set to "account/stream", that stream is written to
+// StoreExportFile as a snapshot that can be restored with the stream restore API. The export
+// works from a copy of the stream, so the store itself is left untouched.
+//
+// The store can not be inspected while a server is running on it.
+// Returns an error if any problems were found.
+func InspectStore(opts *Options, w io.Writer) error {
+	dir := opts.StoreInspect
+	if dir == _EMPTY_ {
+		dir = opts.StoreDir
+	}
+	if dir == _EMPTY_ {
+		return errStoreInspectNoDir
+	}
+	// Accept either the store directory or the jetstream directory beneath it.
+	if fi, err := os.Stat(filepath.Join(dir, JetStreamStoreDir)); err == nil && fi.IsDir() {
+		dir = filepath.Join(dir, JetStreamStoreDir)
+	} else if fi, err := os.Stat(dir); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("store directory %q is not a directory", dir)
+	}
+
+	// A running server holds the lock on its store, which could change underneath us.
+	if _, err := os.Stat(filepath.Join(dir, storeLockFile)); err == nil {
+		lf, err := lockStoreDir(dir)
+		if err == errStoreDirLocked {
+			return fmt.Errorf("store directory %q is in use by a running server", dir)
+		} else if err != nil {
+			return err
+		}
+		defer lf.Close()
+	}
+
+	var exportAcc, exportStream string
+	if opts.StoreExport != _EMPTY_ {
+		var ok bool
+		if exportAcc, exportStream, ok = strings.Cut(opts.StoreExport, "/"); !ok || exportAcc == _EMPTY_ || exportStream == _EMPTY_ {
+			return fmt.Errorf("stream to export needs to be given as <account>/<stream>, got %q", opts.StoreExport)
+		}
+	}
+
+	// We need a server for our encryption keys, but it is never started.
+	s, err := NewServer(opts.Clone())
+	if err != nil {
+		return err
+	}
+	s.ConfigureLogger()
+	if err := s.initJetStreamEncryption(); err != nil {
+		return err
+	}
+
+	si := &storeInspector{s: s, w: w, sc: s.getOpts().JetStreamCipher}
+	si.printf("Store directory: %s\n", dir)
+
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var exported bool
+	for _, fi := range fis {
+		sdir := filepath.Join(dir, fi.Name(), streamsDir)
+		if !fi.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(sdir); err != nil {
+			continue
+		}
+		acc := fi.Name()
+		si.printf("\nAccount %q\n", acc)
+		sfis, err := os.ReadDir(sdir)
+		if err != nil {
+			si.problem("  ", "reading streams: %v", err)
+			continue
+		}
+		for _, sfi := range sfis {
+			// Partially deleted streams are removed on startup.
+			if !sfi.IsDir() || strings.HasPrefix(sfi.Name(), tsep) {
+				continue
+			}
+			export := acc == exportAcc && sfi.Name() == exportStream
+			si.stream(acc, filepath.Join(sdir, sfi.Name()), opts.StoreRepair, export, opts.StoreExportFile)
+			exported = exported || export
+		}
+	}
+	if opts.StoreExport != _EMPTY_ && !exported {
+		si.problem(_EMPTY_, "stream %q to export not found", opts.StoreExport)
+	}
+
+	if si.problems > 0 {
+		si.printf("\nFound %d problem(s)\n", si.problems)
+		return fmt.Errorf("found %d problem(s) in store %q", si.problems, dir)
+	}
+	si.printf("\nNo problems found\n")
+	return nil
+}
+
+// storeInspector holds the state for inspecting a store offline.
+type storeInspector struct {
+	s        *Server
+	w        io.Writer
+	sc       StoreCipher
+	problems int
+}
+
+func (si *storeInspector) printf(format string, args ...any) {
+	fmt.Fprintf(si.w, format, args...)
+}
+
+// Report a problem, indented to line up with what it is about.
+func (si *storeInspector) problem(indent, format string, args ...any) {
+	si.problems++
+	si.printf("%sPROBLEM: %s\n", indent, fmt.Sprintf(format, args...))
+}
+
+// Recover the seed held in an encrypted key file, trying both ciphers since we could be in
+// the middle of a cipher conversion. Returns the cipher that worked.
+func (si *storeInspector) openSeed(ekey []byte, acc, context string) ([]byte, StoreCipher, error) {
+	prf, oldprf, err := si.s.jsAccountKeyGen(acc, acc)
+	if err != nil {
+		return nil, NoCipher, err
+	}
+	if prf == nil {
+		return nil, NoCipher, errStoreInspectNoKey
+	}
+	for _, sc := range []StoreCipher{si.sc, AES, ChaCha} {
+		if seed, err := openKeySeed(ekey, context, sc, prf, oldprf); err == nil {
+			return seed, sc, nil
+		}
+	}
+	return nil, NoCipher, errStoreInspectKeys
+}
+
+// Load the asset encryption key from the meta key file in dir, if encrypted.
+func (si *storeInspector) metaKey(dir, acc, context string) (cipher.AEAD, error) {
+	ekey, err := os.ReadFile(filepath.Join(dir, JetStreamMetaFileKey))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	seed, sc, err := si.openSeed(ekey, acc, context)
+	if err != nil {
+		return nil, err
+	}
+	return genEncryptionKey(sc, seed)
+}
+
+// Read and verify the meta file in dir, decrypting it with aek if needed.
+func readInspectMeta(dir, hashKey string, aek cipher.AEAD, v any) error {
+	buf, err := os.ReadFile(filepath.Join(dir, JetStreamMetaFile))
+	if err != nil {
+		return err
+	}
+	sum, err := os.ReadFile(filepath.Join(dir, JetStreamMetaFileSum))
+	if err != nil {
+		return err
+	}
+	key := sha256.Sum256([]byte(hashKey))
+	hh, err := highwayhash.New64(key[:])
+	if err != nil {
+		return err
+	}
+	hh.Write(buf)
+	if checksum := hex.EncodeToString(hh.Sum(nil)); checksum != string(sum) {
+		return fmt.Errorf("meta file checksums do not match %q vs %q", sum, checksum)
+	}
+	if aek != nil {
+		ns := aek.NonceSize()
+		if len(buf) < ns {
+			return errBadKeySize
+		}
+		if buf, err = aek.Open(nil, buf[:ns], buf[ns:], nil); err != nil {
+			return fmt.Errorf("decrypting meta file: %w", err)
+		}
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// Inspect a single stream, optionally repairing or exporting it first.
+func (si *storeInspector) stream(acc, sdir string, repair, export bool, exportFile string) {
+	name := filepath.Base(sdir)
+	const indent = "    "
+
+	aek, err := si.metaKey(sdir, acc, name)
+	if err != nil {
+		si.printf("  Stream %q\n", name)
+		si.problem(indent, "recovering meta key: %v", err)
+		return
+	}
+	var cfg FileStreamInfo
+	if err := readInspectMeta(sdir, name, aek, &cfg); err != nil {
+		si.printf("  Stream %q\n", name)
+		si.problem(indent, "reading meta file: %v", err)
+		return
+	}
+	si.printf("  Stream %q (%s, %s, %d replica(s), created %s)\n", name, cfg.Storage, cfg.Retention,
+		max(cfg.Replicas, 1), cfg.Created.Format(time.RFC3339))
+	if len(cfg.Subjects) > 0 {
+		si.printf("%sSubjects: %s\n", indent, strings.Join(cfg.Subjects, ", "))
+	}
+	if aek != nil {
+		si.printf("%sEncrypted\n", indent)
+	}
+	if cfg.Compression != NoCompression {
+		si.printf("%sCompression: %s\n", indent, cfg.Compression)
+	}
+
+	if repair {
+		if err := si.repairStream(acc, sdir, &cfg); err != nil {
+			si.problem(indent, "repairing stream: %v", err)
+		}
+		// Recovery can write out new keys.
+		if aek, err = si.metaKey(sdir, acc, name); err != nil {
+			si.problem(indent, "recovering meta key: %v", err)
+			return
+		}
+	}
+
+	// A bare store that lets us use the message block helpers without recovering anything.
+	fs := &fileStore{
+		fcfg: FileStoreConfig{StoreDir: sdir, Cipher: si.sc, Compression: cfg.Compression, CompressionOpts: cfg.CompressionOpts},
+		zc:   newZstdCoders(cfg.CompressionOpts),
+		cfg:  cfg,
+	}
+	defer fs.zc.close()
+	key := sha256.Sum256([]byte(cfg.Name))
+	fs.hh, _ = highwayhash.New64(key[:])
+
+	si.index(fs, aek)
+	si.blocks(fs, acc)
+	si.consumers(fs, acc)
+
+	if export {
+		if exportFile == _EMPTY_ {
+			exportFile = name + ".tar.s2"
+		}
+		if err := si.exportStream(acc, sdir, &cfg, exportFile); err != nil {
+			si.problem(indent, "exporting stream: %v", err)
+		} else {
+			si.printf("%sExported to %s\n", indent, exportFile)
+		}
+	}
+}
+
+// Dump the stream state held in the index file.
+func (si *storeInspector) index(fs *fileStore, aek cipher.AEAD) {
+	const indent = "    "
+	fn := filepath.Join(fs.fcfg.StoreDir, msgDir, streamStreamStateFile)
+	buf, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		si.printf("%sIndex: missing, will be rebuilt from blocks on recovery\n", indent)
+		return
+	} else if err != nil {
+		si.problem(indent, "reading index: %v", err)
+		return
+	}
+	size := len(buf)
+	if len(buf) < 32 {
+		si.problem(indent, "index too short (%d bytes)", len(buf))
+		return
+	}
+	h := buf[len(buf)-highwayhash.Size64:]
+	buf = buf[:len(buf)-highwayhash.Size64]
+	fs.hh.Reset()
+	fs.hh.Write(buf)
+	if !bytes.Equal(h, fs.hh.Sum(nil)) {
+		si.problem(indent, "index checksum does not match")
+		return
+	}
+	// Snapshot restores strip encryption, so we may not be encrypted even if the stream is.
+	if aek != nil && (len(buf) < hdrLen || buf[0] != fullStateMagic) {
+		ns := aek.NonceSize()
+		if buf, err = aek.Open(nil, buf[:ns], buf[ns:], nil); err != nil {
+			si.problem(indent, "decrypting index: %v", err)
+			return
+		}
+	}
+	if buf[0] != fullStateMagic || buf[1] < fullStateMinVersion || buf[1] > fullStateVersion {
+		si.problem(indent, "index magic and version mismatch")
+		return
+	}
+	bi := hdrLen
+	readU64 := func() uint64 {
+		if bi < 0 {
+			return 0
+		}
+		v, n := binary.Uvarint(buf[bi:])
+		if n <= 0 {
+			bi = -1
+			return 0
+		}
+		bi += n
+		return v
+	}
+	readI64 := func() int64 {
+		if bi < 0 {
+			return 0
+		}
+		v, n := binary.Varint(buf[bi:])
+		if n <= 0 {
+			bi = -1
+			return 0
+		}
+		bi += n
+		return v
+	}
+	msgs, nb, fseq, fts, lseq, lts := readU64(), readU64(), readU64(), readI64(), readU64(), readI64()
+	if bi < 0 {
+		si.problem(indent, "index state is truncated")
+		return
+	}
+	si.printf("%sIndex: %s, version %d, %s messages, %s, sequences %d..%d, %s..%s\n", indent,
+		friendlyBytes(size), buf[1], comma(int64(msgs)), friendlyBytes(nb), fseq, lseq, inspectTime(fts), inspectTime(lts))
+}
+
+// inspectBlock is what we found scanning a message block.
+type inspectBlock struct {
+	index      uint32
+	size       int64
+	encrypted  bool
+	compressed StoreCompression
+	records    int
+	msgs       int
+	erased     int
+	tombs      int
+	first      msgId
+	last       msgId
+	// Corrupt records and the range of sequences they claim to hold, which may be garbage too.
+	corrupt  int
+	badFirst uint64
+	badLast  uint64
+	// Trailing bytes that do not make up a complete record.
+	torn    int
+	tornOff int
+}
+
+// Dump the metadata of all message blocks, verifying all records.
+func (si *storeInspector) blocks(fs *fileStore, acc string) {
+	const indent = "    "
+	mdir := filepath.Join(fs.fcfg.StoreDir, msgDir)
+	fis, err := os.ReadDir(mdir)
+	if err != nil {
+		si.problem(indent, "reading message blocks: %v", err)
+		return
+	}
+	var indexes []uint32
+	for _, fi := range fis {
+		var index uint32
+		if n, err := fmt.Sscanf(fi.Name(), blkScan, &index); err == nil && n == 1 {
+			indexes = append(indexes, index)
+		}
+	}
+	slices.Sort(indexes)
+
+	var records, msgs int
+	var first, last uint64
+	for _, index := range indexes {
+		ib, err := si.scanBlock(fs, acc, index)
+		if err != nil {
+			si.problem(indent, "block %d: %v", index, err)
+			continue
+		}
+		var flags []string
+		if ib.encrypted {
+			flags = append(flags, "encrypted")
+		}
+		if ib.compressed != NoCompression {
+			flags = append(flags, strings.ToLower(ib.compressed.String()))
+		}
+		var fl string
+		if len(flags) > 0 {
+			fl = fmt.Sprintf(" [%s]", strings.Join(flags, ", "))
+		}
+		si.printf("%sBlock %d: %s%s, %d records, %d messages, %d erased, %d tombstones", indent,
+			ib.index, friendlyBytes(ib.size), fl, ib.records, ib.msgs, ib.erased, ib.tombs)
+		if ib.first.seq > 0 {
+			si.printf(", sequences %d..%d, %s..%s", ib.first.seq, ib.last.seq, inspectTime(ib.first.ts), inspectTime(ib.last.ts))
+		}
+		si.printf("\n")
+		if ib.corrupt > 0 {
+			si.problem(indent+"  ", "%d record(s) failed checksum, claiming sequences %d..%d", ib.corrupt, ib.badFirst, ib.badLast)
+		}
+		if ib.torn > 0 {
+			si.problem(indent+"  ", "torn tail of %d bytes at offset %d", ib.torn, ib.tornOff)
+		}
+		records += ib.records
+		msgs += ib.msgs
+		if ib.first.seq > 0 && (first == 0 || ib.first.seq < first) {
+			first = ib.first.seq
+		}
+		last = max(last, ib.last.seq)
+	}
+	si.printf("%sBlocks: %d blocks, %d records, %d messages, sequences %d..%d\n", indent, len(indexes), records, msgs, first, last)
+
+	// Report any blocks offloaded to the cold tier.
+	if coldDir := si.s.getOpts().JetStreamColdStoreDir; coldDir != _EMPTY_ && fs.cfg.ColdTier != nil {
+		cs := newDirColdStore(si.s.coldStreamDir(coldDir, acc, fs.cfg.Name))
+		if names, err := cs.List(); err != nil && !os.IsNotExist(err) {
+			si.problem(indent, "listing cold store: %v", err)
+		} else {
+			si.printf("%sCold store: %d blocks\n", indent, len(names))
+		}
+	}
+}
+
+// Scan a message block from disk without loading it into the store.
+func (si *storeInspector) scanBlock(fs *fileStore, acc string, index uint32) (*inspectBlock, error) {
+	mb := fs.initMsgBlock(index)
+	buf, err := os.ReadFile(mb.mfn)
+	if err != nil {
+		return nil, err
+	}
+	ib := &inspectBlock{index: index, size: int64(len(buf))}
+
+	// Blocks without a key file are plaintext, even if the stream is encrypted.
+	ekey, err := os.ReadFile(filepath.Join(fs.fcfg.StoreDir, msgDir, fmt.Sprintf(keyScan, index)))
+	if err == nil {
+		seed, sc, err := si.openSeed(ekey, acc, fmt.Sprintf("%s:%d", fs.cfg.Name, index))
+		if err != nil {
+			return nil, err
+		}
+		aek, err := genEncryptionKey(sc, seed)
+		if err != nil {
+			return nil, err
+		}
+		if len(ekey) < aek.NonceSize() {
+			return nil, errBadKeySize
+		}
+		bek, err := genBlockEncryptionKey(sc, seed, ekey[:aek.NonceSize()])
+		if err != nil {
+			return nil, err
+		}
+		bek.XORKeyStream(buf, buf)
+		ib.encrypted = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var meta CompressionInfo
+	if n, err := meta.UnmarshalMetadata(buf); err != nil {
+		return nil, err
+	} else if n > 0 {
+		ib.compressed = meta.Algorithm
+	}
+	if buf, err = mb.decompressIfNeeded(buf); err != nil {
+		return nil, err
+	}
+
+	le := binary.LittleEndian
+	for index, lbuf := uint32(0), uint32(len(buf)); index < lbuf; {
+		if index+msgHdrSize > lbuf {
+			ib.torn, ib.tornOff = int(lbuf-index), int(index)
+			break
+		}
+		hdr := buf[index : index+msgHdrSize]
+		rl, slen := le.Uint32(hdr[0:]), int(le.Uint16(hdr[20:]))
+		hasHeaders := rl&hbit != 0
+		rl &^= hbit
+		dlen := int(rl) - msgHdrSize
+		// Without a sane length we can not find the next record.
+		if dlen < 0 || slen > (dlen-recordHashSize) || index+rl > lbuf || rl > rlBadThresh {
+			ib.torn, ib.tornOff = int(lbuf-index), int(index)
+			break
+		}
+		ib.records++
+		data := buf[index+msgHdrSize : index+rl]
+		index += rl
+
+		seq, ts := le.Uint64(hdr[4:]), int64(le.Uint64(hdr[12:]))
+		mb.hh.Reset()
+		mb.hh.Write(hdr[4:20])
+		mb.hh.Write(data[:slen])
+		if hasHeaders {
+			mb.hh.Write(data[slen+4 : dlen-recordHashSize])
+		} else {
+			mb.hh.Write(data[slen : dlen-recordHashSize])
+		}
+		if !bytes.Equal(mb.hh.Sum(nil), data[len(data)-recordHashSize:]) {
+			bseq := seq &^ (ebit | tbit)
+			if ib.corrupt == 0 || bseq < ib.badFirst {
+				ib.badFirst = bseq
+			}
+			ib.badLast = max(ib.badLast, bseq)
+			ib.corrupt++
+			continue
+		}
+		switch {
+		case seq&tbit != 0:
+			ib.tombs++
+			continue
+		case seq&ebit != 0:
+			ib.erased++
+			seq &^= ebit
+		default:
+			ib.msgs++
+		}
+		if ib.first.seq == 0 {
+			ib.first = msgId{seq, ts}
+		}
+		ib.last = msgId{seq, ts}
+	}
+	return ib, nil
+}
+
+// List the consumers of the stream along with their state.
+func (si *storeInspector) consumers(fs *fileStore, acc string) {
+	const indent = "    "
+	odir := filepath.Join(fs.fcfg.StoreDir, consumerDir)
+	fis, _ := os.ReadDir(odir)
+	for _, fi := range fis {
+		name := fi.Name()
+		cdir := filepath.Join(odir, name)
+		context := fs.cfg.Name + tsep + name
+		aek, err := si.metaKey(cdir, acc, context)
+		if err != nil {
+			si.printf("%sConsumer %q\n", indent, name)
+			si.problem(indent+"  ", "recovering meta key: %v", err)
+			continue
+		}
+		var cfg FileConsumerInfo
+		if err := readInspectMeta(cdir, fs.cfg.Name+"/"+name, aek, &cfg); err != nil {
+			si.printf("%sConsumer %q\n", indent, name)
+			si.problem(indent+"  ", "reading meta file: %v", err)
+			continue
+		}
+		kind := "ephemeral"
+		if isDurableConsumer(&cfg.ConsumerConfig) {
+			kind = "durable"
+		}
+		si.printf("%sConsumer %q (%s, %s)", indent, name, kind, cfg.AckPolicy)
+
+		buf, err := os.ReadFile(filepath.Join(cdir, consumerState))
+		if os.IsNotExist(err) || err == nil && len(buf) == 0 {
+			si.printf(": no state\n")
+			continue
+		} else if err != nil {
+			si.printf("\n")
+			si.problem(indent+"  ", "reading state: %v", err)
+			continue
+		}
+		if aek != nil {
+			ns := aek.NonceSize()
+			if len(buf) < ns {
+				err = errBadKeySize
+			} else {
+				buf, err = aek.Open(nil, buf[:ns], buf[ns:], nil)
+			}
+		}
+		var state *ConsumerState
+		if err == nil {
+			state, err = decodeConsumerState(buf)
+		}
+		if err != nil {
+			si.printf("\n")
+			si.problem(indent+"  ", "decoding state: %v", err)
+			continue
+		}
+		si.printf(": delivered %d/%d, ack floor %d/%d, %d pending, %d redelivered\n",
+			state.Delivered.Consumer, state.Delivered.Stream, state.AckFloor.Consumer, state.AckFloor.Stream,
+			len(state.Pending), len(state.Redelivered))
+	}
+}
+
+// Open the stream's store, which recovers it the same as the server would on startup.
+// With readOnly set the cold tier is never written to.
+func (si *storeInspector) openStream(acc, sdir string, cfg *FileStreamInfo, readOnly bool) (*fileStore, error) {
+	if cfg.Storage != FileStorage {
+		return nil, fmt.Errorf("stream is not file based")
+	}
+	prf, oldprf, err := si.s.jsAccountKeyGen(acc, acc)
+	if err != nil {
+		return nil, err
+	}
+	opts := si.s.getOpts()
+	fcfg := FileStoreConfig{
+		StoreDir:        sdir,
+		SyncInterval:    opts.SyncInterval,
+		SyncAlways:      opts.SyncAlways,
+		Compression:     cfg.Compression,
+		CompressionOpts: cfg.CompressionOpts,
+		srv:             si.s,
+	}
+	if prf != nil {
+		fcfg.Cipher = si.sc
+	}
+	// Without our cold store blocks offloaded to it would look to be missing.
+	if cfg.ColdTier != nil {
+		if opts.JetStreamColdStoreDir == _EMPTY_ {
+			return nil, fmt.Errorf("stream has a cold tier but no cold store directory is configured")
+		}
+		fcfg.ColdStore = newDirColdStore(si.s.coldStreamDir(opts.JetStreamColdStoreDir, acc, cfg.Name))
+		if readOnly {
+			fcfg.ColdStore = readOnlyColdStore{fcfg.ColdStore}
+		}
+	}
+	return newFileStoreWithCreated(fcfg, cfg.StreamConfig, cfg.Created, prf, oldprf)
+}
+
+// Recover the stream with its index rebuilt from the message blocks, then write out a new index.
+func (si *storeInspector) repairStream(acc, sdir string, cfg *FileStreamInfo) error {
+	const indent = "    "
+	fn := filepath.Join(sdir, msgDir, streamStreamStateFile)
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fs, err := si.openStream(acc, sdir, cfg, false)
+	if err != nil {
+		return err
+	}
+	// Without an index we may have new encryption keys, so rewrite our meta file the same as the server would.
+	fs.mu.Lock()
+	err = fs.writeStreamMeta()
+	fs.mu.Unlock()
+	if err != nil {
+		fs.Stop()
+		return err
+	}
+	var state StreamState
+	fs.FastState(&state)
+	ld := fs.lostData()
+	if err := fs.Stop(); err != nil {
+		return err
+	}
+	si.printf("%sRepaired: rebuilt index with %s messages, sequences %d..%d\n", indent,
+		comma(int64(state.Msgs)), state.FirstSeq, state.LastSeq)
+	if ld != nil && len(ld.Msgs) > 0 {
+		si.printf("%sRepaired: lost %d messages (%s)\n", indent, len(ld.Msgs), friendlyBytes(ld.Bytes))
+	}
+	return nil
+}
+
+// Export the stream along with its consumers to a snapshot in file.
+// Recovering the store writes to it, so we work from a copy of the stream next to file.
+func (si *storeInspector) exportStream(acc, sdir string, cfg *FileStreamInfo, file string) error {
+	tmp, err := os.MkdirTemp(filepath.Dir(file), "export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	sdir, err = copyInspectDir(sdir, filepath.Join(tmp, filepath.Base(sdir)))
+	if err != nil {
+		return err
+	}
+
+	fs, err := si.openStream(acc, sdir, cfg, true)
+	if err != nil {
+		return err
+	}
+	defer fs.Stop()
+
+	// Consumers need to be known to the store to be included.
+	odir := filepath.Join(sdir, consumerDir)
+	fis, _ := os.ReadDir(odir)
+	for _, fi := range fis {
+		cdir := filepath.Join(odir, fi.Name())
+		aek, err := si.metaKey(cdir, acc, cfg.Name+tsep+fi.Name())
+		if err != nil {
+			return fmt.Errorf("consumer %q: %w", fi.Name(), err)
+		}
+		var ccfg FileConsumerInfo
+		if err := readInspectMeta(cdir, cfg.Name+"/"+fi.Name(), aek, &ccfg); err != nil {
+			return fmt.Errorf("consumer %q: %w", fi.Name(), err)
+		}
+		o, err := fs.ConsumerStore(fi.Name(), &ccfg.ConsumerConfig)
+		if err != nil {
+			return fmt.Errorf("consumer %q: %w", fi.Name(), err)
+		}
+		defer o.Stop()
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, defaultFilePerms)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sr, err := fs.Snapshot(0, false, true)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, sr.Reader); err != nil {
+		return err
+	}
+	if err := <-sr.errCh; err != _EMPTY_ {
+		return errors.New(err)
+	}
+	return f.Sync()
+}
+
+// Copy the directory src to dst, returning dst.
+func copyInspectDir(src, dst string) (string, error) {
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, defaultDirPerms)
+		}
+		return copyFileAtomic(path, target)
+	})
+	return dst, err
+}
+
+// readOnlyColdStore lets an export read blocks offloaded to the cold tier without changing it.
+type readOnlyColdStore struct {
+	ColdStore
+}
+
+func (readOnlyColdStore) Put(name, src string) error { return errStoreInspectRO }
+func (readOnlyColdStore) Remove(name string) error   { return errStoreInspectRO }
+func (readOnlyColdStore) RemoveAll() error           { return errStoreInspectRO }
+
+// Format a timestamp from the store.
+func inspectTime(ts int64) string {
+	if ts == 0 {
+		return "-"
+	}
+	return time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+}
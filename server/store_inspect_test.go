@@ -0,0 +1,237 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !skip_js_tests
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Runs a server on the given store with an optional encryption key.
+func runStoreInspectServer(t *testing.T, sd, key string) *Server {
+	t.Helper()
+	var keyOpt string
+	if key != _EMPTY_ {
+		keyOpt = fmt.Sprintf("key: %q", key)
+	}
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: { store_dir: %q, %s }
+	`, sd, keyOpt)))
+	s, _ := RunServerWithConfig(conf)
+	return s
+}
+
+// Creates a stream with a consumer that has acked half of its messages.
+func setupStoreInspectServer(t *testing.T, sd, key string) *Server {
+	t.Helper()
+	s := runStoreInspectServer(t, sd, key)
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = js.Publish("foo", []byte("hello"))
+		require_NoError(t, err)
+	}
+	sub, err := js.PullSubscribe("foo", "C")
+	require_NoError(t, err)
+	msgs, err := sub.Fetch(5)
+	require_NoError(t, err)
+	for _, m := range msgs {
+		require_NoError(t, m.AckSync())
+	}
+	return s
+}
+
+func inspectStore(t *testing.T, opts *Options) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	opts.NoLog, opts.NoSigs = true, true
+	err := InspectStore(opts, &buf)
+	return buf.String(), err
+}
+
+func TestJetStreamStoreInspect(t *testing.T) {
+	for _, key := range []string{_EMPTY_, "s3cr3t"} {
+		t.Run(fmt.Sprintf("encrypted=%v", key != _EMPTY_), func(t *testing.T) {
+			sd := t.TempDir()
+			s := setupStoreInspectServer(t, sd, key)
+
+			// Not while the server is running on it.
+			_, err := inspectStore(t, &Options{StoreInspect: sd, JetStreamKey: key})
+			require_Error(t, err)
+			require_True(t, strings.Contains(err.Error(), "in use by a running server"))
+			s.Shutdown()
+
+			out, err := inspectStore(t, &Options{StoreInspect: sd, JetStreamKey: key})
+			require_NoError(t, err)
+			for _, expected := range []string{
+				`Account "$G"`,
+				`Stream "TEST"`,
+				"Block 1:",
+				"10 messages, sequences 1..10",
+				`Consumer "C" (durable, explicit): delivered 5/5, ack floor 5/5`,
+				"No problems found",
+			} {
+				if !strings.Contains(out, expected) {
+					t.Fatalf("Expected %q in output:\n%s", expected, out)
+				}
+			}
+			if key != _EMPTY_ && !strings.Contains(out, "[encrypted]") {
+				t.Fatalf("Expected block to be reported as encrypted:\n%s", out)
+			}
+
+			// Without the key we can not read anything.
+			if key != _EMPTY_ {
+				out, err = inspectStore(t, &Options{StoreInspect: sd})
+				require_Error(t, err)
+				require_True(t, strings.Contains(out, errStoreInspectNoKey.Error()))
+			}
+
+			// Simulate a torn write at the end of the last block.
+			fn := filepath.Join(sd, JetStreamStoreDir, globalAccountName, streamsDir, "TEST", msgDir, fmt.Sprintf(blkScan, 1))
+			fi, err := os.Stat(fn)
+			require_NoError(t, err)
+			f, err := os.OpenFile(fn, os.O_APPEND|os.O_WRONLY, 0644)
+			require_NoError(t, err)
+			_, err = f.Write([]byte("torn"))
+			require_NoError(t, err)
+			require_NoError(t, f.Close())
+
+			out, err = inspectStore(t, &Options{StoreInspect: sd, JetStreamKey: key})
+			require_Error(t, err)
+			if expected := fmt.Sprintf("torn tail of 4 bytes at offset %d", fi.Size()); !strings.Contains(out, expected) {
+				t.Fatalf("Expected %q in output:\n%s", expected, out)
+			}
+
+			// Repair should truncate the tail and rebuild the index.
+			out, err = inspectStore(t, &Options{StoreInspect: sd, JetStreamKey: key, StoreRepair: true})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n%s", err, out)
+			}
+			require_True(t, strings.Contains(out, "Repaired: rebuilt index with 10 messages, sequences 1..10"))
+			fi2, err := os.Stat(fn)
+			require_NoError(t, err)
+			require_Equal(t, fi2.Size(), fi.Size())
+
+			// Make sure the server is happy with the repaired store.
+			s = runStoreInspectServer(t, sd, key)
+			defer s.Shutdown()
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 10)
+		})
+	}
+}
+
+func TestJetStreamStoreInspectExport(t *testing.T) {
+	sd := t.TempDir()
+	s := setupStoreInspectServer(t, sd, "s3cr3t")
+	s.Shutdown()
+
+	// Without an index recovering the stream would write a new one.
+	require_NoError(t, os.Remove(filepath.Join(sd, JetStreamStoreDir, globalAccountName, streamsDir, "TEST", msgDir, streamStreamStateFile)))
+
+	// Returns the contents of every file in the store.
+	storeFiles := func() map[string]string {
+		t.Helper()
+		files := make(map[string]string)
+		err := filepath.WalkDir(sd, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			buf, err := os.ReadFile(path)
+			files[path] = string(buf)
+			return err
+		})
+		require_NoError(t, err)
+		return files
+	}
+	before := storeFiles()
+
+	snap := filepath.Join(t.TempDir(), "TEST.tar.s2")
+	opts := &Options{StoreInspect: sd, JetStreamKey: "s3cr3t", StoreExport: "$G/TEST", StoreExportFile: snap}
+	out, err := inspectStore(t, opts)
+	require_NoError(t, err)
+	require_True(t, strings.Contains(out, "Exported to "+snap))
+
+	// Exporting must not have changed the store, nor left anything behind.
+	if after := storeFiles(); !reflect.DeepEqual(before, after) {
+		t.Fatalf("Expected store to be unchanged by export")
+	}
+	fis, err := os.ReadDir(filepath.Dir(snap))
+	require_NoError(t, err)
+	require_Len(t, len(fis), 1)
+
+	// Unknown streams are reported.
+	opts.StoreExport = "$G/FOO"
+	_, err = inspectStore(t, opts)
+	require_Error(t, err)
+
+	// Restore into a new server.
+	s = RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	data, err := os.ReadFile(snap)
+	require_NoError(t, err)
+	req, err := json.Marshal(&JSApiStreamRestoreRequest{
+		Config: StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Storage: FileStorage},
+	})
+	require_NoError(t, err)
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamRestoreT, "TEST"), req, 5*time.Second)
+	require_NoError(t, err)
+	var rresp JSApiStreamRestoreResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &rresp))
+	require_True(t, rresp.Error == nil)
+
+	var chunk [1024]byte
+	for r := bytes.NewReader(data); ; {
+		n, err := r.Read(chunk[:])
+		if err != nil {
+			break
+		}
+		_, err = nc.Request(rresp.DeliverSubject, chunk[:n], time.Second)
+		require_NoError(t, err)
+	}
+	rmsg, err = nc.Request(rresp.DeliverSubject, nil, 5*time.Second)
+	require_NoError(t, err)
+	rresp.Error = nil
+	require_NoError(t, json.Unmarshal(rmsg.Data, &rresp))
+	require_True(t, rresp.Error == nil)
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 10)
+	ci, err := js.ConsumerInfo("TEST", "C")
+	require_NoError(t, err)
+	require_Equal(t, ci.AckFloor.Stream, 5)
+}
@@ -0,0 +1,41 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Name of the lock file a running server holds in its store directory.
+const storeLockFile = ".lock"
+
+var errStoreDirLocked = errors.New("store directory is locked by another process")
+
+// lockStoreDir takes an exclusive lock on the store directory so that offline tools
+// can tell whether a running server owns it. The lock is released when the returned
+// file is closed, or when the process exits. Returns errStoreDirLocked if the lock
+// is already held.
+func lockStoreDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, storeLockFile), os.O_RDWR|os.O_CREATE, defaultFilePerms)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -33,6 +33,7 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/s2"
+	"github.com/nats-io/nats-server/v2/server/thw"
 	"github.com/nats-io/nuid"
 )
 
@@ -69,6 +70,12 @@ type StreamConfig struct {
 	Compression  StoreCompression `json:"compression"`
 	FirstSeq     uint64           `json:"first_seq,omitempty"`
 
+	// Optional settings for the compression algorithm, like the zstd level and dictionary.
+	CompressionOpts *StoreCompressionOpts `json:"compression_opts,omitempty"`
+
+	// Optional offloading of older message blocks to the server's cold store. File storage only.
+	ColdTier *StreamColdTier `json:"cold_tier,omitempty"`
+
 	// Allow applying a subject transform to incoming messages before doing anything else
 	SubjectTransform *SubjectTransformConfig `json:"subject_transform,omitempty"`
 
@@ -108,6 +115,24 @@ type StreamConfig struct {
 	// subject delete markers.
 	SubjectDeleteMarkerTTL time.Duration `json:"subject_delete_marker_ttl,omitempty"`
 
+	// AllowAtomicPublish allows groups of messages to be published atomically,
+	// using the `Nats-Batch-*` headers. Either all messages are stored or none are.
+	AllowAtomicPublish bool `json:"allow_atomic,omitempty"`
+
+	// AllowMsgSchedules allows messages to be held back until a later time, using the
+	// `Nats-Deliver-At` or `Nats-Delay` headers. Held messages are not visible to consumers
+	// or gets, and a copy is published to the stream once the schedule is due.
+	AllowMsgSchedules bool `json:"allow_msg_schedules,omitempty"`
+
+	// AllowMsgCounter turns the stream into a counter stream. Every message must carry the
+	// `Nats-Incr` header, which is added to the last total for the subject and stored as the new total.
+	AllowMsgCounter bool `json:"allow_msg_counter,omitempty"`
+
+	// Schemas binds JSON Schema documents to subject filters. Messages on a matching subject
+	// must have a JSON payload that validates against every matching schema, or they are rejected.
+	// Messages received from sources are not validated again.
+	Schemas []*StreamSchema `json:"schemas,omitempty"`
+
 	// Metadata is additional metadata for the Stream.
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
@@ -118,6 +143,10 @@ func (cfg *StreamConfig) clone() *StreamConfig {
 	clone := *cfg
 	if cfg.Placement != nil {
 		placement := *cfg.Placement
+		if placement.Learners != nil {
+			learners := *placement.Learners
+			placement.Learners = &learners
+		}
 		clone.Placement = &placement
 	}
 	if cfg.Mirror != nil {
@@ -139,6 +168,22 @@ func (cfg *StreamConfig) clone() *StreamConfig {
 		rePublish := *cfg.RePublish
 		clone.RePublish = &rePublish
 	}
+	if cfg.CompressionOpts != nil {
+		compressionOpts := *cfg.CompressionOpts
+		clone.CompressionOpts = &compressionOpts
+	}
+	if cfg.ColdTier != nil {
+		coldTier := *cfg.ColdTier
+		clone.ColdTier = &coldTier
+	}
+	if cfg.Schemas != nil {
+		clone.Schemas = make([]*StreamSchema, len(cfg.Schemas))
+		for i, ss := range cfg.Schemas {
+			if ss != nil {
+				clone.Schemas[i] = &StreamSchema{Subject: ss.Subject, Schema: append(json.RawMessage(nil), ss.Schema...)}
+			}
+		}
+	}
 	if cfg.Metadata != nil {
 		clone.Metadata = make(map[string]string, len(cfg.Metadata))
 		for k, v := range cfg.Metadata {
@@ -159,6 +204,15 @@ type SubjectTransformConfig struct {
 	Destination string `json:"dest"`
 }
 
+// StreamColdTier determines when sealed message blocks are moved to the cold tier.
+// A block is moved once either threshold is exceeded.
+type StreamColdTier struct {
+	// MaxAge moves blocks whose newest message is older than this.
+	MaxAge time.Duration `json:"max_age,omitempty"`
+	// MaxBytes moves the oldest blocks once the hot tier holds more than this.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
 // RePublish is for republishing messages once committed to a stream.
 type RePublish struct {
 	Source      string `json:"src,omitempty"`
@@ -188,6 +242,11 @@ type PubAck struct {
 	Sequence  uint64 `json:"seq"`
 	Domain    string `json:"domain,omitempty"`
 	Duplicate bool   `json:"duplicate,omitempty"`
+	// For atomic batch publishes, Sequence is the last sequence of the batch.
+	BatchId   string `json:"batch,omitempty"`
+	BatchSize int    `json:"count,omitempty"`
+	// For counter streams, the new total for the subject.
+	Value string `json:"val,omitempty"`
 }
 
 // StreamInfo shows config and current state for this stream.
@@ -228,6 +287,8 @@ type PeerInfo struct {
 	Active  time.Duration `json:"active"`
 	Lag     uint64        `json:"lag,omitempty"`
 	Peer    string        `json:"peer"`
+	// Learners replicate the stream without voting.
+	Learner bool `json:"learner,omitempty"`
 	// For migrations.
 	cluster string
 }
@@ -291,6 +352,8 @@ type stream struct {
 	ackq      *ipQueue[uint64]        // Intra-process queue for acks.
 	lseq      uint64                  // The sequence number of the last message stored in the stream.
 	lmsgId    string                  // The de-duplication message ID of the last message stored in the stream.
+	imsgs     uint64                  // The number of messages stored since we were created, to weigh our leader when balancing.
+	ibytes    uint64                  // The number of bytes stored since we were created.
 	consumers map[string]*consumer    // The consumers for this stream.
 	numFilter int                     // The number of filtered consumers.
 	cfg       StreamConfig            // The stream's config.
@@ -321,12 +384,18 @@ type stream struct {
 	// Indicates we have direct consumers.
 	directs int
 
+	// Number of consumers with header filters, these need the headers of new messages.
+	hfcons atomic.Int32
+
 	// For input subject transform.
 	itr *subjectTransform
 
 	// For republishing.
 	tr *subjectTransform
 
+	// For validating message payloads.
+	schemas []*msgSchema
+
 	// For processing consumers without main stream lock.
 	clsMu sync.RWMutex
 	cList []*consumer     // Consumer list.
@@ -356,11 +425,22 @@ type stream struct {
 	inflight  map[uint64]uint64 // Inflight message sizes per clseq.
 	lqsent    time.Time         // The time at which the last lost quorum advisory was sent. Used to rate limit.
 	uch       chan struct{}     // The channel to signal updates to the monitor routine.
+	rpch      chan []scrubRange // The channel to signal the monitor routine to repair corrupt data from a peer.
 	inMonitor bool              // True if the monitor routine has been started.
 
 	expectedPerSubjectSequence  map[uint64]string   // Inflight 'expected per subject' subjects per clseq.
 	expectedPerSubjectInProcess map[string]struct{} // Current 'expected per subject' subjects in process.
 
+	// Atomic batches being staged, only tracked on the leader.
+	batches map[string]*batchGroup
+	// Atomic batch being applied from the raft log, only accessed by the monitor routine.
+	batchApply *batchApply
+
+	// Messages held back by a schedule, released by the leader once due.
+	held       heldMsgs
+	scheduled  *thw.HashWheel
+	schedTimer *time.Timer
+
 	// Direct get subscription.
 	directSub *subscription
 	lastBySub *subscription
@@ -416,11 +496,20 @@ const (
 	JSLastConsumerSeq         = "Nats-Last-Consumer"
 	JSLastStreamSeq           = "Nats-Last-Stream"
 	JSConsumerStalled         = "Nats-Consumer-Stalled"
+	JSConsumerRateLimited     = "Nats-Consumer-Rate-Limited"
 	JSMsgRollup               = "Nats-Rollup"
 	JSMsgSize                 = "Nats-Msg-Size"
 	JSResponseType            = "Nats-Response-Type"
 	JSMessageTTL              = "Nats-TTL"
 	JSMarkerReason            = "Nats-Marker-Reason"
+	JSBatchId                 = "Nats-Batch-Id"
+	JSBatchSeq                = "Nats-Batch-Sequence"
+	JSBatchCommit             = "Nats-Batch-Commit"
+	JSMsgDeliverAt            = "Nats-Deliver-At"
+	JSMsgDelay                = "Nats-Delay"
+	JSScheduledSequence       = "Nats-Scheduled-Sequence"
+	JSMsgIncr                 = "Nats-Incr"
+	JSMsgCounterSources       = "Nats-Counter-Sources"
 )
 
 // Headers for republished messages and direct gets.
@@ -670,6 +759,7 @@ func (a *Account) addStreamWithAssignment(config *StreamConfig, fsConfig *FileSt
 		qch:  make(chan struct{}),
 		mqch: make(chan struct{}),
 		uch:  make(chan struct{}, 4),
+		rpch: make(chan []scrubRange, 1),
 		sch:  make(chan struct{}, 1),
 	}
 
@@ -692,6 +782,14 @@ func (a *Account) addStreamWithAssignment(config *StreamConfig, fsConfig *FileSt
 		mset.itr = tr
 	}
 
+	// Check for payload schemas.
+	schemas, err := compileStreamSchemas(cfg.Schemas)
+	if err != nil {
+		jsa.mu.Unlock()
+		return nil, fmt.Errorf("stream schemas: %w", err)
+	}
+	mset.schemas = schemas
+
 	// Check for RePublish.
 	if cfg.RePublish != nil {
 		tr, err := NewSubjectTransform(cfg.RePublish.Source, cfg.RePublish.Destination)
@@ -726,11 +824,18 @@ func (a *Account) addStreamWithAssignment(config *StreamConfig, fsConfig *FileSt
 	fsCfg.SyncInterval = s.getOpts().SyncInterval
 	fsCfg.SyncAlways = s.getOpts().SyncAlways
 	fsCfg.Compression = config.Compression
+	fsCfg.CompressionOpts = config.CompressionOpts
+	if coldDir := s.getOpts().JetStreamColdStoreDir; coldDir != _EMPTY_ && cfg.Storage == FileStorage {
+		fsCfg.ColdStore = newDirColdStore(s.coldStreamDir(coldDir, a.Name, cfg.Name))
+	}
 
 	if err := mset.setupStore(fsCfg); err != nil {
 		mset.stop(true, false)
 		return nil, NewJSStreamStoreFailedError(err)
 	}
+	if cfg.AllowMsgSchedules {
+		mset.loadHeldMsgs()
+	}
 
 	// Create our pubAck template here. Better than json marshal each time on success.
 	if domain := s.getOpts().JetStreamDomain; domain != _EMPTY_ {
@@ -925,6 +1030,15 @@ func (mset *stream) updateC() <-chan struct{} {
 	return mset.uch
 }
 
+func (mset *stream) repairC() <-chan []scrubRange {
+	if mset == nil {
+		return nil
+	}
+	mset.mu.RLock()
+	defer mset.mu.RUnlock()
+	return mset.rpch
+}
+
 // IsLeader will return if we are the current leader.
 func (mset *stream) IsLeader() bool {
 	mset.mu.RLock()
@@ -960,6 +1074,9 @@ func (mset *stream) setLeader(isLeader bool) error {
 			mset.mu.Unlock()
 			return err
 		}
+
+		// Only the leader releases held messages.
+		mset.rebuildScheduledMsgsLocked()
 	} else {
 		// cancel timer to create the source consumers if not fired yet
 		if mset.sourcesConsumerSetup != nil {
@@ -976,6 +1093,9 @@ func (mset *stream) setLeader(isLeader bool) error {
 		mset.unsubscribeToStream(false)
 		// Clear catchup state
 		mset.clearAllCatchupPeers()
+		// Staged batches can only be committed by the leader.
+		mset.clearBatchesLocked()
+		mset.clearScheduledMsgsLocked()
 	}
 	mset.mu.Unlock()
 
@@ -1383,6 +1503,72 @@ func (s *Server) checkStreamCfg(config *StreamConfig, acc *Account, pedantic boo
 		return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("subject marker delete TTL must not be negative"))
 	}
 
+	// Mirrors don't accept published messages, so can't accept atomic batches either.
+	if cfg.AllowAtomicPublish && cfg.Mirror != nil {
+		return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("atomic publish is not supported for mirrors"))
+	}
+
+	if cfg.CompressionOpts != nil && cfg.Compression != ZstdCompression {
+		return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("compression options require zstd compression"))
+	}
+	if err := cfg.CompressionOpts.validate(); err != nil {
+		return StreamConfig{}, NewJSStreamInvalidConfigError(err)
+	}
+
+	if ct := cfg.ColdTier; ct != nil {
+		if cfg.Storage != FileStorage {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("cold tier requires file storage"))
+		}
+		if s.getOpts().JetStreamColdStoreDir == _EMPTY_ {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("cold tier requires a cold store directory to be configured"))
+		}
+		if ct.MaxAge < 0 || ct.MaxBytes < 0 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("cold tier thresholds can not be negative"))
+		}
+		if ct.MaxAge == 0 && ct.MaxBytes == 0 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("cold tier requires a max age or max bytes threshold"))
+		}
+	}
+
+	// Counters replace the message body with the new total, so can't be combined with features
+	// that store or release messages without going through the regular publish path.
+	if cfg.AllowMsgCounter {
+		if cfg.AllowAtomicPublish {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("message counters can not be combined with atomic publish"))
+		}
+		if cfg.AllowMsgSchedules {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("message counters can not be combined with message schedules"))
+		}
+		if cfg.Retention != LimitsPolicy {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("message counters require limits retention policy"))
+		}
+	}
+
+	// Schemas are checked on publish, mirrors don't accept published messages and
+	// counter streams store their own totals instead of the published payload.
+	if len(cfg.Schemas) > 0 {
+		if cfg.Mirror != nil {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("schemas are not supported for mirrors"))
+		}
+		if cfg.AllowMsgCounter {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("schemas can not be combined with message counters"))
+		}
+		if _, err := compileStreamSchemas(cfg.Schemas); err != nil {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(err)
+		}
+	}
+
+	// Held messages are released by publishing a copy into the stream, which mirrors can't do.
+	// They also must not be removed by consumer acks before being released.
+	if cfg.AllowMsgSchedules {
+		if cfg.Mirror != nil {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("message schedules are not supported for mirrors"))
+		}
+		if cfg.Retention != LimitsPolicy {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("message schedules require limits retention policy"))
+		}
+	}
+
 	getStream := func(streamName string) (bool, StreamConfig) {
 		var exists bool
 		var cfg StreamConfig
@@ -1738,6 +1924,16 @@ func (s *Server) checkStreamCfg(config *StreamConfig, acc *Account, pedantic boo
 		return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("preferred server not permitted in placement"))
 	}
 
+	// Learners join the stream's raft group, so the stream needs to be replicated.
+	if cfg.Placement != nil && cfg.Placement.Learners != nil {
+		if cfg.Placement.Learners.Replicas < 1 || cfg.Placement.Learners.Replicas > StreamMaxReplicas {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("learner replicas must be between 1 and %d", StreamMaxReplicas))
+		}
+		if cfg.Replicas < 2 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("learners require a replicated stream"))
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -1817,11 +2013,45 @@ func (jsa *jsAccount) configUpdateCheck(old, new *StreamConfig, s *Server, pedan
 		}
 	}
 
+	// Check for learner changes which are not allowed.
+	var learners, oldLearners *LearnerPlacement
+	if cfg.Placement != nil {
+		learners = cfg.Placement.Learners
+	}
+	if old.Placement != nil {
+		oldLearners = old.Placement.Learners
+	}
+	if !reflect.DeepEqual(learners, oldLearners) {
+		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("stream learners can not be changed after stream creation"))
+	}
+
 	// Check on the allowed message TTL status.
 	if cfg.AllowMsgTTL != old.AllowMsgTTL {
 		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("message TTL status can not be changed after stream creation"))
 	}
 
+	// Compressed blocks can only be read back with the dictionary they were compressed with.
+	var dict, oldDict []byte
+	if cfg.CompressionOpts != nil {
+		dict = cfg.CompressionOpts.Dictionary
+	}
+	if old.CompressionOpts != nil {
+		oldDict = old.CompressionOpts.Dictionary
+	}
+	if !bytes.Equal(dict, oldDict) {
+		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("compression dictionary can not be changed after stream creation"))
+	}
+
+	// Check on the allowed message counter status.
+	if cfg.AllowMsgCounter != old.AllowMsgCounter {
+		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("message counter status can not be changed after stream creation"))
+	}
+
+	// Check on the allowed message schedules status.
+	if cfg.AllowMsgSchedules != old.AllowMsgSchedules {
+		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("message schedules status can not be changed after stream creation"))
+	}
+
 	// Do some adjustments for being sealed.
 	// Pedantic mode will allow those changes to be made, as they are determinictic and important to get a sealed stream.
 	if cfg.Sealed {
@@ -2097,6 +2327,14 @@ func (mset *stream) updateWithAdvisory(config *StreamConfig, sendAdvisory bool,
 		mset.itr = nil
 	}
 
+	// Recompile payload schemas.
+	schemas, err := compileStreamSchemas(cfg.Schemas)
+	if err != nil {
+		mset.mu.Unlock()
+		return fmt.Errorf("stream configuration for schemas: %w", err)
+	}
+	mset.schemas = schemas
+
 	js := mset.js
 
 	if targetTier := tierName(cfg.Replicas); mset.tier != targetTier {
@@ -4070,6 +4308,15 @@ func (mset *stream) unsubscribe(sub *subscription) {
 }
 
 func (mset *stream) setupStore(fsCfg *FileStoreConfig) error {
+	// The key provider could be slow, so get our keys before taking the lock.
+	var prf, oldprf keyGen
+	if mset.cfg.Storage == FileStorage {
+		var err error
+		if prf, oldprf, err = mset.srv.jsAccountKeyGen(mset.acc.Name, mset.acc.Name); err != nil {
+			return err
+		}
+	}
+
 	mset.mu.Lock()
 	mset.created = time.Now().UTC()
 
@@ -4083,12 +4330,10 @@ func (mset *stream) setupStore(fsCfg *FileStoreConfig) error {
 		mset.store = ms
 	case FileStorage:
 		s := mset.srv
-		prf := s.jsKeyGen(s.getOpts().JetStreamKey, mset.acc.Name)
 		if prf != nil {
 			// We are encrypted here, fill in correct cipher selection.
 			fsCfg.Cipher = s.getOpts().JetStreamCipher
 		}
-		oldprf := s.jsKeyGen(s.getOpts().JetStreamOldKey, mset.acc.Name)
 		cfg := *fsCfg
 		cfg.srv = s
 		fs, err := newFileStoreWithCreated(cfg, mset.cfg, mset.created, prf, oldprf)
@@ -4101,7 +4346,7 @@ func (mset *stream) setupStore(fsCfg *FileStoreConfig) error {
 	// This will fire the callback but we do not require the lock since md will be 0 here.
 	mset.store.RegisterStorageUpdates(mset.storeUpdates)
 	mset.store.RegisterSubjectDeleteMarkerUpdates(func(seq uint64, subj string) {
-		mset.signalConsumers(subj, seq)
+		mset.signalConsumers(subj, seq, nil)
 	})
 	mset.mu.Unlock()
 
@@ -4116,18 +4361,22 @@ func (mset *stream) storeUpdates(md, bd int64, seq uint64, subj string) {
 	// If we have a single negative update then we will process our consumers for stream pending.
 	// Purge and Store handled separately inside individual calls.
 	if md == -1 && seq > 0 && subj != _EMPTY_ {
-		// We use our consumer list mutex here instead of the main stream lock since it may be held already.
-		mset.clsMu.RLock()
-		// TODO(dlc) - Do sublist like signaling so we do not have to match?
-		for _, o := range mset.cList {
-			o.decStreamPending(seq, subj)
+		// Held messages were never pending for our consumers.
+		if !mset.held.remove(seq) {
+			// We use our consumer list mutex here instead of the main stream lock since it may be held already.
+			mset.clsMu.RLock()
+			// TODO(dlc) - Do sublist like signaling so we do not have to match?
+			for _, o := range mset.cList {
+				o.decStreamPending(seq, subj)
+			}
+			mset.clsMu.RUnlock()
 		}
-		mset.clsMu.RUnlock()
 	} else if md < 0 {
+		mset.held.prune(mset.store)
 		// Batch decrements we need to force consumers to re-calculate num pending.
 		mset.clsMu.RLock()
 		for _, o := range mset.cList {
-			o.streamNumPendingLocked()
+			o.streamNumPendingAfterRemoval()
 		}
 		mset.clsMu.RUnlock()
 	}
@@ -4314,6 +4563,18 @@ func (mset *stream) isClustered() bool {
 	return mset.node != nil
 }
 
+// Returns whether we can serve linearizable reads. This requires us to be
+// the leader, and to hold our leader lease if we are replicated.
+func (mset *stream) leaseValid() bool {
+	mset.mu.RLock()
+	node := mset.node
+	mset.mu.RUnlock()
+	if node != nil {
+		return node.LeaseValid()
+	}
+	return mset.isLeader()
+}
+
 // Used if we have to queue things internally to avoid the route/gw path.
 type inMsg struct {
 	subj string
@@ -4400,6 +4661,14 @@ func (mset *stream) processDirectGetRequest(_ *subscription, c *client, _ *Accou
 		mset.outq.send(newJSPubMsg(reply, _EMPTY_, _EMPTY_, hdr, nil, nil, 0))
 		return
 	}
+	// Any replica or mirror can answer direct gets, but linearizable ones only
+	// from the stream leader while it holds its lease. Mirrors answering for their
+	// origin are never up to date enough.
+	if req.Linearizable && (tokenAt(subject, 5) != mset.name() || !mset.leaseValid()) {
+		hdr := []byte("NATS/1.0 409 Linearizable Read Unavailable\r\n\r\n")
+		mset.outq.send(newJSPubMsg(reply, _EMPTY_, _EMPTY_, hdr, nil, nil, 0))
+		return
+	}
 
 	inlineOk := c.kind != ROUTER && c.kind != GATEWAY && c.kind != LEAF
 	if !inlineOk {
@@ -4527,6 +4796,13 @@ func (mset *stream) getDirectMulti(req *JSApiMsgGetRequest, reply string) {
 			mset.outq.send(newJSPubMsg(reply, _EMPTY_, _EMPTY_, hdr, nil, nil, 0))
 			return
 		}
+		// Messages held back by a schedule are not visible until released.
+		if mset.cfg.AllowMsgSchedules && isScheduledMsg(sm.hdr) {
+			if np > 0 {
+				np--
+			}
+			continue
+		}
 
 		hdr := sm.hdr
 		ts := time.Unix(0, sm.ts).UTC()
@@ -4576,7 +4852,7 @@ func (mset *stream) getDirectRequest(req *JSApiMsgGetRequest, reply string) {
 	}
 
 	mset.mu.RLock()
-	store, name, s := mset.store, mset.cfg.Name, mset.srv
+	store, name, s, schedules := mset.store, mset.cfg.Name, mset.srv, mset.cfg.AllowMsgSchedules
 	mset.mu.RUnlock()
 
 	var seq uint64
@@ -4616,19 +4892,31 @@ func (mset *stream) getDirectRequest(req *JSApiMsgGetRequest, reply string) {
 			sm  *StoreMsg
 			err error
 		)
+		// Messages held back by a schedule are not visible until released.
 		if seq > 0 && req.NextFor == _EMPTY_ {
 			// Only do direct lookup for first in a batch.
 			if i == 0 {
 				sm, err = store.LoadMsg(seq, &svp)
+				if err == nil && schedules && isScheduledMsg(sm.hdr) {
+					sm, err = nil, ErrStoreMsgNotFound
+				}
 			} else {
 				// We want to use load next with fwcs to step over deleted msgs.
 				sm, seq, err = store.LoadNextMsg(fwcs, true, seq, &svp)
+				for err == nil && schedules && isScheduledMsg(sm.hdr) {
+					sm, seq, err = store.LoadNextMsg(fwcs, true, seq+1, &svp)
+				}
 			}
 			// Bump for next loop if applicable.
 			seq++
 		} else if req.NextFor != _EMPTY_ {
 			sm, seq, err = store.LoadNextMsg(req.NextFor, wc, seq, &svp)
+			for err == nil && schedules && isScheduledMsg(sm.hdr) {
+				sm, seq, err = store.LoadNextMsg(req.NextFor, wc, seq+1, &svp)
+			}
 			seq++
+		} else if schedules {
+			sm, err = loadLastVisibleMsg(store, req.LastFor, &svp)
 		} else {
 			// Batch is not applicable here, this is checked before we get here.
 			sm, err = store.LoadLastMsg(req.LastFor, &svp)
@@ -4696,6 +4984,10 @@ func (mset *stream) getDirectRequest(req *JSApiMsgGetRequest, reply string) {
 // processInboundJetStreamMsg handles processing messages bound for a stream.
 func (mset *stream) processInboundJetStreamMsg(_ *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
 	hdr, msg := c.msgParts(copyBytes(rmsg)) // Need to copy.
+	// The scheduled sequence is only set by the server when releasing held messages.
+	if len(hdr) > 0 {
+		hdr = removeHeaderIfPresent(hdr, JSScheduledSequence)
+	}
 	if mt, traceOnly := c.isMsgTraceEnabled(); mt != nil {
 		// If message is delivered, we need to disable the message trace headers
 		// to prevent a trace event to be generated when a stored message
@@ -4719,6 +5011,9 @@ var (
 	errInvalidMsgHandler = errors.New("undefined message handler")
 	errStreamMismatch    = errors.New("expected stream does not match")
 	errMsgTTLDisabled    = errors.New("message TTL disabled")
+
+	errMsgSchedulesDisabled = errors.New("message schedules disabled")
+	errMsgScheduleReleased  = errors.New("scheduled message already released")
 )
 
 // processJetStreamMsg is where we try to actually process the stream msg.
@@ -4735,6 +5030,14 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 		return errStreamClosed
 	}
 
+	// Payloads must validate against any schemas bound to the subject, before they are sequenced.
+	// When clustered this was done before proposing, which is when lseq is not set.
+	var schemaApiErr *ApiError
+	var schemaErr error
+	if lseq == 0 && !sourced {
+		schemaApiErr, schemaErr = mset.checkSchemas(subject, msg)
+	}
+
 	mset.mu.Lock()
 	s, store := mset.srv, mset.store
 
@@ -4836,6 +5139,31 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 	var rollupSub, rollupAll bool
 	isClustered := mset.isClustered()
 
+	// Counter increments are rejected if counters are not enabled on the stream, and required if they are.
+	if apiErr, err := checkMsgCounter(hdr, mset.cfg.AllowMsgCounter, sourced); apiErr != nil {
+		mset.mu.Unlock()
+		bumpCLFS()
+		if canRespond {
+			resp.PubAck = &PubAck{Stream: name}
+			resp.Error = apiErr
+			b, _ := json.Marshal(resp)
+			mset.outq.sendMsg(reply, b)
+		}
+		return err
+	}
+
+	if schemaApiErr != nil {
+		mset.mu.Unlock()
+		bumpCLFS()
+		if canRespond {
+			resp.PubAck = &PubAck{Stream: name}
+			resp.Error = schemaApiErr
+			b, _ := json.Marshal(resp)
+			mset.outq.sendMsg(reply, b)
+		}
+		return schemaErr
+	}
+
 	if len(hdr) > 0 {
 		outq := mset.outq
 
@@ -4872,6 +5200,29 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 			return errMsgTTLDisabled
 		}
 
+		// Scheduled messages are rejected entirely if schedules are not enabled on the stream,
+		// or if the schedule can't be parsed. Clustered mode already checked this as well.
+		if !sourced && isScheduledMsg(hdr) {
+			var apiErr *ApiError
+			var err error
+			if !mset.cfg.AllowMsgSchedules {
+				apiErr, err = NewJSMessageSchedulesDisabledError(), errMsgSchedulesDisabled
+			} else if _, err = getMessageSchedule(hdr, 0); err != nil {
+				apiErr = NewJSMessageSchedulesInvalidError()
+			}
+			if apiErr != nil {
+				mset.mu.Unlock()
+				bumpCLFS()
+				if canRespond {
+					resp.PubAck = &PubAck{Stream: name}
+					resp.Error = apiErr
+					b, _ := json.Marshal(resp)
+					outq.sendMsg(reply, b)
+				}
+				return err
+			}
+		}
+
 		// Dedupe detection. This is done at the cluster level for dedupe detectiom above the
 		// lower layers. But we still need to pull out the msgId.
 		if msgId = getMsgId(hdr); msgId != _EMPTY_ {
@@ -5140,6 +5491,37 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 		return err
 	}
 
+	// Counter streams store the new total for the subject instead of the published message.
+	var counterValue string
+	if mset.cfg.AllowMsgCounter {
+		var apiErr *ApiError
+		if hdr, msg, counterValue, apiErr, err = mset.applyMsgCounterLocked(subject, hdr, msg, sourced); apiErr != nil {
+			mset.mu.Unlock()
+			bumpCLFS()
+			if canRespond {
+				resp.PubAck = &PubAck{Stream: name}
+				resp.Error = apiErr
+				response, _ = json.Marshal(resp)
+				mset.outq.sendMsg(reply, response)
+			}
+			return err
+		}
+	}
+
+	// A released copy of a held message is only stored if the held message is still there,
+	// since a new leader could release the same message again.
+	var schedSeq uint64
+	if !sourced && mset.cfg.AllowMsgSchedules {
+		if schedSeq = getScheduledSequence(hdr); schedSeq > 0 {
+			var smv StoreMsg
+			if sm, err := store.LoadMsg(schedSeq, &smv); err != nil || !isScheduledMsg(sm.hdr) {
+				mset.mu.Unlock()
+				bumpCLFS()
+				return errMsgScheduleReleased
+			}
+		}
+	}
+
 	// Store actual msg.
 	if lseq == 0 && ts == 0 {
 		seq, ts, err = store.StoreMsg(subject, hdr, msg, ttl)
@@ -5202,7 +5584,23 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 		}
 	}
 
+	// The held message is removed once its copy is stored. Otherwise track newly held
+	// messages so they can be released once due, they are not republished until then.
+	var held bool
+	if schedSeq > 0 {
+		store.RemoveMsg(schedSeq)
+	} else if mset.cfg.AllowMsgSchedules && isScheduledMsg(hdr) {
+		due, _ := getMessageSchedule(hdr, ts)
+		mset.held.add(seq, subject, due)
+		if isLeader {
+			mset.trackScheduledMsgLocked(seq, due)
+		}
+		republish, held = false, true
+	}
+
 	// If here we succeeded in storing the message.
+	mset.imsgs++
+	mset.ibytes += uint64(len(hdr) + len(msg))
 	mset.mu.Unlock()
 
 	// No errors, this is the normal path.
@@ -5214,43 +5612,26 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 
 	// Check for republish.
 	if republish {
-		const ht = "NATS/1.0\r\nNats-Stream: %s\r\nNats-Subject: %s\r\nNats-Sequence: %d\r\nNats-Time-Stamp: %s\r\nNats-Last-Sequence: %d\r\n\r\n"
-		const htho = "NATS/1.0\r\nNats-Stream: %s\r\nNats-Subject: %s\r\nNats-Sequence: %d\r\nNats-Time-Stamp: %s\r\nNats-Last-Sequence: %d\r\nNats-Msg-Size: %d\r\n\r\n"
-		// When adding to existing headers, will use the fmt.Append version so this skips the headers from above.
-		const hoff = 10
-
-		tsStr := time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
-		var rpMsg []byte
-		if len(hdr) == 0 {
-			if !thdrsOnly {
-				hdr = fmt.Appendf(nil, ht, name, subject, seq, tsStr, tlseq)
-				rpMsg = copyBytes(msg)
-			} else {
-				hdr = fmt.Appendf(nil, htho, name, subject, seq, tsStr, tlseq, len(msg))
-			}
-		} else {
-			// use hdr[:end:end] to make sure as we add we copy the original hdr.
-			end := len(hdr) - LEN_CR_LF
-			if !thdrsOnly {
-				hdr = fmt.Appendf(hdr[:end:end], ht[hoff:], name, subject, seq, tsStr, tlseq)
-				rpMsg = copyBytes(msg)
-			} else {
-				hdr = fmt.Appendf(hdr[:end:end], htho[hoff:], name, subject, seq, tsStr, tlseq, len(msg))
-			}
-		}
-		mset.outq.send(newJSPubMsg(tsubj, _EMPTY_, _EMPTY_, hdr, rpMsg, nil, seq))
+		mset.republishMsg(tsubj, name, subject, hdr, msg, seq, ts, tlseq, thdrsOnly)
 	}
 
 	// Send response here.
 	if canRespond {
 		response = append(pubAck, strconv.FormatUint(seq, 10)...)
+		if counterValue != _EMPTY_ {
+			response = fmt.Appendf(response, ",\"val\":%q", counterValue)
+		}
 		response = append(response, '}')
 		mset.outq.sendMsg(reply, response)
 	}
 
-	// Signal consumers for new messages.
-	if numConsumers > 0 {
-		mset.sigq.push(newCMsg(subject, seq))
+	// Signal consumers for new messages, held messages are not delivered until released.
+	if numConsumers > 0 && !held {
+		var shdr []byte
+		if mset.hfcons.Load() > 0 {
+			shdr = append([]byte{sigHdrs}, hdr...)
+		}
+		mset.sigq.push(newCMsg(subject, seq, shdr))
 		select {
 		case mset.sch <- struct{}{}:
 		default:
@@ -5260,17 +5641,51 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 	return nil
 }
 
+// republishMsg will send a copy of a stored message to the republish subject,
+// adding the stream, subject, sequence and timestamp headers.
+func (mset *stream) republishMsg(tsubj, name, subject string, hdr, msg []byte, seq uint64, ts int64, tlseq uint64, thdrsOnly bool) {
+	const ht = "NATS/1.0\r\nNats-Stream: %s\r\nNats-Subject: %s\r\nNats-Sequence: %d\r\nNats-Time-Stamp: %s\r\nNats-Last-Sequence: %d\r\n\r\n"
+	const htho = "NATS/1.0\r\nNats-Stream: %s\r\nNats-Subject: %s\r\nNats-Sequence: %d\r\nNats-Time-Stamp: %s\r\nNats-Last-Sequence: %d\r\nNats-Msg-Size: %d\r\n\r\n"
+	// When adding to existing headers, will use the fmt.Append version so this skips the headers from above.
+	const hoff = 10
+
+	tsStr := time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+	var rpMsg []byte
+	if len(hdr) == 0 {
+		if !thdrsOnly {
+			hdr = fmt.Appendf(nil, ht, name, subject, seq, tsStr, tlseq)
+			rpMsg = copyBytes(msg)
+		} else {
+			hdr = fmt.Appendf(nil, htho, name, subject, seq, tsStr, tlseq, len(msg))
+		}
+	} else {
+		// use hdr[:end:end] to make sure as we add we copy the original hdr.
+		end := len(hdr) - LEN_CR_LF
+		if !thdrsOnly {
+			hdr = fmt.Appendf(hdr[:end:end], ht[hoff:], name, subject, seq, tsStr, tlseq)
+			rpMsg = copyBytes(msg)
+		} else {
+			hdr = fmt.Appendf(hdr[:end:end], htho[hoff:], name, subject, seq, tsStr, tlseq, len(msg))
+		}
+	}
+	mset.outq.send(newJSPubMsg(tsubj, _EMPTY_, _EMPTY_, hdr, rpMsg, nil, seq))
+}
+
 // Used to signal inbound message to registered consumers.
 type cMsg struct {
 	seq  uint64
 	subj string
+	hdr  []byte
 }
 
+// Marks the headers of a message being included when signaling consumers.
+const sigHdrs = byte(1)
+
 // Pool to recycle consumer bound msgs.
 var cMsgPool sync.Pool
 
 // Used to queue up consumer bound msgs for signaling.
-func newCMsg(subj string, seq uint64) *cMsg {
+func newCMsg(subj string, seq uint64, hdr []byte) *cMsg {
 	var m *cMsg
 	cm := cMsgPool.Get()
 	if cm != nil {
@@ -5278,7 +5693,7 @@ func newCMsg(subj string, seq uint64) *cMsg {
 	} else {
 		m = new(cMsg)
 	}
-	m.subj, m.seq = subj, seq
+	m.subj, m.seq, m.hdr = subj, seq, hdr
 
 	return m
 }
@@ -5287,7 +5702,7 @@ func (m *cMsg) returnToPool() {
 	if m == nil {
 		return
 	}
-	m.subj, m.seq = _EMPTY_, 0
+	m.subj, m.seq, m.hdr = _EMPTY_, 0, nil
 	cMsgPool.Put(m)
 }
 
@@ -5307,10 +5722,10 @@ func (mset *stream) signalConsumersLoop() {
 		case <-sch:
 			cms := msgs.pop()
 			for _, m := range cms {
-				seq, subj := m.seq, m.subj
+				seq, subj, hdr := m.seq, m.subj, m.hdr
 				m.returnToPool()
 				// Signal all appropriate consumers.
-				mset.signalConsumers(subj, seq)
+				mset.signalConsumers(subj, seq, hdr)
 			}
 			msgs.recycle(&cms)
 		}
@@ -5318,7 +5733,8 @@ func (mset *stream) signalConsumersLoop() {
 }
 
 // This will update and signal all consumers that match.
-func (mset *stream) signalConsumers(subj string, seq uint64) {
+// Headers are only included for consumers with header filters, marked with sigHdrs.
+func (mset *stream) signalConsumers(subj string, seq uint64, hdr []byte) {
 	mset.clsMu.RLock()
 	if mset.csl == nil {
 		mset.clsMu.RUnlock()
@@ -5335,6 +5751,9 @@ func (mset *stream) signalConsumers(subj string, seq uint64) {
 	var le = binary.LittleEndian
 	le.PutUint64(eseq[:], seq)
 	msg := eseq[:]
+	if len(hdr) > 0 {
+		msg = append(msg, hdr...)
+	}
 	for _, sub := range r.psubs {
 		sub.icb(sub, nil, nil, subj, _EMPTY_, msg)
 	}
@@ -5566,6 +5985,11 @@ func (mset *stream) internalLoop() {
 			isClustered := mset.IsClustered()
 			ims := msgs.pop()
 			for _, im := range ims {
+				// Messages that are part of an atomic batch are staged until the batch is committed.
+				if len(im.hdr) > 0 && len(getHeader(JSBatchId, im.hdr)) > 0 {
+					mset.processInboundBatchMsg(im)
+					continue
+				}
 				// If we are clustered we need to propose this message to the underlying raft group.
 				if isClustered {
 					mset.processClusteredInboundMsg(im.subj, im.rply, im.hdr, im.msg, im.mt, false)
@@ -5660,6 +6084,9 @@ func (mset *stream) stop(deleteFlag, advisory bool) error {
 	mset.stopClusterSubs()
 	// Unsubscribe from direct stream.
 	mset.unsubscribeToStream(true)
+	// Drop any staged batches and stop releasing held messages.
+	mset.clearBatchesLocked()
+	mset.clearScheduledMsgsLocked()
 
 	// Our info sub if we spun it up.
 	if mset.infoSub != nil {
@@ -5888,6 +6315,9 @@ func (mset *stream) setConsumer(o *consumer) {
 	if o.cfg.Direct {
 		mset.directs++
 	}
+	if len(o.cfg.HeaderFilters) > 0 {
+		mset.hfcons.Add(1)
+	}
 	// Now update consumers list as well
 	mset.clsMu.Lock()
 	mset.cList = append(mset.cList, o)
@@ -5908,6 +6338,9 @@ func (mset *stream) removeConsumer(o *consumer) {
 	if o.cfg.Direct && mset.directs > 0 {
 		mset.directs--
 	}
+	if len(o.cfg.HeaderFilters) > 0 {
+		mset.hfcons.Add(-1)
+	}
 	if mset.consumers != nil {
 		delete(mset.consumers, o.name)
 		// Now update consumers list as well
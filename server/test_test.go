@@ -15,6 +15,7 @@ package server
 
 import (
 	"cmp"
+	"encoding/binary"
 	"fmt"
 	"math/rand"
 	"net/url"
@@ -205,6 +206,37 @@ func checkNatsError(t *testing.T, e *ApiError, id ErrorIdentifier) {
 	}
 }
 
+// Flips a byte of the stored message's payload on disk, which breaks its checksum.
+// Message blocks are stream ciphered, so this works for encrypted blocks too.
+func corruptStoredMsg(t *testing.T, fs *fileStore, seq uint64) {
+	t.Helper()
+	fs.mu.RLock()
+	mb := fs.selectMsgBlock(seq)
+	fs.mu.RUnlock()
+	require_True(t, mb != nil)
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	_, err := mb.flushPendingMsgsLocked()
+	require_NoError(t, err)
+	require_NoError(t, mb.loadMsgsWithLock())
+	ri, _, _, err := mb.slotInfo(int(seq - mb.cache.fseq))
+	require_NoError(t, err)
+	// Take the record length from the record itself, the slot could include trailing tombstones.
+	rl := binary.LittleEndian.Uint32(mb.cache.buf[ri:]) &^ hbit
+
+	f, err := os.OpenFile(mb.mfn, os.O_RDWR, 0)
+	require_NoError(t, err)
+	defer f.Close()
+	var b [1]byte
+	off := int64(mb.cache.off) + int64(ri+rl) - recordHashSize - 1
+	_, err = f.ReadAt(b[:], off)
+	require_NoError(t, err)
+	b[0] ^= 0xff
+	_, err = f.WriteAt(b[:], off)
+	require_NoError(t, err)
+}
+
 // Creates a full cluster with numServers and given name and makes sure its well formed.
 // Will have Gateways and Leaf Node connections active.
 func createClusterWithName(t *testing.T, clusterName string, numServers int, connectTo ...*cluster) *cluster {